@@ -1,8 +1,13 @@
 package cache
 
 import (
+	"Aegis/internal/utils"
+	"errors"
+	"fmt"
+	"hash/crc32"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -13,26 +18,223 @@ type Response struct {
 	Body     []byte
 	SavedAt  time.Time
 	ExpireAt time.Time // zero => no expiration
+	Checksum string    // Body checksum taken at store time, empty if none
+	// Vary marks an entry without a response: the response varies on these
+	// request headers and is stored under a key qualified by their values
+	Vary []string
+	// Compressed marks a Body gzip-compressed by the in-memory cache
+	Compressed bool
+}
+
+// BodyChecksum returns the checksum stored in Response.Checksum
+func BodyChecksum(body []byte) string {
+	return fmt.Sprintf("crc32c:%08x", crc32.Checksum(body, crc32cTable))
+}
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// ChecksumValid reports whether the body still matches its checksum.
+// Entries stored without a checksum are considered valid.
+func (r Response) ChecksumValid() bool {
+	return r.Checksum == "" || r.Checksum == BodyChecksum(r.Body)
+}
+
+// StaleFetcher is implemented by stores that can return an entry past its
+// expiry while they still hold it, e.g. to revalidate it with upstream
+type StaleFetcher interface {
+	FetchStale(key string) (Response, bool, error)
+}
+
+// Store is a cache storage backend used by the proxy.
+// Errors report that the backend itself is unavailable; a missing
+// or expired entry is reported as (Response{}, false, nil).
+type Store interface {
+	Fetch(key string) (Response, bool, error)
+	Put(key string, value Response) error
+	Delete(key string) error
 }
 
 // Cache is a thread-safe in-memory cache for HTTP responses
 type Cache struct {
-	mu   sync.RWMutex
-	data map[string]Response
+	mu    sync.RWMutex
+	data  map[string]Response
+	bytes atomic.Int64 // running MemoryUsage total, updated on every write
+	clock utils.Clock
+
+	maxEntries int
+	maxMemory  int64   // MemoryUsage budget in bytes (0 = unlimited)
+	policy     string  // Eviction policy
+	evictor    Evictor // Usage order, nil when unbounded
+	evictions  atomic.Int64
+
+	// Reads and refreshes per entry, ranking entries for Hottest
+	uses map[string]*atomic.Int64
+
+	compressMin int // Smallest body stored compressed (0 = disabled)
+
+	onExpire   func(key string, value Response)
+	onEvict    func(key string)
+	sweepGrace time.Duration
+	sweepStop  chan struct{}
+	sweepDone  chan struct{}
+	stopOnce   sync.Once
 }
 
-// New creates a new cache instance
-func New() *Cache {
-	return &Cache{
-		data: make(map[string]Response),
+// ErrTooLarge is returned by Put for an entry larger than the whole
+// memory budget. Such entries are not cached.
+var ErrTooLarge = errors.New("entry exceeds the cache memory budget")
+
+// New creates a new cache instance holding at most maxEntries entries;
+// beyond that the least recently used entry is evicted (0 = unlimited)
+func New(maxEntries int) *Cache {
+	c := &Cache{
+		data:       make(map[string]Response),
+		uses:       make(map[string]*atomic.Int64),
+		clock:      utils.RealClock{},
+		maxEntries: maxEntries,
+		policy:     EvictLRU,
 	}
+	c.resetEvictor()
+	return c
+}
+
+// SetEvictionPolicy replaces the policy choosing entries to evict at
+// maxEntries or maxMemory (EvictLRU or EvictSLRU). Call it before the
+// cache is used.
+func (c *Cache) SetEvictionPolicy(policy string) {
+	c.policy = policy
+	c.resetEvictor()
+}
+
+// SetMaxMemory bounds MemoryUsage to maxBytes by evicting entries
+// (0 = unlimited). Call it before the cache is used.
+func (c *Cache) SetMaxMemory(maxBytes int64) {
+	c.maxMemory = maxBytes
+	c.resetEvictor()
+}
+
+// resetEvictor creates the usage tracking needed by the configured bounds
+func (c *Cache) resetEvictor() {
+	c.evictor = nil
+	if c.maxEntries > 0 || c.maxMemory > 0 {
+		c.evictor = NewEvictor(c.policy, c.maxEntries)
+	}
+}
+
+// SetClock replaces the clock used for expiry checks. Call it before the
+// cache is used.
+func (c *Cache) SetClock(clock utils.Clock) {
+	c.clock = clock
+}
+
+// SetExpireHook registers fn to be called for every entry the sweeper
+// removes. Call it before the cache is used.
+func (c *Cache) SetExpireHook(fn func(key string, value Response)) {
+	c.onExpire = fn
+}
+
+// SetEvictHook registers fn to be called for every entry evicted to stay
+// within the entry or memory bounds. Call it before the cache is used.
+func (c *Cache) SetEvictHook(fn func(key string)) {
+	c.onEvict = fn
+}
+
+// SetSweepGrace keeps expired entries for grace past ExpireAt before Sweep
+// removes them, so they can still be served stale. Call it before the
+// cache is used.
+func (c *Cache) SetSweepGrace(grace time.Duration) {
+	c.sweepGrace = grace
+}
+
+// StartSweeper removes expired entries every interval in a background
+// goroutine until Stop is called. Without it an expired entry is only
+// skipped by Get and keeps counting toward Size and MemoryUsage.
+func (c *Cache) StartSweeper(interval time.Duration) {
+	if interval <= 0 || c.sweepStop != nil {
+		return
+	}
+	c.sweepStop = make(chan struct{})
+	c.sweepDone = make(chan struct{})
+	go func() {
+		defer close(c.sweepDone)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.sweepStop:
+				return
+			case <-ticker.C:
+				c.Sweep()
+			}
+		}
+	}()
+}
+
+// Stop shuts down the sweeper and waits for it to exit. It is safe to
+// call more than once and without a running sweeper.
+func (c *Cache) Stop() {
+	if c.sweepStop == nil {
+		return
+	}
+	c.stopOnce.Do(func() { close(c.sweepStop) })
+	<-c.sweepDone
+}
+
+// Sweep removes all entries expired for longer than the sweep grace and
+// returns how many were removed
+func (c *Cache) Sweep() int {
+	now := c.clock.Now()
+	var expired map[string]Response
+
+	c.mu.Lock()
+	for k, v := range c.data {
+		if v.ExpireAt.IsZero() || !now.After(v.ExpireAt.Add(c.sweepGrace)) {
+			continue
+		}
+		if expired == nil {
+			expired = make(map[string]Response)
+		}
+		expired[k] = v
+		c.bytes.Add(-entrySize(k, v))
+		delete(c.data, k)
+		delete(c.uses, k)
+		if c.evictor != nil {
+			c.evictor.Remove(k)
+		}
+	}
+	c.mu.Unlock()
+
+	// The hook runs outside the lock so it may use the cache
+	if c.onExpire != nil {
+		for k, v := range expired {
+			if v, ok := decompress(v); ok {
+				c.onExpire(k, v)
+			}
+		}
+	}
+	return len(expired)
 }
 
 // Get retrieves a cached response by key
 // Returns the response and true if found and not expired, false otherwise
 func (c *Cache) Get(key string) (Response, bool) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+	v, ok := c.get(key)
+	if !ok {
+		return v, false
+	}
+	return decompress(v)
+}
+
+// get is Get without decompression
+func (c *Cache) get(key string) (Response, bool) {
+	// Reads reorder the eviction state when the cache is bounded
+	if c.evictor != nil {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	} else {
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+	}
 
 	v, ok := c.data[key]
 	if !ok {
@@ -40,18 +242,122 @@ func (c *Cache) Get(key string) (Response, bool) {
 	}
 
 	// TTL check
-	if !v.ExpireAt.IsZero() && time.Now().After(v.ExpireAt) {
+	if !v.ExpireAt.IsZero() && c.clock.Now().After(v.ExpireAt) {
 		return Response{}, false
 	}
 
+	if c.evictor != nil {
+		c.evictor.Access(key)
+	}
+	if n := c.uses[key]; n != nil {
+		n.Add(1)
+	}
 	return v, true
 }
 
-// Set stores a response in the cache
-func (c *Cache) Set(key string, value Response) {
+// Set stores a response in the cache, evicting other entries to stay
+// within the bounds. It reports false when the entry was not stored
+// because it alone exceeds the memory budget.
+func (c *Cache) Set(key string, value Response) bool {
+	value = c.compress(value)
+	size := entrySize(key, value)
+	if c.maxMemory > 0 && size > c.maxMemory {
+		return false
+	}
+
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	if old, ok := c.data[key]; ok {
+		c.bytes.Add(-entrySize(key, old))
+		// A refresh means the key was requested again
+		c.uses[key].Add(1)
+	} else {
+		c.uses[key] = new(atomic.Int64)
+	}
 	c.data[key] = value
+	c.bytes.Add(size)
+
+	if c.evictor == nil {
+		c.mu.Unlock()
+		return true
+	}
+	c.evictor.Add(key)
+	var evicted []string
+	for c.overBudget() {
+		victim, ok := c.evictor.Victim()
+		if !ok {
+			break
+		}
+		c.evictor.Remove(victim)
+		c.bytes.Add(-entrySize(victim, c.data[victim]))
+		delete(c.data, victim)
+		delete(c.uses, victim)
+		c.evictions.Add(1)
+		evicted = append(evicted, victim)
+	}
+	c.mu.Unlock()
+
+	// The hook runs outside the lock so it may use the cache
+	if c.onEvict != nil {
+		for _, k := range evicted {
+			c.onEvict(k)
+		}
+	}
+	return true
+}
+
+// overBudget reports whether the cache holds more than its bounds allow
+func (c *Cache) overBudget() bool {
+	return (c.maxEntries > 0 && len(c.data) > c.maxEntries) ||
+		(c.maxMemory > 0 && c.bytes.Load() > c.maxMemory)
+}
+
+// Remove deletes an entry, reporting whether it existed
+func (c *Cache) Remove(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	old, ok := c.data[key]
+	if ok {
+		c.bytes.Add(-entrySize(key, old))
+		delete(c.data, key)
+		delete(c.uses, key)
+		if c.evictor != nil {
+			c.evictor.Remove(key)
+		}
+	}
+	return ok
+}
+
+// Fetch implements Store. The in-memory cache never fails.
+func (c *Cache) Fetch(key string) (Response, bool, error) {
+	v, ok := c.Get(key)
+	return v, ok, nil
+}
+
+// FetchStale implements StaleFetcher. Expired entries are returned until
+// the sweeper removes them.
+func (c *Cache) FetchStale(key string) (Response, bool, error) {
+	c.mu.RLock()
+	v, ok := c.data[key]
+	c.mu.RUnlock()
+	if !ok {
+		return v, false, nil
+	}
+	v, ok = decompress(v)
+	return v, ok, nil
+}
+
+// Put implements Store. The in-memory cache only fails with ErrTooLarge.
+func (c *Cache) Put(key string, value Response) error {
+	if !c.Set(key, value) {
+		return ErrTooLarge
+	}
+	return nil
+}
+
+// Delete implements Store. The in-memory cache never fails.
+func (c *Cache) Delete(key string) error {
+	c.Remove(key)
+	return nil
 }
 
 // Size returns the number of cached entries
@@ -61,23 +367,42 @@ func (c *Cache) Size() int {
 	return len(c.data)
 }
 
-// MemoryUsage returns approximate memory usage in bytes
+// Evictions returns the number of entries evicted to stay within the bounds
+func (c *Cache) Evictions() int64 {
+	return c.evictions.Load()
+}
+
+// MemoryUsage returns approximate memory usage in bytes, counting
+// compressed bodies at their compressed size.
+// It is maintained incrementally and does not take the lock.
 func (c *Cache) MemoryUsage() int64 {
+	return c.bytes.Load()
+}
+
+// RecomputeMemoryUsage computes MemoryUsage with a full scan under the read
+// lock. It is slow on large caches and meant for verifying the running total.
+func (c *Cache) RecomputeMemoryUsage() int64 {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
 	var total int64
 	for k, v := range c.data {
-		// key
-		total += int64(len(k))
-		// body
-		total += int64(len(v.Body))
-		// headers (approximate)
-		for key, values := range v.Header {
-			total += int64(len(key))
-			for _, val := range values {
-				total += int64(len(val))
-			}
+		total += entrySize(k, v)
+	}
+	return total
+}
+
+// entrySize approximates the memory held by one entry
+func entrySize(key string, v Response) int64 {
+	// key
+	total := int64(len(key))
+	// body
+	total += int64(len(v.Body))
+	// headers (approximate)
+	for name, values := range v.Header {
+		total += int64(len(name))
+		for _, val := range values {
+			total += int64(len(val))
 		}
 	}
 	return total
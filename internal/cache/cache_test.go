@@ -1,14 +1,24 @@
 package cache
 
 import (
+	"Aegis/internal/utils"
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 )
 
 func TestCacheBasicOperations(t *testing.T) {
-	c := New()
+	c := New(0)
 
 	// Test empty cache
 	if _, ok := c.Get("nonexistent"); ok {
@@ -36,7 +46,7 @@ func TestCacheBasicOperations(t *testing.T) {
 }
 
 func TestCacheTTL(t *testing.T) {
-	c := New()
+	c := New(0)
 
 	// Set entry with expiry in the past
 	expired := Response{
@@ -77,8 +87,61 @@ func TestCacheTTL(t *testing.T) {
 	}
 }
 
+func TestCacheExpiryWithClock(t *testing.T) {
+	clock := utils.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	c := New(0)
+	c.SetClock(clock)
+
+	c.Set("key", Response{Status: 200, ExpireAt: utils.ZeroOrExpiry(clock, time.Minute)})
+
+	clock.Advance(time.Minute)
+	if _, ok := c.Get("key"); !ok {
+		t.Error("expected cache hit at the expiry instant")
+	}
+
+	clock.Advance(time.Nanosecond)
+	if _, ok := c.Get("key"); ok {
+		t.Error("expected cache miss after expiry")
+	}
+}
+
+func TestCacheSweeper(t *testing.T) {
+	c := New(0)
+	c.Set("short", Response{Body: []byte("a"), ExpireAt: time.Now().Add(20 * time.Millisecond)})
+	c.Set("expired", Response{Body: []byte("b"), ExpireAt: time.Now().Add(-time.Second)})
+
+	var mu sync.Mutex
+	var expired []string
+	c.SetExpireHook(func(key string, _ Response) {
+		mu.Lock()
+		expired = append(expired, key)
+		mu.Unlock()
+	})
+	c.StartSweeper(10 * time.Millisecond)
+	defer c.Stop()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for c.Size() > 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if c.Size() != 0 {
+		t.Fatalf("expected empty cache after sweeping, got %d entries", c.Size())
+	}
+	if c.MemoryUsage() != 0 {
+		t.Errorf("expected memory usage 0 after sweeping, got %d", c.MemoryUsage())
+	}
+
+	c.Stop()
+	c.Stop()
+	mu.Lock()
+	defer mu.Unlock()
+	if len(expired) != 2 {
+		t.Errorf("expected expire hook for 2 entries, got %v", expired)
+	}
+}
+
 func TestCacheSize(t *testing.T) {
-	c := New()
+	c := New(0)
 
 	if c.Size() != 0 {
 		t.Errorf("expected size 0, got %d", c.Size())
@@ -93,7 +156,7 @@ func TestCacheSize(t *testing.T) {
 }
 
 func TestCacheMemoryUsage(t *testing.T) {
-	c := New()
+	c := New(0)
 
 	resp := Response{
 		Status: 200,
@@ -110,8 +173,77 @@ func TestCacheMemoryUsage(t *testing.T) {
 	}
 }
 
+func TestCacheMemoryUsageMatchesRecompute(t *testing.T) {
+	c := New(0)
+
+	c.Set("a", Response{Header: http.Header{"X-One": {"1", "2"}}, Body: []byte("first")})
+	c.Set("b", Response{Body: []byte("second body")})
+	// Overwrite with a smaller entry - the old size must be released
+	c.Set("a", Response{Body: []byte("x")})
+
+	if fast, full := c.MemoryUsage(), c.RecomputeMemoryUsage(); fast != full {
+		t.Errorf("expected running total %d to match recomputed %d", fast, full)
+	}
+	if want := int64(len("a") + len("x") + len("b") + len("second body")); c.MemoryUsage() != want {
+		t.Errorf("expected memory %d, got %d", want, c.MemoryUsage())
+	}
+}
+
+func TestCacheCompression(t *testing.T) {
+	c := New(0)
+	c.SetCompression(1024)
+
+	text := []byte(strings.Repeat("compressible text ", 1000))
+	c.Set("text", Response{Header: http.Header{"Content-Type": {"text/plain; charset=utf-8"}}, Body: text})
+	c.Set("small", Response{Header: http.Header{"Content-Type": {"text/plain"}}, Body: []byte("short")})
+	c.Set("image", Response{Header: http.Header{"Content-Type": {"image/png"}}, Body: text})
+	c.Set("encoded", Response{Header: http.Header{"Content-Type": {"text/plain"}, "Content-Encoding": {"br"}}, Body: text})
+
+	if !c.data["text"].Compressed {
+		t.Fatal("expected the text body to be stored compressed")
+	}
+	for _, key := range []string{"small", "image", "encoded"} {
+		if c.data[key].Compressed {
+			t.Errorf("expected %s to be stored as is", key)
+		}
+	}
+
+	v, ok := c.Get("text")
+	if !ok || string(v.Body) != string(text) || v.Compressed {
+		t.Errorf("expected Get to return the original body, got %d bytes (compressed=%v)", len(v.Body), v.Compressed)
+	}
+	if v, _, _ := c.FetchStale("text"); string(v.Body) != string(text) {
+		t.Errorf("expected FetchStale to return the original body, got %d bytes", len(v.Body))
+	}
+	if fast, full := c.MemoryUsage(), c.RecomputeMemoryUsage(); fast != full {
+		t.Errorf("expected running total %d to match recomputed %d", fast, full)
+	}
+	if c.MemoryUsage() >= int64(3*len(text)) {
+		t.Errorf("expected memory to count the compressed size, got %d", c.MemoryUsage())
+	}
+}
+
+func TestCacheRemove(t *testing.T) {
+	c := New(0)
+	c.Set("a", Response{Body: []byte("first")})
+	c.Set("b", Response{Body: []byte("second")})
+
+	if !c.Remove("a") {
+		t.Error("expected Remove to report an existing entry")
+	}
+	if c.Remove("a") {
+		t.Error("expected Remove to report a missing entry")
+	}
+	if _, ok := c.Get("a"); ok {
+		t.Error("expected removed entry to be gone")
+	}
+	if want := int64(len("b") + len("second")); c.MemoryUsage() != want {
+		t.Errorf("expected memory %d after remove, got %d", want, c.MemoryUsage())
+	}
+}
+
 func TestCacheConcurrency(t *testing.T) {
-	c := New()
+	c := New(0)
 	var wg sync.WaitGroup
 
 	// Concurrent writes
@@ -136,3 +268,459 @@ func TestCacheConcurrency(t *testing.T) {
 
 	wg.Wait()
 }
+
+func TestResponseChecksum(t *testing.T) {
+	r := Response{Body: []byte("payload")}
+	if !r.ChecksumValid() {
+		t.Error("expected entry without checksum to be valid")
+	}
+	r.Checksum = BodyChecksum(r.Body)
+	if !r.ChecksumValid() {
+		t.Error("expected matching checksum to be valid")
+	}
+	r.Body = []byte("pay1oad")
+	if r.ChecksumValid() {
+		t.Error("expected modified body to fail verification")
+	}
+}
+
+// fill adds keys to ev, evicting beyond capacity, and returns the evicted keys
+func fill(ev Evictor, capacity int, keys ...string) []string {
+	var evicted []string
+	for _, k := range keys {
+		ev.Add(k)
+		for ev.Len() > capacity {
+			victim, _ := ev.Victim()
+			ev.Remove(victim)
+			evicted = append(evicted, victim)
+		}
+	}
+	return evicted
+}
+
+func TestEvictorScanResistance(t *testing.T) {
+	scan := make([]string, 20)
+	for i := range scan {
+		scan[i] = fmt.Sprintf("scan-%d", i)
+	}
+
+	for policy, hotSurvives := range map[string]bool{EvictLRU: false, EvictSLRU: true} {
+		ev := NewEvictor(policy, 5)
+		fill(ev, 5, "hot")
+		ev.Access("hot") // second hit
+		evicted := fill(ev, 5, scan...)
+
+		if got := slices.Contains(evicted, "hot"); got == hotSurvives {
+			t.Errorf("%s: expected hot key evicted=%v after a scan, got %v", policy, !hotSurvives, got)
+		}
+		if ev.Len() != 5 {
+			t.Errorf("%s: expected 5 tracked keys, got %d", policy, ev.Len())
+		}
+	}
+}
+
+func TestSLRUDemotesProtected(t *testing.T) {
+	ev := NewEvictor(EvictSLRU, 5) // 4 protected slots
+	fill(ev, 5, "a", "b", "c", "d", "e")
+	for _, k := range []string{"a", "b", "c", "d", "e"} {
+		ev.Access(k) // e pushes a, the least recently promoted, back to probation
+	}
+	if victim, _ := ev.Victim(); victim != "a" {
+		t.Errorf("expected demoted key a to be the next victim, got %q", victim)
+	}
+	ev.Remove("a")
+	if victim, _ := ev.Victim(); victim != "b" {
+		t.Errorf("expected oldest protected key b once probation is empty, got %q", victim)
+	}
+}
+
+func TestCacheMaxEntriesLRU(t *testing.T) {
+	c := New(2)
+	c.Set("a", Response{Status: 200})
+	c.Set("b", Response{Status: 200})
+	c.Get("a") // b is now the least recently used
+	c.Set("c", Response{Status: 200})
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("expected least recently used entry b to be evicted")
+	}
+	for _, k := range []string{"a", "c"} {
+		if _, ok := c.Get(k); !ok {
+			t.Errorf("expected %s to stay cached", k)
+		}
+	}
+	if c.Size() != 2 || c.Evictions() != 1 {
+		t.Errorf("expected 2 entries and 1 eviction, got %d and %d", c.Size(), c.Evictions())
+	}
+	if c.MemoryUsage() != c.RecomputeMemoryUsage() {
+		t.Errorf("running memory total %d drifted from %d", c.MemoryUsage(), c.RecomputeMemoryUsage())
+	}
+
+	// Removed entries leave the eviction order too
+	c.Remove("a")
+	c.Set("d", Response{Status: 200})
+	if c.Size() != 2 || c.Evictions() != 1 {
+		t.Errorf("expected no eviction after a removal, got %d entries, %d evictions", c.Size(), c.Evictions())
+	}
+}
+
+func TestCacheMaxMemory(t *testing.T) {
+	c := New(0)
+	c.SetMaxMemory(100)
+	entry := func(n int) Response { return Response{Body: make([]byte, n)} }
+
+	c.Set("a", entry(40)) // 41 bytes with the key
+	c.Set("b", entry(40))
+	c.Get("a") // b is now the least recently used
+	c.Set("c", entry(40))
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("expected b to be evicted to fit c")
+	}
+	if c.MemoryUsage() > 100 {
+		t.Errorf("expected memory within budget, got %d", c.MemoryUsage())
+	}
+	if c.MemoryUsage() != c.RecomputeMemoryUsage() {
+		t.Errorf("running memory total %d drifted from %d", c.MemoryUsage(), c.RecomputeMemoryUsage())
+	}
+
+	// An entry larger than the whole budget is refused without evicting
+	if err := c.Put("huge", entry(200)); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("expected ErrTooLarge, got %v", err)
+	}
+	if c.Size() != 2 || c.Evictions() != 1 {
+		t.Errorf("expected 2 entries and 1 eviction, got %d and %d", c.Size(), c.Evictions())
+	}
+}
+
+func TestCacheHandoffTopN(t *testing.T) {
+	clock := utils.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	c := New(0)
+	c.SetClock(clock)
+	for i, uses := range []int{5, 0, 9, 2, 7} {
+		key := fmt.Sprintf("GET /%d?", i)
+		c.Set(key, Response{Status: 200, Body: []byte(key), ExpireAt: utils.ZeroOrExpiry(clock, time.Hour)})
+		for j := 0; j < uses; j++ {
+			c.Get(key)
+		}
+	}
+	// Refreshing an entry counts as a use as well
+	c.Set("GET /3?", Response{Status: 200, Body: []byte("GET /3?")})
+	// Expired entries are never exported
+	for j := 0; j < 20; j++ {
+		c.Set("GET /expired?", Response{Status: 200, ExpireAt: clock.Now().Add(-time.Second)})
+	}
+
+	path := filepath.Join(t.TempDir(), "handoff")
+	n, err := c.WriteHandoff(path, 3)
+	if err != nil || n != 3 {
+		t.Fatalf("expected 3 exported entries, got %d (err=%v)", n, err)
+	}
+
+	next := New(0)
+	next.SetClock(clock)
+	if n, err := next.ReadHandoff(path); err != nil || n != 3 {
+		t.Fatalf("expected 3 imported entries, got %d (err=%v)", n, err)
+	}
+	var keys []string
+	for _, e := range next.Hottest(0) {
+		keys = append(keys, e.Key)
+	}
+	if want := []string{"GET /2?", "GET /4?", "GET /0?"}; !slices.Equal(keys, want) {
+		t.Errorf("expected hottest entries %v with their counts kept, got %v", want, keys)
+	}
+	if v, ok := next.Get("GET /2?"); !ok || string(v.Body) != "GET /2?" {
+		t.Errorf("expected imported entry to be served, got %q (ok=%v)", v.Body, ok)
+	}
+
+	// Entries that expire before the import are skipped
+	clock.Advance(2 * time.Hour)
+	later := New(0)
+	later.SetClock(clock)
+	if n, err := later.ReadHandoff(path); err != nil || n != 0 {
+		t.Errorf("expected expired entries to be skipped, got %d (err=%v)", n, err)
+	}
+}
+
+func TestFileStoreRoundTrip(t *testing.T) {
+	s, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	want := Response{
+		Status:   http.StatusOK,
+		Header:   http.Header{"Content-Type": {"text/plain"}},
+		Body:     []byte("hello"),
+		SavedAt:  time.Now().Truncate(time.Second),
+		ExpireAt: time.Now().Add(time.Hour).Truncate(time.Second),
+		Checksum: BodyChecksum([]byte("hello")),
+	}
+	if err := s.Put("GET /a?", want); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	got, ok, err := s.Fetch("GET /a?")
+	if err != nil || !ok {
+		t.Fatalf("expected entry, got ok=%v err=%v", ok, err)
+	}
+	if got.Status != want.Status || string(got.Body) != "hello" || got.Header.Get("Content-Type") != "text/plain" ||
+		!got.ExpireAt.Equal(want.ExpireAt) || !got.ChecksumValid() {
+		t.Errorf("entry changed on disk: %+v", got)
+	}
+	if s.Size() != 1 {
+		t.Errorf("expected 1 entry, got %d", s.Size())
+	}
+
+	if err := s.Delete("GET /a?"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, ok, _ := s.Fetch("GET /a?"); ok {
+		t.Error("expected deleted entry to be gone")
+	}
+	if err := s.Delete("GET /a?"); err != nil {
+		t.Errorf("expected deleting a missing entry to succeed, got %v", err)
+	}
+}
+
+func TestFileStoreDiscardsExpiredOnOpen(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	s, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	s.SetClock(utils.NewFakeClock(now))
+	_ = s.Put("expired", Response{Status: 200, Body: []byte("old"), ExpireAt: now.Add(-time.Minute)})
+	_ = s.Put("fresh", Response{Status: 200, Body: []byte("new"), ExpireAt: time.Now().Add(time.Hour)})
+	_ = s.Put("forever", Response{Status: 200, Body: []byte("kept")})
+
+	// Expired entries stay readable as stale until swept
+	if _, ok, _ := s.Fetch("expired"); ok {
+		t.Error("expected expired entry to miss")
+	}
+	if v, ok, _ := s.FetchStale("expired"); !ok || string(v.Body) != "old" {
+		t.Error("expected expired entry to be available as stale")
+	}
+
+	reopened, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if reopened.Size() != 2 {
+		t.Errorf("expected 2 entries after reopening, got %d", reopened.Size())
+	}
+	if _, ok, _ := reopened.FetchStale("expired"); ok {
+		t.Error("expected expired entry to be discarded on open")
+	}
+	for _, key := range []string{"fresh", "forever"} {
+		if _, ok, _ := reopened.Fetch(key); !ok {
+			t.Errorf("expected %s to survive reopening", key)
+		}
+	}
+}
+
+func TestFileStoreSweepGrace(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := utils.NewFakeClock(now)
+	s, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	s.SetClock(clock)
+	s.SetSweepGrace(time.Minute)
+	_ = s.Put("k", Response{Status: 200, ExpireAt: now.Add(time.Minute)})
+
+	clock.Advance(90 * time.Second)
+	if n := s.Sweep(); n != 0 {
+		t.Errorf("expected entry within the grace to be kept, got %d swept", n)
+	}
+	clock.Advance(time.Minute)
+	if n := s.Sweep(); n != 1 || s.Size() != 0 {
+		t.Errorf("expected entry past the grace to be swept, got %d swept, %d left", n, s.Size())
+	}
+}
+
+// fakeRedis serves GET, SET [PX ms], DEL, AUTH and SELECT for RedisStore tests
+type fakeRedis struct {
+	mu       sync.Mutex
+	data     map[string]string
+	ttl      map[string]int64
+	password string
+	db       int
+}
+
+func startFakeRedis(t *testing.T, password string) (*fakeRedis, string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	f := &fakeRedis{data: make(map[string]string), ttl: make(map[string]int64), password: password}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f, ln.Addr().String()
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	authed := f.password == ""
+	for {
+		var n int
+		if _, err := fmt.Fscanf(r, "*%d\r\n", &n); err != nil {
+			return
+		}
+		args := make([]string, n)
+		for i := range args {
+			var size int
+			if _, err := fmt.Fscanf(r, "$%d\r\n", &size); err != nil {
+				return
+			}
+			buf := make([]byte, size+2)
+			if _, err := io.ReadFull(r, buf); err != nil {
+				return
+			}
+			args[i] = string(buf[:size])
+		}
+
+		f.mu.Lock()
+		var reply string
+		switch {
+		case args[0] == "AUTH":
+			authed = args[1] == f.password
+			reply = "+OK\r\n"
+			if !authed {
+				reply = "-WRONGPASS invalid password\r\n"
+			}
+		case !authed:
+			reply = "-NOAUTH Authentication required\r\n"
+		case args[0] == "SELECT":
+			f.db, _ = strconv.Atoi(args[1])
+			reply = "+OK\r\n"
+		case args[0] == "GET":
+			v, ok := f.data[args[1]]
+			reply = "$-1\r\n"
+			if ok {
+				reply = fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+			}
+		case args[0] == "SET":
+			f.data[args[1]] = args[2]
+			delete(f.ttl, args[1])
+			if len(args) == 5 && args[3] == "PX" {
+				f.ttl[args[1]], _ = strconv.ParseInt(args[4], 10, 64)
+			}
+			reply = "+OK\r\n"
+		case args[0] == "DEL":
+			_, ok := f.data[args[1]]
+			delete(f.data, args[1])
+			reply = ":0\r\n"
+			if ok {
+				reply = ":1\r\n"
+			}
+		default:
+			reply = "-ERR unknown command\r\n"
+		}
+		f.mu.Unlock()
+		if _, err := io.WriteString(conn, reply); err != nil {
+			return
+		}
+	}
+}
+
+func TestRedisStoreRoundTrip(t *testing.T) {
+	f, addr := startFakeRedis(t, "secret")
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := utils.NewFakeClock(now)
+	s := NewRedisStore(RedisOptions{Addr: addr, Password: "secret", DB: 2})
+	s.SetClock(clock)
+	defer s.Close()
+
+	want := Response{
+		Status:   http.StatusOK,
+		Header:   http.Header{"Content-Type": {"text/plain"}},
+		Body:     []byte("shared"),
+		SavedAt:  now,
+		ExpireAt: now.Add(90 * time.Second),
+	}
+	if err := s.Put("GET /a?", want); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	f.mu.Lock()
+	ttl, db := f.ttl["aegis:GET /a?"], f.db
+	f.mu.Unlock()
+	if ttl != 90000 || db != 2 {
+		t.Errorf("expected PX 90000 in db 2, got PX %d in db %d", ttl, db)
+	}
+
+	got, ok, err := s.Fetch("GET /a?")
+	if err != nil || !ok {
+		t.Fatalf("expected entry, got ok=%v err=%v", ok, err)
+	}
+	if got.Status != 200 || string(got.Body) != "shared" || got.Header.Get("Content-Type") != "text/plain" || !got.ExpireAt.Equal(want.ExpireAt) {
+		t.Errorf("entry changed in Redis: %+v", got)
+	}
+
+	// Redis may keep a key a moment longer than ExpireAt
+	clock.Advance(2 * time.Minute)
+	if _, ok, _ := s.Fetch("GET /a?"); ok {
+		t.Error("expected expired entry to miss")
+	}
+
+	if err := s.Put("forever", Response{Status: 200}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	f.mu.Lock()
+	_, hasTTL := f.ttl["aegis:forever"]
+	f.mu.Unlock()
+	if hasTTL {
+		t.Error("expected no Redis TTL for an entry without expiry")
+	}
+	if err := s.Delete("forever"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, ok, _ := s.Fetch("forever"); ok {
+		t.Error("expected deleted entry to be gone")
+	}
+}
+
+func TestRedisStoreErrors(t *testing.T) {
+	_, addr := startFakeRedis(t, "secret")
+	s := NewRedisStore(RedisOptions{Addr: addr, Password: "wrong"})
+	defer s.Close()
+	if _, _, err := s.Fetch("k"); err == nil {
+		t.Error("expected an authentication error")
+	}
+
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	down := ln.Addr().String()
+	ln.Close()
+	s = NewRedisStore(RedisOptions{Addr: down, Timeout: 100 * time.Millisecond})
+	if err := s.Put("k", Response{Status: 200}); err == nil {
+		t.Error("expected an error from an unreachable server")
+	}
+}
+
+func TestCacheEvictHook(t *testing.T) {
+	c := New(2)
+	var evicted []string
+	c.SetEvictHook(func(key string) {
+		// Runs outside the lock, so the cache is usable here
+		if _, ok := c.Get(key); ok {
+			t.Errorf("expected %s to be gone when its hook runs", key)
+		}
+		evicted = append(evicted, key)
+	})
+	for _, key := range []string{"a", "b", "c", "d"} {
+		c.Set(key, Response{Status: 200, Body: []byte(key)})
+	}
+	if len(evicted) != 2 || evicted[0] != "a" || evicted[1] != "b" {
+		t.Errorf("expected a and b to be evicted, got %v", evicted)
+	}
+}
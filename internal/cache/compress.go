@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// SetCompression stores bodies of at least minBytes gzip-compressed when
+// their content type compresses well (0 = disabled). Get and FetchStale
+// return them decompressed. Call it before the cache is used.
+func (c *Cache) SetCompression(minBytes int) {
+	c.compressMin = minBytes
+}
+
+// Compressible reports whether a body with these headers is worth
+// compressing: text-like and not already encoded
+func Compressible(h http.Header) bool {
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "+json"), strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+	switch mediaType {
+	case "application/json", "application/javascript", "application/xml",
+		"application/x-www-form-urlencoded", "image/svg+xml":
+		return true
+	}
+	return false
+}
+
+// compress returns v with a gzip-compressed body, or v unchanged when
+// compression is disabled, not worthwhile or would not save space
+func (c *Cache) compress(v Response) Response {
+	if c.compressMin <= 0 || v.Compressed || len(v.Body) < c.compressMin || !Compressible(v.Header) {
+		return v
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, _ = zw.Write(v.Body)
+	if zw.Close() != nil || buf.Len() >= len(v.Body) {
+		return v
+	}
+	v.Body = buf.Bytes()
+	v.Compressed = true
+	return v
+}
+
+// decompress returns v with its stored body restored. It reports false
+// for a compressed body that cannot be read, which callers treat as a miss.
+func decompress(v Response) (Response, bool) {
+	if !v.Compressed {
+		return v, true
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(v.Body))
+	if err != nil {
+		return Response{}, false
+	}
+	body, err := io.ReadAll(zr)
+	if err != nil {
+		return Response{}, false
+	}
+	v.Body = body
+	v.Compressed = false
+	return v, true
+}
@@ -0,0 +1,157 @@
+package cache
+
+import "container/list"
+
+// Eviction policies
+const (
+	EvictLRU  = "lru"  // Least recently used
+	EvictSLRU = "slru" // Segmented LRU: probationary and protected segments
+)
+
+// Evictor tracks key usage and picks the key to evict when a cache is full.
+// Implementations are not safe for concurrent use.
+type Evictor interface {
+	Add(key string)         // Record a stored key, a use if already tracked
+	Access(key string)      // Record a use of a tracked key
+	Remove(key string)      // Stop tracking key
+	Victim() (string, bool) // Key to evict next
+	Len() int               // Number of tracked keys
+}
+
+// NewEvictor returns an Evictor for policy; unknown policies use LRU.
+// capacity sizes the SLRU protected segment.
+func NewEvictor(policy string, capacity int) Evictor {
+	if policy == EvictSLRU {
+		return newSLRU(capacity)
+	}
+	return newLRU()
+}
+
+// lru evicts the least recently used key
+type lru struct {
+	order *list.List // Most recently used first
+	elems map[string]*list.Element
+}
+
+func newLRU() *lru {
+	return &lru{order: list.New(), elems: make(map[string]*list.Element)}
+}
+
+func (l *lru) Add(key string) {
+	if el, ok := l.elems[key]; ok {
+		l.order.MoveToFront(el)
+		return
+	}
+	l.elems[key] = l.order.PushFront(key)
+}
+
+func (l *lru) Access(key string) {
+	if el, ok := l.elems[key]; ok {
+		l.order.MoveToFront(el)
+	}
+}
+
+func (l *lru) Remove(key string) {
+	if el, ok := l.elems[key]; ok {
+		l.order.Remove(el)
+		delete(l.elems, key)
+	}
+}
+
+func (l *lru) Victim() (string, bool) {
+	if el := l.order.Back(); el != nil {
+		return el.Value.(string), true
+	}
+	return "", false
+}
+
+func (l *lru) Len() int {
+	return l.order.Len()
+}
+
+// slru admits new keys to a probationary segment and promotes them to a
+// protected segment on their second use. Victims come from the
+// probationary segment first, so a scan of one-hit keys can't push out
+// entries that are used repeatedly.
+type slru struct {
+	probation    *list.List // Most recently used first
+	protected    *list.List
+	protectedCap int
+	elems        map[string]*list.Element
+	inProtected  map[string]bool
+}
+
+func newSLRU(capacity int) *slru {
+	// 80% protected, but always leave room for new keys
+	protectedCap := capacity * 4 / 5
+	if protectedCap >= capacity {
+		protectedCap = capacity - 1
+	}
+	return &slru{
+		probation:    list.New(),
+		protected:    list.New(),
+		protectedCap: protectedCap,
+		elems:        make(map[string]*list.Element),
+		inProtected:  make(map[string]bool),
+	}
+}
+
+func (s *slru) Add(key string) {
+	if _, ok := s.elems[key]; ok {
+		s.Access(key)
+		return
+	}
+	s.elems[key] = s.probation.PushFront(key)
+}
+
+func (s *slru) Access(key string) {
+	el, ok := s.elems[key]
+	if !ok {
+		return
+	}
+	if s.inProtected[key] {
+		s.protected.MoveToFront(el)
+		return
+	}
+	if s.protectedCap <= 0 {
+		s.probation.MoveToFront(el)
+		return
+	}
+	// Second use: promote, demoting the protected tail if it is full
+	s.probation.Remove(el)
+	s.elems[key] = s.protected.PushFront(key)
+	s.inProtected[key] = true
+	if s.protected.Len() > s.protectedCap {
+		demoted := s.protected.Remove(s.protected.Back()).(string)
+		delete(s.inProtected, demoted)
+		s.elems[demoted] = s.probation.PushFront(demoted)
+	}
+}
+
+func (s *slru) Remove(key string) {
+	el, ok := s.elems[key]
+	if !ok {
+		return
+	}
+	if s.inProtected[key] {
+		s.protected.Remove(el)
+		delete(s.inProtected, key)
+	} else {
+		s.probation.Remove(el)
+	}
+	delete(s.elems, key)
+}
+
+func (s *slru) Victim() (string, bool) {
+	if el := s.probation.Back(); el != nil {
+		return el.Value.(string), true
+	}
+	if el := s.protected.Back(); el != nil {
+		return el.Value.(string), true
+	}
+	return "", false
+}
+
+func (s *slru) Len() int {
+	return len(s.elems)
+}
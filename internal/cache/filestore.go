@@ -0,0 +1,228 @@
+package cache
+
+import (
+	"Aegis/internal/utils"
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// fileEntrySuffix names entry files in a FileStore directory
+const fileEntrySuffix = ".entry"
+
+// fileMeta is everything but the body of an entry file. It is written
+// first, so expiry can be checked without reading the body.
+type fileMeta struct {
+	Key      string
+	Status   int
+	Header   http.Header
+	SavedAt  time.Time
+	ExpireAt time.Time
+	Checksum string
+	Vary     []string
+}
+
+// FileStore is a Store keeping one file per entry in a directory, so the
+// cache survives restarts. A file is named after the SHA-256 of its key
+// and holds a length-prefixed gob of the entry metadata followed by the
+// body. Files are replaced atomically, so readers never see partial writes.
+type FileStore struct {
+	dir   string
+	clock utils.Clock
+	grace time.Duration // Kept past expiry before Sweep removes an entry
+}
+
+// NewFileStore opens or creates a FileStore in dir. Entries that expired
+// while the store was closed and unreadable files are removed.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("open file store: %w", err)
+	}
+	s := &FileStore{dir: dir, clock: utils.RealClock{}}
+	if _, err := s.sweep(true); err != nil {
+		return nil, fmt.Errorf("open file store: %w", err)
+	}
+	return s, nil
+}
+
+// SetClock replaces the clock used for expiry checks
+func (s *FileStore) SetClock(clock utils.Clock) {
+	s.clock = clock
+}
+
+func (s *FileStore) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:])+fileEntrySuffix)
+}
+
+func (s *FileStore) Fetch(key string) (Response, bool, error) {
+	v, ok, err := s.FetchStale(key)
+	if !ok || err != nil {
+		return v, ok, err
+	}
+	if !v.ExpireAt.IsZero() && s.clock.Now().After(v.ExpireAt) {
+		return Response{}, false, nil
+	}
+	return v, true, nil
+}
+
+// FetchStale implements StaleFetcher: expired entries are returned until
+// Sweep removes them
+func (s *FileStore) FetchStale(key string) (Response, bool, error) {
+	f, err := os.Open(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return Response{}, false, nil
+	}
+	if err != nil {
+		return Response{}, false, err
+	}
+	defer f.Close()
+
+	br := bufio.NewReader(f)
+	meta, err := readFileMeta(br)
+	if err != nil {
+		return Response{}, false, fmt.Errorf("read %s: %w", f.Name(), err)
+	}
+	// Two keys sharing a digest would be a SHA-256 collision; treat as a miss
+	if meta.Key != key {
+		return Response{}, false, nil
+	}
+	body, err := io.ReadAll(br)
+	if err != nil {
+		return Response{}, false, fmt.Errorf("read %s: %w", f.Name(), err)
+	}
+	return Response{
+		Status:   meta.Status,
+		Header:   meta.Header,
+		Body:     body,
+		SavedAt:  meta.SavedAt,
+		ExpireAt: meta.ExpireAt,
+		Checksum: meta.Checksum,
+		Vary:     meta.Vary,
+	}, true, nil
+}
+
+func (s *FileStore) Put(key string, value Response) error {
+	var meta bytes.Buffer
+	err := gob.NewEncoder(&meta).Encode(fileMeta{
+		Key:      key,
+		Status:   value.Status,
+		Header:   value.Header,
+		SavedAt:  value.SavedAt,
+		ExpireAt: value.ExpireAt,
+		Checksum: value.Checksum,
+		Vary:     value.Vary,
+	})
+	if err != nil {
+		return fmt.Errorf("encode entry: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(s.dir, ".put-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	w := bufio.NewWriter(tmp)
+	_ = binary.Write(w, binary.BigEndian, uint32(meta.Len()))
+	_, _ = meta.WriteTo(w)
+	_, _ = w.Write(value.Body)
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path(key))
+}
+
+func (s *FileStore) Delete(key string) error {
+	err := os.Remove(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// SetSweepGrace keeps expired entries for grace past ExpireAt before Sweep
+// removes them, so they can still be served stale
+func (s *FileStore) SetSweepGrace(grace time.Duration) {
+	s.grace = grace
+}
+
+// Size returns the number of entry files, expired or not
+func (s *FileStore) Size() int {
+	files, _ := filepath.Glob(filepath.Join(s.dir, "*"+fileEntrySuffix))
+	return len(files)
+}
+
+// Sweep removes all entries expired for longer than the sweep grace and
+// returns how many were removed
+func (s *FileStore) Sweep() int {
+	n, _ := s.sweep(false)
+	return n
+}
+
+// sweep removes expired entry files, and with pruneBroken also files whose
+// metadata cannot be read, such as leftovers of an interrupted write
+func (s *FileStore) sweep(pruneBroken bool) (int, error) {
+	names, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 0, err
+	}
+	now := s.clock.Now()
+	removed := 0
+	for _, e := range names {
+		name := filepath.Join(s.dir, e.Name())
+		if pruneBroken && strings.HasPrefix(e.Name(), ".put-") {
+			_ = os.Remove(name)
+			continue
+		}
+		if !strings.HasSuffix(e.Name(), fileEntrySuffix) {
+			continue
+		}
+		meta, err := readFileMetaFrom(name)
+		if err != nil {
+			if pruneBroken && os.Remove(name) == nil {
+				removed++
+			}
+			continue
+		}
+		if !meta.ExpireAt.IsZero() && now.After(meta.ExpireAt.Add(s.grace)) && os.Remove(name) == nil {
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+func readFileMetaFrom(name string) (fileMeta, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return fileMeta{}, err
+	}
+	defer f.Close()
+	return readFileMeta(bufio.NewReader(f))
+}
+
+func readFileMeta(r io.Reader) (fileMeta, error) {
+	var n uint32
+	if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+		return fileMeta{}, err
+	}
+	var meta fileMeta
+	if err := gob.NewDecoder(io.LimitReader(r, int64(n))).Decode(&meta); err != nil {
+		return fileMeta{}, err
+	}
+	return meta, nil
+}
@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"cmp"
+	"encoding/gob"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// Entry is a cached response with its key and usage count
+type Entry struct {
+	Key      string
+	Response Response
+	Uses     int64 // Reads and refreshes since the entry was first stored
+}
+
+// Hottest returns up to n unexpired entries with the highest usage counts,
+// most used first (n <= 0 = all). Bodies are returned as stored, so they
+// may be compressed.
+func (c *Cache) Hottest(n int) []Entry {
+	now := c.clock.Now()
+	c.mu.RLock()
+	entries := make([]Entry, 0, len(c.data))
+	for k, v := range c.data {
+		if !v.ExpireAt.IsZero() && now.After(v.ExpireAt) {
+			continue
+		}
+		entries = append(entries, Entry{Key: k, Response: v, Uses: c.uses[k].Load()})
+	}
+	c.mu.RUnlock()
+
+	slices.SortFunc(entries, func(a, b Entry) int {
+		if d := cmp.Compare(b.Uses, a.Uses); d != 0 {
+			return d
+		}
+		return cmp.Compare(a.Key, b.Key)
+	})
+	if n > 0 && len(entries) > n {
+		entries = entries[:n]
+	}
+	return entries
+}
+
+// WriteHandoff saves the n hottest entries to path for ReadHandoff in the
+// next instance. The file is replaced atomically.
+func (c *Cache) WriteHandoff(path string, n int) (int, error) {
+	entries := c.Hottest(n)
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return 0, fmt.Errorf("write handoff: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := gob.NewEncoder(tmp).Encode(entries); err != nil {
+		tmp.Close()
+		return 0, fmt.Errorf("write handoff: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, fmt.Errorf("write handoff: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return 0, fmt.Errorf("write handoff: %w", err)
+	}
+	return len(entries), nil
+}
+
+// ReadHandoff stores the entries saved by WriteHandoff, keeping their
+// usage counts. Entries that expired in the meantime are skipped.
+func (c *Cache) ReadHandoff(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("read handoff: %w", err)
+	}
+	defer f.Close()
+
+	var entries []Entry
+	if err := gob.NewDecoder(f).Decode(&entries); err != nil {
+		return 0, fmt.Errorf("read handoff %s: %w", path, err)
+	}
+	now := c.clock.Now()
+	imported := 0
+	// Least used first, so a bounded cache evicts those if it must
+	for _, e := range slices.Backward(entries) {
+		if !e.Response.ExpireAt.IsZero() && now.After(e.Response.ExpireAt) {
+			continue
+		}
+		if !c.Set(e.Key, e.Response) {
+			continue
+		}
+		c.mu.RLock()
+		if n := c.uses[e.Key]; n != nil {
+			n.Store(e.Uses)
+		}
+		c.mu.RUnlock()
+		imported++
+	}
+	return imported, nil
+}
@@ -0,0 +1,229 @@
+package cache
+
+import (
+	"Aegis/internal/utils"
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// Redis defaults
+const (
+	DefaultRedisTimeout   = time.Second
+	DefaultRedisPoolSize  = 16
+	DefaultRedisKeyPrefix = "aegis:"
+)
+
+// RedisOptions configures a RedisStore
+type RedisOptions struct {
+	Addr      string        // host:port
+	Password  string        // AUTH password (empty = none)
+	DB        int           // Database selected after connecting
+	Timeout   time.Duration // Dial and per-command timeout
+	PoolSize  int           // Idle connections kept for reuse
+	KeyPrefix string        // Prepended to every cache key
+}
+
+// RedisStore is a Store in a Redis server, so several instances share one
+// cache. Entries are gob-encoded and expire through the native Redis TTL.
+// It speaks the Redis protocol directly over a small connection pool.
+type RedisStore struct {
+	opts  RedisOptions
+	idle  chan *redisConn
+	clock utils.Clock
+}
+
+// NewRedisStore returns a store for the server at opts.Addr. Connections
+// are made on first use, so an unreachable server shows up as backend
+// errors subject to the backend failure policy.
+func NewRedisStore(opts RedisOptions) *RedisStore {
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultRedisTimeout
+	}
+	if opts.PoolSize <= 0 {
+		opts.PoolSize = DefaultRedisPoolSize
+	}
+	if opts.KeyPrefix == "" {
+		opts.KeyPrefix = DefaultRedisKeyPrefix
+	}
+	return &RedisStore{
+		opts:  opts,
+		idle:  make(chan *redisConn, opts.PoolSize),
+		clock: utils.RealClock{},
+	}
+}
+
+// SetClock replaces the clock used for expiry checks
+func (s *RedisStore) SetClock(clock utils.Clock) {
+	s.clock = clock
+}
+
+func (s *RedisStore) Fetch(key string) (Response, bool, error) {
+	reply, err := s.do("GET", s.opts.KeyPrefix+key)
+	if err != nil {
+		return Response{}, false, err
+	}
+	data, ok := reply.([]byte)
+	if !ok {
+		return Response{}, false, nil
+	}
+	var v Response
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&v); err != nil {
+		return Response{}, false, fmt.Errorf("decode %s: %w", key, err)
+	}
+	// Redis expires in milliseconds; the proxy clock has the last word
+	if !v.ExpireAt.IsZero() && s.clock.Now().After(v.ExpireAt) {
+		return Response{}, false, nil
+	}
+	return v, true, nil
+}
+
+// Put stores value, with a Redis TTL up to its ExpireAt when it has one.
+// An entry that is already expired is not stored.
+func (s *RedisStore) Put(key string, value Response) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(value); err != nil {
+		return fmt.Errorf("encode %s: %w", key, err)
+	}
+	args := []string{"SET", s.opts.KeyPrefix + key, buf.String()}
+	if !value.ExpireAt.IsZero() {
+		ttl := value.ExpireAt.Sub(s.clock.Now()).Milliseconds()
+		if ttl <= 0 {
+			return nil
+		}
+		args = append(args, "PX", strconv.FormatInt(ttl, 10))
+	}
+	_, err := s.do(args...)
+	return err
+}
+
+func (s *RedisStore) Delete(key string) error {
+	_, err := s.do("DEL", s.opts.KeyPrefix+key)
+	return err
+}
+
+// Close closes the idle connections
+func (s *RedisStore) Close() {
+	for {
+		select {
+		case c := <-s.idle:
+			c.conn.Close()
+		default:
+			return
+		}
+	}
+}
+
+// redisError is an error reply; the connection stays usable after one
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+type redisConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+}
+
+// do runs one command on a pooled connection and returns its reply:
+// a string, an int64, a []byte, or nil for a missing value
+func (s *RedisStore) do(args ...string) (any, error) {
+	c, err := s.get()
+	if err != nil {
+		return nil, err
+	}
+	reply, err := c.do(s.opts.Timeout, args...)
+	var re redisError
+	if err != nil && !errors.As(err, &re) {
+		c.conn.Close()
+		return nil, err
+	}
+	s.put(c)
+	return reply, err
+}
+
+func (s *RedisStore) get() (*redisConn, error) {
+	select {
+	case c := <-s.idle:
+		return c, nil
+	default:
+	}
+	conn, err := net.DialTimeout("tcp", s.opts.Addr, s.opts.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	c := &redisConn{conn: conn, r: bufio.NewReader(conn), w: bufio.NewWriter(conn)}
+	if s.opts.Password != "" {
+		if _, err := c.do(s.opts.Timeout, "AUTH", s.opts.Password); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if s.opts.DB != 0 {
+		if _, err := c.do(s.opts.Timeout, "SELECT", strconv.Itoa(s.opts.DB)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (s *RedisStore) put(c *redisConn) {
+	select {
+	case s.idle <- c:
+	default:
+		c.conn.Close()
+	}
+}
+
+func (c *redisConn) do(timeout time.Duration, args ...string) (any, error) {
+	_ = c.conn.SetDeadline(time.Now().Add(timeout))
+	fmt.Fprintf(c.w, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(c.w, "$%d\r\n%s\r\n", len(a), a)
+	}
+	if err := c.w.Flush(); err != nil {
+		return nil, err
+	}
+	return c.readReply()
+}
+
+func (c *redisConn) readReply() (any, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("redis: malformed reply %q", line)
+	}
+	kind, rest := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return rest, nil
+	case '-':
+		return nil, redisError(rest)
+	case ':':
+		return strconv.ParseInt(rest, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(rest)
+		if err != nil {
+			return nil, fmt.Errorf("redis: malformed bulk length %q", rest)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, data); err != nil {
+			return nil, err
+		}
+		return data[:n], nil
+	default:
+		return nil, fmt.Errorf("redis: unsupported reply type %q", kind)
+	}
+}
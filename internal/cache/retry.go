@@ -0,0 +1,64 @@
+package cache
+
+import (
+	"errors"
+	"time"
+)
+
+// retryStore retries failed backend operations a few times before
+// reporting the error
+type retryStore struct {
+	Store
+	retries int
+	delay   time.Duration
+}
+
+// WithRetries wraps s so each Fetch, Put and Delete is retried up to retries times,
+// delay apart, while the backend reports an error. ErrTooLarge is final.
+func WithRetries(s Store, retries int, delay time.Duration) Store {
+	if retries <= 0 {
+		return s
+	}
+	return &retryStore{Store: s, retries: retries, delay: delay}
+}
+
+func (s *retryStore) Fetch(key string) (Response, bool, error) {
+	v, ok, err := s.Store.Fetch(key)
+	for i := 0; err != nil && i < s.retries; i++ {
+		time.Sleep(s.delay)
+		v, ok, err = s.Store.Fetch(key)
+	}
+	return v, ok, err
+}
+
+// FetchStale implements StaleFetcher when the wrapped store does
+func (s *retryStore) FetchStale(key string) (Response, bool, error) {
+	sf, ok := s.Store.(StaleFetcher)
+	if !ok {
+		return s.Fetch(key)
+	}
+	v, ok, err := sf.FetchStale(key)
+	for i := 0; err != nil && i < s.retries; i++ {
+		time.Sleep(s.delay)
+		v, ok, err = sf.FetchStale(key)
+	}
+	return v, ok, err
+}
+
+func (s *retryStore) Put(key string, value Response) error {
+	err := s.Store.Put(key, value)
+	for i := 0; err != nil && !errors.Is(err, ErrTooLarge) && i < s.retries; i++ {
+		time.Sleep(s.delay)
+		err = s.Store.Put(key, value)
+	}
+	return err
+}
+
+func (s *retryStore) Delete(key string) error {
+	err := s.Store.Delete(key)
+	for i := 0; err != nil && i < s.retries; i++ {
+		time.Sleep(s.delay)
+		err = s.Store.Delete(key)
+	}
+	return err
+}
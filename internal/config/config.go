@@ -1,10 +1,18 @@
 package config
 
 import (
+	"Aegis/internal/utils"
 	"flag"
 	"fmt"
 	"log"
+	"math"
+	"net"
+	"net/url"
 	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -18,6 +26,53 @@ type Config struct {
 	TTL      time.Duration
 	Cache    CacheConfig
 	Logging  LoggingConfig
+
+	// ExtraUpstreams share requests round-robin with Upstream
+	ExtraUpstreams []string
+
+	// TrustedProxies are networks allowed to set X-Forwarded-* headers
+	TrustedProxies []*net.IPNet
+	// ForwardedHeaders adds X-Forwarded-For/Host/Proto to upstream requests
+	ForwardedHeaders bool
+	// CompressMinBytes gzip-compresses text-like responses of at least this
+	// size for clients that accept it (0 = compression disabled)
+	CompressMinBytes int
+	// HonorRequestTimeoutHeader lets clients shorten Timeout via Request-Timeout
+	HonorRequestTimeoutHeader bool
+	// Retries resends failed GET/HEAD requests upstream, waiting RetryBackoff
+	// before the first retry and doubling it for each further one
+	Retries      int
+	RetryBackoff time.Duration
+	// InstanceID identifies this instance in InstanceHeader (generated if empty)
+	InstanceID     string
+	InstanceHeader string
+	// ServedBy is the X-Served-By value (empty = header omitted)
+	ServedBy string
+	// DefaultHost replaces a missing Host (HTTP/1.0); empty rejects such requests
+	DefaultHost string
+	// NonStandardMethods handles methods like PROPFIND: forward, reject or normalize
+	NonStandardMethods string
+
+	CircuitBreaker CircuitBreakerConfig
+	Readiness      ReadinessConfig
+	Admission      AdmissionConfig
+	RateLimit      RateLimitConfig
+	Routing        RoutingConfig
+	Diagnostics    DiagnosticsConfig
+	Transport      TransportConfig
+	Stats          StatsConfig
+	Security       SecurityConfig
+	Transform      TransformConfig
+	Debug          DebugConfig
+	Shadow         ShadowConfig
+	ErrorBody      ErrorBodyConfig
+	Background     BackgroundConfig
+	WebSocket      WebSocketConfig
+	Admin          AdminConfig
+	Health         HealthConfig
+	Maintenance    MaintenanceConfig
+	Events         EventsConfig
+	TLS            TLSConfig
 }
 
 // CacheConfig holds cache-specific configuration
@@ -25,6 +80,157 @@ type CacheConfig struct {
 	// KeyHeaders is a list of HTTP headers to include in cache key
 	// This allows caching different responses for different header values
 	KeyHeaders []string
+
+	// KeyIncludeScheme adds the effective request scheme (http/https) to the key
+	KeyIncludeScheme bool
+	// IgnoreQueryParams are left out of the key but still forwarded
+	IgnoreQueryParams []string
+	// IgnoreAnalyticsParams also ignores the built-in analytics list (utm_*, gclid, ...)
+	IgnoreAnalyticsParams bool
+	// CountryHeader adds its normalized country code to the key (empty = disabled)
+	CountryHeader string
+	// CountryDefault replaces a missing or invalid country code
+	CountryDefault string
+	// VaryKeyHeaders adds the key headers to the response Vary header
+	VaryKeyHeaders bool
+	// Preload lists URLs fetched into the cache at startup
+	Preload []PreloadConfig
+	// PreloadStrict makes a failed preload fatal
+	PreloadStrict bool
+	// KeyIncludeHost adds the request Host to the key
+	KeyIncludeHost bool
+	// MaxEntries caps the number of cached entries (0 = unlimited)
+	MaxEntries int
+	// MaxMemory caps approximate cache memory in bytes (0 = unlimited)
+	MaxMemory int64
+	// CompressMinBytes stores text-like bodies of at least this size
+	// gzip-compressed in memory (0 = compression disabled)
+	CompressMinBytes int
+	// MaxEntriesPerHost caps entries per Host with per-host eviction (0 = no cap)
+	MaxEntriesPerHost int
+	// EvictionPolicy picks entries to evict at a cap: lru or slru
+	EvictionPolicy string
+	// SweepInterval is how often expired entries are removed (0 = never)
+	SweepInterval time.Duration
+	// StaleWhileRevalidate serves recently expired entries while refreshing
+	// them in the background (0 = disabled)
+	StaleWhileRevalidate time.Duration
+	// MaxStale is the oldest backup served when upstream fails (0 = any age)
+	MaxStale time.Duration
+	// NegativeTTL caches NegativeStatuses (404 by default) that long (0 = disabled)
+	NegativeTTL      time.Duration
+	NegativeStatuses []int
+	// CacheableStatus are the upstream statuses cached (empty = 200-299)
+	CacheableStatus []StatusRange
+	// FailoverStatus are the upstream statuses answered with a backup
+	// (empty = 500-599)
+	FailoverStatus []StatusRange
+	// Backend stores entries in memory, on disk in Dir, or in Redis
+	Backend string
+	Dir     string
+	Redis   RedisConfig
+	// HandoffPath receives the hottest entries on shutdown and is imported
+	// on startup (empty = disabled)
+	HandoffPath string
+	// HandoffTopN is the number of entries exported to HandoffPath
+	HandoffTopN int
+	// TimeBucket adds floor(now / TimeBucket) to the key (0 = disabled)
+	TimeBucket time.Duration
+
+	// StatusHeader selects the cache status header: x-cache, cache-status or both
+	StatusHeader string
+
+	// GetBody controls GET/HEAD requests with a body: ignore, reject or key
+	GetBody string
+	// GetBodyKeyFields keys JSON bodies on these dotted fields only
+	GetBodyKeyFields []string
+
+	// MinBodyBytes and MaxBodyBytes bound the size of cached bodies (0 = no limit)
+	MinBodyBytes int
+	MaxBodyBytes int
+	// OversizeBody handles bodies growing beyond MaxBodyBytes: stream or reject
+	OversizeBody string
+	// StreamThresholdBytes streams larger responses while caching them (0 = disabled)
+	StreamThresholdBytes int
+	// SkipEmptyBody refuses to cache 2xx responses with an empty body
+	SkipEmptyBody bool
+	// Coalesce shares one upstream fetch among concurrent requests for a key
+	Coalesce bool
+	// BypassHeader and BypassValue mark upstream responses not to cache
+	// (empty header = disabled, empty value = any)
+	BypassHeader string
+	BypassValue  string
+
+	// HashKeys hashes cache keys to a fixed-length digest: none, sha256 or xxhash
+	HashKeys string
+	// HashKeysLongerThan hashes longer plaintext keys with SHA-256 (0 = never)
+	HashKeysLongerThan int
+	// Rules overrides the TTL by path; the first matching rule wins
+	Rules []TTLRuleConfig
+	// MaxKeyHeaderValueBytes hashes longer key header values (0 = no limit)
+	MaxKeyHeaderValueBytes int
+
+	// BackendFailure controls cache backend errors: fail_open or fail_closed
+	BackendFailure string
+	// BackendRetries retries failed cache backend operations before BackendFailure applies
+	BackendRetries int
+
+	// AllowSharedAuthBackup serves backups to requests with Authorization
+	// even when Authorization is not in KeyHeaders
+	AllowSharedAuthBackup bool
+
+	// HeuristicFraction derives TTL from Last-Modified when upstream sent no
+	// explicit freshness and no TTL is configured (0 = disabled)
+	HeuristicFraction float64
+	// MaxTTL caps heuristic TTLs (0 = no cap)
+	MaxTTL time.Duration
+	// HeaderPrecedence orders the TTL signals, the first one present wins
+	HeaderPrecedence []string
+	// RespectOriginTTL takes the TTL from max-age/Expires, capped by TTL
+	RespectOriginTTL bool
+	// ETagRevalidation confirms cached entries with If-None-Match
+	ETagRevalidation bool
+
+	// Head controls HEAD response caching: separate or none
+	Head string
+	// Immutable serves Cache-Control: immutable entries without contacting upstream
+	Immutable bool
+	// AllowTTLRequestHeader honors X-Aegis-Cache-TTL from trusted proxies
+	AllowTTLRequestHeader bool
+	// HonorPragma treats request Pragma: no-cache like Cache-Control: no-cache
+	HonorPragma bool
+	// FailoverOn429 serves a cached backup on upstream 429 Too Many Requests
+	FailoverOn429 bool
+	// VerifyChecksums stores body checksums and rejects corrupted entries
+	VerifyChecksums bool
+	// AdaptiveTTL scales TTLs per path by hit and change ratios within the factor bounds
+	AdaptiveTTL          bool
+	AdaptiveTTLMinFactor float64
+	AdaptiveTTLMaxFactor float64
+}
+
+// RedisConfig holds the connection of the redis cache backend
+type RedisConfig struct {
+	Addr     string // host:port
+	Password string // AUTH password (empty = none)
+	DB       int    // Database number
+}
+
+// PreloadConfig is one entry of the preload manifest
+type PreloadConfig struct {
+	URL string        // Path and optional query
+	TTL time.Duration // Entry TTL (0 = normal TTL rules)
+}
+
+// StatusRange is an inclusive range of HTTP status codes
+type StatusRange struct {
+	Min, Max int
+}
+
+// TTLRuleConfig is one entry of cache.rules
+type TTLRuleConfig struct {
+	Path string        // Path prefix, or glob when it contains *, ? or [
+	TTL  time.Duration // TTL for matching paths
 }
 
 // LoggingConfig holds logging configuration
@@ -32,33 +238,445 @@ type LoggingConfig struct {
 	Enabled   bool   // Enable/disable all logging
 	AccessLog bool   // Enable/disable access log
 	Level     string // Log level: debug, info, error
+
+	AccessExcludePaths []string // Paths without access-log lines ("prefix*" or exact)
+	AccessLogErrors    bool     // Still log >= 400 responses on excluded paths
+
+	DumpRequestBody BodyDumpConfig // Debug logging of request bodies
+}
+
+// BodyDumpConfig holds request body debug logging configuration
+type BodyDumpConfig struct {
+	MaxBytes     int      // Leading body bytes to log (0 = disabled)
+	ContentTypes []string // Content-Type prefixes to dump (empty = all)
+	Paths        []string // Path prefixes to dump (empty = all)
+	RedactFields []string // JSON/form field names whose values are masked
+}
+
+// CircuitBreakerConfig holds upstream circuit breaker configuration
+type CircuitBreakerConfig struct {
+	FailureThreshold int           // Consecutive failures before opening (0 = disabled)
+	Cooldown         time.Duration // How long to stay open before retrying upstream
+}
+
+// ReadinessConfig holds /readyz configuration
+type ReadinessConfig struct {
+	// ReadyWithCache keeps the instance ready while the breaker is open
+	// as long as the cache holds at least one entry
+	ReadyWithCache bool
+}
+
+// AdmissionConfig holds global backpressure configuration
+type AdmissionConfig struct {
+	MaxInFlight  int           // Concurrent requests allowed (0 = unlimited)
+	Policy       string        // queue or shed when all slots are taken
+	QueueDepth   int           // Maximum number of queued requests
+	QueueTimeout time.Duration // Maximum time a request waits in the queue
+}
+
+// EventsConfig holds cache event delivery configuration
+type EventsConfig struct {
+	Enabled    bool
+	WebhookURL string        // POST each event as JSON
+	LogFile    string        // Append events as JSON lines
+	QueueSize  int           // Pending events before new ones are dropped
+	Timeout    time.Duration // Timeout of one webhook request
+}
+
+// RateLimitConfig holds request rate limits
+type RateLimitConfig struct {
+	Rate  float64 // Global requests per second (0 = unlimited)
+	Burst int     // Global burst size
+	// Paths override the global limit for path prefixes, longest prefix wins
+	Paths []PathRateLimitConfig
+}
+
+// PathRateLimitConfig is the rate limit of one path prefix
+type PathRateLimitConfig struct {
+	Prefix string  `yaml:"prefix"`
+	Rate   float64 `yaml:"rate"`
+	Burst  int     `yaml:"burst"`
+}
+
+// RoutingConfig holds request routing configuration
+type RoutingConfig struct {
+	// Rewrites are regex rewrite rules for the upstream URL, first match wins
+	Rewrites []RewriteConfig
+	// NoopPaths are answered with 204 for GET/HEAD without contacting upstream
+	NoopPaths []string
+	// CanonicalizePath decodes safe percent-encodings and collapses duplicate slashes
+	CanonicalizePath bool
+	// DecodeEncodedSlash also turns %2F into "/" when canonicalizing
+	DecodeEncodedSlash bool
+}
+
+// RewriteConfig is a single regex rewrite rule
+type RewriteConfig struct {
+	Match   string `yaml:"match"`
+	Replace string `yaml:"replace"`
+}
+
+// DiagnosticsConfig holds diagnostic features configuration
+type DiagnosticsConfig struct {
+	CaptureErrorsN int // Number of recent upstream errors kept for /errors (0 = disabled)
+}
+
+// TransportConfig holds upstream connection tuning
+type TransportConfig struct {
+	IdleConnTimeout   time.Duration // How long idle keep-alive connections are kept
+	DisableKeepAlives bool          // Open a new upstream connection per request
+	OutboundProxy     string        // http(s)/socks5 proxy URL, "direct", or empty for environment
+}
+
+// StatsConfig holds statistics reporting configuration
+type StatsConfig struct {
+	LogInterval time.Duration // How often to log a stats snapshot (0 = disabled)
+	Savings     bool          // Report requests and bytes served from cache
+}
+
+// SecurityConfig holds security-related configuration
+type SecurityConfig struct {
+	// AllowedUpstreamHosts are hosts requests may be sent to in addition
+	// to the configured upstream (hostname or host:port)
+	AllowedUpstreamHosts []string
+}
+
+// TransformConfig holds external body transform configuration
+type TransformConfig struct {
+	Command      []string      // Program and arguments (empty = disabled)
+	ContentTypes []string      // Content-Type prefixes to transform (empty = all)
+	Timeout      time.Duration // Maximum run time per body
+	MaxBytes     int           // Largest input/output body handled
+}
+
+// DebugConfig holds debugging aids exposed to clients
+type DebugConfig struct {
+	Enabled      bool // Emit X-Cache-Entries and X-Cache-Memory-Bytes
+	ServerTiming bool // Emit Server-Timing with upstream duration and cache status
+}
+
+// ShadowConfig holds request mirroring configuration
+type ShadowConfig struct {
+	Upstream   string        // Shadow upstream base URL (empty = disabled)
+	SampleRate float64       // Fraction of eligible requests mirrored
+	Paths      []string      // Path prefixes to mirror (empty = all)
+	Sticky     bool          // Deterministic sampling per request
+	Timeout    time.Duration // Timeout of a mirrored request
+}
+
+// ErrorBodyConfig holds detection of upstream error pages sent as 2xx
+type ErrorBodyConfig struct {
+	Contains     []string // Body substrings marking an error page (empty = disabled)
+	ContentTypes []string // Content-Type prefixes checked
+	Paths        []string // Path prefixes checked (empty = all)
+}
+
+// HealthConfig holds active upstream health check configuration
+type HealthConfig struct {
+	Path                 string        // Probed path
+	Interval             time.Duration // Time between probes (0 = disabled)
+	Timeout              time.Duration // Timeout of a single probe
+	Method               string        // Probe method
+	ExpectedStatus       []int         // Healthy statuses (empty = any below 500)
+	ExpectedBodyContains string        // Substring the body must contain (empty = any)
+	FailureThreshold     int           // Failed probes in a row taking an upstream out of rotation
+}
+
+// MaintenanceConfig holds scheduled upstream maintenance windows
+type MaintenanceConfig struct {
+	Location *time.Location // Time zone the windows are given in
+	Windows  []MaintenanceWindowConfig
+	Paths    []MaintenancePathConfig
+}
+
+// MaintenancePathConfig takes one path prefix offline with a static response
+type MaintenancePathConfig struct {
+	Prefix      string // Path prefix
+	Status      int    // Response status
+	Body        string // Response body
+	ContentType string // Response Content-Type
+	Enabled     bool   // Initial state, toggled at /maintenance
+}
+
+// MaintenanceWindowConfig is a daily window, optionally limited to weekdays
+type MaintenanceWindowConfig struct {
+	Start time.Duration  // Offset from midnight
+	End   time.Duration  // Offset from midnight, before Start if past midnight
+	Days  []time.Weekday // Days the window starts on (empty = every day)
+}
+
+// BackgroundConfig holds background fetch configuration
+type BackgroundConfig struct {
+	MaxWorkers             int // Concurrent background upstream fetches
+	MaxRevalidationsPerKey int // Concurrent background refreshes of one cache key
+}
+
+// TLSConfig holds TLS termination settings of the listener
+type TLSConfig struct {
+	CertFile             string        // PEM certificate (empty = plain HTTP)
+	KeyFile              string        // PEM private key
+	MinVersion           uint16        // Lowest accepted TLS version
+	ALPN                 []string      // Offered application protocols
+	CipherSuites         []uint16      // TLS 1.2 cipher suites (nil = Go defaults)
+	SessionTicketKeyFile string        // Persisted session ticket keys (empty = random per process)
+	SessionTicketReload  time.Duration // How often the key file is reread (0 = never)
+}
+
+// WebSocketConfig holds settings for upgraded (WebSocket) connections
+type WebSocketConfig struct {
+	IdleTimeout time.Duration // Close upgraded connections after this long without traffic
+}
+
+// AdminConfig holds settings for admin endpoints
+type AdminConfig struct {
+	User      string // Basic auth user for admin endpoints
+	Password  string // Basic auth password for admin endpoints
+	Dashboard bool   // Serve the HTML status page at /dashboard
+	Purge     bool   // Serve the cache purge endpoint at /purge
+	// Maintenance serves /maintenance to toggle maintenance.paths
+	Maintenance bool
 }
 
 // FileConfig represents the structure of the YAML config file
 type FileConfig struct {
 	Server struct {
-		Listen   string `yaml:"listen"`
-		Upstream string `yaml:"upstream"`
-		Timeout  string `yaml:"timeout"`
+		Listen   string     `yaml:"listen"`
+		Upstream stringList `yaml:"upstream"`
+		Timeout  string     `yaml:"timeout"`
+
+		TrustedProxies            []string `yaml:"trusted_proxies"`
+		ForwardedHeaders          *bool    `yaml:"forwarded_headers"`
+		HonorRequestTimeoutHeader bool     `yaml:"honor_request_timeout_header"`
+		InstanceID                string   `yaml:"instance_id"`
+		InstanceHeader            string   `yaml:"instance_header"`
+		DefaultHost               string   `yaml:"default_host"`
+		NonStandardMethods        string   `yaml:"non_standard_methods"`
+		ServedByHeader            *string  `yaml:"served_by_header"`
+		Retries                   int      `yaml:"retries"`
+		RetryBackoff              string   `yaml:"retry_backoff"`
+		CompressResponses         bool     `yaml:"compress_responses"`
+		CompressMinBytes          string   `yaml:"compress_min_bytes"`
 	} `yaml:"server"`
 	Cache struct {
 		TTL        string   `yaml:"ttl"`
 		KeyHeaders []string `yaml:"key_headers"`
+
+		KeyIncludeScheme bool   `yaml:"key_include_scheme"`
+		KeyIncludeHost   bool   `yaml:"key_include_host"`
+		VaryKeyHeaders   bool   `yaml:"vary_key_headers"`
+		PreloadManifest  string `yaml:"preload_manifest"`
+		PreloadStrict    bool   `yaml:"preload_strict"`
+		TimeBucket       string `yaml:"time_bucket"`
+		CountryHeader    string `yaml:"country_header"`
+		CountryDefault   string `yaml:"country_default"`
+		StatusHeader     string `yaml:"status_header"`
+		GetBody          string `yaml:"get_body"`
+		MinBodyBytes     int    `yaml:"min_body_bytes"`
+		MaxBodyBytes     int    `yaml:"max_body_bytes"`
+		OversizeBody     string `yaml:"oversize_body"`
+		SkipEmptyBody    bool   `yaml:"skip_empty_body"`
+		Coalesce         *bool  `yaml:"coalesce"`
+		HashKeys         string `yaml:"hash_keys"`
+
+		HashKeysLongerThan int `yaml:"hash_keys_longer_than"`
+
+		Rules []struct {
+			Path string `yaml:"path"`
+			TTL  string `yaml:"ttl"`
+		} `yaml:"rules"`
+
+		GetBodyKeyFields      []string `yaml:"get_body_key_fields"`
+		BypassHeader          *string  `yaml:"bypass_header"`
+		BypassHeaderValue     *string  `yaml:"bypass_header_value"`
+		MaxEntries            int      `yaml:"max_entries"`
+		MaxMemory             string   `yaml:"max_memory"`
+		Compress              bool     `yaml:"compress"`
+		CompressMinBytes      string   `yaml:"compress_min_bytes"`
+		MaxEntriesPerHost     int      `yaml:"max_entries_per_host"`
+		EvictionPolicy        string   `yaml:"eviction_policy"`
+		SweepInterval         string   `yaml:"sweep_interval"`
+		StaleWhileRevalidate  string   `yaml:"stale_while_revalidate"`
+		MaxStale              string   `yaml:"max_stale"`
+		NegativeTTL           string   `yaml:"negative_ttl"`
+		NegativeStatuses      []int    `yaml:"negative_statuses"`
+		CacheableStatus       []string `yaml:"cacheable_status"`
+		FailoverStatus        []string `yaml:"failover_status"`
+		Backend               string   `yaml:"backend"`
+		Dir                   string   `yaml:"dir"`
+		RedisAddr             string   `yaml:"redis_addr"`
+		RedisPassword         string   `yaml:"redis_password"`
+		RedisDB               int      `yaml:"redis_db"`
+		HandoffPath           string   `yaml:"handoff_path"`
+		HandoffTopN           int      `yaml:"handoff_top_n"`
+		IgnoreQueryParams     []string `yaml:"ignore_query_params"`
+		IgnoreAnalyticsParams bool     `yaml:"ignore_analytics_params"`
+
+		MaxKeyHeaderValueBytes int `yaml:"max_key_header_value_bytes"`
+		StreamThresholdBytes   int `yaml:"stream_threshold_bytes"`
+
+		BackendFailure string `yaml:"backend_failure"`
+		BackendRetries int    `yaml:"backend_retries"`
+
+		AllowSharedAuthBackup bool    `yaml:"allow_shared_auth_backup"`
+		HeuristicFraction     float64 `yaml:"heuristic_fraction"`
+		MaxTTL                string  `yaml:"max_ttl"`
+
+		HeaderPrecedence []string `yaml:"header_precedence"`
+		RespectOriginTTL bool     `yaml:"respect_origin_ttl"`
+		ETagRevalidation bool     `yaml:"etag_revalidation"`
+
+		Head                  string  `yaml:"head"`
+		Immutable             bool    `yaml:"immutable"`
+		AllowTTLRequestHeader bool    `yaml:"allow_ttl_request_header"`
+		HonorPragma           bool    `yaml:"honor_pragma"`
+		FailoverOn429         bool    `yaml:"failover_on_429"`
+		VerifyChecksums       bool    `yaml:"verify_checksums"`
+		AdaptiveTTL           bool    `yaml:"adaptive_ttl"`
+		AdaptiveTTLMinFactor  float64 `yaml:"adaptive_ttl_min_factor"`
+		AdaptiveTTLMaxFactor  float64 `yaml:"adaptive_ttl_max_factor"`
 	} `yaml:"cache"`
 	Logging struct {
 		Enabled   bool   `yaml:"enabled"`
 		AccessLog bool   `yaml:"access_log"`
 		Level     string `yaml:"level"`
+
+		AccessExcludePaths []string `yaml:"access_exclude_paths"`
+		AccessLogErrors    bool     `yaml:"access_log_excluded_errors"`
+
+		DumpRequestBody struct {
+			MaxBytes     int      `yaml:"max_bytes"`
+			ContentTypes []string `yaml:"content_types"`
+			Paths        []string `yaml:"paths"`
+			RedactFields []string `yaml:"redact_fields"`
+		} `yaml:"dump_request_body"`
 	} `yaml:"logging"`
+	CircuitBreaker struct {
+		FailureThreshold int    `yaml:"failure_threshold"`
+		Cooldown         string `yaml:"cooldown"`
+	} `yaml:"circuit_breaker"`
+	Readiness struct {
+		ReadyWithCache bool `yaml:"ready_with_cache"`
+	} `yaml:"readiness"`
+	Admission struct {
+		MaxInFlight  int    `yaml:"max_in_flight"`
+		Policy       string `yaml:"policy"`
+		QueueDepth   int    `yaml:"queue_depth"`
+		QueueTimeout string `yaml:"queue_timeout"`
+	} `yaml:"admission"`
+	Events struct {
+		Enabled    bool   `yaml:"enabled"`
+		WebhookURL string `yaml:"webhook_url"`
+		LogFile    string `yaml:"log_file"`
+		QueueSize  int    `yaml:"queue_size"`
+		Timeout    string `yaml:"timeout"`
+	} `yaml:"events"`
+	TLS struct {
+		CertFile             string   `yaml:"cert_file"`
+		KeyFile              string   `yaml:"key_file"`
+		MinVersion           string   `yaml:"min_version"`
+		ALPN                 []string `yaml:"alpn"`
+		CipherSuites         []string `yaml:"cipher_suites"`
+		SessionTicketKeyFile string   `yaml:"session_ticket_key_file"`
+		SessionTicketReload  string   `yaml:"session_ticket_reload"`
+	} `yaml:"tls"`
+	RateLimit struct {
+		Rate  float64               `yaml:"rate"`
+		Burst int                   `yaml:"burst"`
+		Paths []PathRateLimitConfig `yaml:"paths"`
+	} `yaml:"rate_limit"`
+	Routing struct {
+		Rewrites           []RewriteConfig `yaml:"rewrites"`
+		NoopPaths          []string        `yaml:"noop_paths"`
+		CanonicalizePath   bool            `yaml:"canonicalize_path"`
+		DecodeEncodedSlash bool            `yaml:"decode_encoded_slash"`
+	} `yaml:"routing"`
+	Diagnostics struct {
+		CaptureErrorsN int `yaml:"capture_errors_n"`
+	} `yaml:"diagnostics"`
+	Transport struct {
+		IdleConnTimeout   string `yaml:"idle_conn_timeout"`
+		DisableKeepAlives bool   `yaml:"disable_keep_alives"`
+		OutboundProxy     string `yaml:"outbound_proxy"`
+	} `yaml:"transport"`
+	Stats struct {
+		LogInterval string `yaml:"log_interval"`
+		Savings     bool   `yaml:"savings"`
+	} `yaml:"stats"`
+	Security struct {
+		AllowedUpstreamHosts []string `yaml:"allowed_upstream_hosts"`
+	} `yaml:"security"`
+	Transform struct {
+		Command      []string `yaml:"command"`
+		ContentTypes []string `yaml:"content_types"`
+		Timeout      string   `yaml:"timeout"`
+		MaxBytes     int      `yaml:"max_bytes"`
+	} `yaml:"transform"`
+	Debug struct {
+		Enabled      bool `yaml:"enabled"`
+		ServerTiming bool `yaml:"server_timing"`
+	} `yaml:"debug"`
+	Shadow struct {
+		Upstream   string   `yaml:"upstream"`
+		SampleRate *float64 `yaml:"sample_rate"`
+		Paths      []string `yaml:"paths"`
+		Sticky     bool     `yaml:"sticky"`
+		Timeout    string   `yaml:"timeout"`
+	} `yaml:"shadow"`
+	Upstream struct {
+		ErrorBodyContains     []string `yaml:"error_body_contains"`
+		ErrorBodyContentTypes []string `yaml:"error_body_content_types"`
+		ErrorBodyPaths        []string `yaml:"error_body_paths"`
+	} `yaml:"upstream"`
+	Background struct {
+		MaxWorkers             int `yaml:"max_workers"`
+		MaxRevalidationsPerKey int `yaml:"max_revalidations_per_key"`
+	} `yaml:"background"`
+	WebSocket struct {
+		IdleTimeout string `yaml:"idle_timeout"`
+	} `yaml:"websocket"`
+	Admin struct {
+		User      string `yaml:"user"`
+		Password  string `yaml:"password"`
+		Dashboard bool   `yaml:"dashboard"`
+		Purge     bool   `yaml:"purge"`
+
+		Maintenance bool `yaml:"maintenance"`
+	} `yaml:"admin"`
+	Health struct {
+		Path                 string `yaml:"path"`
+		Interval             string `yaml:"interval"`
+		Timeout              string `yaml:"timeout"`
+		Method               string `yaml:"method"`
+		ExpectedStatus       []int  `yaml:"expected_status"`
+		ExpectedBodyContains string `yaml:"expected_body_contains"`
+		FailureThreshold     int    `yaml:"failure_threshold"`
+	} `yaml:"health"`
+	Maintenance struct {
+		Timezone string `yaml:"timezone"`
+		Windows  []struct {
+			Start string   `yaml:"start"`
+			End   string   `yaml:"end"`
+			Days  []string `yaml:"days"`
+		} `yaml:"windows"`
+		Paths []struct {
+			Prefix      string `yaml:"prefix"`
+			Status      int    `yaml:"status"`
+			Body        string `yaml:"body"`
+			ContentType string `yaml:"content_type"`
+			Enabled     *bool  `yaml:"enabled"`
+		} `yaml:"paths"`
+	} `yaml:"maintenance"`
 }
 
 // Load loads configuration from YAML file
 func Load() *Config {
 	configPath := flag.String("config", "config.yaml", "path to config file")
+	configMultiple := flag.String("config-multiple", "first",
+		"behavior when several config files exist: first, merge or error")
 	flag.Parse()
 
 	// Load config file
-	fileConfig, err := loadConfigFile(*configPath)
+	fileConfig, err := loadConfigFile(*configPath, *configMultiple)
 	if err != nil {
 		log.Fatalf("failed to load config: %v", err)
 	}
@@ -68,12 +686,540 @@ func Load() *Config {
 	if err != nil {
 		log.Fatalf("invalid timeout in config: %v", err)
 	}
+	if fileConfig.Server.Retries < 0 {
+		log.Fatalf("invalid server.retries in config: %d (expected 0 or more)", fileConfig.Server.Retries)
+	}
+	retryBackoff, err := parseDuration(fileConfig.Server.RetryBackoff, 100*time.Millisecond)
+	if err != nil || retryBackoff <= 0 {
+		log.Fatalf("invalid server.retry_backoff in config: %q (expected a duration such as 100ms)", fileConfig.Server.RetryBackoff)
+	}
 
 	ttl, err := parseDuration(fileConfig.Cache.TTL, 0)
 	if err != nil {
 		log.Fatalf("invalid ttl in config: %v", err)
 	}
 
+	instanceID := fileConfig.Server.InstanceID
+	if instanceID == "" {
+		instanceID = os.Getenv("AEGIS_INSTANCE_ID")
+	}
+	instanceHeader := fileConfig.Server.InstanceHeader
+	if instanceHeader == "" {
+		instanceHeader = "X-Aegis-Instance"
+	}
+
+	statusHeader := fileConfig.Cache.StatusHeader
+	switch statusHeader {
+	case "":
+		statusHeader = "x-cache"
+	case "x-cache", "cache-status", "both":
+	default:
+		log.Fatalf("invalid cache.status_header in config: %q (expected x-cache, cache-status or both)", statusHeader)
+	}
+
+	getBody := fileConfig.Cache.GetBody
+	switch getBody {
+	case "":
+		getBody = "ignore"
+	case "ignore", "reject", "key":
+	default:
+		log.Fatalf("invalid cache.get_body in config: %q (expected ignore, reject or key)", getBody)
+	}
+	if len(fileConfig.Cache.GetBodyKeyFields) > 0 && getBody != "key" {
+		log.Fatalf("invalid cache.get_body_key_fields in config: requires cache.get_body: key")
+	}
+
+	if fileConfig.Cache.MaxEntries < 0 {
+		log.Fatalf("invalid cache.max_entries in config: %d (expected 0 or more)", fileConfig.Cache.MaxEntries)
+	}
+	maxMemory, err := parseByteSize(fileConfig.Cache.MaxMemory)
+	if err != nil {
+		log.Fatalf("invalid cache.max_memory in config: %v", err)
+	}
+	compressMinBytes := 0
+	if fileConfig.Cache.Compress {
+		minBytes, err := parseByteSize(fileConfig.Cache.CompressMinBytes)
+		if err != nil || minBytes > math.MaxInt32 {
+			log.Fatalf("invalid cache.compress_min_bytes in config: %q (expected a size such as 1KB)", fileConfig.Cache.CompressMinBytes)
+		}
+		compressMinBytes = int(minBytes)
+		if compressMinBytes == 0 {
+			compressMinBytes = 1024
+		}
+	}
+	staleWhileRevalidate, err := parseDuration(fileConfig.Cache.StaleWhileRevalidate, 0)
+	if err != nil || staleWhileRevalidate < 0 {
+		log.Fatalf("invalid cache.stale_while_revalidate in config: %q (expected a duration such as 30s, 0 = disabled)", fileConfig.Cache.StaleWhileRevalidate)
+	}
+
+	negativeTTL, err := parseDuration(fileConfig.Cache.NegativeTTL, 0)
+	if err != nil || negativeTTL < 0 {
+		log.Fatalf("invalid cache.negative_ttl in config: %q (expected a duration such as 30s, 0 = disabled)", fileConfig.Cache.NegativeTTL)
+	}
+	for _, status := range fileConfig.Cache.NegativeStatuses {
+		// 5xx must keep failing over to a backup instead of being cached
+		if status < 400 || status > 499 {
+			log.Fatalf("invalid cache.negative_statuses in config: %d (expected a 4xx status such as 404 or 410)", status)
+		}
+	}
+	cacheableStatus, err := parseStatusRanges(fileConfig.Cache.CacheableStatus)
+	if err != nil {
+		log.Fatalf("invalid cache.cacheable_status in config: %v", err)
+	}
+	failoverStatus, err := parseStatusRanges(fileConfig.Cache.FailoverStatus)
+	if err != nil {
+		log.Fatalf("invalid cache.failover_status in config: %v", err)
+	}
+
+	responseCompressMin := 0
+	if fileConfig.Server.CompressResponses {
+		minBytes, err := parseByteSize(fileConfig.Server.CompressMinBytes)
+		if err != nil || minBytes > math.MaxInt32 {
+			log.Fatalf("invalid server.compress_min_bytes in config: %q (expected a size such as 1KB)", fileConfig.Server.CompressMinBytes)
+		}
+		responseCompressMin = int(minBytes)
+		if responseCompressMin == 0 {
+			responseCompressMin = 1024
+		}
+	}
+
+	forwardedHeaders := true
+	if fileConfig.Server.ForwardedHeaders != nil {
+		forwardedHeaders = *fileConfig.Server.ForwardedHeaders
+	}
+
+	coalesce := true
+	if fileConfig.Cache.Coalesce != nil {
+		coalesce = *fileConfig.Cache.Coalesce
+	}
+
+	maxStale, err := parseDuration(fileConfig.Cache.MaxStale, 0)
+	if err != nil || maxStale < 0 {
+		log.Fatalf("invalid cache.max_stale in config: %q (expected a duration such as 24h, 0 = unlimited)", fileConfig.Cache.MaxStale)
+	}
+
+	sweepInterval, err := parseDuration(fileConfig.Cache.SweepInterval, time.Minute)
+	if err != nil || sweepInterval < 0 {
+		log.Fatalf("invalid cache.sweep_interval in config: %q (expected a duration such as 1m, 0 = disabled)", fileConfig.Cache.SweepInterval)
+	}
+	cacheBackend := fileConfig.Cache.Backend
+	switch cacheBackend {
+	case "":
+		cacheBackend = "memory"
+	case "memory":
+	case "disk":
+		if fileConfig.Cache.Dir == "" {
+			log.Fatalf("invalid cache.dir in config: required with cache.backend disk")
+		}
+	case "redis":
+		if fileConfig.Cache.RedisAddr == "" {
+			log.Fatalf("invalid cache.redis_addr in config: required with cache.backend redis")
+		}
+		if fileConfig.Cache.RedisDB < 0 {
+			log.Fatalf("invalid cache.redis_db in config: %d (expected 0 or more)", fileConfig.Cache.RedisDB)
+		}
+	default:
+		log.Fatalf("invalid cache.backend in config: %q (expected memory, disk or redis)", cacheBackend)
+	}
+
+	redisConfig := RedisConfig{
+		Addr:     fileConfig.Cache.RedisAddr,
+		Password: fileConfig.Cache.RedisPassword,
+		DB:       fileConfig.Cache.RedisDB,
+	}
+
+	handoffTopN := fileConfig.Cache.HandoffTopN
+	if handoffTopN < 0 {
+		log.Fatalf("invalid cache.handoff_top_n in config: %d (expected 0 or more)", handoffTopN)
+	}
+	if handoffTopN == 0 {
+		handoffTopN = 1000
+	}
+	if fileConfig.Cache.MaxEntriesPerHost > 0 && !fileConfig.Cache.KeyIncludeHost {
+		log.Fatalf("invalid cache.max_entries_per_host in config: requires cache.key_include_host")
+	}
+	oversizeBody := fileConfig.Cache.OversizeBody
+	switch oversizeBody {
+	case "":
+		oversizeBody = "stream"
+	case "stream", "reject":
+	default:
+		log.Fatalf("invalid cache.oversize_body in config: %q (expected stream or reject)", oversizeBody)
+	}
+	evictionPolicy := fileConfig.Cache.EvictionPolicy
+	switch evictionPolicy {
+	case "":
+		evictionPolicy = "lru"
+	case "lru", "slru":
+	default:
+		log.Fatalf("invalid cache.eviction_policy in config: %q (expected lru or slru)", evictionPolicy)
+	}
+
+	var preload []PreloadConfig
+	if path := fileConfig.Cache.PreloadManifest; path != "" {
+		if preload, err = readPreloadManifest(path); err != nil {
+			log.Fatalf("invalid cache.preload_manifest in config: %v", err)
+		}
+	}
+
+	countryDefault := strings.ToUpper(fileConfig.Cache.CountryDefault)
+	if countryDefault == "" {
+		countryDefault = "ZZ"
+	}
+	if len(countryDefault) != 2 || strings.Trim(countryDefault, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+		log.Fatalf("invalid cache.country_default in config: %q (expected a two-letter code)", fileConfig.Cache.CountryDefault)
+	}
+
+	timeBucket, err := parseDuration(fileConfig.Cache.TimeBucket, 0)
+	if err != nil || timeBucket < 0 {
+		log.Fatalf("invalid cache.time_bucket in config: %q", fileConfig.Cache.TimeBucket)
+	}
+
+	maxTTL, err := parseDuration(fileConfig.Cache.MaxTTL, 0)
+	if err != nil {
+		log.Fatalf("invalid cache.max_ttl in config: %v", err)
+	}
+	headerPrecedence := make([]string, 0, len(fileConfig.Cache.HeaderPrecedence))
+	for _, s := range fileConfig.Cache.HeaderPrecedence {
+		s = strings.ToLower(strings.TrimSpace(s))
+		switch s {
+		case "x-cache-ttl", "cache-control", "expires", "default":
+		default:
+			log.Fatalf("invalid cache.header_precedence in config: %q (expected x-cache-ttl, cache-control, expires or default)", s)
+		}
+		headerPrecedence = append(headerPrecedence, s)
+	}
+	if f := fileConfig.Cache.HeuristicFraction; f < 0 || f > 1 {
+		log.Fatalf("invalid cache.heuristic_fraction in config: %v (expected 0..1)", f)
+	}
+	adaptiveMin := fileConfig.Cache.AdaptiveTTLMinFactor
+	if adaptiveMin == 0 {
+		adaptiveMin = 0.5
+	}
+	if adaptiveMin < 0 || adaptiveMin > 1 {
+		log.Fatalf("invalid cache.adaptive_ttl_min_factor in config: %v (expected 0..1)", adaptiveMin)
+	}
+	adaptiveMax := fileConfig.Cache.AdaptiveTTLMaxFactor
+	if adaptiveMax == 0 {
+		adaptiveMax = 2
+	}
+	if adaptiveMax < 1 {
+		log.Fatalf("invalid cache.adaptive_ttl_max_factor in config: %v (expected >= 1)", adaptiveMax)
+	}
+
+	head := fileConfig.Cache.Head
+	switch head {
+	case "":
+		head = "separate"
+	case "separate", "none":
+	default:
+		log.Fatalf("invalid cache.head in config: %q (expected separate or none)", head)
+	}
+
+	hashKeys := fileConfig.Cache.HashKeys
+	switch hashKeys {
+	case "":
+		hashKeys = "none"
+	case "none", "sha256", "xxhash":
+	default:
+		log.Fatalf("invalid cache.hash_keys in config: %q (expected none, sha256 or xxhash)", hashKeys)
+	}
+	if fileConfig.Cache.HashKeysLongerThan < 0 {
+		log.Fatalf("invalid cache.hash_keys_longer_than in config: %d (expected 0 or more)", fileConfig.Cache.HashKeysLongerThan)
+	}
+	ttlRules := make([]TTLRuleConfig, 0, len(fileConfig.Cache.Rules))
+	for i, rule := range fileConfig.Cache.Rules {
+		if !strings.HasPrefix(rule.Path, "/") {
+			log.Fatalf("invalid cache.rules[%d].path in config: %q (expected a path starting with /)", i, rule.Path)
+		}
+		if _, err := path.Match(rule.Path, "/"); err != nil {
+			log.Fatalf("invalid cache.rules[%d].path in config: %q: %v", i, rule.Path, err)
+		}
+		ruleTTL, err := parseDuration(rule.TTL, 0)
+		if err != nil || ruleTTL <= 0 {
+			log.Fatalf("invalid cache.rules[%d].ttl in config: %q (expected a positive duration)", i, rule.TTL)
+		}
+		ttlRules = append(ttlRules, TTLRuleConfig{Path: rule.Path, TTL: ruleTTL})
+	}
+
+	backendFailure := fileConfig.Cache.BackendFailure
+	switch backendFailure {
+	case "":
+		backendFailure = "fail_open"
+	case "fail_open", "fail_closed":
+	default:
+		log.Fatalf("invalid cache.backend_failure in config: %q (expected fail_open or fail_closed)", backendFailure)
+	}
+
+	trustedProxies, err := utils.ParseCIDRs(fileConfig.Server.TrustedProxies)
+	if err != nil {
+		log.Fatalf("invalid server.trusted_proxies in config: %v", err)
+	}
+
+	breakerCooldown, err := parseDuration(fileConfig.CircuitBreaker.Cooldown, 30*time.Second)
+	if err != nil {
+		log.Fatalf("invalid circuit_breaker.cooldown in config: %v", err)
+	}
+
+	queueTimeout, err := parseDuration(fileConfig.Admission.QueueTimeout, 1*time.Second)
+	if err != nil {
+		log.Fatalf("invalid admission.queue_timeout in config: %v", err)
+	}
+	admissionPolicy := fileConfig.Admission.Policy
+	switch admissionPolicy {
+	case "":
+		admissionPolicy = "shed"
+	case "queue", "shed":
+	default:
+		log.Fatalf("invalid admission.policy in config: %q (expected queue or shed)", admissionPolicy)
+	}
+	queueDepth := fileConfig.Admission.QueueDepth
+	if queueDepth <= 0 {
+		queueDepth = 100
+	}
+
+	if fileConfig.RateLimit.Rate < 0 || fileConfig.RateLimit.Burst < 0 {
+		log.Fatalf("invalid rate_limit in config: rate and burst must not be negative")
+	}
+	for _, pl := range fileConfig.RateLimit.Paths {
+		if !strings.HasPrefix(pl.Prefix, "/") || pl.Rate < 0 || pl.Burst < 0 {
+			log.Fatalf("invalid rate_limit.paths entry in config: %+v (prefix must start with /, rate and burst not negative)", pl)
+		}
+	}
+
+	if (fileConfig.TLS.CertFile == "") != (fileConfig.TLS.KeyFile == "") {
+		log.Fatalf("invalid tls in config: cert_file and key_file must be set together")
+	}
+	if fileConfig.TLS.SessionTicketKeyFile != "" && fileConfig.TLS.CertFile == "" {
+		log.Fatalf("invalid tls.session_ticket_key_file in config: requires tls.cert_file")
+	}
+	tlsMinVersion := fileConfig.TLS.MinVersion
+	if tlsMinVersion == "" {
+		tlsMinVersion = "1.2"
+	}
+	tlsVersion, err := utils.ParseTLSVersion(tlsMinVersion)
+	if err != nil {
+		log.Fatalf("invalid tls.min_version in config: %v", err)
+	}
+	tlsCiphers, err := utils.ParseCipherSuites(fileConfig.TLS.CipherSuites)
+	if err != nil {
+		log.Fatalf("invalid tls.cipher_suites in config: %v", err)
+	}
+	tlsALPN := fileConfig.TLS.ALPN
+	if len(tlsALPN) == 0 {
+		tlsALPN = []string{"h2", "http/1.1"}
+	}
+	for _, proto := range tlsALPN {
+		if proto != "h2" && proto != "http/1.1" {
+			log.Fatalf("invalid tls.alpn in config: %q (expected h2 or http/1.1)", proto)
+		}
+	}
+	ticketReload, err := parseDuration(fileConfig.TLS.SessionTicketReload, 0)
+	if err != nil || ticketReload < 0 {
+		log.Fatalf("invalid tls.session_ticket_reload in config: %q", fileConfig.TLS.SessionTicketReload)
+	}
+	if ticketReload > 0 && fileConfig.TLS.SessionTicketKeyFile == "" {
+		log.Fatalf("invalid tls.session_ticket_reload in config: requires tls.session_ticket_key_file")
+	}
+
+	eventsTimeout, err := parseDuration(fileConfig.Events.Timeout, 5*time.Second)
+	if err != nil {
+		log.Fatalf("invalid events.timeout in config: %v", err)
+	}
+	if fileConfig.Events.Enabled {
+		if fileConfig.Events.WebhookURL == "" && fileConfig.Events.LogFile == "" {
+			log.Fatalf("invalid events in config: enabled requires webhook_url or log_file")
+		}
+		if wh := fileConfig.Events.WebhookURL; wh != "" {
+			if u, err := url.Parse(wh); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				log.Fatalf("invalid events.webhook_url in config: %q", wh)
+			}
+		}
+	}
+	eventsQueueSize := fileConfig.Events.QueueSize
+	if eventsQueueSize <= 0 {
+		eventsQueueSize = 1000
+	}
+
+	idleConnTimeout, err := parseDuration(fileConfig.Transport.IdleConnTimeout, 90*time.Second)
+	if err != nil {
+		log.Fatalf("invalid transport.idle_conn_timeout in config: %v", err)
+	}
+	if op := fileConfig.Transport.OutboundProxy; op != "" && op != "direct" {
+		if u, err := url.Parse(op); err != nil || u.Host == "" {
+			log.Fatalf("invalid transport.outbound_proxy in config: %q (expected a proxy URL or \"direct\")", op)
+		}
+	}
+
+	statsLogInterval, err := parseDuration(fileConfig.Stats.LogInterval, 0)
+	if err != nil {
+		log.Fatalf("invalid stats.log_interval in config: %v", err)
+	}
+
+	shadowRate := 1.0
+	if fileConfig.Shadow.SampleRate != nil {
+		shadowRate = *fileConfig.Shadow.SampleRate
+	}
+	if shadowRate < 0 || shadowRate > 1 {
+		log.Fatalf("invalid shadow.sample_rate in config: %v (expected 0..1)", shadowRate)
+	}
+	// Error pages are usually HTML; an explicit empty list checks every type
+	errorBodyTypes := fileConfig.Upstream.ErrorBodyContentTypes
+	if errorBodyTypes == nil {
+		errorBodyTypes = []string{"text/html"}
+	}
+	for _, s := range fileConfig.Upstream.ErrorBodyContains {
+		if s == "" {
+			log.Fatalf("invalid upstream.error_body_contains in config: empty string matches every body")
+		}
+	}
+
+	shadowTimeout, err := parseDuration(fileConfig.Shadow.Timeout, 5*time.Second)
+	if err != nil {
+		log.Fatalf("invalid shadow.timeout in config: %v", err)
+	}
+
+	nonStandardMethods := fileConfig.Server.NonStandardMethods
+	switch nonStandardMethods {
+	case "":
+		nonStandardMethods = "forward"
+	case "forward", "reject", "normalize":
+	default:
+		log.Fatalf("invalid server.non_standard_methods in config: %q (expected forward, reject or normalize)", nonStandardMethods)
+	}
+
+	servedBy := "Aegis"
+	if fileConfig.Server.ServedByHeader != nil {
+		servedBy = *fileConfig.Server.ServedByHeader
+	}
+
+	// X-Cache: no-store unless configured; a custom header matches any value by default
+	bypassHeader, bypassValue := "X-Cache", "no-store"
+	if fileConfig.Cache.BypassHeader != nil {
+		bypassHeader, bypassValue = *fileConfig.Cache.BypassHeader, ""
+	}
+	if fileConfig.Cache.BypassHeaderValue != nil {
+		bypassValue = *fileConfig.Cache.BypassHeaderValue
+	}
+
+	healthInterval, err := parseDuration(fileConfig.Health.Interval, 0)
+	if err != nil {
+		log.Fatalf("invalid health.interval in config: %v", err)
+	}
+	healthTimeout, err := parseDuration(fileConfig.Health.Timeout, 2*time.Second)
+	if err != nil {
+		log.Fatalf("invalid health.timeout in config: %v", err)
+	}
+	healthPath := fileConfig.Health.Path
+	if healthPath == "" {
+		healthPath = "/"
+	}
+	if !strings.HasPrefix(healthPath, "/") {
+		log.Fatalf("invalid health.path in config: %q (expected an absolute path)", healthPath)
+	}
+	healthMethod := strings.ToUpper(fileConfig.Health.Method)
+	if healthMethod == "" {
+		healthMethod = "GET"
+	}
+	for _, status := range fileConfig.Health.ExpectedStatus {
+		if status < 100 || status > 599 {
+			log.Fatalf("invalid health.expected_status in config: %d", status)
+		}
+	}
+	if fileConfig.Health.FailureThreshold < 0 {
+		log.Fatalf("invalid health.failure_threshold in config: %d (expected 0 or more)", fileConfig.Health.FailureThreshold)
+	}
+
+	maintenanceLoc := time.UTC
+	if tz := fileConfig.Maintenance.Timezone; tz != "" {
+		if maintenanceLoc, err = time.LoadLocation(tz); err != nil {
+			log.Fatalf("invalid maintenance.timezone in config: %v", err)
+		}
+	}
+	maintenanceWindows := make([]MaintenanceWindowConfig, 0, len(fileConfig.Maintenance.Windows))
+	for i, w := range fileConfig.Maintenance.Windows {
+		start, err := parseClock(w.Start)
+		if err != nil {
+			log.Fatalf("invalid maintenance.windows[%d].start in config: %v", i, err)
+		}
+		end, err := parseClock(w.End)
+		if err != nil {
+			log.Fatalf("invalid maintenance.windows[%d].end in config: %v", i, err)
+		}
+		if start == end {
+			log.Fatalf("invalid maintenance.windows[%d] in config: empty window", i)
+		}
+		days := make([]time.Weekday, 0, len(w.Days))
+		for _, d := range w.Days {
+			day, err := parseWeekday(d)
+			if err != nil {
+				log.Fatalf("invalid maintenance.windows[%d].days in config: %v", i, err)
+			}
+			days = append(days, day)
+		}
+		maintenanceWindows = append(maintenanceWindows, MaintenanceWindowConfig{Start: start, End: end, Days: days})
+	}
+	maintenancePaths := make([]MaintenancePathConfig, 0, len(fileConfig.Maintenance.Paths))
+	for i, mp := range fileConfig.Maintenance.Paths {
+		if !strings.HasPrefix(mp.Prefix, "/") {
+			log.Fatalf("invalid maintenance.paths[%d].prefix in config: %q (expected a path starting with /)", i, mp.Prefix)
+		}
+		status := mp.Status
+		if status == 0 {
+			status = 503
+		}
+		if status < 200 || status > 599 {
+			log.Fatalf("invalid maintenance.paths[%d].status in config: %d", i, status)
+		}
+		enabled := mp.Enabled == nil || *mp.Enabled
+		maintenancePaths = append(maintenancePaths, MaintenancePathConfig{
+			Prefix: mp.Prefix, Status: status, Body: mp.Body, ContentType: mp.ContentType, Enabled: enabled,
+		})
+	}
+
+	backgroundWorkers := fileConfig.Background.MaxWorkers
+	if backgroundWorkers <= 0 {
+		backgroundWorkers = 16
+	}
+	revalidationsPerKey := fileConfig.Background.MaxRevalidationsPerKey
+	if revalidationsPerKey < 0 {
+		log.Fatalf("invalid background.max_revalidations_per_key in config: %d (expected 0 or more)", revalidationsPerKey)
+	}
+	if revalidationsPerKey == 0 {
+		revalidationsPerKey = 1
+	}
+
+	wsIdleTimeout, err := parseDuration(fileConfig.WebSocket.IdleTimeout, 5*time.Minute)
+	if err != nil {
+		log.Fatalf("invalid websocket.idle_timeout in config: %v", err)
+	}
+
+	adminUser := fileConfig.Admin.User
+	if adminUser == "" {
+		adminUser = "admin"
+	}
+	if fileConfig.Admin.Dashboard && fileConfig.Admin.Password == "" {
+		log.Fatalf("invalid admin config: dashboard requires admin.password")
+	}
+	if fileConfig.Admin.Purge && fileConfig.Admin.Password == "" {
+		log.Fatalf("invalid admin config: purge requires admin.password")
+	}
+	if fileConfig.Admin.Maintenance && fileConfig.Admin.Password == "" {
+		log.Fatalf("invalid admin config: maintenance requires admin.password")
+	}
+	if fileConfig.Diagnostics.CaptureErrorsN > 0 && fileConfig.Admin.Password == "" {
+		log.Fatalf("invalid admin config: diagnostics.capture_errors_n requires admin.password")
+	}
+
+	transformTimeout, err := parseDuration(fileConfig.Transform.Timeout, 1*time.Second)
+	if err != nil {
+		log.Fatalf("invalid transform.timeout in config: %v", err)
+	}
+	transformMaxBytes := fileConfig.Transform.MaxBytes
+	if transformMaxBytes <= 0 {
+		transformMaxBytes = 1 << 20
+	}
+
 	// Set logging defaults
 	loggingEnabled := fileConfig.Logging.Enabled
 	accessLog := fileConfig.Logging.AccessLog
@@ -82,65 +1228,331 @@ func Load() *Config {
 		logLevel = "info"
 	}
 
+	var upstream string
+	var extraUpstreams []string
+	for i, u := range fileConfig.Server.Upstream {
+		if strings.TrimSpace(u) == "" {
+			log.Fatalf("invalid server.upstream[%d] in config: empty URL", i)
+		}
+	}
+	if len(fileConfig.Server.Upstream) > 0 {
+		upstream = fileConfig.Server.Upstream[0]
+		extraUpstreams = fileConfig.Server.Upstream[1:]
+	}
+
 	return &Config{
-		Listen:   fileConfig.Server.Listen,
-		Upstream: fileConfig.Server.Upstream,
-		Timeout:  timeout,
-		TTL:      ttl,
+		Listen:         fileConfig.Server.Listen,
+		Upstream:       upstream,
+		ExtraUpstreams: extraUpstreams,
+		Timeout:        timeout,
+		TTL:            ttl,
 		Cache: CacheConfig{
-			KeyHeaders: fileConfig.Cache.KeyHeaders,
+			KeyHeaders:       fileConfig.Cache.KeyHeaders,
+			KeyIncludeScheme: fileConfig.Cache.KeyIncludeScheme,
+			KeyIncludeHost:   fileConfig.Cache.KeyIncludeHost,
+			VaryKeyHeaders:   fileConfig.Cache.VaryKeyHeaders,
+			Preload:          preload,
+			PreloadStrict:    fileConfig.Cache.PreloadStrict,
+			TimeBucket:       timeBucket,
+			CountryHeader:    fileConfig.Cache.CountryHeader,
+			CountryDefault:   countryDefault,
+			StatusHeader:     statusHeader,
+			GetBody:          getBody,
+			GetBodyKeyFields: fileConfig.Cache.GetBodyKeyFields,
+			BypassHeader:     bypassHeader,
+			BypassValue:      bypassValue,
+			MinBodyBytes:     fileConfig.Cache.MinBodyBytes,
+			MaxBodyBytes:     fileConfig.Cache.MaxBodyBytes,
+			OversizeBody:     oversizeBody,
+			SkipEmptyBody:    fileConfig.Cache.SkipEmptyBody,
+			Coalesce:         coalesce,
+			HashKeys:         hashKeys,
+
+			HashKeysLongerThan: fileConfig.Cache.HashKeysLongerThan,
+			Rules:              ttlRules,
+
+			MaxEntries:            fileConfig.Cache.MaxEntries,
+			MaxMemory:             maxMemory,
+			CompressMinBytes:      compressMinBytes,
+			MaxEntriesPerHost:     fileConfig.Cache.MaxEntriesPerHost,
+			EvictionPolicy:        evictionPolicy,
+			SweepInterval:         sweepInterval,
+			StaleWhileRevalidate:  staleWhileRevalidate,
+			MaxStale:              maxStale,
+			NegativeTTL:           negativeTTL,
+			NegativeStatuses:      fileConfig.Cache.NegativeStatuses,
+			CacheableStatus:       cacheableStatus,
+			FailoverStatus:        failoverStatus,
+			Backend:               cacheBackend,
+			Dir:                   fileConfig.Cache.Dir,
+			Redis:                 redisConfig,
+			HandoffPath:           fileConfig.Cache.HandoffPath,
+			HandoffTopN:           handoffTopN,
+			IgnoreQueryParams:     fileConfig.Cache.IgnoreQueryParams,
+			IgnoreAnalyticsParams: fileConfig.Cache.IgnoreAnalyticsParams,
+
+			MaxKeyHeaderValueBytes: fileConfig.Cache.MaxKeyHeaderValueBytes,
+			StreamThresholdBytes:   fileConfig.Cache.StreamThresholdBytes,
+
+			BackendFailure: backendFailure,
+			BackendRetries: fileConfig.Cache.BackendRetries,
+
+			AllowSharedAuthBackup: fileConfig.Cache.AllowSharedAuthBackup,
+			HeuristicFraction:     fileConfig.Cache.HeuristicFraction,
+			MaxTTL:                maxTTL,
+			HeaderPrecedence:      headerPrecedence,
+			RespectOriginTTL:      fileConfig.Cache.RespectOriginTTL,
+			ETagRevalidation:      fileConfig.Cache.ETagRevalidation,
+			Head:                  head,
+			Immutable:             fileConfig.Cache.Immutable,
+			AllowTTLRequestHeader: fileConfig.Cache.AllowTTLRequestHeader,
+			HonorPragma:           fileConfig.Cache.HonorPragma,
+			FailoverOn429:         fileConfig.Cache.FailoverOn429,
+			VerifyChecksums:       fileConfig.Cache.VerifyChecksums,
+			AdaptiveTTL:           fileConfig.Cache.AdaptiveTTL,
+			AdaptiveTTLMinFactor:  adaptiveMin,
+			AdaptiveTTLMaxFactor:  adaptiveMax,
 		},
+		TrustedProxies:            trustedProxies,
+		ForwardedHeaders:          forwardedHeaders,
+		CompressMinBytes:          responseCompressMin,
+		HonorRequestTimeoutHeader: fileConfig.Server.HonorRequestTimeoutHeader,
+		Retries:                   fileConfig.Server.Retries,
+		RetryBackoff:              retryBackoff,
+		InstanceID:                instanceID,
+		InstanceHeader:            instanceHeader,
+		DefaultHost:               fileConfig.Server.DefaultHost,
+		NonStandardMethods:        nonStandardMethods,
+		ServedBy:                  servedBy,
 		Logging: LoggingConfig{
 			Enabled:   loggingEnabled,
 			AccessLog: accessLog,
 			Level:     logLevel,
+
+			AccessExcludePaths: fileConfig.Logging.AccessExcludePaths,
+			AccessLogErrors:    fileConfig.Logging.AccessLogErrors,
+
+			DumpRequestBody: BodyDumpConfig{
+				MaxBytes:     fileConfig.Logging.DumpRequestBody.MaxBytes,
+				ContentTypes: fileConfig.Logging.DumpRequestBody.ContentTypes,
+				Paths:        fileConfig.Logging.DumpRequestBody.Paths,
+				RedactFields: fileConfig.Logging.DumpRequestBody.RedactFields,
+			},
+		},
+		CircuitBreaker: CircuitBreakerConfig{
+			FailureThreshold: fileConfig.CircuitBreaker.FailureThreshold,
+			Cooldown:         breakerCooldown,
+		},
+		Readiness: ReadinessConfig{
+			ReadyWithCache: fileConfig.Readiness.ReadyWithCache,
+		},
+		Admission: AdmissionConfig{
+			MaxInFlight:  fileConfig.Admission.MaxInFlight,
+			Policy:       admissionPolicy,
+			QueueDepth:   queueDepth,
+			QueueTimeout: queueTimeout,
+		},
+		TLS: TLSConfig{
+			CertFile:             fileConfig.TLS.CertFile,
+			KeyFile:              fileConfig.TLS.KeyFile,
+			MinVersion:           tlsVersion,
+			ALPN:                 tlsALPN,
+			CipherSuites:         tlsCiphers,
+			SessionTicketKeyFile: fileConfig.TLS.SessionTicketKeyFile,
+			SessionTicketReload:  ticketReload,
+		},
+		Events: EventsConfig{
+			Enabled:    fileConfig.Events.Enabled,
+			WebhookURL: fileConfig.Events.WebhookURL,
+			LogFile:    fileConfig.Events.LogFile,
+			QueueSize:  eventsQueueSize,
+			Timeout:    eventsTimeout,
+		},
+		RateLimit: RateLimitConfig{
+			Rate:  fileConfig.RateLimit.Rate,
+			Burst: fileConfig.RateLimit.Burst,
+			Paths: fileConfig.RateLimit.Paths,
+		},
+		Routing: RoutingConfig{
+			Rewrites:           fileConfig.Routing.Rewrites,
+			NoopPaths:          fileConfig.Routing.NoopPaths,
+			CanonicalizePath:   fileConfig.Routing.CanonicalizePath,
+			DecodeEncodedSlash: fileConfig.Routing.DecodeEncodedSlash,
+		},
+		Diagnostics: DiagnosticsConfig{
+			CaptureErrorsN: fileConfig.Diagnostics.CaptureErrorsN,
+		},
+		Transport: TransportConfig{
+			IdleConnTimeout:   idleConnTimeout,
+			DisableKeepAlives: fileConfig.Transport.DisableKeepAlives,
+			OutboundProxy:     fileConfig.Transport.OutboundProxy,
+		},
+		Stats: StatsConfig{
+			LogInterval: statsLogInterval,
+			Savings:     fileConfig.Stats.Savings,
+		},
+		Security: SecurityConfig{
+			AllowedUpstreamHosts: fileConfig.Security.AllowedUpstreamHosts,
+		},
+		Transform: TransformConfig{
+			Command:      fileConfig.Transform.Command,
+			ContentTypes: fileConfig.Transform.ContentTypes,
+			Timeout:      transformTimeout,
+			MaxBytes:     transformMaxBytes,
+		},
+		Shadow: ShadowConfig{
+			Upstream:   fileConfig.Shadow.Upstream,
+			SampleRate: shadowRate,
+			Paths:      fileConfig.Shadow.Paths,
+			Sticky:     fileConfig.Shadow.Sticky,
+			Timeout:    shadowTimeout,
+		},
+		ErrorBody: ErrorBodyConfig{
+			Contains:     fileConfig.Upstream.ErrorBodyContains,
+			ContentTypes: errorBodyTypes,
+			Paths:        fileConfig.Upstream.ErrorBodyPaths,
+		},
+		Health: HealthConfig{
+			Path:                 healthPath,
+			Interval:             healthInterval,
+			Timeout:              healthTimeout,
+			Method:               healthMethod,
+			ExpectedStatus:       fileConfig.Health.ExpectedStatus,
+			ExpectedBodyContains: fileConfig.Health.ExpectedBodyContains,
+			FailureThreshold:     fileConfig.Health.FailureThreshold,
+		},
+		Maintenance: MaintenanceConfig{
+			Location: maintenanceLoc,
+			Windows:  maintenanceWindows,
+			Paths:    maintenancePaths,
+		},
+		Background: BackgroundConfig{
+			MaxWorkers:             backgroundWorkers,
+			MaxRevalidationsPerKey: revalidationsPerKey,
+		},
+		WebSocket: WebSocketConfig{
+			IdleTimeout: wsIdleTimeout,
+		},
+		Admin: AdminConfig{
+			User:      adminUser,
+			Password:  fileConfig.Admin.Password,
+			Dashboard: fileConfig.Admin.Dashboard,
+			Purge:     fileConfig.Admin.Purge,
+
+			Maintenance: fileConfig.Admin.Maintenance,
+		},
+		Debug: DebugConfig{
+			Enabled:      fileConfig.Debug.Enabled,
+			ServerTiming: fileConfig.Debug.ServerTiming,
 		},
 	}
 }
 
-func loadConfigFile(path string) (FileConfig, error) {
-	var fc FileConfig
-
-	// Try specified path first
-	if path != "" {
-		if fileExists(path) {
-			data, err := os.ReadFile(path)
-			if err != nil {
-				return fc, fmt.Errorf("failed to read config file %s: %w", path, err)
-			}
+// defaultPaths are searched, in priority order, after the -config path
+var defaultPaths = []string{"config.yaml", "aegis.yaml", "/etc/aegis/config.yaml"}
 
-			if err := yaml.Unmarshal(data, &fc); err != nil {
-				return fc, fmt.Errorf("failed to parse config file %s: %w", path, err)
-			}
+// loadConfigFile reads the config from the -config path and the default
+// locations. multiple controls what happens when more than one file exists:
+// "first" uses the highest-priority file, "merge" applies all of them in
+// search order with later files overriding earlier ones, and "error" refuses
+// to start.
+func loadConfigFile(path, multiple string) (FileConfig, error) {
+	var fc FileConfig
 
-			log.Printf("loaded config from %s", path)
-			return fc, nil
+	candidates := []string{}
+	for _, p := range append([]string{path}, defaultPaths...) {
+		if p != "" && !slices.Contains(candidates, p) {
+			candidates = append(candidates, p)
 		}
 	}
-
-	// Fallback: try default locations
-	defaultPaths := []string{"config.yaml", "aegis.yaml", "/etc/aegis/config.yaml"}
-	for _, p := range defaultPaths {
-		if !fileExists(p) {
-			continue
+	var found []string
+	for _, p := range candidates {
+		if fileExists(p) {
+			found = append(found, p)
 		}
+	}
+	log.Printf("config files considered: %v, found: %v", candidates, found)
+	if len(found) == 0 {
+		return fc, fmt.Errorf("no config file found (tried: %v)", candidates)
+	}
 
-		data, err := os.ReadFile(p)
-		if err != nil {
-			log.Printf("warning: failed to read config file %s: %v", p, err)
-			continue
+	switch multiple {
+	case "first":
+		// An explicitly requested file must be valid; defaults are best-effort
+		for _, p := range found {
+			err := readConfigFile(p, &fc)
+			if err == nil {
+				log.Printf("loaded config from %s", p)
+				return fc, nil
+			}
+			if p == path {
+				return fc, err
+			}
+			log.Printf("warning: %v", err)
+			fc = FileConfig{}
 		}
-
-		if err := yaml.Unmarshal(data, &fc); err != nil {
-			log.Printf("warning: failed to parse config file %s: %v", p, err)
-			continue
+		return fc, fmt.Errorf("no usable config file found (tried: %v)", found)
+	case "merge":
+		// Apply in search order so later files override earlier ones
+		for _, p := range found {
+			if err := readConfigFile(p, &fc); err != nil {
+				return fc, err
+			}
 		}
-
-		log.Printf("loaded config from %s", p)
+		log.Printf("loaded merged config from %v (%s wins on conflicts)", found, found[len(found)-1])
+		return fc, nil
+	case "error":
+		if len(found) > 1 {
+			return fc, fmt.Errorf("multiple config files found: %v", found)
+		}
+		if err := readConfigFile(found[0], &fc); err != nil {
+			return fc, err
+		}
+		log.Printf("loaded config from %s", found[0])
 		return fc, nil
+	default:
+		return fc, fmt.Errorf("invalid -config-multiple %q (expected first, merge or error)", multiple)
 	}
+}
 
-	return fc, fmt.Errorf("no config file found (tried: %s, %v)", path, defaultPaths)
+// readConfigFile decodes path on top of fc; keys absent from the file keep
+// their current values
+func readConfigFile(path string, fc *FileConfig) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+	if err := yaml.Unmarshal(data, fc); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// readPreloadManifest reads a YAML list of {url, ttl} preload entries
+func readPreloadManifest(path string) ([]PreloadConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw []struct {
+		URL string `yaml:"url"`
+		TTL string `yaml:"ttl"`
+	}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	entries := make([]PreloadConfig, 0, len(raw))
+	for i, e := range raw {
+		if !strings.HasPrefix(e.URL, "/") {
+			return nil, fmt.Errorf("%s: entry %d: url %q must start with /", path, i, e.URL)
+		}
+		ttl, err := parseDuration(e.TTL, 0)
+		if err != nil {
+			return nil, fmt.Errorf("%s: entry %d: ttl: %w", path, i, err)
+		}
+		entries = append(entries, PreloadConfig{URL: e.URL, TTL: ttl})
+	}
+	return entries, nil
 }
 
 func fileExists(path string) bool {
@@ -154,3 +1566,86 @@ func parseDuration(value string, defaultValue time.Duration) (time.Duration, err
 	}
 	return time.ParseDuration(value)
 }
+
+// stringList is a YAML value given either as one string or as a list
+type stringList []string
+
+func (l stringList) String() string {
+	return strings.Join(l, ", ")
+}
+
+func (l *stringList) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*l = stringList{value.Value}
+		return nil
+	}
+	var list []string
+	if err := value.Decode(&list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+// parseStatusRanges parses status codes such as "301" and inclusive
+// ranges such as "200-299"
+func parseStatusRanges(values []string) ([]StatusRange, error) {
+	ranges := make([]StatusRange, 0, len(values))
+	for _, value := range values {
+		lo, hi, isRange := strings.Cut(strings.TrimSpace(value), "-")
+		if !isRange {
+			hi = lo
+		}
+		minStatus, err1 := strconv.Atoi(strings.TrimSpace(lo))
+		maxStatus, err2 := strconv.Atoi(strings.TrimSpace(hi))
+		if err1 != nil || err2 != nil || minStatus < 100 || maxStatus > 599 || minStatus > maxStatus {
+			return nil, fmt.Errorf("%q (expected a status such as 301 or a range such as 200-299)", value)
+		}
+		ranges = append(ranges, StatusRange{Min: minStatus, Max: maxStatus})
+	}
+	return ranges, nil
+}
+
+// parseByteSize parses a size such as "512", "64KB", "128MB" or "1GB"
+// (binary multiples) into bytes
+func parseByteSize(value string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(value))
+	if s == "" {
+		return 0, nil
+	}
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		size   int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}} {
+		if n, ok := strings.CutSuffix(s, unit.suffix); ok {
+			s, multiplier = strings.TrimSpace(n), unit.size
+			break
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%q is not a byte size", value)
+	}
+	return n * multiplier, nil
+}
+
+// parseClock parses a "HH:MM" time of day into an offset from midnight
+func parseClock(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a HH:MM time", value)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// parseWeekday parses a weekday name or its three-letter abbreviation
+func parseWeekday(value string) (time.Weekday, error) {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		name := d.String()
+		if strings.EqualFold(value, name) || strings.EqualFold(value, name[:3]) {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("%q is not a weekday", value)
+}
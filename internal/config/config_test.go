@@ -0,0 +1,162 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// withConfigFiles writes files into a temp dir and points the default search
+// paths at them, returning the paths in priority order
+func withConfigFiles(t *testing.T, contents ...string) []string {
+	t.Helper()
+	dir := t.TempDir()
+	var paths []string
+	for i, c := range contents {
+		p := filepath.Join(dir, "config"+string(rune('a'+i))+".yaml")
+		if err := os.WriteFile(p, []byte(c), 0o644); err != nil {
+			t.Fatalf("failed to write %s: %v", p, err)
+		}
+		paths = append(paths, p)
+	}
+	saved := defaultPaths
+	defaultPaths = paths
+	t.Cleanup(func() { defaultPaths = saved })
+	return paths
+}
+
+func TestLoadConfigFileFirstWins(t *testing.T) {
+	withConfigFiles(t,
+		"server:\n  upstream: http://first\n",
+		"server:\n  upstream: http://second\n  listen: :9000\n")
+
+	fc, err := loadConfigFile("", "first")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fc.Server.Upstream.String() != "http://first" || fc.Server.Listen != "" {
+		t.Errorf("expected only the first file to be used, got %+v", fc.Server)
+	}
+}
+
+func TestLoadConfigFileMerge(t *testing.T) {
+	withConfigFiles(t,
+		"server:\n  upstream: http://base\n  listen: :9000\ncache:\n  ttl: 5m\n  key_headers: [X-B, X-C]\n",
+		"server:\n  upstream: http://override\ncache:\n  key_headers: [X-A]\n")
+
+	fc, err := loadConfigFile("", "merge")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fc.Server.Upstream.String() != "http://override" {
+		t.Errorf("expected the later file's upstream, got %q", fc.Server.Upstream)
+	}
+	if fc.Server.Listen != ":9000" || fc.Cache.TTL != "5m" {
+		t.Errorf("expected keys missing from the override to be kept, got %+v / %q", fc.Server, fc.Cache.TTL)
+	}
+	if len(fc.Cache.KeyHeaders) != 1 || fc.Cache.KeyHeaders[0] != "X-A" {
+		t.Errorf("expected lists to be replaced, got %v", fc.Cache.KeyHeaders)
+	}
+}
+
+func TestLoadConfigFileErrorOnMultiple(t *testing.T) {
+	paths := withConfigFiles(t, "server:\n  upstream: http://a\n", "server:\n  upstream: http://b\n")
+
+	_, err := loadConfigFile("", "error")
+	if err == nil || !strings.Contains(err.Error(), "multiple config files") {
+		t.Fatalf("expected multiple-files error, got %v", err)
+	}
+
+	os.Remove(paths[1])
+	fc, err := loadConfigFile("", "error")
+	if err != nil {
+		t.Fatalf("unexpected error with a single file: %v", err)
+	}
+	if fc.Server.Upstream.String() != "http://a" {
+		t.Errorf("expected upstream from the single file, got %q", fc.Server.Upstream)
+	}
+}
+
+func TestLoadConfigFileExplicitPathTakesPriority(t *testing.T) {
+	paths := withConfigFiles(t, "server:\n  upstream: http://explicit\n", "server:\n  upstream: http://default\n")
+	defaultPaths = paths[1:]
+
+	fc, err := loadConfigFile(paths[0], "first")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fc.Server.Upstream.String() != "http://explicit" {
+		t.Errorf("expected -config path to win, got %q", fc.Server.Upstream)
+	}
+}
+
+func TestParseClockAndWeekday(t *testing.T) {
+	if d, err := parseClock("02:30"); err != nil || d != 2*time.Hour+30*time.Minute {
+		t.Errorf("expected 2h30m, got %v (%v)", d, err)
+	}
+	if _, err := parseClock("25:00"); err == nil {
+		t.Error("expected error for invalid time")
+	}
+	for _, name := range []string{"sat", "Saturday", "SAT"} {
+		if d, err := parseWeekday(name); err != nil || d != time.Saturday {
+			t.Errorf("%q: expected Saturday, got %v (%v)", name, d, err)
+		}
+	}
+	if _, err := parseWeekday("someday"); err == nil {
+		t.Error("expected error for invalid weekday")
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	for value, want := range map[string]int64{
+		"":       0,
+		"512":    512,
+		"64KB":   64 << 10,
+		"128MB":  128 << 20,
+		"1 gb":   1 << 30,
+		"2048 B": 2048,
+	} {
+		if got, err := parseByteSize(value); err != nil || got != want {
+			t.Errorf("%q: expected %d, got %d (%v)", value, want, got, err)
+		}
+	}
+	for _, value := range []string{"MB", "12TB", "-1", "1.5MB"} {
+		if _, err := parseByteSize(value); err == nil {
+			t.Errorf("%q: expected an error", value)
+		}
+	}
+}
+
+func TestParseStatusRanges(t *testing.T) {
+	got, err := parseStatusRanges([]string{"200-299", "301", " 502 - 503 "})
+	want := []StatusRange{{200, 299}, {301, 301}, {502, 503}}
+	if err != nil || !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v (%v)", want, got, err)
+	}
+	for _, value := range []string{"", "2xx", "99", "600", "300-200", "200-"} {
+		if _, err := parseStatusRanges([]string{value}); err == nil {
+			t.Errorf("%q: expected an error", value)
+		}
+	}
+}
+
+func TestUpstreamStringOrList(t *testing.T) {
+	var fc FileConfig
+	if err := yaml.Unmarshal([]byte("server:\n  upstream: http://a\n"), &fc); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(fc.Server.Upstream, stringList{"http://a"}) {
+		t.Errorf("expected a single upstream, got %v", fc.Server.Upstream)
+	}
+	if err := yaml.Unmarshal([]byte("server:\n  upstream: [http://a, http://b]\n"), &fc); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(fc.Server.Upstream, stringList{"http://a", "http://b"}) {
+		t.Errorf("expected two upstreams, got %v", fc.Server.Upstream)
+	}
+}
@@ -1,8 +1,11 @@
 package logger
 
 import (
+	"bufio"
 	"log"
+	"net"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -11,6 +14,9 @@ type Logger struct {
 	enabled   bool
 	accessLog bool
 	level     string
+
+	accessExclude     []string // Paths without access-log lines
+	logExcludedErrors bool     // Still log >= 400 responses on excluded paths
 }
 
 // New creates a new logger instance
@@ -22,9 +28,40 @@ func New(enabled, accessLog bool, level string) *Logger {
 	}
 }
 
+// ExcludeAccessPaths suppresses access-log lines for the given paths. An
+// entry ending in "*" matches a path prefix, any other entry the exact
+// path. With logErrors, responses with status >= 400 are still logged.
+func (l *Logger) ExcludeAccessPaths(paths []string, logErrors bool) {
+	l.accessExclude = paths
+	l.logExcludedErrors = logErrors
+}
+
+// accessExcluded reports whether an access line for path and status is
+// suppressed
+func (l *Logger) accessExcluded(path string, status int) bool {
+	if l.logExcludedErrors && status >= http.StatusBadRequest {
+		return false
+	}
+	for _, p := range l.accessExclude {
+		if prefix, ok := strings.CutSuffix(p, "*"); ok {
+			if strings.HasPrefix(path, prefix) {
+				return true
+			}
+		} else if path == p {
+			return true
+		}
+	}
+	return false
+}
+
+// DebugEnabled reports whether debug messages are emitted
+func (l *Logger) DebugEnabled() bool {
+	return l.enabled && l.level == "debug"
+}
+
 // Debug logs a debug message
 func (l *Logger) Debug(format string, v ...interface{}) {
-	if !l.enabled || (l.level != "debug") {
+	if !l.DebugEnabled() {
 		return
 	}
 	log.Printf("[DEBUG] "+format, v...)
@@ -46,24 +83,54 @@ func (l *Logger) Error(format string, v ...interface{}) {
 	log.Printf("[ERROR] "+format, v...)
 }
 
-// responseWriter wraps http.ResponseWriter to capture status code
+// responseWriter wraps http.ResponseWriter to capture status code and the
+// number of body bytes actually sent
 type responseWriter struct {
 	http.ResponseWriter
-	statusCode int
-	written    int64
+	statusCode  int
+	written     int64
+	wroteHeader bool
 }
 
+// WriteHeader records the first status only; later calls are no-ops in
+// net/http as well
 func (rw *responseWriter) WriteHeader(code int) {
-	rw.statusCode = code
+	if !rw.wroteHeader {
+		rw.statusCode = code
+		rw.wroteHeader = true
+	}
 	rw.ResponseWriter.WriteHeader(code)
 }
 
 func (rw *responseWriter) Write(b []byte) (int, error) {
+	rw.wroteHeader = true
 	n, err := rw.ResponseWriter.Write(b)
 	rw.written += int64(n)
 	return n, err
 }
 
+// Flush passes through to the underlying writer so streaming keeps working
+func (rw *responseWriter) Flush() {
+	rw.wroteHeader = true
+	_ = http.NewResponseController(rw.ResponseWriter).Flush()
+}
+
+// Hijack passes through to the underlying writer for protocol upgrades.
+// Bytes sent over a hijacked connection are not counted.
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(rw.ResponseWriter).Hijack()
+	if err == nil && !rw.wroteHeader {
+		rw.statusCode = http.StatusSwitchingProtocols
+		rw.wroteHeader = true
+	}
+	return conn, brw, err
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 // AccessLogMiddleware creates middleware for access logging
 func (l *Logger) AccessLogMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -79,6 +146,9 @@ func (l *Logger) AccessLogMiddleware(next http.Handler) http.Handler {
 		}
 
 		next.ServeHTTP(wrapped, r)
+		if l.accessExcluded(r.URL.Path, wrapped.statusCode) {
+			return
+		}
 
 		duration := time.Since(start)
 		cacheStatus := wrapped.Header().Get("X-Cache")
@@ -0,0 +1,118 @@
+package logger
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	return &buf
+}
+
+func TestAccessLogCountsBytesAndFirstStatus(t *testing.T) {
+	logs := captureLog(t)
+	l := New(true, true, "info")
+	h := l.AccessLogMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.WriteHeader(http.StatusInternalServerError) // superfluous, ignored by net/http
+		w.Write([]byte("hello "))
+		w.Write([]byte("world"))
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/x", nil))
+
+	if !strings.Contains(logs.String(), "GET /x 201 ") {
+		t.Errorf("expected first status 201 in access log, got %q", logs.String())
+	}
+	if !strings.Contains(logs.String(), "bytes=11") {
+		t.Errorf("expected bytes=11 in access log, got %q", logs.String())
+	}
+}
+
+func TestAccessLogWriterPassesFlush(t *testing.T) {
+	captureLog(t)
+	l := New(true, true, "info")
+
+	var flushErr error
+	h := l.AccessLogMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("chunk"))
+		flushErr = http.NewResponseController(w).Flush()
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/stream", nil))
+
+	if flushErr != nil || !rec.Flushed {
+		t.Errorf("expected Flush to reach the underlying writer, err=%v flushed=%v", flushErr, rec.Flushed)
+	}
+}
+
+func TestAccessLogWriterPassesHijack(t *testing.T) {
+	captureLog(t)
+	l := New(true, true, "info")
+
+	srv := httptest.NewServer(l.AccessLogMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, brw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			t.Errorf("expected hijack to be supported, got %v", err)
+			return
+		}
+		defer conn.Close()
+		brw.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 8\r\nConnection: close\r\n\r\nhijacked")
+		brw.Flush()
+	})))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "hijacked" {
+		t.Errorf("expected response written over hijacked conn, got %q", body)
+	}
+}
+
+func TestAccessLogExcludePaths(t *testing.T) {
+	logs := captureLog(t)
+	l := New(true, true, "info")
+	l.ExcludeAccessPaths([]string{"/healthz", "/metrics*"}, true)
+
+	status := http.StatusOK
+	h := l.AccessLogMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	get := func(path string) {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	get("/healthz")
+	get("/metrics/cache")
+	get("/healthz/deep") // exact entries don't match sub-paths
+	get("/page")
+	status = http.StatusServiceUnavailable
+	get("/healthz") // errors are still logged
+
+	out := logs.String()
+	for _, excluded := range []string{"GET /healthz 200", "GET /metrics/cache"} {
+		if strings.Contains(out, excluded) {
+			t.Errorf("expected no access line for %q, got %q", excluded, out)
+		}
+	}
+	for _, logged := range []string{"GET /healthz/deep 200", "GET /page 200", "GET /healthz 503"} {
+		if !strings.Contains(out, logged) {
+			t.Errorf("expected access line %q, got %q", logged, out)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package proxy
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// adaptiveMinSamples is the number of observations before a path's TTL moves
+	adaptiveMinSamples = 5
+	// adaptiveMaxPaths bounds the number of paths tracked
+	adaptiveMaxPaths = 10000
+)
+
+// adaptiveTTL scales entry TTLs by per-path behavior: paths often answered
+// from cache get longer TTLs, paths whose body keeps changing get shorter ones
+type adaptiveTTL struct {
+	minFactor float64
+	maxFactor float64
+
+	mu    sync.Mutex
+	paths map[string]*pathStats
+}
+
+type pathStats struct {
+	hits    int64 // Responses served from cache
+	stores  int64 // Responses fetched from upstream and stored
+	changes int64 // Stores whose body differed from the previous entry
+}
+
+func newAdaptiveTTL(minFactor, maxFactor float64) *adaptiveTTL {
+	return &adaptiveTTL{
+		minFactor: minFactor,
+		maxFactor: maxFactor,
+		paths:     make(map[string]*pathStats),
+	}
+}
+
+// stats returns the counters for path, or nil once the tracking limit is hit
+func (a *adaptiveTTL) stats(path string) *pathStats {
+	s, ok := a.paths[path]
+	if !ok && len(a.paths) < adaptiveMaxPaths {
+		s = &pathStats{}
+		a.paths[path] = s
+	}
+	return s
+}
+
+func (a *adaptiveTTL) recordHit(path string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if s := a.stats(path); s != nil {
+		s.hits++
+	}
+}
+
+func (a *adaptiveTTL) recordStore(path string, changed bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if s := a.stats(path); s != nil {
+		s.stores++
+		if changed {
+			s.changes++
+		}
+	}
+}
+
+// adjust scales ttl for path within [minFactor, maxFactor]. The hit ratio
+// pushes the factor towards maxFactor, the change ratio towards minFactor.
+// A zero TTL (no expiration) is left alone.
+func (a *adaptiveTTL) adjust(path string, ttl time.Duration) time.Duration {
+	if ttl <= 0 {
+		return ttl
+	}
+	a.mu.Lock()
+	s, ok := a.paths[path]
+	var st pathStats
+	if ok {
+		st = *s
+	}
+	a.mu.Unlock()
+	if st.hits+st.stores < adaptiveMinSamples {
+		return ttl
+	}
+
+	factor := 1.0
+	factor += float64(st.hits) / float64(st.hits+st.stores) * (a.maxFactor - 1)
+	if st.stores > 1 {
+		factor -= float64(st.changes) / float64(st.stores-1) * (1 - a.minFactor)
+	}
+	factor = min(max(factor, a.minFactor), a.maxFactor)
+	return time.Duration(float64(ttl) * factor)
+}
@@ -0,0 +1,78 @@
+package proxy
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// Admission policies applied when all in-flight slots are taken
+const (
+	AdmissionQueue = "queue"
+	AdmissionShed  = "shed"
+)
+
+// Admission configures the global admission controller
+type Admission struct {
+	MaxInFlight  int           // Concurrent requests allowed (0 = unlimited)
+	Policy       string        // "queue" or "shed"
+	QueueDepth   int           // Maximum number of waiting requests (queue policy)
+	QueueTimeout time.Duration // Maximum wait for a slot (queue policy)
+}
+
+// admission limits the number of requests handled concurrently
+type admission struct {
+	cfg    Admission
+	slots  chan struct{}
+	queued atomic.Int64
+	shed   atomic.Int64
+}
+
+func newAdmission(cfg Admission) *admission {
+	return &admission{
+		cfg:   cfg,
+		slots: make(chan struct{}, cfg.MaxInFlight),
+	}
+}
+
+// acquire takes an in-flight slot, waiting in the queue if the policy allows.
+// It returns false when the request must be shed.
+func (a *admission) acquire(ctx context.Context) bool {
+	select {
+	case a.slots <- struct{}{}:
+		return true
+	default:
+	}
+
+	if a.cfg.Policy != AdmissionQueue {
+		a.shed.Add(1)
+		return false
+	}
+	if a.queued.Add(1) > int64(a.cfg.QueueDepth) {
+		a.queued.Add(-1)
+		a.shed.Add(1)
+		return false
+	}
+	defer a.queued.Add(-1)
+
+	timer := time.NewTimer(a.cfg.QueueTimeout)
+	defer timer.Stop()
+	select {
+	case a.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+	a.shed.Add(1)
+	return false
+}
+
+// release frees an in-flight slot
+func (a *admission) release() {
+	<-a.slots
+}
+
+// inFlight returns the number of requests currently being handled
+func (a *admission) inFlight() int {
+	return len(a.slots)
+}
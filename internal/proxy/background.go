@@ -0,0 +1,64 @@
+package proxy
+
+import "sync"
+
+// defaultBackgroundWorkers is used when no worker limit is configured
+const defaultBackgroundWorkers = 16
+
+// backgroundQueueSize bounds the number of background tasks waiting for a worker
+const backgroundQueueSize = 1024
+
+// backgroundPool runs background upstream fetches (shadow mirroring,
+// revalidation, warming) on a fixed number of workers, so bursts queue
+// instead of spawning unbounded goroutines
+type backgroundPool struct {
+	tasks  chan func()
+	mu     sync.RWMutex
+	closed bool
+}
+
+// newBackgroundPool starts workers goroutines through spawn
+func newBackgroundPool(workers int, spawn func(func())) *backgroundPool {
+	b := &backgroundPool{tasks: make(chan func(), backgroundQueueSize)}
+	for i := 0; i < workers; i++ {
+		spawn(func() {
+			for fn := range b.tasks {
+				fn()
+			}
+		})
+	}
+	return b
+}
+
+// submit queues fn. It returns false when the queue is full or the pool
+// is closed, in which case fn is dropped.
+func (b *backgroundPool) submit(fn func()) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return false
+	}
+	select {
+	case b.tasks <- fn:
+		return true
+	default:
+		return false
+	}
+}
+
+// close stops accepting tasks; workers exit once the queue is drained
+func (b *backgroundPool) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.closed {
+		b.closed = true
+		close(b.tasks)
+	}
+}
+
+// runBackground queues a background upstream fetch on the shared pool
+func (p *Proxy) runBackground(name string, fn func()) {
+	if !p.background.submit(fn) && p.logger != nil {
+		p.logger.Error("background queue full, dropping %s", name)
+	}
+}
@@ -0,0 +1,81 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// BodyDump configures debug logging of request bodies
+type BodyDump struct {
+	MaxBytes     int      // Number of leading body bytes to log (0 = disabled)
+	ContentTypes []string // Content-Type prefixes to dump (empty = all)
+	PathPrefixes []string // Request path prefixes to dump (empty = all)
+	RedactFields []string // JSON/form field names whose values are masked
+}
+
+// bodyDumper logs the first bytes of request bodies and replays them upstream
+type bodyDumper struct {
+	cfg     BodyDump
+	redacts []redactRule
+}
+
+type redactRule struct {
+	re   *regexp.Regexp
+	repl []byte
+}
+
+func newBodyDumper(cfg BodyDump) *bodyDumper {
+	d := &bodyDumper{cfg: cfg}
+	for _, field := range cfg.RedactFields {
+		q := regexp.QuoteMeta(field)
+		// "field": "value" in JSON and field=value in form bodies. A JSON
+		// value cut off by MaxBytes is masked up to the end of the capture.
+		d.redacts = append(d.redacts,
+			redactRule{regexp.MustCompile(`("` + q + `"\s*:\s*)"(?:[^"\\]|\\.)*\\?(?:"|$)`), []byte(`${1}"[REDACTED]"`)},
+			redactRule{regexp.MustCompile(`((?:^|&)` + q + `=)[^&]*`), []byte(`${1}[REDACTED]`)},
+		)
+	}
+	return d
+}
+
+// matches reports whether the request is in scope for dumping
+func (d *bodyDumper) matches(r *http.Request) bool {
+	if len(d.cfg.PathPrefixes) > 0 && !hasAnyPrefix(r.URL.Path, d.cfg.PathPrefixes) {
+		return false
+	}
+	if len(d.cfg.ContentTypes) > 0 && !hasAnyPrefix(r.Header.Get("Content-Type"), d.cfg.ContentTypes) {
+		return false
+	}
+	return true
+}
+
+// capture reads up to MaxBytes of the body and returns them together with
+// a body that replays the captured prefix followed by the rest of the stream
+func (d *bodyDumper) capture(body io.ReadCloser) ([]byte, io.ReadCloser, error) {
+	prefix, err := io.ReadAll(io.LimitReader(body, int64(d.cfg.MaxBytes)))
+	replay := struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(prefix), body), body}
+	return prefix, replay, err
+}
+
+// redact masks configured fields in a captured body
+func (d *bodyDumper) redact(b []byte) []byte {
+	for _, rule := range d.redacts {
+		b = rule.re.ReplaceAll(b, rule.repl)
+	}
+	return b
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package proxy
+
+import (
+	"sync"
+	"time"
+)
+
+// breaker is a consecutive-failure circuit breaker for the upstream
+type breaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openedAt  time.Time
+	now       func() time.Time
+}
+
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	return &breaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+	}
+}
+
+// Allow reports whether a request may be sent upstream.
+// Once the cooldown has passed, requests are let through (half-open)
+// until the next success or failure decides the state.
+func (b *breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < b.threshold {
+		return true
+	}
+	return b.now().Sub(b.openedAt) >= b.cooldown
+}
+
+// Open reports whether the breaker is currently rejecting requests
+func (b *breaker) Open() bool {
+	return !b.Allow()
+}
+
+// Success resets the failure count and closes the breaker
+func (b *breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+}
+
+// Failure records an upstream failure, opening the breaker at the threshold
+func (b *breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openedAt = b.now()
+	}
+}
+
+// State returns a short description of the breaker state
+func (b *breaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case b.failures < b.threshold:
+		return "closed"
+	case b.now().Sub(b.openedAt) >= b.cooldown:
+		return "half-open"
+	default:
+		return "open"
+	}
+}
@@ -0,0 +1,32 @@
+package proxy
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Default upstream marker for responses that must not be cached
+const (
+	DefaultBypassHeader = "X-Cache"
+	DefaultBypassValue  = "no-store"
+)
+
+// bypassMarked reports whether upstream marked the response as not to be
+// cached with the bypass header. Without a configured value any non-empty
+// header counts; otherwise one of its comma-separated tokens must match.
+func (p *Proxy) bypassMarked(h http.Header) bool {
+	if p.bypassHeader == "" {
+		return false
+	}
+	for _, v := range h.Values(p.bypassHeader) {
+		if p.bypassValue == "" && strings.TrimSpace(v) != "" {
+			return true
+		}
+		for _, token := range strings.Split(v, ",") {
+			if p.bypassValue != "" && strings.EqualFold(strings.TrimSpace(token), p.bypassValue) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package proxy
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// X-Cache values
+const (
+	CacheMiss      = "MISS"
+	CachePass      = "PASS"
+	CacheBypass    = "BYPASS"
+	CacheHitBackup = "HIT-BACKUP"
+	CacheHit       = "HIT"
+
+	CacheRevalidated = "REVALIDATED"
+	CacheStale       = "STALE"
+	CacheHitNegative = "HIT-NEGATIVE"
+	CacheCoalesced   = "COALESCED"
+)
+
+// Cache status header modes
+const (
+	StatusHeaderXCache      = "x-cache"
+	StatusHeaderCacheStatus = "cache-status"
+	StatusHeaderBoth        = "both"
+)
+
+// cacheStatusTokens maps X-Cache values to RFC 9211 Cache-Status parameters
+var cacheStatusTokens = map[string]string{
+	CacheMiss:      "fwd=miss; stored",
+	CachePass:      "fwd=miss",
+	CacheBypass:    "fwd=method",
+	CacheHitBackup: "hit; detail=backup",
+	CacheHit:       "hit",
+
+	CacheRevalidated: "fwd=stale; fwd-status=304",
+	CacheStale:       "hit; detail=stale",
+	CacheHitNegative: "hit; detail=negative",
+	CacheCoalesced:   "fwd=miss; collapsed",
+}
+
+// setCacheStatus reports the cache outcome using the configured header(s)
+func (p *Proxy) setCacheStatus(w http.ResponseWriter, status string) {
+	p.counters.record(status)
+	if status != CacheBypass {
+		p.rolling.Record(status == CacheHitBackup || status == CacheHit || status == CacheRevalidated || status == CacheStale || status == CacheHitNegative)
+	}
+	if p.statusHeader != StatusHeaderCacheStatus {
+		w.Header().Set("X-Cache", status)
+	}
+	if p.statusHeader == StatusHeaderCacheStatus || p.statusHeader == StatusHeaderBoth {
+		w.Header().Set("Cache-Status", "Aegis; "+cacheStatusTokens[status])
+	}
+	if p.serverTiming {
+		timing := "cache;desc=" + status
+		if upstream := w.Header().Get("Server-Timing"); strings.HasPrefix(upstream, "upstream;") {
+			timing = upstream + ", " + timing
+		}
+		w.Header().Set("Server-Timing", timing)
+	}
+	if p.varyKeyHeaders {
+		p.reflectVary(w.Header())
+	}
+	if p.debugHeaders {
+		w.Header().Set("X-Cache-Entries", strconv.Itoa(p.cacheSize()))
+		w.Header().Set("X-Cache-Memory-Bytes", strconv.FormatInt(p.cache.MemoryUsage(), 10))
+	}
+}
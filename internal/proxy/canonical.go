@@ -0,0 +1,69 @@
+package proxy
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// canonicalizePath rewrites r's path to its canonical form in place, so the
+// cache key and the forwarded path agree
+func (p *Proxy) canonicalizePath(r *http.Request) {
+	escaped := canonicalPath(r.URL.EscapedPath(), p.decodeSlash)
+	path, err := url.PathUnescape(escaped)
+	if err != nil {
+		return
+	}
+	r.URL.Path, r.URL.RawPath = path, escaped
+}
+
+// canonicalPath normalizes an escaped path: percent-encoded unreserved
+// characters (RFC 3986 section 2.3) are decoded, remaining escapes are
+// upper-cased and duplicate slashes are collapsed. %2F is decoded into a
+// path separator only with decodeSlash, as that changes routing.
+func canonicalPath(escaped string, decodeSlash bool) string {
+	var b strings.Builder
+	b.Grow(len(escaped))
+	var last byte
+	for i := 0; i < len(escaped); i++ {
+		c := escaped[i]
+		if c == '%' && i+2 < len(escaped) && isHex(escaped[i+1]) && isHex(escaped[i+2]) {
+			v := unhex(escaped[i+1])<<4 | unhex(escaped[i+2])
+			if !isUnreserved(v) && !(v == '/' && decodeSlash) {
+				b.WriteByte('%')
+				b.WriteString(strings.ToUpper(escaped[i+1 : i+3]))
+				last = 0
+				i += 2
+				continue
+			}
+			c = v
+			i += 2
+		}
+		if c == '/' && last == '/' {
+			continue
+		}
+		b.WriteByte(c)
+		last = c
+	}
+	return b.String()
+}
+
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
@@ -0,0 +1,175 @@
+package proxy
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// defaultCoalesceBodyBytes bounds the body a leader records for waiting
+// requests when max_body_bytes is unlimited
+const defaultCoalesceBodyBytes = 10 << 20
+
+// flight is an upstream fetch in progress that requests for the same
+// cache key wait on instead of fetching themselves
+type flight struct {
+	done chan struct{}
+
+	// Request headers of the leader, compared against the Vary of its response
+	request http.Header
+
+	// Set by the leader before done is closed
+	status int
+	header http.Header
+	body   []byte
+	shared bool // false when the response is not reusable or not recorded whole
+}
+
+// flights tracks the in-flight fetch per cache key
+type flights struct {
+	mu sync.Mutex
+	m  map[string]*flight
+}
+
+func newFlights() *flights {
+	return &flights{m: make(map[string]*flight)}
+}
+
+// join returns the fetch in flight for key, or starts one and reports
+// that the caller leads it. The leader must call finish.
+func (f *flights) join(key string) (fl *flight, leader bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if fl, ok := f.m[key]; ok {
+		return fl, false
+	}
+	fl = &flight{done: make(chan struct{})}
+	f.m[key] = fl
+	return fl, true
+}
+
+// finish publishes what the leader wrote to its client and releases the
+// requests waiting on key
+func (f *flights) finish(key string, rec *flightRecorder) {
+	f.mu.Lock()
+	delete(f.m, key)
+	f.mu.Unlock()
+
+	fl := rec.fl
+	fl.shared = rec.completed && rec.status != 0 && !rec.overflow && rec.complete() && shareableResponse(fl.header)
+	close(fl.done)
+}
+
+// shareableResponse reports whether a response may be handed to other
+// clients: not private to the leader and not setting its cookies
+func shareableResponse(h http.Header) bool {
+	return !originForbidsStore(h) && len(h.Values("Set-Cookie")) == 0
+}
+
+// varyMatches reports whether r sends the same values as the leader for
+// every request header its response varies on
+func (fl *flight) varyMatches(r *http.Request) bool {
+	for _, name := range responseVary(fl.header) {
+		if name == "*" || keyHeaderValue(r.Header, name) != keyHeaderValue(fl.request, name) {
+			return false
+		}
+	}
+	return true
+}
+
+// flightRecorder passes a leader's response through to its client while
+// keeping a copy for the requests waiting on the same key
+type flightRecorder struct {
+	http.ResponseWriter
+	fl        *flight
+	limit     int
+	status    int
+	overflow  bool
+	completed bool // set once the leader's handler returned normally
+}
+
+func (w *flightRecorder) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+		w.fl.status = code
+		w.fl.header = w.Header().Clone()
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *flightRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.overflow {
+		if len(w.fl.body)+len(b) > w.limit {
+			w.overflow = true
+			w.fl.body = nil
+		} else {
+			w.fl.body = append(w.fl.body, b...)
+		}
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *flightRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// complete reports whether the recorded body matches its Content-Length,
+// so a stream cut short by upstream is not handed on
+func (w *flightRecorder) complete() bool {
+	cl := w.fl.header.Get("Content-Length")
+	if cl == "" {
+		return true
+	}
+	n, err := strconv.Atoi(cl)
+	return err == nil && n == len(w.fl.body)
+}
+
+// mayCoalesce reports whether r can share another request's upstream
+// response. Conditional and range requests get answers specific to them.
+func (p *Proxy) mayCoalesce(r *http.Request) bool {
+	return p.flights != nil && p.mayShareEntry(r) && !hasConditionalHeaders(r) && r.Header.Get("Range") == ""
+}
+
+// leadFlight wraps w to record the response to r for requests that join
+// the flight of key while it is being fetched
+func (p *Proxy) leadFlight(w http.ResponseWriter, r *http.Request, fl *flight) *flightRecorder {
+	fl.request = r.Header.Clone()
+	limit := p.maxBodyBytes
+	if limit <= 0 {
+		limit = defaultCoalesceBodyBytes
+	}
+	return &flightRecorder{ResponseWriter: w, fl: fl, limit: limit}
+}
+
+// serveCoalesced waits for the leader of fl and answers r with the same
+// response. It returns false when the response was not shared and r must
+// be fetched on its own.
+func (p *Proxy) serveCoalesced(w http.ResponseWriter, r *http.Request, key string, fl *flight) bool {
+	select {
+	case <-fl.done:
+	case <-r.Context().Done():
+		// Client gave up; nothing left to answer
+		return true
+	}
+	if !fl.shared || !fl.varyMatches(r) {
+		if p.logger != nil {
+			p.logger.Debug("coalesced response not shareable, fetching separately: key=%s", key)
+		}
+		return false
+	}
+	for name, values := range fl.header {
+		switch name {
+		case "X-Cache", "Cache-Status", "Server-Timing":
+			continue
+		}
+		w.Header()[name] = values
+	}
+	p.setCacheStatus(w, CacheCoalesced)
+	p.recordSavings(r, CacheCoalesced, fl.body)
+	w.WriteHeader(fl.status)
+	_, _ = w.Write(fl.body)
+	return true
+}
@@ -0,0 +1,87 @@
+package proxy
+
+import "sync/atomic"
+
+// counters tracks request outcomes since startup
+type counters struct {
+	requests  atomic.Int64
+	miss      atomic.Int64
+	pass      atomic.Int64
+	bypass    atomic.Int64
+	hitBackup atomic.Int64
+	hit       atomic.Int64
+
+	revalidated atomic.Int64
+	stale       atomic.Int64
+	hitNegative atomic.Int64
+	coalesced   atomic.Int64
+
+	// upstreamErrors counts failed upstream calls: transport errors, 5xx
+	// and detected error pages
+	upstreamErrors atomic.Int64
+}
+
+// record counts a cache outcome by its X-Cache value
+func (c *counters) record(status string) {
+	switch status {
+	case CacheMiss:
+		c.miss.Add(1)
+	case CachePass:
+		c.pass.Add(1)
+	case CacheBypass:
+		c.bypass.Add(1)
+	case CacheHitBackup:
+		c.hitBackup.Add(1)
+	case CacheHit:
+		c.hit.Add(1)
+	case CacheRevalidated:
+		c.revalidated.Add(1)
+	case CacheStale:
+		c.stale.Add(1)
+	case CacheHitNegative:
+		c.hitNegative.Add(1)
+	case CacheCoalesced:
+		c.coalesced.Add(1)
+	}
+}
+
+// hitRatio returns the share of cacheable requests answered from cache
+func (c *counters) hitRatio() float64 {
+	hits := c.hitBackup.Load() + c.hit.Load() + c.revalidated.Load() + c.stale.Load() + c.hitNegative.Load()
+	total := hits + c.miss.Load() + c.pass.Load() + c.coalesced.Load()
+	if total == 0 {
+		return 0
+	}
+	return float64(hits) / float64(total)
+}
+
+// counterStats is the counters section of /stats
+type counterStats struct {
+	Requests       int64 `json:"requests"`
+	Miss           int64 `json:"miss"`
+	Pass           int64 `json:"pass"`
+	Bypass         int64 `json:"bypass"`
+	HitBackup      int64 `json:"hit_backup"`
+	Hit            int64 `json:"hit"`
+	Revalidated    int64 `json:"revalidated"`
+	Stale          int64 `json:"stale"`
+	HitNegative    int64 `json:"hit_negative"`
+	Coalesced      int64 `json:"coalesced"`
+	UpstreamErrors int64 `json:"upstream_errors"`
+}
+
+func (c *counters) stats() counterStats {
+	return counterStats{
+		Requests:       c.requests.Load(),
+		Miss:           c.miss.Load(),
+		Pass:           c.pass.Load(),
+		Bypass:         c.bypass.Load(),
+		HitBackup:      c.hitBackup.Load(),
+		Hit:            c.hit.Load(),
+		Revalidated:    c.revalidated.Load(),
+		Stale:          c.stale.Load(),
+		HitNegative:    c.hitNegative.Load(),
+		Coalesced:      c.coalesced.Load(),
+		UpstreamErrors: c.upstreamErrors.Load(),
+	}
+}
@@ -0,0 +1,21 @@
+package proxy
+
+import (
+	"net/http"
+	"strings"
+)
+
+// countryKey returns the ISO 3166-1 alpha-2 code from the configured
+// country header, upper-cased. Missing or malformed codes map to the
+// configured default.
+func (p *Proxy) countryKey(r *http.Request) string {
+	code := strings.ToUpper(strings.TrimSpace(r.Header.Get(p.countryHeader)))
+	if len(code) != 2 || !isASCIILetter(code[0]) || !isASCIILetter(code[1]) {
+		return p.countryDefault
+	}
+	return code
+}
+
+func isASCIILetter(c byte) bool {
+	return c >= 'A' && c <= 'Z'
+}
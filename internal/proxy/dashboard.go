@@ -0,0 +1,61 @@
+package proxy
+
+import (
+	_ "embed"
+	"html/template"
+	"net/http"
+	"time"
+)
+
+//go:embed dashboard.html
+var dashboardHTML string
+
+var dashboardTemplate = template.Must(template.New("dashboard").Parse(dashboardHTML))
+
+// dashboardRefresh is how often the dashboard page reloads itself
+const dashboardRefresh = 5 * time.Second
+
+type dashboardWindow struct {
+	Name    string
+	Percent float64
+}
+
+type dashboardData struct {
+	Instance        string
+	Upstream        string
+	Breaker         string
+	CacheSize       int
+	MemoryMB        float64
+	Requests        int64
+	HitRatioPercent float64
+	Windows         []dashboardWindow
+	Generated       string
+	RefreshSeconds  int
+}
+
+// DashboardHandler renders a self-contained HTML status page
+func (p *Proxy) DashboardHandler(w http.ResponseWriter, r *http.Request) {
+	data := dashboardData{
+		Instance:        p.instanceID,
+		Upstream:        p.upstream.Redacted(),
+		Breaker:         "disabled",
+		CacheSize:       p.cacheSize(),
+		MemoryMB:        float64(p.cache.MemoryUsage()) / (1024 * 1024),
+		Requests:        p.counters.requests.Load(),
+		HitRatioPercent: p.counters.hitRatio() * 100,
+		Generated:       p.clock.Now().UTC().Format(time.RFC3339),
+		RefreshSeconds:  int(dashboardRefresh.Seconds()),
+	}
+	if p.breaker != nil {
+		data.Breaker = p.breaker.State()
+	}
+	for _, win := range hitRatioWindows {
+		data.Windows = append(data.Windows, dashboardWindow{Name: win.name, Percent: p.rolling.Ratio(win.d) * 100})
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := dashboardTemplate.Execute(w, data); err != nil && p.logger != nil {
+		p.logger.Error("render dashboard: %v", err)
+	}
+}
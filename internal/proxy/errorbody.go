@@ -0,0 +1,39 @@
+package proxy
+
+import (
+	"bytes"
+	"net/http"
+)
+
+// ErrorBody detects upstream error pages served with a 2xx status, so they
+// are treated as failures instead of being cached as successes.
+type ErrorBody struct {
+	Contains     []string // Body substrings marking an error page (empty = disabled)
+	ContentTypes []string // Content-Type prefixes checked (empty = all)
+	Paths        []string // Path prefixes checked (empty = all)
+}
+
+// applies reports whether the body of resp is checked at all
+func (e *ErrorBody) applies(r *http.Request, resp *http.Response) bool {
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return false
+	}
+	if len(e.ContentTypes) > 0 && !hasAnyPrefix(resp.Header.Get("Content-Type"), e.ContentTypes) {
+		return false
+	}
+	return len(e.Paths) == 0 || hasAnyPrefix(r.URL.Path, e.Paths)
+}
+
+// isErrorPage reports whether a buffered 2xx body contains one of the
+// configured error markers
+func (p *Proxy) isErrorPage(r *http.Request, resp *http.Response, body []byte) bool {
+	if p.errorBody == nil || !p.errorBody.applies(r, resp) {
+		return false
+	}
+	for _, marker := range p.errorBody.Contains {
+		if bytes.Contains(body, []byte(marker)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package proxy
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// maxCapturedErrorBody bounds the body bytes kept per captured error
+const maxCapturedErrorBody = 4096
+
+// capturedHeaderDenylist names headers dropped from captured errors, so
+// /errors never hands out credentials or session cookies
+var capturedHeaderDenylist = []string{
+	"Set-Cookie", "Cookie", "Authorization", "Proxy-Authorization", "Proxy-Authenticate",
+}
+
+// CapturedError is a diagnostic record of an upstream error response
+type CapturedError struct {
+	Time   time.Time   `json:"time"`
+	Method string      `json:"method"`
+	Path   string      `json:"path"`
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	Body   string      `json:"body"`
+}
+
+// errorRing keeps the last N captured upstream errors
+type errorRing struct {
+	mu    sync.Mutex
+	items []CapturedError
+	next  int
+	full  bool
+}
+
+func newErrorRing(n int) *errorRing {
+	return &errorRing{items: make([]CapturedError, n)}
+}
+
+// Add records an error, overwriting the oldest once full
+func (e *errorRing) Add(item CapturedError) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.items[e.next] = item
+	e.next = (e.next + 1) % len(e.items)
+	if e.next == 0 {
+		e.full = true
+	}
+}
+
+// List returns captured errors, oldest first
+func (e *errorRing) List() []CapturedError {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.full {
+		return append([]CapturedError(nil), e.items[:e.next]...)
+	}
+	out := make([]CapturedError, 0, len(e.items))
+	out = append(out, e.items[e.next:]...)
+	return append(out, e.items[:e.next]...)
+}
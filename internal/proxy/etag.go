@@ -0,0 +1,69 @@
+package proxy
+
+import (
+	"Aegis/internal/cache"
+	"Aegis/internal/utils"
+	"net/http"
+)
+
+// revalidationEntry returns the cached entry for r that upstream can
+// confirm with If-None-Match: one with an ETag, fresh or already expired
+// but still held by the store. Clients sending their own conditional
+// headers get upstream's answer unchanged instead.
+func (p *Proxy) revalidationEntry(r *http.Request, key string) (cache.Response, bool) {
+	if !p.etagRevalidation || hasConditionalHeaders(r) || !p.mayShareEntry(r) {
+		return cache.Response{}, false
+	}
+	cached, ok := p.fetchStaleEntry(r, key)
+	if !ok || cached.Header.Get("ETag") == "" {
+		return cache.Response{}, false
+	}
+	return cached, true
+}
+
+// fetchStaleEntry is fetchEntry that also returns expired entries, when
+// the store can
+func (p *Proxy) fetchStaleEntry(r *http.Request, key string) (cache.Response, bool) {
+	sf, ok := p.store.(cache.StaleFetcher)
+	if !ok {
+		cached, ok, _ := p.fetchEntry(r, key)
+		return cached, ok
+	}
+	cached, ok, err := sf.FetchStale(key)
+	if err == nil && ok && cached.Vary != nil {
+		cached, ok, err = sf.FetchStale(p.varyKey(r, key, cached.Vary))
+	}
+	if err != nil || !ok || (p.verifyChecksums && !cached.ChecksumValid()) {
+		return cache.Response{}, false
+	}
+	return cached, true
+}
+
+func hasConditionalHeaders(r *http.Request) bool {
+	return r.Header.Get("If-None-Match") != "" || r.Header.Get("If-Modified-Since") != ""
+}
+
+// serveRevalidated answers r with the cached body after upstream confirmed
+// it with 304 Not Modified. Headers sent with the 304 update the stored
+// ones, and the entry is stored again with a fresh SavedAt and TTL.
+func (p *Proxy) serveRevalidated(w http.ResponseWriter, r *http.Request, key string, cached cache.Response, notModified *http.Response) {
+	header := cached.Header.Clone()
+	for name, values := range notModified.Header {
+		if utils.IsHopByHopHeader(name) || name == "Content-Length" {
+			continue
+		}
+		header[name] = values
+	}
+	refreshed := &http.Response{StatusCode: cached.Status, Header: header}
+	_ = p.storeEntry(r, key, refreshed, cached.Body, p.storeTTL(r, key, refreshed, cached.Body))
+	if p.logger != nil {
+		p.logger.Debug("upstream confirmed cached entry: key=%s etag=%s", key, header.Get("ETag"))
+	}
+
+	utils.CopyHeadersForClient(w.Header(), header)
+	p.setServedBy(w)
+	p.setCacheStatus(w, CacheRevalidated)
+	p.recordSavings(r, CacheRevalidated, cached.Body)
+	w.WriteHeader(cached.Status)
+	_, _ = w.Write(cached.Body)
+}
@@ -0,0 +1,155 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// Cache event types
+const (
+	EventStore  = "store"
+	EventEvict  = "evict"
+	EventPurge  = "purge"
+	EventExpire = "expire"
+)
+
+const (
+	defaultEventQueueSize = 1000
+	defaultEventTimeout   = 5 * time.Second
+)
+
+// Events configures best-effort delivery of cache events
+type Events struct {
+	WebhookURL string        // POST each event as JSON (empty = disabled)
+	LogFile    string        // Append events as JSON lines (empty = disabled)
+	QueueSize  int           // Pending events kept before new ones are dropped
+	Timeout    time.Duration // Timeout of one webhook request
+}
+
+// CacheEvent is one change to the cache
+type CacheEvent struct {
+	Type   string    `json:"type"`
+	Key    string    `json:"key"`
+	Time   time.Time `json:"time"`
+	Status int       `json:"status,omitempty"`
+	Bytes  int       `json:"bytes,omitempty"`
+}
+
+// eventSink delivers queued events in a single background worker
+type eventSink struct {
+	cfg     Events
+	queue   chan CacheEvent
+	dropped atomic.Int64
+	client  *http.Client
+	log     io.WriteCloser
+}
+
+func newEventSink(cfg Events) (*eventSink, error) {
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = defaultEventQueueSize
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultEventTimeout
+	}
+	s := &eventSink{
+		cfg:    cfg,
+		queue:  make(chan CacheEvent, cfg.QueueSize),
+		client: &http.Client{Timeout: cfg.Timeout},
+	}
+	if cfg.LogFile != "" {
+		f, err := os.OpenFile(cfg.LogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("open event log: %w", err)
+		}
+		s.log = f
+	}
+	return s, nil
+}
+
+// emit queues ev without blocking; a full queue drops it
+func (s *eventSink) emit(ev CacheEvent) {
+	select {
+	case s.queue <- ev:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+// run delivers events until stop is closed, then flushes what is queued
+func (s *eventSink) run(stop <-chan struct{}, logErr func(string, ...interface{})) {
+	if s.log != nil {
+		defer s.log.Close()
+	}
+	for {
+		select {
+		case ev := <-s.queue:
+			s.deliver(ev, logErr)
+		case <-stop:
+			for {
+				select {
+				case ev := <-s.queue:
+					s.deliver(ev, logErr)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// deliver writes ev to the event log and posts it to the webhook
+func (s *eventSink) deliver(ev CacheEvent, logErr func(string, ...interface{})) {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		logErr("encode cache event: %v", err)
+		return
+	}
+	if s.log != nil {
+		if _, err := s.log.Write(append(data, '\n')); err != nil {
+			logErr("write cache event log: %v", err)
+		}
+	}
+	if s.cfg.WebhookURL == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.WebhookURL, bytes.NewReader(data))
+	if err != nil {
+		logErr("cache event webhook: %v", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		logErr("cache event webhook: %v", err)
+		return
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		logErr("cache event webhook: status %d", resp.StatusCode)
+	}
+}
+
+// emitEvent reports a cache event when events are enabled
+func (p *Proxy) emitEvent(typ, key string, status, size int) {
+	if p.events == nil {
+		return
+	}
+	p.events.emit(CacheEvent{Type: typ, Key: key, Time: p.clock.Now(), Status: status, Bytes: size})
+}
+
+// logEventError logs event delivery failures when logging is enabled
+func (p *Proxy) logEventError(format string, v ...interface{}) {
+	if p.logger != nil {
+		p.logger.Error(format, v...)
+	}
+}
@@ -0,0 +1,32 @@
+package proxy
+
+import (
+	"Aegis/internal/utils"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// setForwardedHeaders tells upstream who the client is: its address is
+// appended to X-Forwarded-For, and X-Forwarded-Host and X-Forwarded-Proto
+// carry the original Host and scheme. A Host forwarded by a trusted proxy
+// is kept, as requestScheme keeps its scheme.
+func (p *Proxy) setForwardedHeaders(dst http.Header, r *http.Request) {
+	if !p.forwardedHeaders {
+		return
+	}
+	if ip, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		if prior := strings.Join(r.Header.Values("X-Forwarded-For"), ", "); prior != "" {
+			ip = prior + ", " + ip
+		}
+		dst.Set("X-Forwarded-For", ip)
+	}
+	host := r.Host
+	if fwd := r.Header.Get("X-Forwarded-Host"); fwd != "" && utils.RemoteAddrInNets(r.RemoteAddr, p.trustedProxies) {
+		host = fwd
+	}
+	if host != "" {
+		dst.Set("X-Forwarded-Host", host)
+	}
+	dst.Set("X-Forwarded-Proto", p.requestScheme(r))
+}
@@ -0,0 +1,58 @@
+package proxy
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// defaultTTL returns the TTL of the "default" signal. The configured TTL
+// base wins; without one, an RFC 7234 heuristic based on Last-Modified may
+// apply when upstream sent no explicit freshness.
+func (p *Proxy) defaultTTL(h http.Header, base time.Duration) time.Duration {
+	if base > 0 || p.heuristicFraction <= 0 || hasExplicitFreshness(h) {
+		return base
+	}
+	return p.heuristicTTL(h, p.clock.Now())
+}
+
+// heuristicTTL computes fraction * (now - Last-Modified), capped by maxTTL
+func (p *Proxy) heuristicTTL(h http.Header, now time.Time) time.Duration {
+	lm, err := http.ParseTime(h.Get("Last-Modified"))
+	if err != nil || !lm.Before(now) {
+		return 0
+	}
+	ttl := time.Duration(float64(now.Sub(lm)) * p.heuristicFraction)
+	if p.maxTTL > 0 && ttl > p.maxTTL {
+		ttl = p.maxTTL
+	}
+	return ttl
+}
+
+// hasExplicitFreshness reports whether upstream set max-age, s-maxage or Expires
+func hasExplicitFreshness(h http.Header) bool {
+	if h.Get("Expires") != "" {
+		return true
+	}
+	return hasCacheControl(h, "max-age") || hasCacheControl(h, "s-maxage")
+}
+
+// originForbidsStore reports whether Cache-Control keeps a shared cache
+// from storing the response (no-store, private) or from reusing it without
+// revalidation (no-cache), which a backup served during an outage can't do
+func originForbidsStore(h http.Header) bool {
+	return hasCacheControl(h, "no-store") || hasCacheControl(h, "private") || hasCacheControl(h, "no-cache")
+}
+
+// hasCacheControl reports whether a Cache-Control header carries the directive
+func hasCacheControl(h http.Header, directive string) bool {
+	for _, v := range h.Values("Cache-Control") {
+		for _, d := range strings.Split(v, ",") {
+			name, _, _ := strings.Cut(strings.TrimSpace(d), "=")
+			if strings.EqualFold(name, directive) {
+				return true
+			}
+		}
+	}
+	return false
+}
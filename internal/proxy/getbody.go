@@ -0,0 +1,97 @@
+package proxy
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Policies for GET/HEAD requests carrying a body
+const (
+	GetBodyIgnore = "ignore" // forward the body, key on path/query only
+	GetBodyReject = "reject" // reply 400 Bad Request
+	GetBodyKey    = "key"    // include a digest of the body in the cache key
+)
+
+// maxKeyedBodyBytes bounds the body buffered to compute a key digest
+const maxKeyedBodyBytes = 1 << 20
+
+var errBodyTooLarge = errors.New("request body too large")
+
+// requestHasBody reports whether the client sent a request body
+func requestHasBody(r *http.Request) bool {
+	return r.Body != nil && r.Body != http.NoBody && (r.ContentLength > 0 || len(r.TransferEncoding) > 0)
+}
+
+// bufferBodyDigest reads the request body, replaces it with a replayable copy
+// and returns a hex SHA-256 digest of its contents. With fields, a JSON
+// object body is digested by those fields only (see jsonFieldsDigestInput).
+func bufferBodyDigest(r *http.Request, fields []string) (string, error) {
+	b, err := io.ReadAll(io.LimitReader(r.Body, maxKeyedBodyBytes+1))
+	if err != nil {
+		return "", err
+	}
+	if len(b) > maxKeyedBodyBytes {
+		return "", errBodyTooLarge
+	}
+	r.Body = io.NopCloser(bytes.NewReader(b))
+	input := b
+	if len(fields) > 0 {
+		if in, ok := jsonFieldsDigestInput(b, fields); ok {
+			input = in
+		}
+	}
+	sum := sha256.Sum256(input)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// jsonFieldsDigestInput returns a canonical encoding of the given dotted
+// field paths of a JSON object body, so bodies differing only in other
+// fields or in key order produce the same digest. It reports false when
+// the body is not a JSON object.
+func jsonFieldsDigestInput(body []byte, fields []string) ([]byte, bool) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var doc map[string]interface{}
+	if err := dec.Decode(&doc); err != nil || doc == nil {
+		return nil, false
+	}
+	var buf bytes.Buffer
+	for _, field := range fields {
+		buf.WriteString(field)
+		v, ok := jsonField(doc, field)
+		if !ok {
+			buf.WriteString(" absent\n")
+			continue
+		}
+		// Marshal sorts object keys, making the encoding deterministic
+		enc, err := json.Marshal(v)
+		if err != nil {
+			return nil, false
+		}
+		buf.WriteByte('=')
+		buf.Write(enc)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), true
+}
+
+// jsonField looks up a dotted path such as "filters.category"
+func jsonField(doc map[string]interface{}, path string) (interface{}, bool) {
+	var cur interface{} = doc
+	for _, name := range strings.Split(path, ".") {
+		obj, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if cur, ok = obj[name]; !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
@@ -0,0 +1,154 @@
+package proxy
+
+import (
+	"Aegis/internal/cache"
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// acceptsGzip reports whether the client takes gzip-encoded responses,
+// named or through "*", and not refused with q=0
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != "gzip" && coding != "*" {
+			continue
+		}
+		name, value, _ := strings.Cut(params, "=")
+		if strings.TrimSpace(strings.ToLower(name)) != "q" {
+			return true
+		}
+		q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		return err == nil && q > 0
+	}
+	return false
+}
+
+// gzipWriter compresses text-like responses of at least min bytes on the
+// way to the client. Until the size is known, from Content-Length or by
+// buffering min bytes, the body is held back; a Flush decides early in
+// favour of compression, since a streamed body is likely to be large.
+type gzipWriter struct {
+	http.ResponseWriter
+	min     int
+	status  int
+	buf     []byte
+	zw      *gzip.Writer
+	decided bool
+}
+
+func (w *gzipWriter) WriteHeader(status int) {
+	if w.status != 0 {
+		return
+	}
+	w.status = status
+	h := w.Header()
+	if !eligibleForGzip(status, h) {
+		w.decided = true
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	h.Add("Vary", "Accept-Encoding")
+	if cl := h.Get("Content-Length"); cl != "" {
+		if n, err := strconv.Atoi(cl); err == nil {
+			w.decide(n >= w.min)
+		}
+	}
+}
+
+// eligibleForGzip reports whether a response may be compressed at all
+func eligibleForGzip(status int, h http.Header) bool {
+	if status < 200 || status == http.StatusNoContent || status == http.StatusNotModified ||
+		status == http.StatusPartialContent || h.Get("Content-Range") != "" || noTransform(h) {
+		return false
+	}
+	return cache.Compressible(h)
+}
+
+// decide sends the header, compressed or as is
+func (w *gzipWriter) decide(compress bool) {
+	w.decided = true
+	if compress {
+		h := w.Header()
+		h.Set("Content-Encoding", "gzip")
+		h.Del("Content-Length")
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			h.Set("ETag", "W/"+etag)
+		}
+		w.zw = gzip.NewWriter(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+}
+
+func (w *gzipWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.decided {
+		w.buf = append(w.buf, b...)
+		if len(w.buf) < w.min {
+			return len(b), nil
+		}
+		w.decide(true)
+		if err := w.writeBuffered(); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	if w.zw != nil {
+		return w.zw.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// writeBuffered passes on what was held back before the decision
+func (w *gzipWriter) writeBuffered() error {
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	if w.zw != nil {
+		_, err := w.zw.Write(buf)
+		return err
+	}
+	_, err := w.ResponseWriter.Write(buf)
+	return err
+}
+
+func (w *gzipWriter) Flush() {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.decided {
+		w.decide(true)
+		_ = w.writeBuffered()
+	}
+	if w.zw != nil {
+		_ = w.zw.Flush()
+	}
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// close ends the response: a body that stayed below the threshold is sent
+// uncompressed, and a compressed one gets its gzip trailer
+func (w *gzipWriter) close() {
+	if w.status == 0 {
+		return
+	}
+	if !w.decided {
+		w.Header().Set("Content-Length", strconv.Itoa(len(w.buf)))
+		w.decide(false)
+		_ = w.writeBuffered()
+	}
+	if w.zw != nil {
+		_ = w.zw.Close()
+	}
+}
+
+func (w *gzipWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,36 @@
+package proxy
+
+import (
+	"errors"
+	"io/fs"
+)
+
+// readHandoff imports the hot entries left by the previous instance.
+// A missing file is normal on first start; other failures only cost warmup.
+func (p *Proxy) readHandoff() {
+	n, err := p.cache.ReadHandoff(p.handoffPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	if p.logger == nil {
+		return
+	}
+	if err != nil {
+		p.logger.Error("cache handoff import failed: %v", err)
+		return
+	}
+	p.logger.Info("imported %d cache entries from handoff %s", n, p.handoffPath)
+}
+
+// writeHandoff exports the hottest entries for the next instance
+func (p *Proxy) writeHandoff() {
+	n, err := p.cache.WriteHandoff(p.handoffPath, p.handoffTopN)
+	if p.logger == nil {
+		return
+	}
+	if err != nil {
+		p.logger.Error("cache handoff export failed: %v", err)
+		return
+	}
+	p.logger.Info("exported %d cache entries to handoff %s", n, p.handoffPath)
+}
@@ -0,0 +1,127 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxHealthBody caps how much of a health response is read for matching
+const maxHealthBody = 64 * 1024
+
+// HealthCheck configures active probing of the upstreams. Results are
+// reported in /stats and logged when an upstream changes state. With
+// several upstreams, unhealthy ones are skipped by the round-robin until
+// they pass a probe again.
+type HealthCheck struct {
+	Path                 string        // Probed path, default "/"
+	Interval             time.Duration // Time between probes (0 = disabled)
+	Timeout              time.Duration // Timeout of a single probe
+	Method               string        // Probe method, default GET
+	ExpectedStatus       []int         // Healthy statuses (empty = any below 500)
+	ExpectedBodyContains string        // Substring the body must contain (empty = any)
+	FailureThreshold     int           // Failed probes in a row marking an upstream unhealthy, default 1
+}
+
+// passes reports whether a probe response counts as healthy
+func (h *HealthCheck) passes(status int, body []byte) bool {
+	if len(h.ExpectedStatus) > 0 {
+		if !slices.Contains(h.ExpectedStatus, status) {
+			return false
+		}
+	} else if status >= 500 {
+		return false
+	}
+	return h.ExpectedBodyContains == "" || bytes.Contains(body, []byte(h.ExpectedBodyContains))
+}
+
+// probeUpstream sends one health check request to upstream
+func (p *Proxy) probeUpstream(ctx context.Context, upstream *url.URL) bool {
+	ctx, cancel := context.WithTimeout(ctx, p.health.Timeout)
+	defer cancel()
+
+	u := *upstream
+	u.Path = strings.TrimSuffix(u.Path, "/") + p.health.Path
+	u.RawPath = ""
+	u.RawQuery = ""
+	req, err := http.NewRequestWithContext(ctx, p.health.Method, u.String(), nil)
+	if err != nil {
+		return false
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		if p.logger != nil {
+			p.logger.Debug("health check failed: upstream=%s err=%v", upstream.Redacted(), err)
+		}
+		return false
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxHealthBody))
+	if err != nil {
+		return false
+	}
+	return p.health.passes(resp.StatusCode, body)
+}
+
+// runHealthChecks probes all upstreams in parallel every interval until
+// stop is closed
+func (p *Proxy) runHealthChecks(stop <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-stop
+		cancel()
+	}()
+
+	failures := make([]int, len(p.upstreams))
+	passed := make([]bool, len(p.upstreams))
+	ticker := time.NewTicker(p.health.Interval)
+	defer ticker.Stop()
+	for {
+		var wg sync.WaitGroup
+		for i, u := range p.upstreams {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				passed[i] = p.probeUpstream(ctx, u)
+			}()
+		}
+		wg.Wait()
+		for i := range p.upstreams {
+			if passed[i] {
+				failures[i] = 0
+			} else {
+				failures[i]++
+			}
+			p.recordHealth(i, failures[i] < p.health.FailureThreshold)
+		}
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// recordHealth stores the state of upstream i, logging changes, and
+// updates the overall upstream health: healthy while any upstream is
+func (p *Proxy) recordHealth(i int, healthy bool) {
+	if p.upstreamDown[i].Swap(!healthy) != !healthy && p.logger != nil {
+		if healthy {
+			p.logger.Info("upstream healthy again: %s", p.upstreams[i].Redacted())
+		} else {
+			p.logger.Error("upstream failed health check: %s", p.upstreams[i].Redacted())
+		}
+	}
+	anyHealthy := false
+	for j := range p.upstreamDown {
+		anyHealthy = anyHealthy || !p.upstreamDown[j].Load()
+	}
+	p.upstreamHealthy.Store(anyHealthy)
+}
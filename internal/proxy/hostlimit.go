@@ -0,0 +1,119 @@
+package proxy
+
+import (
+	"Aegis/internal/cache"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// hostEntries caps the number of cached entries per host so one busy host
+// can't take the whole cache. Each host evicts its own entries following
+// the eviction policy.
+type hostEntries struct {
+	mu      sync.Mutex
+	max     int
+	policy  string
+	hosts   map[string]cache.Evictor // host -> usage of its keys
+	keyHost map[string]string        // key -> host it is counted for
+}
+
+func newHostEntries(max int, policy string) *hostEntries {
+	return &hostEntries{
+		max:     max,
+		policy:  policy,
+		hosts:   make(map[string]cache.Evictor),
+		keyHost: make(map[string]string),
+	}
+}
+
+// touch records use of key by host and returns the keys that have to be
+// evicted to keep host within its cap
+func (h *hostEntries) touch(host, key string) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if kh, ok := h.keyHost[key]; ok {
+		h.hosts[kh].Access(key)
+		return nil
+	}
+	ev := h.hosts[host]
+	if ev == nil {
+		ev = cache.NewEvictor(h.policy, h.max)
+		h.hosts[host] = ev
+	}
+	ev.Add(key)
+	h.keyHost[key] = host
+
+	var evicted []string
+	for ev.Len() > h.max {
+		victim, _ := ev.Victim()
+		ev.Remove(victim)
+		delete(h.keyHost, victim)
+		evicted = append(evicted, victim)
+	}
+	return evicted
+}
+
+// forget stops counting key, which left the cache some other way, and
+// drops its host once the host has no entries left
+func (h *hostEntries) forget(key string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	host, ok := h.keyHost[key]
+	if !ok {
+		return
+	}
+	delete(h.keyHost, key)
+	ev := h.hosts[host]
+	ev.Remove(key)
+	if ev.Len() == 0 {
+		delete(h.hosts, host)
+	}
+}
+
+// count returns the number of tracked entries of host
+func (h *hostEntries) count(host string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if ev := h.hosts[host]; ev != nil {
+		return ev.Len()
+	}
+	return 0
+}
+
+// hostCount returns the number of hosts with tracked entries
+func (h *hostEntries) hostCount() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.hosts)
+}
+
+// requestHost returns the lower-cased Host a request was sent to
+func requestHost(r *http.Request) string {
+	return strings.ToLower(r.Host)
+}
+
+// touchHostEntry records use of a cached entry for the per-host cap and
+// evicts the host's entries beyond it
+func (p *Proxy) touchHostEntry(r *http.Request, key string) {
+	if p.hostEntries == nil {
+		return
+	}
+	for _, evicted := range p.hostEntries.touch(requestHost(r), key) {
+		if err := p.store.Delete(evicted); err != nil {
+			if p.logger != nil {
+				p.logger.Error("cache backend delete failed: key=%s err=%v", evicted, err)
+			}
+			continue
+		}
+		p.emitEvent(EventEvict, evicted, 0, 0)
+	}
+}
+
+// forgetHostEntry stops counting a key that was expired, evicted or purged
+func (p *Proxy) forgetHostEntry(key string) {
+	if p.hostEntries != nil {
+		p.hostEntries.forget(key)
+	}
+}
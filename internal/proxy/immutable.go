@@ -0,0 +1,56 @@
+package proxy
+
+import (
+	"Aegis/internal/utils"
+	"net/http"
+	"strings"
+)
+
+// serveImmutable answers r from an entry that upstream marked immutable.
+// Such entries are never revalidated: max-age is ignored, both here and when
+// storeTTL picks their lifetime, and the entry is used until the configured
+// TTL expires or it is evicted. It returns false when the request
+// must go upstream.
+func (p *Proxy) serveImmutable(w http.ResponseWriter, r *http.Request, key string) bool {
+	if !p.immutable || !p.mayShareEntry(r) || p.requestNoCache(r) {
+		return false
+	}
+	cached, ok, err := p.fetchEntry(r, key)
+	if err != nil || !ok || !hasCacheControl(cached.Header, "immutable") {
+		return false
+	}
+	if p.adaptive != nil {
+		p.adaptive.recordHit(r.URL.Path)
+	}
+	p.touchHostEntry(r, key)
+	if p.logger != nil {
+		p.logger.Debug("serving immutable entry from cache: key=%s", key)
+	}
+	utils.CopyHeadersForClient(w.Header(), cached.Header)
+	p.setServedBy(w)
+	p.setCacheStatus(w, CacheHit)
+	p.recordSavings(r, CacheHit, cached.Body)
+	w.WriteHeader(cached.Status)
+	_, _ = w.Write(cached.Body)
+	return true
+}
+
+// requestNoCache reports whether the client demands a fresh response with
+// Cache-Control: no-cache, or with Pragma: no-cache when honorPragma is set.
+// Pragma is ignored when Cache-Control is present (RFC 9111 section 5.4).
+func (p *Proxy) requestNoCache(r *http.Request) bool {
+	if len(r.Header.Values("Cache-Control")) > 0 {
+		return hasCacheControl(r.Header, "no-cache")
+	}
+	if !p.honorPragma {
+		return false
+	}
+	for _, v := range r.Header.Values("Pragma") {
+		for _, d := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(d), "no-cache") {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+package proxy
+
+import (
+	"Aegis/internal/utils"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+)
+
+// Cache key hash functions
+const (
+	KeyHashNone   = "none"
+	KeyHashSHA256 = "sha256"
+	KeyHashXXHash = "xxhash"
+)
+
+// hashKey reduces a composed cache key to a fixed-length digest
+// according to the configured hash function. Without one, keys longer
+// than hashLongKeys bytes are still reduced to their SHA-256 digest.
+func (p *Proxy) hashKey(key string) string {
+	switch p.keyHash {
+	case KeyHashSHA256:
+		return sha256Key(key)
+	case KeyHashXXHash:
+		return "xxhash:" + strconv.FormatUint(utils.XXHash64([]byte(key)), 16)
+	default:
+		if p.hashLongKeys > 0 && len(key) > p.hashLongKeys {
+			return sha256Key(key)
+		}
+		return key
+	}
+}
+
+func sha256Key(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// limitKeyValue replaces a header value longer than maxKeyValueBytes with
+// its SHA-256 digest, bounding key size without making distinct values collide
+func (p *Proxy) limitKeyValue(value string) string {
+	if p.maxKeyValueBytes <= 0 || len(value) <= p.maxKeyValueBytes {
+		return value
+	}
+	sum := sha256.Sum256([]byte(value))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,51 @@
+package proxy
+
+import (
+	"slices"
+	"time"
+)
+
+// MaintenanceWindow is a recurring daily period during which upstream is
+// known to be down. Start and End are offsets from midnight; a window with
+// End before Start runs past midnight.
+type MaintenanceWindow struct {
+	Start time.Duration
+	End   time.Duration
+	Days  []time.Weekday // Days the window starts on (empty = every day)
+}
+
+// maintenance evaluates maintenance windows in a fixed location
+type maintenance struct {
+	windows []MaintenanceWindow
+	loc     *time.Location
+}
+
+// active reports whether now falls inside a window and how long it lasts
+func (m *maintenance) active(now time.Time) (bool, time.Duration) {
+	now = now.In(m.loc)
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, m.loc)
+	offset := now.Sub(midnight)
+	day := now.Weekday()
+	yesterday := (day + 6) % 7
+
+	for _, w := range m.windows {
+		if w.Start <= w.End {
+			if w.startsOn(day) && offset >= w.Start && offset < w.End {
+				return true, w.End - offset
+			}
+			continue
+		}
+		// Crosses midnight: evening part today or morning part of yesterday's window
+		if w.startsOn(day) && offset >= w.Start {
+			return true, 24*time.Hour - offset + w.End
+		}
+		if w.startsOn(yesterday) && offset < w.End {
+			return true, w.End - offset
+		}
+	}
+	return false, 0
+}
+
+func (w MaintenanceWindow) startsOn(day time.Weekday) bool {
+	return len(w.Days) == 0 || slices.Contains(w.Days, day)
+}
@@ -0,0 +1,145 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// MaintenancePath takes the paths under Prefix offline: while Enabled,
+// requests get the static response instead of upstream or cache
+type MaintenancePath struct {
+	Prefix      string
+	Status      int    // Response status (0 = 503)
+	Body        string // Response body
+	ContentType string // Response Content-Type (empty = text/plain)
+	Enabled     bool
+}
+
+// maintenanceRule is a MaintenancePath whose state can be toggled at runtime
+type maintenanceRule struct {
+	MaintenancePath
+	enabled atomic.Bool
+}
+
+// maintenancePaths matches request paths to rules, longest prefix first
+type maintenancePaths struct {
+	rules []*maintenanceRule
+}
+
+func newMaintenancePaths(paths []MaintenancePath) *maintenancePaths {
+	m := &maintenancePaths{}
+	for _, mp := range paths {
+		if mp.Status == 0 {
+			mp.Status = http.StatusServiceUnavailable
+		}
+		if mp.ContentType == "" {
+			mp.ContentType = "text/plain; charset=utf-8"
+		}
+		rule := &maintenanceRule{MaintenancePath: mp}
+		rule.enabled.Store(mp.Enabled)
+		m.rules = append(m.rules, rule)
+	}
+	sort.SliceStable(m.rules, func(i, j int) bool {
+		return len(m.rules[i].Prefix) > len(m.rules[j].Prefix)
+	})
+	return m
+}
+
+// match returns the enabled rule for path, if any. The longest matching
+// prefix decides, so a disabled /api/checkout/status can stay online
+// under an enabled /api/checkout.
+func (m *maintenancePaths) match(path string) *maintenanceRule {
+	for _, rule := range m.rules {
+		if strings.HasPrefix(path, rule.Prefix) {
+			if rule.enabled.Load() {
+				return rule
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// find returns the rule with exactly this prefix
+func (m *maintenancePaths) find(prefix string) *maintenanceRule {
+	for _, rule := range m.rules {
+		if rule.Prefix == prefix {
+			return rule
+		}
+	}
+	return nil
+}
+
+// serveMaintenancePath answers a request under an enabled maintenance
+// path with its static response. It returns false for other requests.
+func (p *Proxy) serveMaintenancePath(w http.ResponseWriter, r *http.Request) bool {
+	if p.maintenancePaths == nil {
+		return false
+	}
+	rule := p.maintenancePaths.match(r.URL.Path)
+	if rule == nil {
+		return false
+	}
+	if p.logger != nil {
+		p.logger.Debug("path under maintenance, serving static response: %s %s", r.Method, r.URL.Path)
+	}
+	p.setServedBy(w)
+	p.setCacheStatus(w, CacheBypass)
+	w.Header().Set("Content-Type", rule.ContentType)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(rule.Status)
+	if r.Method != http.MethodHead {
+		_, _ = w.Write([]byte(rule.Body))
+	}
+	return true
+}
+
+// maintenancePathState is one entry of the /maintenance listing
+type maintenancePathState struct {
+	Prefix  string `json:"prefix"`
+	Status  int    `json:"status"`
+	Enabled bool   `json:"enabled"`
+}
+
+// MaintenanceHandler lists the configured maintenance paths with GET and
+// switches one on or off with POST prefix=/api/checkout&enabled=true|false.
+// Only configured prefixes can be toggled; changes last until restart.
+func (p *Proxy) MaintenanceHandler(w http.ResponseWriter, r *http.Request) {
+	if p.maintenancePaths == nil {
+		http.Error(w, "Not Found: no maintenance paths configured", http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		rule := p.maintenancePaths.find(r.FormValue("prefix"))
+		if rule == nil {
+			http.Error(w, "Not Found: unknown maintenance prefix", http.StatusNotFound)
+			return
+		}
+		enabled, err := strconv.ParseBool(r.FormValue("enabled"))
+		if err != nil {
+			http.Error(w, "Bad Request: enabled must be true or false", http.StatusBadRequest)
+			return
+		}
+		rule.enabled.Store(enabled)
+		if p.logger != nil {
+			p.logger.Info("maintenance path %s enabled=%t", rule.Prefix, enabled)
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	states := make([]maintenancePathState, 0, len(p.maintenancePaths.rules))
+	for _, rule := range p.maintenancePaths.rules {
+		states = append(states, maintenancePathState{Prefix: rule.Prefix, Status: rule.Status, Enabled: rule.enabled.Load()})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(states)
+}
@@ -0,0 +1,45 @@
+package proxy
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Handling of request methods outside the standard set
+const (
+	MethodsForward   = "forward"   // pass them upstream unchanged
+	MethodsReject    = "reject"    // reply 501 Not Implemented
+	MethodsNormalize = "normalize" // uppercase, then pass upstream
+)
+
+// standardMethods are the methods defined by RFC 9110 and RFC 5789
+var standardMethods = map[string]struct{}{
+	http.MethodGet:     {},
+	http.MethodHead:    {},
+	http.MethodPost:    {},
+	http.MethodPut:     {},
+	http.MethodPatch:   {},
+	http.MethodDelete:  {},
+	http.MethodOptions: {},
+	http.MethodTrace:   {},
+	http.MethodConnect: {},
+}
+
+// normalizeMethod rewrites r.Method in place. Standard methods sent in the
+// wrong case are always uppercased, so "get" is cached like GET; other
+// methods (e.g. WebDAV PROPFIND) follow the configured policy. It returns
+// false when the request must be rejected.
+func (p *Proxy) normalizeMethod(r *http.Request) bool {
+	upper := strings.ToUpper(r.Method)
+	if _, ok := standardMethods[upper]; ok {
+		r.Method = upper
+		return true
+	}
+	switch p.methodPolicy {
+	case MethodsReject:
+		return false
+	case MethodsNormalize:
+		r.Method = upper
+	}
+	return true
+}
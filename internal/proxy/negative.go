@@ -0,0 +1,39 @@
+package proxy
+
+import (
+	"Aegis/internal/utils"
+	"net/http"
+	"slices"
+)
+
+// DefaultNegativeStatuses are cached for negativeTTL when no list is configured
+var DefaultNegativeStatuses = []int{http.StatusNotFound}
+
+// negativeStatus reports whether responses with status are cached for
+// negativeTTL
+func (p *Proxy) negativeStatus(status int) bool {
+	return p.negativeTTL > 0 && slices.Contains(p.negativeStatuses, status)
+}
+
+// serveNegative answers r from a fresh negatively cached entry, such as a
+// 404, without contacting upstream. It returns false when the request must
+// go upstream.
+func (p *Proxy) serveNegative(w http.ResponseWriter, r *http.Request, key string) bool {
+	if p.negativeTTL <= 0 || !p.mayShareEntry(r) || p.requestNoCache(r) {
+		return false
+	}
+	cached, ok, err := p.fetchEntry(r, key)
+	if err != nil || !ok || !p.negativeStatus(cached.Status) {
+		return false
+	}
+	if p.logger != nil {
+		p.logger.Debug("serving negatively cached entry: key=%s status=%d", key, cached.Status)
+	}
+	utils.CopyHeadersForClient(w.Header(), cached.Header)
+	p.setServedBy(w)
+	p.setCacheStatus(w, CacheHitNegative)
+	p.recordSavings(r, CacheHitNegative, cached.Body)
+	w.WriteHeader(cached.Status)
+	_, _ = w.Write(cached.Body)
+	return true
+}
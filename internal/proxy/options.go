@@ -0,0 +1,819 @@
+package proxy
+
+import (
+	"Aegis/internal/cache"
+	"Aegis/internal/utils"
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Option configures optional proxy behavior
+type Option func(*Proxy)
+
+// WithCircuitBreaker enables a circuit breaker that stops contacting upstream
+// after threshold consecutive failures and retries after cooldown
+func WithCircuitBreaker(threshold int, cooldown time.Duration) Option {
+	return func(p *Proxy) {
+		if threshold > 0 {
+			p.breaker = newBreaker(threshold, cooldown)
+		}
+	}
+}
+
+// WithReadyWhenCached makes /readyz report ready while the upstream is down
+// as long as the cache holds at least one entry
+func WithReadyWhenCached(enabled bool) Option {
+	return func(p *Proxy) {
+		p.readyWhenCached = enabled
+	}
+}
+
+// WithBodyDump enables debug logging of the leading bytes of request bodies
+func WithBodyDump(cfg BodyDump) Option {
+	return func(p *Proxy) {
+		if cfg.MaxBytes > 0 {
+			p.bodyDump = newBodyDumper(cfg)
+		}
+	}
+}
+
+// WithAdmission enables the global admission controller
+func WithAdmission(cfg Admission) Option {
+	return func(p *Proxy) {
+		if cfg.MaxInFlight > 0 {
+			p.admission = newAdmission(cfg)
+		}
+	}
+}
+
+// WithRateLimit limits the request rate globally and per path prefix.
+// Requests over the limit get 429 Too Many Requests.
+func WithRateLimit(cfg RateLimit) Option {
+	return func(p *Proxy) {
+		if cfg.Rate > 0 || len(cfg.Paths) > 0 {
+			p.rateLimiter = newRateLimiter(cfg)
+		}
+	}
+}
+
+// WithTrustedProxies sets the networks whose forwarding headers are trusted
+func WithTrustedProxies(nets []*net.IPNet) Option {
+	return func(p *Proxy) {
+		p.trustedProxies = nets
+	}
+}
+
+// WithForwardedHeaders sets whether upstream requests carry
+// X-Forwarded-For, X-Forwarded-Host and X-Forwarded-Proto (on by default)
+func WithForwardedHeaders(enabled bool) Option {
+	return func(p *Proxy) {
+		p.forwardedHeaders = enabled
+	}
+}
+
+// WithSchemeInKey adds the effective request scheme to the cache key
+func WithSchemeInKey(enabled bool) Option {
+	return func(p *Proxy) {
+		p.keyScheme = enabled
+	}
+}
+
+// WithClock replaces the wall clock used for expiry, time buckets and
+// rolling stats. Meant for tests.
+func WithClock(clock utils.Clock) Option {
+	return func(p *Proxy) {
+		p.clock = clock
+	}
+}
+
+// WithCountryKey adds the ISO country code from header (e.g. X-Country set
+// by a GeoIP-aware load balancer) to cache keys; missing or invalid codes
+// use fallback
+func WithCountryKey(header, fallback string) Option {
+	return func(p *Proxy) {
+		p.countryHeader = header
+		p.countryDefault = fallback
+	}
+}
+
+// WithIgnoredQueryParams leaves the named query parameters out of cache
+// keys while still forwarding them upstream. A trailing "*" matches a
+// prefix; see AnalyticsQueryParams for a ready-made list.
+func WithIgnoredQueryParams(names []string) Option {
+	return func(p *Proxy) {
+		p.ignoreParams = append(p.ignoreParams, names...)
+	}
+}
+
+// WithHostInKey adds the request Host to cache keys
+func WithHostInKey(enabled bool) Option {
+	return func(p *Proxy) {
+		p.keyHost = enabled
+	}
+}
+
+// WithMaxEntries caps the number of entries in the in-memory cache,
+// evicting by the eviction policy (0 = unlimited)
+func WithMaxEntries(max int) Option {
+	return func(p *Proxy) {
+		p.maxEntries = max
+	}
+}
+
+// WithMaxMemory bounds the approximate memory used by the in-memory cache,
+// evicting by the eviction policy; a single larger response is not cached
+// (0 = unlimited)
+func WithMaxMemory(maxBytes int64) Option {
+	return func(p *Proxy) {
+		p.maxMemory = maxBytes
+	}
+}
+
+// WithCompression stores text-like bodies of at least minBytes
+// gzip-compressed in the in-memory cache (0 = disabled)
+func WithCompression(minBytes int) Option {
+	return func(p *Proxy) {
+		p.compressMin = minBytes
+	}
+}
+
+// WithResponseCompression gzip-compresses text-like responses of at least
+// minBytes for clients that accept it, unless upstream already encoded
+// them (0 = disabled)
+func WithResponseCompression(minBytes int) Option {
+	return func(p *Proxy) {
+		p.gzipMin = minBytes
+	}
+}
+
+// WithSweepInterval removes expired entries from the in-memory cache every
+// interval, so keys that are never read again don't hold memory (0 = never)
+func WithSweepInterval(interval time.Duration) Option {
+	return func(p *Proxy) {
+		p.sweepInterval = interval
+	}
+}
+
+// WithHandoff imports the entries saved at path on startup and, on Close,
+// exports the topN most used entries there for the next instance (0 = all)
+func WithHandoff(path string, topN int) Option {
+	return func(p *Proxy) {
+		p.handoffPath = path
+		p.handoffTopN = topN
+	}
+}
+
+// WithMaxEntriesPerHost caps cached entries per Host, evicting each host's
+// entries by the eviction policy (0 = no cap)
+func WithMaxEntriesPerHost(max int) Option {
+	return func(p *Proxy) {
+		p.maxEntriesPerHost = max
+	}
+}
+
+// WithEvictionPolicy selects how entries are evicted when max entries or
+// max entries per host is reached: cache.EvictLRU (default) or
+// cache.EvictSLRU
+func WithEvictionPolicy(policy string) Option {
+	return func(p *Proxy) {
+		p.evictionPolicy = policy
+	}
+}
+
+// WithTimeBucket adds floor(now / bucket) to cache keys so entries roll
+// over at bucket boundaries (0 = disabled)
+func WithTimeBucket(bucket time.Duration) Option {
+	return func(p *Proxy) {
+		p.timeBucket = bucket
+	}
+}
+
+// WithMaxKeyValueBytes hashes key header values longer than n bytes
+// (0 = no limit)
+func WithMaxKeyValueBytes(n int) Option {
+	return func(p *Proxy) {
+		p.maxKeyValueBytes = n
+	}
+}
+
+// WithServedBy sets the X-Served-By value; empty omits the header
+func WithServedBy(value string) Option {
+	return func(p *Proxy) {
+		p.servedBy = value
+	}
+}
+
+// WithNonStandardMethods sets how methods outside the standard set are
+// handled: MethodsForward (default), MethodsReject or MethodsNormalize.
+// Standard methods are always uppercased.
+func WithNonStandardMethods(policy string) Option {
+	return func(p *Proxy) {
+		p.methodPolicy = policy
+	}
+}
+
+// WithDefaultHost substitutes host for requests sent without Host;
+// without it such requests are rejected with 400
+func WithDefaultHost(host string) Option {
+	return func(p *Proxy) {
+		p.defaultHost = host
+	}
+}
+
+// WithStatusHeader selects which cache status header(s) are emitted:
+// x-cache (default), cache-status (RFC 9211) or both
+func WithStatusHeader(mode string) Option {
+	return func(p *Proxy) {
+		p.statusHeader = mode
+	}
+}
+
+// WithGetBodyPolicy sets how GET/HEAD requests carrying a body are handled:
+// ignore (default), reject or key
+func WithGetBodyPolicy(policy string) Option {
+	return func(p *Proxy) {
+		p.getBodyPolicy = policy
+	}
+}
+
+// WithGetBodyKeyFields limits the body digest of the key policy to these
+// dotted JSON field paths, when the body is a JSON object
+func WithGetBodyKeyFields(fields []string) Option {
+	return func(p *Proxy) {
+		p.getBodyKeyFields = fields
+	}
+}
+
+// WithRewriteRules sets the upstream URL rewrite rules, tried in order
+func WithRewriteRules(rules []RewriteRule) Option {
+	return func(p *Proxy) {
+		p.rewriteRules = rules
+	}
+}
+
+// WithErrorCapture keeps the last n upstream error responses for /errors
+func WithErrorCapture(n int) Option {
+	return func(p *Proxy) {
+		if n > 0 {
+			p.errorLog = newErrorRing(n)
+		}
+	}
+}
+
+// WithIdleConnTimeout overrides how long idle upstream connections are kept
+func WithIdleConnTimeout(d time.Duration) Option {
+	return func(p *Proxy) {
+		if d > 0 {
+			p.transport.IdleConnTimeout = d
+		}
+	}
+}
+
+// WithDisableKeepAlives disables upstream connection reuse
+func WithDisableKeepAlives(disabled bool) Option {
+	return func(p *Proxy) {
+		p.transport.DisableKeepAlives = disabled
+	}
+}
+
+// WithOutboundProxy routes upstream connections through an http(s) or
+// socks5 proxy URL. Empty uses HTTP_PROXY/HTTPS_PROXY, OutboundDirect
+// never uses a proxy.
+func WithOutboundProxy(proxyURL string) Option {
+	return func(p *Proxy) {
+		p.outboundProxy = proxyURL
+	}
+}
+
+// WithBodySizeRange only caches responses whose body size is within
+// [minBytes, maxBytes]; maxBytes <= 0 means no upper bound
+func WithBodySizeRange(minBytes, maxBytes int) Option {
+	return func(p *Proxy) {
+		p.minBodyBytes = minBytes
+		p.maxBodyBytes = maxBytes
+	}
+}
+
+// WithOversizePolicy sets what happens to a response whose body turns out
+// larger than the max body size while it is buffered: OversizeStream
+// (default) or OversizeReject
+func WithOversizePolicy(policy string) Option {
+	return func(p *Proxy) {
+		p.oversizePolicy = policy
+	}
+}
+
+// WithStreaming streams 2xx GET responses larger than thresholdBytes (or of
+// unknown length) to the client while caching them (0 = always buffer)
+func WithStreaming(thresholdBytes int) Option {
+	return func(p *Proxy) {
+		p.streamThreshold = thresholdBytes
+	}
+}
+
+// WithBypassHeader sets the upstream response header that prevents caching
+// of that response (X-Cache: PASS). An empty value matches any non-empty
+// header; an empty name disables the check. Defaults to X-Cache: no-store.
+func WithBypassHeader(name, value string) Option {
+	return func(p *Proxy) {
+		p.bypassHeader = name
+		p.bypassValue = value
+	}
+}
+
+// WithSkipEmptyBody refuses to cache 2xx responses with a zero-length body
+func WithSkipEmptyBody(enabled bool) Option {
+	return func(p *Proxy) {
+		p.skipEmptyBody = enabled
+	}
+}
+
+// WithKeyHash hashes cache keys to a fixed-length digest:
+// none (default), sha256 or xxhash
+func WithKeyHash(fn string) Option {
+	return func(p *Proxy) {
+		p.keyHash = fn
+	}
+}
+
+// WithTTLRules sets the configured TTL per path; the first matching rule
+// wins and the global ttl applies when none matches
+func WithTTLRules(rules []TTLRule) Option {
+	return func(p *Proxy) {
+		p.ttlRules = rules
+	}
+}
+
+// WithHashLongKeys replaces plaintext cache keys longer than maxBytes by
+// their SHA-256 digest, keeping short keys readable (0 = never)
+func WithHashLongKeys(maxBytes int) Option {
+	return func(p *Proxy) {
+		p.hashLongKeys = maxBytes
+	}
+}
+
+// Cache backend failure policies
+const (
+	BackendFailOpen   = "fail_open"   // treat as a cache miss and keep proxying
+	BackendFailClosed = "fail_closed" // reply 503 Service Unavailable
+)
+
+// backendRetryDelay separates retries of a failed cache backend operation
+const backendRetryDelay = 5 * time.Millisecond
+
+// WithStore replaces the in-memory cache with another storage backend
+func WithStore(store cache.Store) Option {
+	return func(p *Proxy) {
+		if store != nil {
+			p.store = store
+		}
+	}
+}
+
+// WithBackendFailurePolicy sets how cache backend errors are handled:
+// fail_open (default) or fail_closed
+func WithBackendFailurePolicy(policy string) Option {
+	return func(p *Proxy) {
+		p.backendPolicy = policy
+	}
+}
+
+// WithBackendRetries retries failed cache backend operations up to n times
+// before the backend failure policy applies
+func WithBackendRetries(n int) Option {
+	return func(p *Proxy) {
+		p.backendRetries = n
+	}
+}
+
+// WithSharedAuthBackup allows serving HIT-BACKUP responses to requests carrying
+// Authorization even when Authorization is not part of the cache key
+func WithSharedAuthBackup(allowed bool) Option {
+	return func(p *Proxy) {
+		p.sharedAuthBackup = allowed
+	}
+}
+
+// WithStatsLogInterval periodically logs a stats snapshot (0 = disabled)
+func WithStatsLogInterval(d time.Duration) Option {
+	return func(p *Proxy) {
+		p.statsInterval = d
+	}
+}
+
+// WithTTLPrecedence sets the order in which TTL signals (TTLSignal*) are
+// consulted when storing a response; the first one present wins. An empty
+// list keeps DefaultTTLPrecedence.
+func WithTTLPrecedence(signals []string) Option {
+	return func(p *Proxy) {
+		if len(signals) > 0 {
+			p.ttlPrecedence = signals
+		}
+	}
+}
+
+// WithRespectOriginTTL takes the TTL from the origin's Cache-Control
+// max-age or Expires (OriginTTLPrecedence, unless WithTTLPrecedence sets
+// another order) and treats the configured ttl as their cap
+func WithRespectOriginTTL(enabled bool) Option {
+	return func(p *Proxy) {
+		p.respectOriginTTL = enabled
+	}
+}
+
+// WithCoalescing makes concurrent GET/HEAD requests for the same cache key
+// wait for a single upstream fetch and share its response as COALESCED
+func WithCoalescing(enabled bool) Option {
+	return func(p *Proxy) {
+		p.flights = nil
+		if enabled {
+			p.flights = newFlights()
+		}
+	}
+}
+
+// WithExtraUpstreams adds upstreams that share requests round-robin with
+// the one passed to New. They must serve the same content, as they share
+// cache entries.
+func WithExtraUpstreams(urls []string) Option {
+	return func(p *Proxy) {
+		p.extraUpstreams = urls
+	}
+}
+
+// WithRetries sends GET and HEAD requests without a body up to retries
+// more times when upstream fails or answers with a failover status, before
+// falling back to the cache. The delay starts at backoff and doubles per
+// attempt, with jitter (backoff 0 = DefaultRetryBackoff).
+func WithRetries(retries int, backoff time.Duration) Option {
+	return func(p *Proxy) {
+		if backoff <= 0 {
+			backoff = DefaultRetryBackoff
+		}
+		p.retries = retries
+		p.retryBackoff = backoff
+	}
+}
+
+// WithCacheableStatus sets the upstream statuses that are cached. An empty
+// list keeps DefaultCacheableStatus.
+func WithCacheableStatus(ranges []StatusRange) Option {
+	return func(p *Proxy) {
+		if len(ranges) > 0 {
+			p.cacheableStatus = ranges
+		}
+	}
+}
+
+// WithFailoverStatus sets the upstream statuses that are answered with a
+// backup from the cache, when there is one. An empty list keeps
+// DefaultFailoverStatus.
+func WithFailoverStatus(ranges []StatusRange) Option {
+	return func(p *Proxy) {
+		if len(ranges) > 0 {
+			p.failoverStatus = ranges
+		}
+	}
+}
+
+// WithNegativeCaching caches responses with one of statuses, such as 404,
+// for ttl and serves them as HIT-NEGATIVE without contacting upstream
+// (ttl 0 = disabled, no statuses = DefaultNegativeStatuses)
+func WithNegativeCaching(ttl time.Duration, statuses []int) Option {
+	return func(p *Proxy) {
+		if len(statuses) == 0 {
+			statuses = DefaultNegativeStatuses
+		}
+		p.negativeTTL = ttl
+		p.negativeStatuses = statuses
+	}
+}
+
+// WithSavingsStats reports in /stats the requests and body bytes served
+// from cache instead of upstream
+func WithSavingsStats(enabled bool) Option {
+	return func(p *Proxy) {
+		if enabled {
+			p.savings = &savings{}
+		}
+	}
+}
+
+// WithStaleWhileRevalidate serves entries that expired less than window
+// ago as STALE and refreshes them in the background (0 = disabled).
+// Expired entries are kept that long before the sweeper removes them.
+func WithStaleWhileRevalidate(window time.Duration) Option {
+	return func(p *Proxy) {
+		p.staleWindow = window
+	}
+}
+
+// WithMaxStale refuses backups saved longer than maxStale ago, answering
+// 502 instead (0 = backups of any age are served)
+func WithMaxStale(maxStale time.Duration) Option {
+	return func(p *Proxy) {
+		p.maxStale = maxStale
+	}
+}
+
+// WithETagRevalidation sends If-None-Match with the ETag of a cached entry,
+// fresh or expired, and serves the cached body as REVALIDATED on 304
+func WithETagRevalidation(enabled bool) Option {
+	return func(p *Proxy) {
+		p.etagRevalidation = enabled
+	}
+}
+
+// WithHeuristicFreshness derives a TTL of fraction * (now - Last-Modified)
+// for responses without explicit freshness when no TTL is configured.
+// The heuristic TTL is capped by maxTTL when positive.
+func WithHeuristicFreshness(fraction float64, maxTTL time.Duration) Option {
+	return func(p *Proxy) {
+		p.heuristicFraction = fraction
+		p.maxTTL = maxTTL
+	}
+}
+
+// HEAD response caching policies. HEAD entries never share a key
+// with GET entries, so a HEAD can't replace a body-bearing GET entry.
+const (
+	HeadCacheSeparate = "separate" // cache HEAD metadata under its own key
+	HeadCacheNone     = "none"     // never cache HEAD responses
+)
+
+// WithHeadPolicy sets how HEAD responses are cached: separate (default) or none
+func WithHeadPolicy(policy string) Option {
+	return func(p *Proxy) {
+		p.headPolicy = policy
+	}
+}
+
+// WithAllowedUpstreamHosts allows requests to hosts other than the configured
+// upstream, e.g. via absolute rewrite targets. Entries are hostnames or host:port.
+func WithAllowedUpstreamHosts(hosts []string) Option {
+	return func(p *Proxy) {
+		p.allowedHosts = hosts
+	}
+}
+
+// WithErrorBodyDetection treats 2xx responses whose body contains one of
+// e.Contains as upstream failures: a backup is served if there is one, and
+// the page is never cached
+func WithErrorBodyDetection(e ErrorBody) Option {
+	return func(p *Proxy) {
+		if len(e.Contains) == 0 {
+			return
+		}
+		p.errorBody = &e
+	}
+}
+
+// WithTransform pipes successful response bodies through an external command
+func WithTransform(t Transform) Option {
+	return func(p *Proxy) {
+		if len(t.Command) == 0 {
+			return
+		}
+		if t.Timeout <= 0 {
+			t.Timeout = time.Second
+		}
+		if t.MaxBytes <= 0 {
+			t.MaxBytes = 1 << 20
+		}
+		p.transform = &t
+	}
+}
+
+// WithImmutable serves entries stored with Cache-Control: immutable directly
+// from cache, without contacting upstream, until they expire or are evicted
+func WithImmutable(enabled bool) Option {
+	return func(p *Proxy) {
+		p.immutable = enabled
+	}
+}
+
+// WithTTLRequestHeader honors X-Aegis-Cache-TTL from trusted proxy addresses
+func WithTTLRequestHeader(enabled bool) Option {
+	return func(p *Proxy) {
+		p.allowTTLHeader = enabled
+	}
+}
+
+// WithMaintenanceWindows serves from cache without contacting upstream
+// during the given windows, evaluated in loc (nil = UTC)
+func WithMaintenanceWindows(windows []MaintenanceWindow, loc *time.Location) Option {
+	return func(p *Proxy) {
+		if len(windows) == 0 {
+			return
+		}
+		if loc == nil {
+			loc = time.UTC
+		}
+		p.maintenance = &maintenance{windows: windows, loc: loc}
+	}
+}
+
+// WithMaintenancePaths serves a static response for the enabled path
+// prefixes, bypassing upstream and cache; MaintenanceHandler toggles them
+func WithMaintenancePaths(paths []MaintenancePath) Option {
+	return func(p *Proxy) {
+		if len(paths) > 0 {
+			p.maintenancePaths = newMaintenancePaths(paths)
+		}
+	}
+}
+
+// WithHealthCheck probes every upstream periodically (Interval > 0) and
+// takes failing ones out of rotation
+func WithHealthCheck(h HealthCheck) Option {
+	return func(p *Proxy) {
+		if h.Interval <= 0 {
+			return
+		}
+		if h.Path == "" {
+			h.Path = "/"
+		}
+		if h.Method == "" {
+			h.Method = http.MethodGet
+		}
+		if h.Timeout <= 0 {
+			h.Timeout = 2 * time.Second
+		}
+		if h.FailureThreshold <= 0 {
+			h.FailureThreshold = 1
+		}
+		p.health = &h
+	}
+}
+
+// WithShadow mirrors a sample of GET and HEAD requests to a shadow upstream
+func WithShadow(s Shadow) Option {
+	return func(p *Proxy) {
+		if s.Upstream == "" {
+			return
+		}
+		if s.Timeout <= 0 {
+			s.Timeout = 5 * time.Second
+		}
+		p.shadow = &s
+	}
+}
+
+// WithNoopPaths answers GET and HEAD requests for the exact given paths with
+// 204 No Content, without contacting upstream or caching
+func WithNoopPaths(paths []string) Option {
+	return func(p *Proxy) {
+		if len(paths) == 0 {
+			return
+		}
+		p.noopPaths = make(map[string]struct{}, len(paths))
+		for _, path := range paths {
+			p.noopPaths[path] = struct{}{}
+		}
+	}
+}
+
+// WithHonorPragma treats a request Pragma: no-cache like Cache-Control: no-cache
+func WithHonorPragma(enabled bool) Option {
+	return func(p *Proxy) {
+		p.honorPragma = enabled
+	}
+}
+
+// WithMaxRevalidationsPerKey limits concurrent background refreshes of one
+// cache key; further triggers are dropped while they run (default 1)
+func WithMaxRevalidationsPerKey(n int) Option {
+	return func(p *Proxy) {
+		p.revalidationsPerKey = n
+	}
+}
+
+// WithBackgroundWorkers limits the number of concurrent background upstream
+// fetches; further fetches queue for a free worker
+func WithBackgroundWorkers(n int) Option {
+	return func(p *Proxy) {
+		p.backgroundWorkers = n
+	}
+}
+
+// WithVaryKeyHeaders adds the cache key headers to the Vary header of
+// responses, next to whatever upstream already sent
+func WithVaryKeyHeaders(enabled bool) Option {
+	return func(p *Proxy) {
+		p.varyKeyHeaders = enabled
+	}
+}
+
+// WithEvents delivers cache events (store, evict, ...) to a webhook and/or
+// an event log file in the background. Events are dropped, never waited
+// for, when the queue is full.
+func WithEvents(cfg Events) Option {
+	return func(p *Proxy) {
+		if cfg.WebhookURL != "" || cfg.LogFile != "" {
+			p.eventsCfg = &cfg
+		}
+	}
+}
+
+// WithPreload sets the entries fetched by Preload. The proxy reports not
+// ready from New until Preload has run.
+func WithPreload(entries []PreloadEntry) Option {
+	return func(p *Proxy) {
+		p.preload = entries
+	}
+}
+
+// WithWebSocketIdleTimeout closes upgraded (WebSocket) connections after d
+// without traffic. Upgraded connections ignore the request timeout.
+// A negative d disables the idle timeout.
+func WithWebSocketIdleTimeout(d time.Duration) Option {
+	return func(p *Proxy) {
+		p.wsIdleTimeout = d
+	}
+}
+
+// WithFailoverOn429 serves a cached backup when upstream answers
+// 429 Too Many Requests; without one the 429 is passed through
+func WithFailoverOn429(enabled bool) Option {
+	return func(p *Proxy) {
+		p.failoverOn429 = enabled
+	}
+}
+
+// WithCanonicalPath normalizes request paths before keying and forwarding:
+// safe percent-encodings are decoded and duplicate slashes collapsed.
+// decodeSlash additionally turns %2F into a path separator.
+func WithCanonicalPath(enabled, decodeSlash bool) Option {
+	return func(p *Proxy) {
+		p.canonicalPath = enabled
+		p.decodeSlash = decodeSlash
+	}
+}
+
+// WithChecksums stores a body checksum with every entry and rejects entries
+// that fail verification when loaded
+func WithChecksums(enabled bool) Option {
+	return func(p *Proxy) {
+		p.verifyChecksums = enabled
+	}
+}
+
+// WithRequestTimeoutHeader lets clients shorten the upstream timeout with a
+// Request-Timeout header (seconds); longer values are clamped
+func WithRequestTimeoutHeader(enabled bool) Option {
+	return func(p *Proxy) {
+		p.honorRequestTimeout = enabled
+	}
+}
+
+// WithAdaptiveTTL scales stored TTLs per path between minFactor and
+// maxFactor: up for paths often served from cache, down for paths whose
+// body keeps changing
+func WithAdaptiveTTL(enabled bool, minFactor, maxFactor float64) Option {
+	return func(p *Proxy) {
+		if enabled {
+			p.adaptive = newAdaptiveTTL(minFactor, maxFactor)
+		}
+	}
+}
+
+// WithInstanceID tags every response with header: id. An empty id is
+// replaced by a random one generated at startup.
+func WithInstanceID(header, id string) Option {
+	return func(p *Proxy) {
+		if header == "" {
+			return
+		}
+		if id == "" {
+			id = newInstanceID()
+		}
+		p.instanceHeader = header
+		p.instanceID = id
+	}
+}
+
+// WithServerTiming adds a Server-Timing header with the upstream round-trip
+// duration and cache status
+func WithServerTiming(enabled bool) Option {
+	return func(p *Proxy) {
+		p.serverTiming = enabled
+	}
+}
+
+// WithDebugHeaders adds X-Cache-Entries and X-Cache-Memory-Bytes with the
+// current cache size to responses
+func WithDebugHeaders(enabled bool) Option {
+	return func(p *Proxy) {
+		p.debugHeaders = enabled
+	}
+}
+
+// newInstanceID returns a random 8-byte hex identifier
+func newInstanceID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,35 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// OutboundDirect disables outbound proxying, even when HTTP_PROXY/HTTPS_PROXY are set
+const OutboundDirect = "direct"
+
+// outboundProxyFunc builds the transport Proxy function for an outbound
+// proxy setting: empty uses the environment, OutboundDirect connects
+// directly, anything else must be an http(s) or socks5 proxy URL.
+func outboundProxyFunc(raw string) (func(*http.Request) (*url.URL, error), error) {
+	switch raw {
+	case "":
+		return http.ProxyFromEnvironment, nil
+	case OutboundDirect:
+		return nil, nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("parse outbound proxy: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("parse outbound proxy: unsupported scheme %q (expected http, https or socks5)", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("parse outbound proxy: %q has no host", raw)
+	}
+	return http.ProxyURL(u), nil
+}
@@ -0,0 +1,69 @@
+package proxy
+
+import (
+	"Aegis/internal/utils"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Oversized body policies, applied when a buffered upstream body grows
+// beyond max_body_bytes
+const (
+	OversizeStream = "stream" // pass the body through without caching it
+	OversizeReject = "reject" // answer with a backup or 502
+)
+
+// readBody buffers an upstream body, reading at most maxBodyBytes+1 bytes
+// when a cap is set. For a larger body it returns the bytes read so far and
+// oversize=true; the rest is left unread.
+func (p *Proxy) readBody(body io.Reader) (buf []byte, oversize bool, err error) {
+	if p.maxBodyBytes <= 0 {
+		buf, err = io.ReadAll(body)
+		return buf, false, err
+	}
+	buf, err = io.ReadAll(io.LimitReader(body, int64(p.maxBodyBytes)+1))
+	return buf, len(buf) > p.maxBodyBytes, err
+}
+
+// serveOversize answers with an upstream response whose body exceeded
+// max_body_bytes while buffering. It is never cached: depending on the
+// policy the read prefix and the remaining body are streamed through, or
+// the response is rejected.
+func (p *Proxy) serveOversize(w http.ResponseWriter, r *http.Request, key string, resp *http.Response, prefix []byte, cacheable bool) {
+	p.recordUpstreamResult(resp.StatusCode < 500)
+	if statusIn(p.failoverStatus, resp.StatusCode) && cacheable {
+		p.tryServeFromCache(w, r, key, fmt.Errorf("upstream status %d", resp.StatusCode))
+		return
+	}
+	if p.oversizePolicy == OversizeReject {
+		err := fmt.Errorf("upstream body exceeds %d bytes", p.maxBodyBytes)
+		if p.logger != nil {
+			p.logger.Error("rejecting oversized upstream response: %s %s: %v", r.Method, r.URL.Path, err)
+		}
+		if cacheable {
+			p.tryServeFromCache(w, r, key, err)
+		} else {
+			http.Error(w, "Bad Gateway: "+err.Error(), http.StatusBadGateway)
+		}
+		return
+	}
+
+	utils.CopyHeadersForClient(w.Header(), resp.Header)
+	p.setServedBy(w)
+	if cacheable {
+		p.setCacheStatus(w, CachePass)
+	} else {
+		p.setCacheStatus(w, CacheBypass)
+	}
+	w.WriteHeader(resp.StatusCode)
+	if _, err := w.Write(prefix); err != nil {
+		return
+	}
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		if p.logger != nil {
+			p.logger.Error("oversized response interrupted: %s %s: %v", r.Method, r.URL.Path, err)
+		}
+		panic(http.ErrAbortHandler)
+	}
+}
@@ -0,0 +1,36 @@
+package proxy
+
+import (
+	"path"
+	"strings"
+	"time"
+)
+
+// TTLRule sets the configured TTL of requests whose path matches Path:
+// a prefix such as "/static/", or a glob such as "/api/*/status" when it
+// contains *, ? or [. A glob matches the whole path and * does not cross
+// a slash.
+type TTLRule struct {
+	Path string
+	TTL  time.Duration
+}
+
+func (rule TTLRule) matches(p string) bool {
+	if strings.ContainsAny(rule.Path, "*?[") {
+		ok, _ := path.Match(rule.Path, p)
+		return ok
+	}
+	return strings.HasPrefix(p, rule.Path)
+}
+
+// pathTTL returns the TTL of the first rule matching the request path, in
+// configured order, or the global ttl when none does. It replaces the ttl
+// in the "default" signal and in the cap on origin TTLs.
+func (p *Proxy) pathTTL(urlPath string) time.Duration {
+	for _, rule := range p.ttlRules {
+		if rule.matches(urlPath) {
+			return rule.TTL
+		}
+	}
+	return p.ttl
+}
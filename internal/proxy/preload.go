@@ -0,0 +1,100 @@
+package proxy
+
+import (
+	"Aegis/internal/utils"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// PreloadEntry is a URL fetched into the cache at startup
+type PreloadEntry struct {
+	URL string        // Path and optional query, e.g. /index.html
+	TTL time.Duration // Entry TTL (0 = the normal TTL rules)
+}
+
+// Preload fetches the WithPreload entries into the cache, at most
+// background.max_workers at a time. /readyz reports not ready until it
+// returns. Failed entries are logged and joined into the returned error.
+func (p *Proxy) Preload(ctx context.Context) error {
+	defer p.preloading.Store(false)
+	entries := p.preload
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	slots := make(chan struct{}, p.backgroundWorkers)
+	for _, e := range entries {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			if err := p.preloadEntry(ctx, e); err != nil {
+				if p.logger != nil {
+					p.logger.Error("preload failed: %s: %v", e.URL, err)
+				}
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("%s: %w", e.URL, err))
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if p.logger != nil {
+		p.logger.Info("preload finished: %d/%d entries cached", len(entries)-len(errs), len(entries))
+	}
+	return errors.Join(errs...)
+}
+
+// preloadEntry fetches one URL and stores it like a client GET would
+func (p *Proxy) preloadEntry(ctx context.Context, e PreloadEntry) error {
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, e.URL, nil)
+	if err != nil {
+		return err
+	}
+	key := p.hashKey(p.cacheKey(r))
+
+	upURL, err := p.resolveUpstream(r.URL.EscapedPath(), r.URL.RawQuery)
+	if err != nil {
+		return err
+	}
+	if !p.hostAllowed(upURL) {
+		return fmt.Errorf("upstream host %s not allowed", upURL.Host)
+	}
+	fetchCtx, cancel := utils.RequestContextWithTimeout(ctx, p.requestTimeout(r))
+	defer cancel()
+	req, err := http.NewRequestWithContext(fetchCtx, http.MethodGet, upURL.String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, oversize, err := p.readBody(resp.Body)
+	if err != nil {
+		return err
+	}
+	if oversize {
+		return fmt.Errorf("body exceeds %d bytes", p.maxBodyBytes)
+	}
+	if !p.shouldStore(r, resp, body) {
+		return fmt.Errorf("not cacheable: status %d, %d bytes", resp.StatusCode, len(body))
+	}
+
+	ttl := e.TTL
+	if ttl <= 0 {
+		ttl = p.storeTTL(r, key, resp, body)
+	}
+	return p.storeEntry(r, key, resp, body, ttl)
+}
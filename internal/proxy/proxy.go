@@ -4,11 +4,21 @@ import (
 	"Aegis/internal/cache"
 	"Aegis/internal/logger"
 	"Aegis/internal/utils"
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net"
 	"net/http"
+	"net/http/httputil"
 	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -16,14 +26,127 @@ import (
 type Proxy struct {
 	upstream   *url.URL
 	client     *http.Client
+	transport  *http.Transport
 	cache      *cache.Cache
+	store      cache.Store
 	ttl        time.Duration
 	keyHeaders []string
 	logger     *logger.Logger
+
+	breaker             *breaker
+	readyWhenCached     bool
+	bodyDump            *bodyDumper
+	admission           *admission
+	trustedProxies      []*net.IPNet
+	keyScheme           bool
+	statusHeader        string
+	getBodyPolicy       string
+	rewriteRules        []RewriteRule
+	rewrites            []compiledRewrite
+	errorLog            *errorRing
+	minBodyBytes        int
+	maxBodyBytes        int
+	keyHash             string
+	backendPolicy       string
+	sharedAuthBackup    bool
+	counters            counters
+	statsInterval       time.Duration
+	heuristicFraction   float64
+	maxTTL              time.Duration
+	headPolicy          string
+	allowedHosts        []string
+	rolling             *rollingCounter
+	transform           *Transform
+	serverTiming        bool
+	immutable           bool
+	allowTTLHeader      bool
+	shadow              *Shadow
+	noopPaths           map[string]struct{}
+	honorPragma         bool
+	backgroundWorkers   int
+	background          *backgroundPool
+	wsIdleTimeout       time.Duration
+	wsProxy             *httputil.ReverseProxy
+	failoverOn429       bool
+	canonicalPath       bool
+	decodeSlash         bool
+	verifyChecksums     bool
+	honorRequestTimeout bool
+	adaptive            *adaptiveTTL
+	instanceHeader      string
+	instanceID          string
+	backendRetries      int
+	skipEmptyBody       bool
+	timeBucket          time.Duration
+	clock               utils.Clock
+	outboundProxy       string
+	maxKeyValueBytes    int
+	timeout             time.Duration
+	debugHeaders        bool
+	defaultHost         string
+	countryHeader       string
+	countryDefault      string
+	streamThreshold     int
+	health              *HealthCheck
+	upstreamHealthy     atomic.Bool
+	servedBy            string
+	ignoreParams        []string
+	maintenance         *maintenance
+	keyHost             bool
+	hostEntries         *hostEntries
+	preloading          atomic.Bool
+	preload             []PreloadEntry
+	varyKeyHeaders      bool
+	rateLimiter         *rateLimiter
+	eventsCfg           *Events
+	events              *eventSink
+	ttlPrecedence       []string
+	maxEntriesPerHost   int
+	evictionPolicy      string
+	oversizePolicy      string
+	maxEntries          int
+	maxMemory           int64
+	getBodyKeyFields    []string
+	bypassHeader        string
+	bypassValue         string
+	sweepInterval       time.Duration
+	revalidationsPerKey int
+	revalidations       *revalidations
+	handoffPath         string
+	handoffTopN         int
+	respectOriginTTL    bool
+	methodPolicy        string
+	maintenancePaths    *maintenancePaths
+	etagRevalidation    bool
+	errorBody           *ErrorBody
+	staleWindow         time.Duration
+	savings             *savings
+	negativeTTL         time.Duration
+	negativeStatuses    []int
+	flights             *flights
+	sizer               storeSizer
+	hashLongKeys        int
+	ttlRules            []TTLRule
+	compressMin         int
+	cacheableStatus     []StatusRange
+	failoverStatus      []StatusRange
+	extraUpstreams      []string
+	upstreams           []*url.URL
+	upstreamNext        atomic.Uint64
+	upstreamDown        []atomic.Bool
+	retries             int
+	retryBackoff        time.Duration
+	forwardedHeaders    bool
+	gzipMin             int
+	maxStale            time.Duration
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	workers  sync.WaitGroup
 }
 
 // New creates a new proxy instance
-func New(upstreamStr string, timeout time.Duration, ttl time.Duration, keyHeaders []string, log *logger.Logger) (*Proxy, error) {
+func New(upstreamStr string, timeout time.Duration, ttl time.Duration, keyHeaders []string, log *logger.Logger, opts ...Option) (*Proxy, error) {
 	u, err := url.Parse(upstreamStr)
 	if err != nil {
 		return nil, fmt.Errorf("parse upstream: %w", err)
@@ -47,21 +170,263 @@ func New(upstreamStr string, timeout time.Duration, ttl time.Duration, keyHeader
 		log.Info("proxy initialized: upstream=%s timeout=%s ttl=%s", upstreamStr, timeout, ttl)
 	}
 
-	return &Proxy{
+	p := &Proxy{
 		upstream: u,
+		// No client.Timeout: the request context carries the only deadline
 		client: &http.Client{
 			Transport: transport,
-			Timeout:   timeout,
+			// Redirects go back to the client, which may also cache them
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
 		},
-		cache:      cache.New(),
+		timeout:    timeout,
+		transport:  transport,
 		ttl:        ttl,
 		keyHeaders: keyHeaders,
 		logger:     log,
-	}, nil
+		rolling:    newRollingCounter(),
+		clock:      utils.RealClock{},
+		servedBy:   "Aegis",
+
+		ttlPrecedence:   DefaultTTLPrecedence,
+		bypassHeader:    DefaultBypassHeader,
+		bypassValue:     DefaultBypassValue,
+		cacheableStatus: DefaultCacheableStatus,
+		failoverStatus:  DefaultFailoverStatus,
+
+		forwardedHeaders: true,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	if p.respectOriginTTL && slices.Equal(p.ttlPrecedence, DefaultTTLPrecedence) {
+		p.ttlPrecedence = OriginTTLPrecedence
+	}
+
+	memCache := cache.New(p.maxEntries)
+	memCache.SetEvictionPolicy(p.evictionPolicy)
+	memCache.SetMaxMemory(p.maxMemory)
+	memCache.SetCompression(p.compressMin)
+	p.cache = memCache
+	if p.store == nil {
+		p.store = memCache
+	}
+
+	// Everything time-based follows the proxy clock
+	memCache.SetClock(p.clock)
+	memCache.SetSweepGrace(p.staleWindow)
+	memCache.SetExpireHook(func(key string, v cache.Response) {
+		p.forgetHostEntry(key)
+		p.emitEvent(EventExpire, key, v.Status, len(v.Body))
+	})
+	memCache.SetEvictHook(func(key string) {
+		p.forgetHostEntry(key)
+		p.emitEvent(EventEvict, key, 0, 0)
+	})
+	if p.handoffPath != "" {
+		p.readHandoff()
+	}
+	p.rolling.now = p.clock.Now
+	if p.breaker != nil {
+		p.breaker.now = p.clock.Now
+	}
+
+	if p.maxEntriesPerHost > 0 {
+		p.hostEntries = newHostEntries(p.maxEntriesPerHost, p.evictionPolicy)
+	}
+
+	// A store with its own clock, entry count and sweeping, such as the
+	// disk store, takes the place of the memory cache for those
+	backend := p.store
+	if s, ok := backend.(clockedStore); ok {
+		s.SetClock(p.clock)
+	}
+	if s, ok := backend.(storeSizer); ok {
+		p.sizer = s
+	}
+
+	// Transient backend errors get a few quick retries before the
+	// fail-open/fail-closed policy applies
+	p.store = cache.WithRetries(p.store, p.backendRetries, backendRetryDelay)
+
+	if p.transport.Proxy, err = outboundProxyFunc(p.outboundProxy); err != nil {
+		return nil, err
+	}
+
+	// Requests rotate through the upstream pool
+	p.upstreams = []*url.URL{u}
+	if len(p.extraUpstreams) > 0 {
+		extra, err := parseUpstreams(p.extraUpstreams)
+		if err != nil {
+			return nil, err
+		}
+		p.upstreams = append(p.upstreams, extra...)
+	}
+	p.upstreamDown = make([]atomic.Bool, len(p.upstreams))
+
+	// The configured upstreams are always reachable, other hosts only if allowed
+	hosts := make([]string, 0, len(p.upstreams)+len(p.allowedHosts))
+	for _, up := range p.upstreams {
+		hosts = append(hosts, up.Host)
+	}
+	p.allowedHosts = append(hosts, p.allowedHosts...)
+
+	if p.shadow != nil {
+		if p.shadow.target, err = parseShadowTarget(p.shadow.Upstream); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.wsIdleTimeout == 0 {
+		p.wsIdleTimeout = defaultWebSocketIdleTimeout
+	}
+	p.wsProxy = p.newWebSocketProxy()
+
+	// Precompile rewrite rules
+	if p.rewrites, err = compileRewrites(p.rewriteRules); err != nil {
+		return nil, err
+	}
+
+	if p.eventsCfg != nil {
+		if p.events, err = newEventSink(*p.eventsCfg); err != nil {
+			return nil, err
+		}
+	}
+
+	// Start background workers
+	p.stop = make(chan struct{})
+	if p.backgroundWorkers <= 0 {
+		p.backgroundWorkers = defaultBackgroundWorkers
+	}
+	p.background = newBackgroundPool(p.backgroundWorkers, p.goWorker)
+	p.revalidations = newRevalidations(p.revalidationsPerKey)
+	if p.statsInterval > 0 && p.logger != nil {
+		p.goWorker(func() { p.logStats(p.statsInterval, p.stop) })
+	}
+	p.upstreamHealthy.Store(true)
+	p.preloading.Store(len(p.preload) > 0)
+	if p.health != nil {
+		p.goWorker(func() { p.runHealthChecks(p.stop) })
+	}
+	if p.events != nil {
+		p.goWorker(func() { p.events.run(p.stop, p.logEventError) })
+	}
+	p.cache.StartSweeper(p.sweepInterval)
+	if s, ok := backend.(sweepingStore); ok && backend != cache.Store(memCache) && p.sweepInterval > 0 {
+		s.SetSweepGrace(p.staleWindow)
+		p.goWorker(func() { p.sweepStore(s, p.sweepInterval, p.stop) })
+	}
+	return p, nil
+}
+
+// Close stops the proxy's background workers and waits for them to exit.
+// With a handoff path, the hottest entries are then exported to it.
+func (p *Proxy) Close() {
+	p.stopOnce.Do(func() {
+		close(p.stop)
+		p.cache.Stop()
+		p.background.close()
+		p.workers.Wait()
+		if p.handoffPath != "" {
+			p.writeHandoff()
+		}
+	})
+	p.workers.Wait()
+}
+
+// goWorker runs fn in a background goroutine tracked by Close
+func (p *Proxy) goWorker(fn func()) {
+	p.workers.Add(1)
+	go func() {
+		defer p.workers.Done()
+		fn()
+	}()
 }
 
 // ServeHTTP handles HTTP requests
 func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Compress text-like responses for clients that accept gzip
+	if p.gzipMin > 0 && r.Method != http.MethodHead && !isUpgrade(r) && acceptsGzip(r) {
+		gw := &gzipWriter{ResponseWriter: w, min: p.gzipMin}
+		p.serve(gw, r)
+		// Not deferred: a response aborted by a panic must not be completed
+		gw.close()
+		return
+	}
+	p.serve(w, r)
+}
+
+// serve handles a request once the response writer is set up
+func (p *Proxy) serve(w http.ResponseWriter, r *http.Request) {
+	p.counters.requests.Add(1)
+	if p.instanceHeader != "" {
+		w.Header().Set(p.instanceHeader, p.instanceID)
+	}
+
+	// HTTP/1.0 clients may omit Host
+	if r.Host == "" {
+		if p.defaultHost == "" {
+			http.Error(w, "Bad Request: missing Host header", http.StatusBadRequest)
+			return
+		}
+		r.Host = p.defaultHost
+	}
+
+	if !p.normalizeMethod(r) {
+		http.Error(w, "Not Implemented: method "+r.Method, http.StatusNotImplemented)
+		return
+	}
+
+	if p.canonicalPath {
+		p.canonicalizePath(r)
+	}
+
+	// Endpoints taken offline answer statically, without upstream or cache
+	if p.serveMaintenancePath(w, r) {
+		return
+	}
+
+	// Trivial endpoints answered locally, never reaching upstream or the cache
+	if _, ok := p.noopPaths[r.URL.Path]; ok && (r.Method == http.MethodGet || r.Method == http.MethodHead) {
+		p.setServedBy(w)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	// WebSocket upgrades are streamed, outside admission, cache and timeout
+	if isUpgrade(r) {
+		p.serveUpgrade(w, r)
+		return
+	}
+
+	// Rate limits - the longest matching path prefix or the global limit
+	if p.rateLimiter != nil && !p.rateLimiter.allow(r.URL.Path, p.clock.Now()) {
+		if p.logger != nil {
+			p.logger.Debug("request rate limited: %s %s", r.Method, r.URL.Path)
+		}
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+		return
+	}
+
+	// Global admission control - queue or shed when overloaded
+	if p.admission != nil {
+		if !p.admission.acquire(r.Context()) {
+			if p.logger != nil {
+				p.logger.Error("request shed, proxy overloaded: %s %s", r.Method, r.URL.Path)
+			}
+			http.Error(w, "Service Unavailable: proxy overloaded", http.StatusServiceUnavailable)
+			return
+		}
+		defer p.admission.release()
+	}
+
+	if p.shadow != nil {
+		p.mirror(r)
+	}
+
 	// Cache only for GET and HEAD
 	cacheable := r.Method == http.MethodGet || r.Method == http.MethodHead
 	var cacheKey string
@@ -69,17 +434,109 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		cacheKey = p.cacheKey(r)
 	}
 
+	// GET/HEAD with a body - reject, ignore or key on it
+	if cacheable && requestHasBody(r) {
+		switch p.getBodyPolicy {
+		case GetBodyReject:
+			http.Error(w, "Bad Request: body not allowed on "+r.Method, http.StatusBadRequest)
+			return
+		case GetBodyKey:
+			digest, err := bufferBodyDigest(r, p.getBodyKeyFields)
+			if errors.Is(err, errBodyTooLarge) {
+				http.Error(w, "Request Entity Too Large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			if err != nil {
+				http.Error(w, "Bad Request: read body: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			cacheKey += "|body:" + digest
+		}
+	}
+	if cacheable {
+		cacheKey = p.hashKey(cacheKey)
+	}
+
+	// Immutable entries never need upstream
+	if cacheable && p.serveImmutable(w, r, cacheKey) {
+		return
+	}
+
+	// Cached 404s spare the upstream repeated misses
+	if cacheable && p.serveNegative(w, r, cacheKey) {
+		return
+	}
+
+	// Recently expired - answer now, refresh in the background
+	if cacheable && p.serveStale(w, r, cacheKey) {
+		return
+	}
+
+	// Scheduled maintenance - upstream is known to be down
+	if p.maintenance != nil {
+		if active, remaining := p.maintenance.active(p.clock.Now()); active {
+			if cacheable {
+				p.tryServeFromCache(w, r, cacheKey, fmt.Errorf("upstream maintenance window"))
+			} else {
+				w.Header().Set("Retry-After", strconv.Itoa(int(remaining.Seconds())+1))
+				http.Error(w, "Service Unavailable: upstream maintenance", http.StatusServiceUnavailable)
+			}
+			return
+		}
+	}
+
+	// Circuit breaker open - don't hit upstream at all
+	if p.breaker != nil && !p.breaker.Allow() {
+		if cacheable {
+			p.tryServeFromCache(w, r, cacheKey, fmt.Errorf("circuit breaker open"))
+		} else {
+			http.Error(w, "Service Unavailable: circuit breaker open", http.StatusServiceUnavailable)
+		}
+		return
+	}
+
+	// Concurrent requests for the same key share one upstream fetch
+	if cacheable && p.mayCoalesce(r) {
+		fl, leader := p.flights.join(cacheKey)
+		if !leader {
+			if p.serveCoalesced(w, r, cacheKey, fl) {
+				return
+			}
+		} else {
+			rec := p.leadFlight(w, r, fl)
+			defer p.flights.finish(cacheKey, rec)
+			p.forward(rec, r, cacheKey, cacheable)
+			// Not reached when the response was aborted mid-stream
+			rec.completed = true
+			return
+		}
+	}
+	p.forward(w, r, cacheKey, cacheable)
+}
+
+// forward fetches r from upstream and answers it, storing the response
+// under cacheKey when it is cacheable
+func (p *Proxy) forward(w http.ResponseWriter, r *http.Request, cacheKey string, cacheable bool) {
 	// Build upstream URL: base + path + query
-	upURL := *p.upstream
-	upURL.Path = utils.SingleSlashJoin(p.upstream.Path, r.URL.Path)
-	upURL.RawQuery = r.URL.RawQuery
+	upURL, err := p.resolveUpstream(r.URL.EscapedPath(), r.URL.RawQuery)
+	if err != nil {
+		http.Error(w, "Bad Gateway: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	if !p.hostAllowed(upURL) {
+		if p.logger != nil {
+			p.logger.Error("blocked request to disallowed upstream host: %s %s -> %s", r.Method, r.URL.Path, upURL.Host)
+		}
+		http.Error(w, "Bad Gateway: upstream host not allowed", http.StatusBadGateway)
+		return
+	}
 
 	// Copy request
 	var body io.ReadCloser
 	if r.Body != nil {
-		body = r.Body
+		body = p.dumpRequestBody(r, r.Body)
 	}
-	ctx, cancel := utils.RequestContextWithTimeout(r.Context(), p.client.Timeout)
+	ctx, cancel := utils.RequestContextWithTimeout(r.Context(), p.requestTimeout(r))
 	defer cancel()
 
 	req, err := http.NewRequestWithContext(
@@ -97,13 +554,29 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	utils.CopyHeadersForUpstream(req.Header, r.Header)
+	req.Header.Del(TTLRequestHeader)
+	p.setForwardedHeaders(req.Header, r)
+
+	// Let upstream confirm a cached copy instead of sending it again
+	var validated cache.Response
+	var conditional bool
+	if cacheable {
+		if validated, conditional = p.revalidationEntry(r, cacheKey); conditional {
+			req.Header.Set("If-None-Match", validated.Header.Get("ETag"))
+		}
+	}
 
 	// Send to upstream
 	if p.logger != nil {
 		p.logger.Debug("sending request to upstream: %s %s", r.Method, upURL.String())
 	}
-	resp, err := p.client.Do(req)
+	start := time.Now()
+	resp, err := p.sendUpstream(r, req)
+	if p.serverTiming {
+		w.Header().Set("Server-Timing", fmt.Sprintf("upstream;dur=%.1f", float64(time.Since(start).Microseconds())/1000))
+	}
 	if err != nil {
+		p.recordUpstreamResult(false)
 		if p.logger != nil {
 			p.logger.Error("upstream request failed: %v", err)
 		}
@@ -116,9 +589,34 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 	defer resp.Body.Close()
 
-	// Read response body
-	respBody, err := io.ReadAll(resp.Body)
+	if conditional && resp.StatusCode == http.StatusNotModified {
+		p.recordUpstreamResult(true)
+		p.serveRevalidated(w, r, cacheKey, validated, resp)
+		return
+	}
+
+	// Large cacheable responses are streamed and cached at the same time
+	if cacheable && p.shouldStream(r, resp) {
+		p.serveStream(w, r, cacheKey, resp)
+		return
+	}
+
+	// Responses that are never cached go straight through
+	if !cacheable && p.shouldPassThrough(r, resp) {
+		p.servePassThrough(w, r, resp)
+		return
+	}
+
+	// A body declared larger than max_body_bytes is not buffered at all
+	if p.maxBodyBytes > 0 && resp.ContentLength > int64(p.maxBodyBytes) {
+		p.serveOversize(w, r, cacheKey, resp, nil, cacheable)
+		return
+	}
+
+	// Read response body, never buffering more than max_body_bytes
+	respBody, oversize, err := p.readBody(resp.Body)
 	if err != nil {
+		p.recordUpstreamResult(false)
 		if p.logger != nil {
 			p.logger.Error("failed to read upstream response: %v", err)
 		}
@@ -129,62 +627,149 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
+	if oversize {
+		p.serveOversize(w, r, cacheKey, resp, respBody, cacheable)
+		return
+	}
 
-	// If 5xx -> fallback to cache (only for cacheable)
-	if resp.StatusCode >= 500 && cacheable {
+	errorPage := p.isErrorPage(r, resp, respBody)
+	p.recordUpstreamResult(resp.StatusCode < 500 && !errorPage)
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 && !errorPage {
+		respBody = p.transformBody(r, resp, respBody)
+	}
+	if resp.StatusCode >= 500 || errorPage {
+		p.captureError(r, resp, respBody)
+	}
+
+	// Failover status (5xx by default) -> fallback to cache (only for cacheable)
+	if statusIn(p.failoverStatus, resp.StatusCode) && cacheable {
 		if p.logger != nil {
-			p.logger.Error("upstream returned 5xx status: %d", resp.StatusCode)
+			p.logger.Error("upstream returned failover status: %d", resp.StatusCode)
 		}
 		p.tryServeFromCache(w, r, cacheKey, fmt.Errorf("upstream status %d", resp.StatusCode))
 		return
 	}
 
-	// Forward response to client
-	utils.CopyHeadersForClient(w.Header(), resp.Header)
-	w.Header().Set("X-Served-By", "Aegis")
+	// Rate limited -> shield the client with a backup if we have one,
+	// otherwise pass the 429 and its Retry-After through
+	if resp.StatusCode == http.StatusTooManyRequests && cacheable && p.failoverOn429 {
+		if p.serveBackup(w, r, cacheKey, fmt.Errorf("upstream status %d", resp.StatusCode)) {
+			return
+		}
+	}
 
-	// Success (2xx): save to cache (only for cacheable)
-	saved := false
-	if cacheable && resp.StatusCode >= 200 && resp.StatusCode <= 299 {
-		entry := cache.Response{
-			Status:   resp.StatusCode,
-			Header:   utils.CloneHeaderSanitized(resp.Header),
-			Body:     respBody,
-			SavedAt:  time.Now(),
-			ExpireAt: utils.ZeroOrExpiry(p.ttl),
-		}
-		p.cache.Set(cacheKey, entry)
-		saved = true
+	// Error page sent as 2xx -> serve a backup if we have one, otherwise
+	// pass the page through without caching it
+	if errorPage && cacheable {
 		if p.logger != nil {
-			p.logger.Debug("response saved to cache: key=%s status=%d size=%d", cacheKey, resp.StatusCode, len(respBody))
+			p.logger.Error("upstream returned an error page with status %d: %s", resp.StatusCode, r.URL.Path)
+		}
+		if p.serveBackup(w, r, cacheKey, fmt.Errorf("upstream error page with status %d", resp.StatusCode)) {
+			return
 		}
 	}
 
-	// Set X-Cache header
+	// Cacheable (2xx by default) or negatively cached status: save to cache
+	// (only for cacheable requests). A failed store is logged and the good
+	// upstream answer still served, under either backend failure policy.
+	saved := false
+	if cacheable && !errorPage && p.shouldStore(r, resp, respBody) {
+		saved = p.storeEntry(r, cacheKey, resp, respBody, p.storeTTL(r, cacheKey, resp, respBody)) == nil
+	}
+
+	// Forward response to client
+	utils.CopyHeadersForClient(w.Header(), resp.Header)
+	p.setServedBy(w)
+
+	// Set cache status header(s)
 	if saved {
-		w.Header().Set("X-Cache", "MISS")
+		p.setCacheStatus(w, CacheMiss)
 	} else if cacheable {
-		w.Header().Set("X-Cache", "PASS")
+		p.setCacheStatus(w, CachePass)
 	} else {
-		w.Header().Set("X-Cache", "BYPASS")
+		p.setCacheStatus(w, CacheBypass)
 	}
 
 	w.WriteHeader(resp.StatusCode)
 	_, _ = w.Write(respBody)
 }
 
-func (p *Proxy) tryServeFromCache(w http.ResponseWriter, r *http.Request, key string, cause error) {
-	if cached, ok := p.cache.Get(key); ok {
-		// We have a cached copy - send as backup
+// setServedBy identifies the proxy in X-Served-By, unless disabled
+func (p *Proxy) setServedBy(w http.ResponseWriter) {
+	if p.servedBy != "" {
+		w.Header().Set("X-Served-By", p.servedBy)
+	}
+}
+
+// storeTTL decides how long a response stored under key stays fresh
+func (p *Proxy) storeTTL(r *http.Request, key string, resp *http.Response, body []byte) time.Duration {
+	if p.negativeStatus(resp.StatusCode) {
+		return p.negativeTTL
+	}
+	if ttl, ok := p.requestTTL(r); ok {
+		return ttl
+	}
+	ttl := p.pathTTL(r.URL.Path)
+	// Immutable entries are never revalidated, so origin max-age would
+	// only expire them early; keep them for the configured TTL instead
+	if !p.immutable || !hasCacheControl(resp.Header, "immutable") {
+		ttl = p.entryTTL(resp.Header, ttl)
+	}
+	if p.adaptive != nil {
+		prev, found, _ := p.fetchEntry(r, key)
+		p.adaptive.recordStore(r.URL.Path, found && !bytes.Equal(prev.Body, body))
+		ttl = p.adaptive.adjust(r.URL.Path, ttl)
+	}
+	return ttl
+}
+
+// storeEntry caches a successful upstream response under key, or, when it
+// carries Vary, under the vary-qualified key with a marker at key. Backend
+// errors are logged and returned for the caller's failure policy.
+func (p *Proxy) storeEntry(r *http.Request, key string, resp *http.Response, body []byte, ttl time.Duration) error {
+	baseKey := key
+	entry := cache.Response{
+		Status:   resp.StatusCode,
+		Header:   utils.CloneHeaderSanitized(resp.Header),
+		Body:     body,
+		SavedAt:  p.clock.Now(),
+		ExpireAt: utils.ZeroOrExpiry(p.clock, ttl),
+	}
+	if p.verifyChecksums {
+		entry.Checksum = cache.BodyChecksum(entry.Body)
+	}
+	if names := responseVary(resp.Header); len(names) > 0 {
+		marker := cache.Response{SavedAt: entry.SavedAt, ExpireAt: entry.ExpireAt, Vary: names}
+		if err := p.store.Put(key, marker); err != nil {
+			if p.logger != nil {
+				p.logger.Error("cache backend store failed: key=%s err=%v", key, err)
+			}
+			return err
+		}
+		key = p.varyKey(r, key, names)
+	}
+	if err := p.store.Put(key, entry); err != nil {
 		if p.logger != nil {
-			p.logger.Info("serving from cache backup: key=%s cause=%v", key, cause)
+			if errors.Is(err, cache.ErrTooLarge) {
+				p.logger.Debug("response not cached, larger than the memory budget: key=%s size=%d", key, len(body))
+			} else {
+				p.logger.Error("cache backend store failed: key=%s err=%v", key, err)
+			}
 		}
-		utils.CopyHeadersForClient(w.Header(), cached.Header)
-		w.Header().Set("X-Served-By", "Aegis")
-		w.Header().Set("X-Cache", "HIT-BACKUP")
-		w.Header().Set("X-Backup-Saved-At", cached.SavedAt.Format(time.RFC3339))
-		w.WriteHeader(cached.Status)
-		_, _ = w.Write(cached.Body)
+		return err
+	}
+	if p.logger != nil {
+		p.logger.Debug("response saved to cache: key=%s status=%d size=%d", key, resp.StatusCode, len(body))
+	}
+	p.emitEvent(EventStore, key, resp.StatusCode, len(body))
+	// Evicting the base key drops the marker and with it every variant
+	p.touchHostEntry(r, baseKey)
+	return nil
+}
+
+// tryServeFromCache serves a cached backup, or 502 when there is none
+func (p *Proxy) tryServeFromCache(w http.ResponseWriter, r *http.Request, key string, cause error) {
+	if p.serveBackup(w, r, key, cause) {
 		return
 	}
 	// No cache - return 502 error
@@ -194,15 +779,242 @@ func (p *Proxy) tryServeFromCache(w http.ResponseWriter, r *http.Request, key st
 	http.Error(w, "Bad Gateway (no cached backup): "+cause.Error(), http.StatusBadGateway)
 }
 
+// serveBackup writes the cached copy of key as HIT-BACKUP. It returns false,
+// without writing anything, when no usable backup exists.
+func (p *Proxy) serveBackup(w http.ResponseWriter, r *http.Request, key string, cause error) bool {
+	if !p.mayShareEntry(r) {
+		if p.logger != nil {
+			p.logger.Error("refusing shared backup for authenticated request: key=%s cause=%v", key, cause)
+		}
+		return false
+	}
+
+	cached, ok, err := p.fetchEntry(r, key)
+	if err != nil {
+		if p.logger != nil {
+			p.logger.Error("cache backend lookup failed: key=%s err=%v", key, err)
+		}
+		if p.backendPolicy == BackendFailClosed {
+			http.Error(w, "Service Unavailable: cache backend error", http.StatusServiceUnavailable)
+			return true
+		}
+	}
+	if !ok {
+		return false
+	}
+	// A negatively cached 404 replaces the good copy but is no backup
+	if !statusIn(p.cacheableStatus, cached.Status) {
+		if p.logger != nil {
+			p.logger.Error("refusing backup with status %d: key=%s cause=%v", cached.Status, key, cause)
+		}
+		return false
+	}
+	age := p.clock.Now().Sub(cached.SavedAt)
+	if p.maxStale > 0 && age > p.maxStale {
+		if p.logger != nil {
+			p.logger.Error("refusing backup older than max_stale: key=%s age=%s cause=%v", key, age.Round(time.Second), cause)
+		}
+		return false
+	}
+	// We have a cached copy - send as backup
+	if p.adaptive != nil {
+		p.adaptive.recordHit(r.URL.Path)
+	}
+	p.touchHostEntry(r, key)
+	if p.logger != nil {
+		p.logger.Info("serving from cache backup: key=%s cause=%v", key, cause)
+	}
+	utils.CopyHeadersForClient(w.Header(), cached.Header)
+	p.setServedBy(w)
+	p.setCacheStatus(w, CacheHitBackup)
+	p.recordSavings(r, CacheHitBackup, cached.Body)
+	w.Header().Set("X-Backup-Saved-At", cached.SavedAt.Format(time.RFC3339))
+	w.Header().Set("X-Cache-Age", strconv.Itoa(int(max(age, 0).Seconds())))
+	w.WriteHeader(cached.Status)
+	_, _ = w.Write(cached.Body)
+	return true
+}
+
+// fetchEntry loads the entry for r from the store, following a vary marker
+// at key to the variant matching r. An entry whose body no longer matches
+// its checksum is treated as a miss.
+func (p *Proxy) fetchEntry(r *http.Request, key string) (cache.Response, bool, error) {
+	cached, ok, err := p.store.Fetch(key)
+	if ok && cached.Vary != nil {
+		cached, ok, err = p.store.Fetch(p.varyKey(r, key, cached.Vary))
+	}
+	if ok && p.verifyChecksums && !cached.ChecksumValid() {
+		if p.logger != nil {
+			p.logger.Error("warning: cached entry failed checksum verification, ignoring: key=%s", key)
+		}
+		return cache.Response{}, false, err
+	}
+	return cached, ok, err
+}
+
+// dumpRequestBody logs the leading bytes of the request body at debug level
+// and returns a body that still yields the complete original stream
+func (p *Proxy) dumpRequestBody(r *http.Request, body io.ReadCloser) io.ReadCloser {
+	if p.bodyDump == nil || p.logger == nil || !p.logger.DebugEnabled() || !p.bodyDump.matches(r) {
+		return body
+	}
+	prefix, replay, err := p.bodyDump.capture(body)
+	if err != nil {
+		p.logger.Error("failed to capture request body: %v", err)
+	}
+	p.logger.Debug("request body: %s %s (first %d bytes): %s",
+		r.Method, r.URL.Path, len(prefix), p.bodyDump.redact(prefix))
+	return replay
+}
+
+// mayShareEntry reports whether a cached entry may answer r. An entry not
+// keyed on Authorization may belong to another user.
+func (p *Proxy) mayShareEntry(r *http.Request) bool {
+	return r.Header.Get("Authorization") == "" || p.sharedAuthBackup || p.keyIncludesHeader("Authorization")
+}
+
+// keyIncludesHeader reports whether the named header is part of the cache key
+func (p *Proxy) keyIncludesHeader(name string) bool {
+	for _, h := range p.keyHeaders {
+		if http.CanonicalHeaderKey(h) == http.CanonicalHeaderKey(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// shouldStore decides whether an upstream response with a cacheable or a
+// negatively cached status is cached
+func (p *Proxy) shouldStore(r *http.Request, resp *http.Response, body []byte) bool {
+	negative := p.negativeStatus(resp.StatusCode)
+	if !statusIn(p.cacheableStatus, resp.StatusCode) && !negative {
+		return false
+	}
+	// Upstream knows this particular response must not be reused
+	if p.bypassMarked(resp.Header) {
+		return false
+	}
+	if originForbidsStore(resp.Header) {
+		return false
+	}
+	// Answers to authenticated requests may be specific to the user
+	if !p.mayShareEntry(r) && !hasCacheControl(resp.Header, "public") && !hasCacheControl(resp.Header, "s-maxage") {
+		return false
+	}
+	// Varies on something other than request headers
+	if slices.Contains(responseVary(resp.Header), "*") {
+		return false
+	}
+	if r.Method == http.MethodHead && p.headPolicy == HeadCacheNone {
+		return false
+	}
+	// An empty 2xx to GET usually means upstream partially failed
+	if p.skipEmptyBody && len(body) == 0 && r.Method != http.MethodHead && !negative {
+		return false
+	}
+	return p.storableSize(len(body))
+}
+
+// storableSize reports whether a body of n bytes is within the cacheable size range
+func (p *Proxy) storableSize(n int) bool {
+	if n < p.minBodyBytes {
+		return false
+	}
+	return p.maxBodyBytes <= 0 || n <= p.maxBodyBytes
+}
+
+// transformBody pipes a buffered body through the configured external command.
+// On failure the original body is kept.
+func (p *Proxy) transformBody(r *http.Request, resp *http.Response, body []byte) []byte {
+	if p.transform == nil || noTransform(resp.Header) || !p.transform.matches(resp.Header.Get("Content-Type"), len(body)) {
+		return body
+	}
+	out, err := p.transform.run(r.Context(), body)
+	if err != nil {
+		if p.logger != nil {
+			p.logger.Error("body transform failed, using original body: %s %v", r.URL.Path, err)
+		}
+		return body
+	}
+	resp.Header.Set("Content-Length", strconv.Itoa(len(out)))
+	return out
+}
+
+// captureError records an upstream error response for diagnostics
+func (p *Proxy) captureError(r *http.Request, resp *http.Response, body []byte) {
+	if p.errorLog == nil {
+		return
+	}
+	if len(body) > maxCapturedErrorBody {
+		body = body[:maxCapturedErrorBody]
+	}
+	header := resp.Header.Clone()
+	for _, name := range capturedHeaderDenylist {
+		header.Del(name)
+	}
+	p.errorLog.Add(CapturedError{
+		Time:   p.clock.Now(),
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Status: resp.StatusCode,
+		Header: header,
+		Body:   string(body),
+	})
+}
+
+// recordUpstreamResult counts a failed upstream call and feeds the outcome
+// to the circuit breaker
+func (p *Proxy) recordUpstreamResult(ok bool) {
+	if !ok {
+		p.counters.upstreamErrors.Add(1)
+	}
+	if p.breaker == nil {
+		return
+	}
+	if ok {
+		p.breaker.Success()
+		return
+	}
+	p.breaker.Failure()
+	if p.logger != nil && p.breaker.Open() {
+		p.logger.Error("circuit breaker open: upstream=%s", p.upstream)
+	}
+}
+
 func (p *Proxy) cacheKey(r *http.Request) string {
-	key := r.Method + " " + r.URL.Path + "?" + r.URL.RawQuery
+	path := r.URL.Path
+	if p.canonicalPath {
+		// Keep an encoded %2F distinct from a path separator
+		path = r.URL.EscapedPath()
+	}
+	key := r.Method + " " + path + "?" + p.keyQuery(r.URL.RawQuery)
+
+	// Separate entries per virtual host
+	if p.keyHost {
+		key += "|host:" + requestHost(r)
+	}
+
+	// Include effective scheme in cache key
+	if p.keyScheme {
+		key += "|scheme:" + p.requestScheme(r)
+	}
+
+	// Geo-varying content keyed on the normalized country code
+	if p.countryHeader != "" {
+		key += "|country:" + p.countryKey(r)
+	}
+
+	// Start a fresh entry at every time bucket boundary
+	if p.timeBucket > 0 {
+		key += "|bucket:" + strconv.FormatInt(p.clock.Now().UnixNano()/int64(p.timeBucket), 10)
+	}
 
 	// Include configured headers in cache key
 	if len(p.keyHeaders) > 0 {
 		for _, headerName := range p.keyHeaders {
-			headerValue := r.Header.Get(headerName)
+			headerValue := keyHeaderValue(r.Header, headerName)
 			if headerValue != "" {
-				key += "|" + headerName + ":" + headerValue
+				key += "|" + headerName + ":" + p.limitKeyValue(headerValue)
 			}
 		}
 	}
@@ -210,13 +1022,204 @@ func (p *Proxy) cacheKey(r *http.Request) string {
 	return key
 }
 
-// StatsHandler returns cache statistics as JSON
+// keyHeaderValue returns all values of a key header, sorted and
+// comma-joined, so requests differing in any value get distinct keys
+func keyHeaderValue(h http.Header, name string) string {
+	values := h.Values(name)
+	if len(values) < 2 {
+		return h.Get(name)
+	}
+	values = slices.Clone(values)
+	slices.Sort(values)
+	return strings.Join(values, ",")
+}
+
+// statsResponse is the JSON document served by /stats
+type statsResponse struct {
+	CacheSize   int     `json:"cache_size"`
+	MemoryBytes int64   `json:"memory_bytes"`
+	MemoryKB    float64 `json:"memory_kb"`
+	MemoryMB    float64 `json:"memory_mb"`
+	Evictions   int64   `json:"evictions"`
+
+	HitRatio       float64            `json:"hit_ratio"`
+	HitRatioWindow map[string]float64 `json:"hit_ratio_window"`
+	Counters       counterStats       `json:"counters"`
+
+	Admission *admissionStats `json:"admission,omitempty"`
+
+	UpstreamHealthy *bool           `json:"upstream_healthy,omitempty"`
+	Upstreams       []upstreamStats `json:"upstreams,omitempty"`
+
+	EventsDropped *int64 `json:"events_dropped,omitempty"`
+
+	Savings *savingsStats `json:"savings,omitempty"`
+}
+
+type admissionStats struct {
+	InFlight   int   `json:"in_flight"`
+	QueueDepth int64 `json:"queue_depth"`
+	Shed       int64 `json:"shed"`
+}
+
+// requestScheme returns the scheme the client used to reach us.
+// X-Forwarded-Proto is honored only when sent by a trusted proxy.
+func (p *Proxy) requestScheme(r *http.Request) string {
+	if utils.RemoteAddrInNets(r.RemoteAddr, p.trustedProxies) {
+		proto := strings.TrimSpace(strings.Split(r.Header.Get("X-Forwarded-Proto"), ",")[0])
+		if proto := strings.ToLower(proto); proto == "http" || proto == "https" {
+			return proto
+		}
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// StatsHandler returns cache statistics as JSON, or as "key value" lines
+// with ?format=text
 func (p *Proxy) StatsHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	size := p.cache.Size()
 	memBytes := p.cache.MemoryUsage()
+	if r.URL.Query().Get("recompute") == "true" {
+		memBytes = p.cache.RecomputeMemoryUsage()
+	}
 	memKB := float64(memBytes) / 1024
 	memMB := memKB / 1024
-	fmt.Fprintf(w, `{"cache_size": %d, "memory_bytes": %d, "memory_kb": %.2f, "memory_mb": %.2f}`,
-		size, memBytes, memKB, memMB)
+	stats := statsResponse{
+		CacheSize:   p.cacheSize(),
+		MemoryBytes: memBytes,
+		MemoryKB:    math.Round(memKB*100) / 100,
+		MemoryMB:    math.Round(memMB*100) / 100,
+		Evictions:   p.cache.Evictions(),
+		HitRatio:    roundRatio(p.counters.hitRatio()),
+
+		HitRatioWindow: make(map[string]float64, len(hitRatioWindows)),
+		Counters:       p.counters.stats(),
+	}
+	for _, win := range hitRatioWindows {
+		stats.HitRatioWindow[win.name] = roundRatio(p.rolling.Ratio(win.d))
+	}
+	if p.admission != nil {
+		stats.Admission = &admissionStats{
+			InFlight:   p.admission.inFlight(),
+			QueueDepth: p.admission.queued.Load(),
+			Shed:       p.admission.shed.Load(),
+		}
+	}
+	if p.health != nil {
+		healthy := p.upstreamHealthy.Load()
+		stats.UpstreamHealthy = &healthy
+		for i, u := range p.upstreams {
+			stats.Upstreams = append(stats.Upstreams, upstreamStats{URL: u.Redacted(), Healthy: !p.upstreamDown[i].Load()})
+		}
+	}
+	if p.events != nil {
+		dropped := p.events.dropped.Load()
+		stats.EventsDropped = &dropped
+	}
+	if p.savings != nil {
+		stats.Savings = &savingsStats{
+			Requests: p.savings.requests.Load(),
+			Bytes:    p.savings.bytes.Load(),
+		}
+	}
+	if r.URL.Query().Get("format") == "text" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		writeStatsText(w, stats)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(stats)
+}
+
+// writeStatsText writes stats as "key value" lines for shell scripts.
+// Booleans are written as 1 or 0; absent optional stats are left out.
+func writeStatsText(w io.Writer, s statsResponse) {
+	fmt.Fprintf(w, "cache_size %d\n", s.CacheSize)
+	fmt.Fprintf(w, "memory_bytes %d\n", s.MemoryBytes)
+	fmt.Fprintf(w, "evictions %d\n", s.Evictions)
+	fmt.Fprintf(w, "hit_ratio %g\n", s.HitRatio)
+	for _, win := range hitRatioWindows {
+		fmt.Fprintf(w, "hit_ratio_%s %g\n", win.name, s.HitRatioWindow[win.name])
+	}
+	c := s.Counters
+	fmt.Fprintf(w, "requests %d\n", c.Requests)
+	fmt.Fprintf(w, "miss %d\n", c.Miss)
+	fmt.Fprintf(w, "pass %d\n", c.Pass)
+	fmt.Fprintf(w, "bypass %d\n", c.Bypass)
+	fmt.Fprintf(w, "hit_backup %d\n", c.HitBackup)
+	fmt.Fprintf(w, "hit %d\n", c.Hit)
+	fmt.Fprintf(w, "revalidated %d\n", c.Revalidated)
+	fmt.Fprintf(w, "stale %d\n", c.Stale)
+	fmt.Fprintf(w, "hit_negative %d\n", c.HitNegative)
+	fmt.Fprintf(w, "coalesced %d\n", c.Coalesced)
+	fmt.Fprintf(w, "upstream_errors %d\n", c.UpstreamErrors)
+	if a := s.Admission; a != nil {
+		fmt.Fprintf(w, "admission_in_flight %d\n", a.InFlight)
+		fmt.Fprintf(w, "admission_queue_depth %d\n", a.QueueDepth)
+		fmt.Fprintf(w, "admission_shed %d\n", a.Shed)
+	}
+	if s.UpstreamHealthy != nil {
+		healthy := 0
+		if *s.UpstreamHealthy {
+			healthy = 1
+		}
+		fmt.Fprintf(w, "upstream_healthy %d\n", healthy)
+	}
+	for i, u := range s.Upstreams {
+		healthy := 0
+		if u.Healthy {
+			healthy = 1
+		}
+		fmt.Fprintf(w, "upstream_%d_healthy %d\n", i, healthy)
+	}
+	if s.EventsDropped != nil {
+		fmt.Fprintf(w, "events_dropped %d\n", *s.EventsDropped)
+	}
+	if s.Savings != nil {
+		fmt.Fprintf(w, "saved_requests %d\n", s.Savings.Requests)
+		fmt.Fprintf(w, "saved_bytes %d\n", s.Savings.Bytes)
+	}
+}
+
+func roundRatio(r float64) float64 {
+	return math.Round(r*10000) / 10000
+}
+
+// ErrorsHandler returns the most recently captured upstream errors as JSON
+func (p *Proxy) ErrorsHandler(w http.ResponseWriter, r *http.Request) {
+	if p.errorLog == nil {
+		http.Error(w, "Not Found: error capture disabled", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(p.errorLog.List())
+}
+
+// ReadyHandler reports whether the proxy can usefully serve traffic.
+// It returns 503 while the circuit breaker is open, unless the proxy is
+// configured to stay ready as long as the cache holds entries.
+func (p *Proxy) ReadyHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	state := "disabled"
+	ready := true
+	if p.breaker != nil {
+		state = p.breaker.State()
+		if state == "open" {
+			ready = p.readyWhenCached && p.cacheSize() > 0
+		}
+	}
+	// Every upstream failing its health check counts like an open breaker
+	if p.health != nil && !p.upstreamHealthy.Load() && !(p.readyWhenCached && p.cacheSize() > 0) {
+		ready = false
+	}
+	// Not ready until the startup preload has finished
+	if p.preloading.Load() {
+		ready = false
+	}
+	if !ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	fmt.Fprintf(w, `{"ready": %t, "circuit_breaker": %q}`, ready, state)
 }
@@ -0,0 +1,71 @@
+package proxy
+
+import (
+	"Aegis/internal/cache"
+	"Aegis/internal/logger"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAccessLogBytesPerServePath(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("nope"))
+		case "/broken", "/backup":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.Write([]byte("0123456789"))
+		}
+	}))
+	defer upstream.Close()
+
+	logs := captureLog(t)
+	log := logger.New(true, true, "error")
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, log)
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	p.cache.Set("GET /backup?", cache.Response{Status: http.StatusOK, Header: http.Header{}, Body: []byte("cached!")})
+	handler := log.AccessLogMiddleware(p)
+
+	noBackup := len("Bad Gateway (no cached backup): upstream status 500\n")
+	tests := []struct {
+		method, path string
+		cacheStatus  string
+		bytes        int
+	}{
+		{"GET", "/ok", CacheMiss, 10},
+		{"GET", "/missing", CachePass, 4},
+		{"POST", "/ok", CacheBypass, 10},
+		{"GET", "/backup", CacheHitBackup, 7},
+		{"GET", "/broken", "-", noBackup},
+		{"HEAD", "/ok", CacheMiss, 0},
+	}
+	for _, tt := range tests {
+		logs.Reset()
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+
+		var line string
+		for _, l := range strings.Split(logs.String(), "\n") {
+			if strings.Contains(l, "[ACCESS]") {
+				line = l
+			}
+		}
+		if !strings.Contains(line, "cache="+tt.cacheStatus+" ") {
+			t.Errorf("%s %s: expected cache=%s, got %q", tt.method, tt.path, tt.cacheStatus, line)
+		}
+		if want := fmt.Sprintf("bytes=%d", tt.bytes); !strings.HasSuffix(line, want) {
+			t.Errorf("%s %s: expected %s, got %q", tt.method, tt.path, want, line)
+		}
+		if tt.method != "HEAD" && rec.Body.Len() != tt.bytes {
+			t.Errorf("%s %s: logged bytes differ from body sent (%d)", tt.method, tt.path, rec.Body.Len())
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// storedTTL returns the TTL of the entry stored under key
+func storedTTL(t *testing.T, p *Proxy, key string) time.Duration {
+	t.Helper()
+	entry, ok := p.cache.Get(key)
+	if !ok {
+		t.Fatalf("expected %s to be cached", key)
+	}
+	return entry.ExpireAt.Sub(entry.SavedAt).Round(time.Second)
+}
+
+func TestAdaptiveTTLExtendsHighHitPaths(t *testing.T) {
+	var failing atomic.Bool
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("stable"))
+	}))
+	defer upstream.Close()
+
+	p, err := New(upstream.URL, 5*time.Second, time.Minute, nil, nil, WithAdaptiveTTL(true, 0.5, 2))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/hot", nil))
+	failing.Store(true)
+	for i := 0; i < 9; i++ {
+		p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/hot", nil))
+	}
+	failing.Store(false)
+	p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/hot", nil))
+
+	if ttl := storedTTL(t, p, "GET /hot?"); ttl <= time.Minute || ttl > 2*time.Minute {
+		t.Errorf("expected TTL extended within (1m, 2m], got %v", ttl)
+	}
+}
+
+func TestAdaptiveTTLShortensChurningPaths(t *testing.T) {
+	var n atomic.Int64
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "version %d", n.Add(1))
+	}))
+	defer upstream.Close()
+
+	p, err := New(upstream.URL, 5*time.Second, time.Minute, nil, nil, WithAdaptiveTTL(true, 0.5, 2))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	for i := 0; i < 8; i++ {
+		p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/churn", nil))
+	}
+
+	if ttl := storedTTL(t, p, "GET /churn?"); ttl >= time.Minute || ttl < 30*time.Second {
+		t.Errorf("expected TTL shortened within [30s, 1m), got %v", ttl)
+	}
+}
+
+func TestAdaptiveTTLBounds(t *testing.T) {
+	a := newAdaptiveTTL(0.5, 2)
+	for i := 0; i < 100; i++ {
+		a.recordHit("/always-hit")
+	}
+	a.recordStore("/always-hit", false)
+	if got := a.adjust("/always-hit", time.Minute); got > 2*time.Minute || got <= time.Minute {
+		t.Errorf("expected TTL within max bound, got %v", got)
+	}
+
+	for i := 0; i < 100; i++ {
+		a.recordStore("/always-changed", i > 0)
+	}
+	if got := a.adjust("/always-changed", time.Minute); got != 30*time.Second {
+		t.Errorf("expected TTL clamped to min bound 30s, got %v", got)
+	}
+
+	if got := a.adjust("/unknown", time.Minute); got != time.Minute {
+		t.Errorf("expected unchanged TTL without samples, got %v", got)
+	}
+}
@@ -0,0 +1,130 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingUpstream holds every request until release is closed
+func blockingUpstream(t *testing.T) (*httptest.Server, chan struct{}, chan struct{}) {
+	t.Helper()
+	started := make(chan struct{}, 10)
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(upstream.Close)
+	return upstream, started, release
+}
+
+func TestAdmissionShedImmediately(t *testing.T) {
+	upstream, started, release := blockingUpstream(t)
+
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, nil,
+		WithAdmission(Admission{MaxInFlight: 1, Policy: AdmissionShed}))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	first := httptest.NewRecorder()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		p.ServeHTTP(first, httptest.NewRequest("POST", "/busy", nil))
+	}()
+	<-started
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("POST", "/busy", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503 while saturated, got %d", rec.Code)
+	}
+
+	close(release)
+	wg.Wait()
+	if first.Code != http.StatusOK {
+		t.Errorf("expected first request to succeed, got %d", first.Code)
+	}
+
+	statsRec := httptest.NewRecorder()
+	p.StatsHandler(statsRec, httptest.NewRequest("GET", "/stats", nil))
+	var stats struct {
+		Admission admissionStats `json:"admission"`
+	}
+	if err := json.Unmarshal(statsRec.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("failed to parse stats JSON: %v", err)
+	}
+	if stats.Admission.Shed != 1 {
+		t.Errorf("expected shed count 1, got %d", stats.Admission.Shed)
+	}
+}
+
+func TestAdmissionQueueThenServe(t *testing.T) {
+	upstream, started, release := blockingUpstream(t)
+
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, nil,
+		WithAdmission(Admission{MaxInFlight: 1, Policy: AdmissionQueue, QueueDepth: 1, QueueTimeout: 5 * time.Second}))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	recs := []*httptest.ResponseRecorder{httptest.NewRecorder(), httptest.NewRecorder()}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		p.ServeHTTP(recs[0], httptest.NewRequest("POST", "/busy", nil))
+	}()
+	<-started
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		p.ServeHTTP(recs[1], httptest.NewRequest("POST", "/busy", nil))
+	}()
+	for p.admission.queued.Load() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// Queue is full - a third request is shed
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("POST", "/busy", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503 with full queue, got %d", rec.Code)
+	}
+
+	close(release)
+	wg.Wait()
+	for i, r := range recs {
+		if r.Code != http.StatusOK {
+			t.Errorf("expected request %d to be served, got %d", i, r.Code)
+		}
+	}
+}
+
+func TestAdmissionQueueTimeout(t *testing.T) {
+	upstream, started, release := blockingUpstream(t)
+	defer close(release)
+
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, nil,
+		WithAdmission(Admission{MaxInFlight: 1, Policy: AdmissionQueue, QueueDepth: 10, QueueTimeout: 20 * time.Millisecond}))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	go p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/busy", nil))
+	<-started
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("POST", "/busy", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503 after queue timeout, got %d", rec.Code)
+	}
+}
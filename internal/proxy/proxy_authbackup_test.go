@@ -0,0 +1,109 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func authBackupProxy(t *testing.T, keyHeaders []string, opts ...Option) *Proxy {
+	t.Helper()
+	shouldFail := false
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if shouldFail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("private data"))
+	}))
+	t.Cleanup(upstream.Close)
+
+	p, err := New(upstream.URL, 5*time.Second, 0, keyHeaders, nil, opts...)
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	// Populate the cache as user 1
+	req := httptest.NewRequest("GET", "/profile", nil)
+	req.Header.Set("Authorization", "Bearer user1")
+	p.ServeHTTP(httptest.NewRecorder(), req)
+
+	shouldFail = true
+	return p
+}
+
+func TestAuthBackupLeakPrevention(t *testing.T) {
+	p := authBackupProxy(t, nil)
+
+	req := httptest.NewRequest("GET", "/profile", nil)
+	req.Header.Set("Authorization", "Bearer user2")
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("expected status 502, got %d", rec.Code)
+	}
+	if rec.Body.String() == "private data" {
+		t.Error("expected another user's backup not to be served")
+	}
+
+	// The authenticated response was never stored, so anonymous requests
+	// cannot get it either
+	rec = httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/profile", nil))
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("expected status 502 for anonymous request, got %d (X-Cache %q)", rec.Code, rec.Header().Get("X-Cache"))
+	}
+}
+
+func TestAuthBackupStoredWhenPublic(t *testing.T) {
+	for _, cc := range []string{"public", "s-maxage=60"} {
+		t.Run(cc, func(t *testing.T) {
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Cache-Control", cc)
+				w.Write([]byte("shared data"))
+			}))
+			defer upstream.Close()
+			p, err := New(upstream.URL, 5*time.Second, 0, nil, nil)
+			if err != nil {
+				t.Fatalf("failed to create proxy: %v", err)
+			}
+
+			req := httptest.NewRequest("GET", "/catalog", nil)
+			req.Header.Set("Authorization", "Bearer user1")
+			p.ServeHTTP(httptest.NewRecorder(), req)
+
+			if _, ok := p.cache.Get(p.cacheKey(req)); !ok {
+				t.Errorf("expected a response marked %q to be cached", cc)
+			}
+		})
+	}
+}
+
+func TestAuthBackupOptOut(t *testing.T) {
+	p := authBackupProxy(t, nil, WithSharedAuthBackup(true))
+
+	req := httptest.NewRequest("GET", "/profile", nil)
+	req.Header.Set("Authorization", "Bearer user2")
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, req)
+
+	if rec.Header().Get("X-Cache") != "HIT-BACKUP" {
+		t.Errorf("expected HIT-BACKUP when sharing is allowed, got %q", rec.Header().Get("X-Cache"))
+	}
+}
+
+func TestAuthBackupKeyedOnAuthorization(t *testing.T) {
+	p := authBackupProxy(t, []string{"authorization"})
+
+	req := httptest.NewRequest("GET", "/profile", nil)
+	req.Header.Set("Authorization", "Bearer user1")
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, req)
+
+	if rec.Header().Get("X-Cache") != "HIT-BACKUP" {
+		t.Errorf("expected HIT-BACKUP for the same user, got %q", rec.Header().Get("X-Cache"))
+	}
+}
@@ -0,0 +1,219 @@
+package proxy
+
+import (
+	"Aegis/internal/cache"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// failingStore simulates an unavailable cache backend
+type failingStore struct{}
+
+func (failingStore) Fetch(string) (cache.Response, bool, error) {
+	return cache.Response{}, false, errors.New("connection refused")
+}
+
+func (failingStore) Put(string, cache.Response) error {
+	return errors.New("connection refused")
+}
+
+func (failingStore) Delete(string) error {
+	return errors.New("connection refused")
+}
+
+func TestBackendFailOpen(t *testing.T) {
+	shouldFail := false
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if shouldFail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	}))
+	defer upstream.Close()
+
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, nil,
+		WithStore(failingStore{}), WithBackendFailurePolicy(BackendFailOpen))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	// Store failure - response still served, just not cached
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/data", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "ok" {
+		t.Errorf("expected upstream response, got %d %q", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("X-Cache") != "PASS" {
+		t.Errorf("expected X-Cache: PASS, got %s", rec.Header().Get("X-Cache"))
+	}
+
+	// Lookup failure during failover - treated as a miss
+	shouldFail = true
+	rec = httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/data", nil))
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("expected status 502, got %d", rec.Code)
+	}
+}
+
+func TestBackendFailClosed(t *testing.T) {
+	shouldFail := false
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if shouldFail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, nil,
+		WithStore(failingStore{}), WithBackendFailurePolicy(BackendFailClosed))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	// A good upstream answer is served even when it cannot be stored
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/data", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("X-Cache") != CachePass {
+		t.Errorf("expected 200 PASS on store failure, got %d %q", rec.Code, rec.Header().Get("X-Cache"))
+	}
+
+	shouldFail = true
+	rec = httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/data", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503 on lookup failure, got %d", rec.Code)
+	}
+
+	// Non-cacheable requests never touch the backend
+	rec = httptest.NewRecorder()
+	shouldFail = false
+	p.ServeHTTP(rec, httptest.NewRequest("POST", "/data", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status 200 for POST, got %d", rec.Code)
+	}
+}
+
+// flakyStore fails the first call of each operation, then delegates
+type flakyStore struct {
+	*cache.Cache
+	fetchFailures, putFailures int
+}
+
+func (s *flakyStore) Fetch(key string) (cache.Response, bool, error) {
+	if s.fetchFailures > 0 {
+		s.fetchFailures--
+		return cache.Response{}, false, errors.New("i/o timeout")
+	}
+	return s.Cache.Fetch(key)
+}
+
+func (s *flakyStore) Put(key string, value cache.Response) error {
+	if s.putFailures > 0 {
+		s.putFailures--
+		return errors.New("i/o timeout")
+	}
+	return s.Cache.Put(key, value)
+}
+
+func TestBackendRetriesRecoverTransientErrors(t *testing.T) {
+	shouldFail := false
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if shouldFail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer upstream.Close()
+
+	store := &flakyStore{Cache: cache.New(0), putFailures: 1}
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, nil,
+		WithStore(store), WithBackendFailurePolicy(BackendFailClosed), WithBackendRetries(2))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/data", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("X-Cache") != CacheMiss {
+		t.Errorf("expected retried store to succeed with MISS, got %d %q", rec.Code, rec.Header().Get("X-Cache"))
+	}
+
+	shouldFail = true
+	store.fetchFailures = 1
+	rec = httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/data", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("X-Cache") != CacheHitBackup {
+		t.Errorf("expected retried lookup to serve backup, got %d %q", rec.Code, rec.Header().Get("X-Cache"))
+	}
+}
+
+func TestBackendRetriesExhausted(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer upstream.Close()
+
+	store := &flakyStore{Cache: cache.New(0), putFailures: 3}
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, nil,
+		WithStore(store), WithBackendFailurePolicy(BackendFailClosed), WithBackendRetries(2))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/data", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("X-Cache") != CachePass {
+		t.Errorf("expected the response served as PASS after retries are exhausted, got %d %q", rec.Code, rec.Header().Get("X-Cache"))
+	}
+}
+
+func TestDiskStoreSurvivesRestart(t *testing.T) {
+	failing := false
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte("persisted"))
+	}))
+	defer upstream.Close()
+
+	dir := t.TempDir()
+	start := func() *Proxy {
+		store, err := cache.NewFileStore(dir)
+		if err != nil {
+			t.Fatalf("NewFileStore: %v", err)
+		}
+		p, err := New(upstream.URL, 5*time.Second, time.Hour, nil, nil, WithStore(store))
+		if err != nil {
+			t.Fatalf("failed to create proxy: %v", err)
+		}
+		t.Cleanup(p.Close)
+		return p
+	}
+
+	p := start()
+	p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/page", nil))
+	p.Close()
+
+	// A new instance on the same directory still has the backup
+	failing = true
+	p = start()
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/page", nil))
+	if rec.Header().Get("X-Cache") != CacheHitBackup || rec.Body.String() != "persisted" {
+		t.Errorf("expected HIT-BACKUP from disk, got %s %q", rec.Header().Get("X-Cache"), rec.Body.String())
+	}
+	if p.cacheSize() != 1 {
+		t.Errorf("expected stats to count the disk entry, got %d", p.cacheSize())
+	}
+}
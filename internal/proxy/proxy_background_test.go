@@ -0,0 +1,58 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBackgroundWorkersLimitConcurrency(t *testing.T) {
+	upstream := okUpstream(t)
+
+	var active, peak, total atomic.Int64
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := active.Add(1)
+		for {
+			old := peak.Load()
+			if n <= old || peak.CompareAndSwap(old, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		active.Add(-1)
+		total.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer shadow.Close()
+
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, nil,
+		WithBackgroundWorkers(2), WithShadow(Shadow{Upstream: shadow.URL, SampleRate: 1}))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/page", nil))
+	}
+	p.Close()
+
+	if got := peak.Load(); got > 2 {
+		t.Errorf("expected at most 2 concurrent background fetches, got %d", got)
+	}
+	if got := total.Load(); got != 10 {
+		t.Errorf("expected queued fetches to complete, got %d of 10", got)
+	}
+}
+
+func TestBackgroundPoolRejectsAfterClose(t *testing.T) {
+	p, err := New("http://example.com", time.Second, 0, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	p.Close()
+	if p.background.submit(func() {}) {
+		t.Error("expected submit after Close to be rejected")
+	}
+}
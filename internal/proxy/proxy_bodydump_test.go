@@ -0,0 +1,121 @@
+package proxy
+
+import (
+	"Aegis/internal/logger"
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	return &buf
+}
+
+func TestRequestBodyDumpCappedAndForwarded(t *testing.T) {
+	var received string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		received = string(b)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	logs := captureLog(t)
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, logger.New(true, false, "debug"),
+		WithBodyDump(BodyDump{MaxBytes: 10}))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	payload := "0123456789abcdefghij"
+	req := httptest.NewRequest("POST", "/submit", strings.NewReader(payload))
+	p.ServeHTTP(httptest.NewRecorder(), req)
+
+	if received != payload {
+		t.Errorf("expected upstream to receive %q, got %q", payload, received)
+	}
+	if !strings.Contains(logs.String(), "(first 10 bytes): 0123456789\n") {
+		t.Errorf("expected capped body in log, got %q", logs.String())
+	}
+	if strings.Contains(logs.String(), "abcdef") {
+		t.Errorf("expected body beyond cap not to be logged, got %q", logs.String())
+	}
+}
+
+func TestRequestBodyDumpFiltersAndRedaction(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	logs := captureLog(t)
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, logger.New(true, false, "debug"),
+		WithBodyDump(BodyDump{
+			MaxBytes:     1024,
+			ContentTypes: []string{"application/json"},
+			PathPrefixes: []string{"/api/"},
+			RedactFields: []string{"password"},
+		}))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	req := httptest.NewRequest("POST", "/api/login", strings.NewReader(`{"user":"bob","password":"s3cret"}`))
+	req.Header.Set("Content-Type", "application/json")
+	p.ServeHTTP(httptest.NewRecorder(), req)
+
+	if !strings.Contains(logs.String(), `{"user":"bob","password":"[REDACTED]"}`) {
+		t.Errorf("expected redacted body in log, got %q", logs.String())
+	}
+	if strings.Contains(logs.String(), "s3cret") {
+		t.Error("expected password to be redacted")
+	}
+
+	logs.Reset()
+	req = httptest.NewRequest("POST", "/other", strings.NewReader(`{"a":1}`))
+	req.Header.Set("Content-Type", "application/json")
+	p.ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest("POST", "/api/form", strings.NewReader("a=1"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	p.ServeHTTP(httptest.NewRecorder(), req)
+
+	if strings.Contains(logs.String(), "request body:") {
+		t.Errorf("expected filtered requests not to be dumped, got %q", logs.String())
+	}
+}
+
+func TestRequestBodyDumpRedactsTruncatedValue(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"plain", `{"user":"bob","password":"hunter2"}`},
+		{"after escaped quote", `{"user":"bob","password":"h\"unter2"}`},
+		{"cut at escape", `{"user":"bob","password":"hun\\x"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newBodyDumper(BodyDump{MaxBytes: 30, RedactFields: []string{"password"}})
+			// The cap falls inside the password value
+			prefix, _, err := d.capture(io.NopCloser(strings.NewReader(tt.body)))
+			if err != nil {
+				t.Fatalf("capture failed: %v", err)
+			}
+			got := string(d.redact(prefix))
+			if got != `{"user":"bob","password":"[REDACTED]"` {
+				t.Errorf("expected the truncated value to be redacted, got %q", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package proxy
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCacheBodySizeRange(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		switch r.URL.Path {
+		case "/small":
+			w.Write([]byte("tiny"))
+		case "/large":
+			w.Write([]byte(strings.Repeat("x", 200)))
+		default:
+			w.Write([]byte(strings.Repeat("x", 50)))
+		}
+	}))
+	defer upstream.Close()
+
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, nil, WithBodySizeRange(10, 100))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"/small", "PASS"},
+		{"/medium", "MISS"},
+		{"/large", "PASS"},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest("GET", tt.path, nil))
+		if got := rec.Header().Get("X-Cache"); got != tt.expected {
+			t.Errorf("%s: expected X-Cache %s, got %s", tt.path, tt.expected, got)
+		}
+	}
+
+	if p.cache.Size() != 1 {
+		t.Errorf("expected only the in-range body to be cached, got %d entries", p.cache.Size())
+	}
+}
+
+func TestSkipEmptyBody(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	tests := []struct {
+		skip     bool
+		expected string
+		entries  int
+	}{
+		{true, "PASS", 0},
+		{false, "MISS", 1},
+	}
+
+	for _, tt := range tests {
+		p, err := New(upstream.URL, 5*time.Second, 0, nil, nil, WithSkipEmptyBody(tt.skip))
+		if err != nil {
+			t.Fatalf("failed to create proxy: %v", err)
+		}
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest("GET", "/empty", nil))
+		if got := rec.Header().Get("X-Cache"); got != tt.expected {
+			t.Errorf("skip=%v: expected X-Cache %s, got %s", tt.skip, tt.expected, got)
+		}
+		if p.cache.Size() != tt.entries {
+			t.Errorf("skip=%v: expected %d cached entries, got %d", tt.skip, tt.entries, p.cache.Size())
+		}
+	}
+}
+
+func TestMaxMemoryPassesOversizedEntry(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(bytes.Repeat([]byte("x"), 2048))
+	}))
+	defer upstream.Close()
+
+	p, err := New(upstream.URL, 5*time.Second, time.Minute, nil, nil,
+		WithMaxMemory(1024), WithBackendFailurePolicy(BackendFailClosed))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/big", nil))
+
+	if rec.Code != http.StatusOK || rec.Body.Len() != 2048 {
+		t.Errorf("expected the response passed through, got %d with %d bytes", rec.Code, rec.Body.Len())
+	}
+	if got := rec.Header().Get("X-Cache"); got != CachePass {
+		t.Errorf("expected X-Cache PASS, got %q", got)
+	}
+	if p.cache.Size() != 0 {
+		t.Errorf("expected nothing cached, got %d entries", p.cache.Size())
+	}
+}
+
+// endlessReader yields zero bytes forever and counts how many were read
+type endlessReader struct{ read int }
+
+func (r *endlessReader) Read(b []byte) (int, error) {
+	clear(b)
+	r.read += len(b)
+	return len(b), nil
+}
+
+func TestReadBodyStopsAtCap(t *testing.T) {
+	p, _ := New("http://example.com", 0, 0, nil, nil, WithBodySizeRange(0, 1024))
+
+	src := &endlessReader{}
+	buf, oversize, err := p.readBody(src)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !oversize || len(buf) != 1025 {
+		t.Errorf("expected an oversize prefix of 1025 bytes, got %d (oversize=%v)", len(buf), oversize)
+	}
+	if src.read > 64*1024 {
+		t.Errorf("expected reading to stop near the cap, read %d bytes", src.read)
+	}
+}
+
+func TestOversizedChunkedBody(t *testing.T) {
+	const size = 4 << 20
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Flushing forces chunked encoding without Content-Length
+		chunk := bytes.Repeat([]byte("x"), 64*1024)
+		for sent := 0; sent < size; sent += len(chunk) {
+			w.Write(chunk)
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer upstream.Close()
+
+	for _, tt := range []struct {
+		policy string
+		status int
+		size   int
+	}{
+		{OversizeStream, http.StatusOK, size},
+		{OversizeReject, http.StatusBadGateway, -1},
+	} {
+		t.Run(tt.policy, func(t *testing.T) {
+			p, err := New(upstream.URL, 5*time.Second, time.Minute, nil, nil,
+				WithBodySizeRange(0, 1024), WithOversizePolicy(tt.policy))
+			if err != nil {
+				t.Fatalf("failed to create proxy: %v", err)
+			}
+
+			rec := httptest.NewRecorder()
+			p.ServeHTTP(rec, httptest.NewRequest("GET", "/big", nil))
+
+			if rec.Code != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, rec.Code)
+			}
+			if tt.size >= 0 && rec.Body.Len() != tt.size {
+				t.Errorf("expected the full %d byte body streamed through, got %d", tt.size, rec.Body.Len())
+			}
+			if tt.policy == OversizeStream && rec.Header().Get("X-Cache") != CachePass {
+				t.Errorf("expected X-Cache PASS, got %q", rec.Header().Get("X-Cache"))
+			}
+			if p.cache.Size() != 0 {
+				t.Errorf("expected oversized body not to be cached, got %d entries", p.cache.Size())
+			}
+		})
+	}
+}
+
+func TestDeclaredOversizeBody(t *testing.T) {
+	body := strings.Repeat("x", 4096)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "4096")
+		w.Write([]byte(body))
+	}))
+	defer upstream.Close()
+
+	p, err := New(upstream.URL, 5*time.Second, time.Minute, nil, nil, WithBodySizeRange(0, 1024))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/big", nil))
+
+	if rec.Header().Get("X-Cache") != CachePass {
+		t.Errorf("expected X-Cache PASS, got %q", rec.Header().Get("X-Cache"))
+	}
+	if rec.Body.String() != body || rec.Header().Get("Content-Length") != "4096" {
+		t.Errorf("expected the %d byte body forwarded, got %d bytes (Content-Length %q)",
+			len(body), rec.Body.Len(), rec.Header().Get("Content-Length"))
+	}
+	if p.cache.Size() != 0 {
+		t.Errorf("expected oversized body not to be cached, got %d entries", p.cache.Size())
+	}
+}
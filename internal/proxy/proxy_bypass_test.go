@@ -0,0 +1,52 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBypassHeader(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			w.Header().Set("X-Cache", "no-store")
+		case "/custom":
+			w.Header().Set("X-No-Cache", "1")
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer upstream.Close()
+
+	tests := []struct {
+		name   string
+		opts   []Option
+		path   string
+		status string
+	}{
+		{"default marker", nil, "/token", CachePass},
+		{"no marker", nil, "/page", CacheMiss},
+		{"custom header ignored by default", nil, "/custom", CacheMiss},
+		{"custom header", []Option{WithBypassHeader("X-No-Cache", "")}, "/custom", CachePass},
+		{"custom header, value mismatch", []Option{WithBypassHeader("X-No-Cache", "true")}, "/custom", CacheMiss},
+		{"disabled", []Option{WithBypassHeader("", "")}, "/token", CacheMiss},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := New(upstream.URL, 5*time.Second, time.Minute, nil, nil, tt.opts...)
+			if err != nil {
+				t.Fatalf("failed to create proxy: %v", err)
+			}
+			rec := httptest.NewRecorder()
+			p.ServeHTTP(rec, httptest.NewRequest("GET", tt.path, nil))
+
+			if got := rec.Header().Get("X-Cache"); got != tt.status {
+				t.Errorf("expected X-Cache %s, got %s", tt.status, got)
+			}
+			if cached := p.cache.Size() == 1; cached != (tt.status == CacheMiss) {
+				t.Errorf("expected cached=%v, got %d entries", tt.status == CacheMiss, p.cache.Size())
+			}
+		})
+	}
+}
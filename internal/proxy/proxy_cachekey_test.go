@@ -1,8 +1,13 @@
 package proxy
 
 import (
+	"Aegis/internal/utils"
+	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestCacheKeyWithHeaders(t *testing.T) {
@@ -66,6 +71,35 @@ func TestCacheKeyWithMultipleHeaders(t *testing.T) {
 	}
 }
 
+func TestCacheKeyMultiValueHeader(t *testing.T) {
+	p, _ := New("http://example.com", 0, 0, []string{"Accept"}, nil)
+
+	req1 := httptest.NewRequest("GET", "/api/data", nil)
+	req1.Header.Add("Accept", "application/json")
+	req1.Header.Add("Accept", "text/html")
+
+	req2 := httptest.NewRequest("GET", "/api/data", nil)
+	req2.Header.Add("Accept", "application/json")
+	req2.Header.Add("Accept", "text/plain")
+
+	if p.cacheKey(req1) == p.cacheKey(req2) {
+		t.Errorf("expected different cache keys when a second header value differs")
+	}
+
+	// Value order doesn't matter
+	req3 := httptest.NewRequest("GET", "/api/data", nil)
+	req3.Header.Add("Accept", "text/html")
+	req3.Header.Add("Accept", "application/json")
+
+	expected := "GET /api/data?|Accept:application/json,text/html"
+	if key := p.cacheKey(req3); key != expected {
+		t.Errorf("expected key %s, got %s", expected, key)
+	}
+	if p.cacheKey(req1) != p.cacheKey(req3) {
+		t.Errorf("expected the same key regardless of value order")
+	}
+}
+
 func TestCacheKeyWithMissingHeaders(t *testing.T) {
 	// Proxy configured to use Authorization in key
 	p, _ := New("http://example.com", 0, 0, []string{"Authorization", "X-Custom"}, nil)
@@ -129,3 +163,200 @@ func TestCacheKeyHeadersCaseSensitive(t *testing.T) {
 		t.Errorf("expected key %s, got %s", expectedKey, key)
 	}
 }
+
+func TestCacheKeyIncludeScheme(t *testing.T) {
+	trusted, _ := utils.ParseCIDRs([]string{"10.0.0.0/8"})
+	p, _ := New("http://example.com", 0, 0, nil, nil,
+		WithSchemeInKey(true), WithTrustedProxies(trusted))
+
+	httpReq := httptest.NewRequest("GET", "/api/data", nil)
+	httpReq.RemoteAddr = "10.0.0.5:1234"
+	httpReq.Header.Set("X-Forwarded-Proto", "http")
+
+	httpsReq := httptest.NewRequest("GET", "/api/data", nil)
+	httpsReq.RemoteAddr = "10.0.0.5:1234"
+	httpsReq.Header.Set("X-Forwarded-Proto", "https")
+
+	if key := p.cacheKey(httpReq); key != "GET /api/data?|scheme:http" {
+		t.Errorf("unexpected http key %s", key)
+	}
+	if key := p.cacheKey(httpsReq); key != "GET /api/data?|scheme:https" {
+		t.Errorf("unexpected https key %s", key)
+	}
+
+	// X-Forwarded-Proto from an untrusted client is ignored
+	spoofed := httptest.NewRequest("GET", "/api/data", nil)
+	spoofed.RemoteAddr = "203.0.113.7:1234"
+	spoofed.Header.Set("X-Forwarded-Proto", "https")
+	if key := p.cacheKey(spoofed); key != "GET /api/data?|scheme:http" {
+		t.Errorf("expected untrusted X-Forwarded-Proto to be ignored, got %s", key)
+	}
+}
+
+func TestCacheSchemeDistinctEntries(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(r.Header.Get("X-Forwarded-Proto")))
+	}))
+	defer upstream.Close()
+
+	trusted, _ := utils.ParseCIDRs([]string{"192.0.2.0/24"})
+	p, _ := New(upstream.URL, 5*time.Second, 0, nil, nil,
+		WithSchemeInKey(true), WithTrustedProxies(trusted))
+
+	for _, proto := range []string{"http", "https"} {
+		req := httptest.NewRequest("GET", "/page", nil)
+		req.Header.Set("X-Forwarded-Proto", proto)
+		p.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if p.cache.Size() != 2 {
+		t.Errorf("expected 2 cache entries for http and https, got %d", p.cache.Size())
+	}
+}
+
+func TestCacheKeyCountry(t *testing.T) {
+	p, _ := New("http://example.com", 0, 0, nil, nil, WithCountryKey("X-Country", "ZZ"))
+
+	tests := []struct {
+		header   string
+		expected string
+	}{
+		{"PL", "GET /page?|country:PL"},
+		{" pl ", "GET /page?|country:PL"},
+		{"de", "GET /page?|country:DE"},
+		{"", "GET /page?|country:ZZ"},
+		{"POL", "GET /page?|country:ZZ"},
+		{"1A", "GET /page?|country:ZZ"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/page", nil)
+		if tt.header != "" {
+			req.Header.Set("X-Country", tt.header)
+		}
+		if key := p.cacheKey(req); key != tt.expected {
+			t.Errorf("X-Country %q: expected key %s, got %s", tt.header, tt.expected, key)
+		}
+	}
+}
+
+func TestCacheKeyTimeBucket(t *testing.T) {
+	clock := utils.NewFakeClock(time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC))
+	p, _ := New("http://example.com", 0, 0, nil, nil, WithTimeBucket(time.Hour), WithClock(clock))
+
+	req := httptest.NewRequest("GET", "/report", nil)
+	start := p.cacheKey(req)
+
+	clock.Advance(59 * time.Minute)
+	if key := p.cacheKey(req); key != start {
+		t.Errorf("expected stable key within a bucket, got %s and %s", start, key)
+	}
+
+	clock.Advance(time.Minute)
+	if key := p.cacheKey(req); key == start {
+		t.Errorf("expected a new key at the bucket boundary, got %s", key)
+	}
+}
+
+func TestCacheKeyLongHeaderValue(t *testing.T) {
+	p, _ := New("http://example.com", 0, 0, []string{"X-Tenant"}, nil, WithMaxKeyValueBytes(16))
+
+	short := httptest.NewRequest("GET", "/api", nil)
+	short.Header.Set("X-Tenant", "acme")
+	if key := p.cacheKey(short); key != "GET /api?|X-Tenant:acme" {
+		t.Errorf("expected short value unchanged, got %s", key)
+	}
+
+	long1 := httptest.NewRequest("GET", "/api", nil)
+	long1.Header.Set("X-Tenant", strings.Repeat("a", 10000))
+	long2 := httptest.NewRequest("GET", "/api", nil)
+	long2.Header.Set("X-Tenant", strings.Repeat("a", 9999)+"b")
+
+	key1 := p.cacheKey(long1)
+	if !strings.HasPrefix(key1, "GET /api?|X-Tenant:sha256:") || len(key1) > 100 {
+		t.Errorf("expected hashed long value, got %s", key1)
+	}
+	if key1 != p.cacheKey(long1) {
+		t.Error("expected consistent key for the same long value")
+	}
+	if key1 == p.cacheKey(long2) {
+		t.Error("expected distinct keys for different long values")
+	}
+}
+
+func TestCacheKeyHashing(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer upstream.Close()
+
+	for _, fn := range []string{KeyHashSHA256, KeyHashXXHash} {
+		p, _ := New(upstream.URL, 5*time.Second, 0, nil, nil, WithKeyHash(fn))
+
+		for _, path := range []string{"/a", "/b", "/a"} {
+			rec := httptest.NewRecorder()
+			p.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		}
+
+		// Two distinct requests, two distinct hashed entries
+		if p.cache.Size() != 2 {
+			t.Errorf("%s: expected 2 cache entries, got %d", fn, p.cache.Size())
+		}
+
+		// Hashed key hits, plaintext key misses
+		hashed := p.hashKey(p.cacheKey(httptest.NewRequest("GET", "/a", nil)))
+		if !strings.HasPrefix(hashed, fn+":") {
+			t.Errorf("%s: expected hashed key prefix, got %s", fn, hashed)
+		}
+		if cached, ok := p.cache.Get(hashed); !ok || string(cached.Body) != "/a" {
+			t.Errorf("%s: expected hashed key to hit /a entry", fn)
+		}
+		if _, ok := p.cache.Get("GET /a?"); ok {
+			t.Errorf("%s: expected plaintext key to miss", fn)
+		}
+	}
+}
+
+func TestCacheKeyHashNone(t *testing.T) {
+	p, _ := New("http://example.com", 0, 0, nil, nil, WithKeyHash(KeyHashNone))
+
+	if key := p.hashKey("GET /a?"); key != "GET /a?" {
+		t.Errorf("expected plaintext key, got %s", key)
+	}
+}
+
+func TestHashLongKeys(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.RawQuery))
+	}))
+	defer upstream.Close()
+
+	p, _ := New(upstream.URL, 5*time.Second, 0, nil, nil, WithHashLongKeys(64))
+	long := "/search?q=" + strings.Repeat("x", 200)
+	for _, path := range []string{"/short", long} {
+		p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	if _, ok := p.cache.Get("GET /short?"); !ok {
+		t.Error("expected short key to stay readable")
+	}
+	hashed := p.hashKey(p.cacheKey(httptest.NewRequest("GET", long, nil)))
+	if !strings.HasPrefix(hashed, "sha256:") || len(hashed) != len("sha256:")+64 {
+		t.Fatalf("expected a SHA-256 key, got %s", hashed)
+	}
+	if _, ok := p.cache.Get(hashed); !ok {
+		t.Error("expected long key to be stored under its digest")
+	}
+	if _, ok := p.cache.Get(p.cacheKey(httptest.NewRequest("GET", long, nil))); ok {
+		t.Error("expected the long plaintext key not to be kept")
+	}
+
+	// Purging by url hashes the same way
+	rec := httptest.NewRecorder()
+	p.PurgeHandler(rec, httptest.NewRequest("POST", "/purge?url="+url.QueryEscape(long), nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected purge of the long key to succeed, got %d %s", rec.Code, rec.Body.String())
+	}
+}
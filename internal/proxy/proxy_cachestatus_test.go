@@ -0,0 +1,89 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCacheStatusHeaderScenarios(t *testing.T) {
+	shouldFail := false
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case shouldFail:
+			w.WriteHeader(http.StatusInternalServerError)
+		case r.URL.Path == "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer upstream.Close()
+
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, nil, WithStatusHeader(StatusHeaderCacheStatus))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader("")))
+		return rec
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		fail     bool
+		expected string
+	}{
+		{"miss", "GET", "/data", false, "Aegis; fwd=miss; stored"},
+		{"pass", "GET", "/missing", false, "Aegis; fwd=miss"},
+		{"bypass", "POST", "/data", false, "Aegis; fwd=method"},
+		{"hit-backup", "GET", "/data", true, "Aegis; hit; detail=backup"},
+	}
+
+	for _, tt := range tests {
+		shouldFail = tt.fail
+		rec := serve(tt.method, tt.path)
+		if got := rec.Header().Get("Cache-Status"); got != tt.expected {
+			t.Errorf("%s: expected Cache-Status %q, got %q", tt.name, tt.expected, got)
+		}
+		if got := rec.Header().Get("X-Cache"); got != "" {
+			t.Errorf("%s: expected no X-Cache in cache-status mode, got %q", tt.name, got)
+		}
+	}
+}
+
+func TestCacheStatusHeaderModes(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	tests := []struct {
+		mode        string
+		xCache      string
+		cacheStatus string
+	}{
+		{"", "MISS", ""},
+		{StatusHeaderXCache, "MISS", ""},
+		{StatusHeaderBoth, "MISS", "Aegis; fwd=miss; stored"},
+	}
+
+	for _, tt := range tests {
+		p, _ := New(upstream.URL, 5*time.Second, 0, nil, nil, WithStatusHeader(tt.mode))
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest("GET", "/data", nil))
+
+		if got := rec.Header().Get("X-Cache"); got != tt.xCache {
+			t.Errorf("mode %q: expected X-Cache %q, got %q", tt.mode, tt.xCache, got)
+		}
+		if got := rec.Header().Get("Cache-Status"); got != tt.cacheStatus {
+			t.Errorf("mode %q: expected Cache-Status %q, got %q", tt.mode, tt.cacheStatus, got)
+		}
+	}
+}
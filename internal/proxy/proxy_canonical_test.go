@@ -0,0 +1,59 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCanonicalPath(t *testing.T) {
+	tests := []struct {
+		in          string
+		decodeSlash bool
+		want        string
+	}{
+		{"/api//users", false, "/api/users"},
+		{"//api///users/", false, "/api/users/"},
+		{"/api/%7Euser%2dx", false, "/api/~user-x"},
+		{"/api/%2fusers", false, "/api/%2Fusers"},
+		{"/api/%2Fusers", true, "/api/users"},
+		{"/a%3fb", false, "/a%3Fb"},
+		{"/bad%zz", false, "/bad%zz"},
+	}
+	for _, tt := range tests {
+		if got := canonicalPath(tt.in, tt.decodeSlash); got != tt.want {
+			t.Errorf("canonicalPath(%q, %v) = %q, want %q", tt.in, tt.decodeSlash, got, tt.want)
+		}
+	}
+}
+
+func TestCanonicalPathKeyAndForwarding(t *testing.T) {
+	var gotURI string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotURI = r.RequestURI
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, nil, WithCanonicalPath(true, false))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api//us%65rs?x=1", nil))
+	if gotURI != "/api/users?x=1" {
+		t.Errorf("expected canonical path upstream, got %q", gotURI)
+	}
+	if _, ok := p.cache.Get("GET /api/users?x=1"); !ok {
+		t.Error("expected entry under the canonical key")
+	}
+
+	p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/%2Fusers", nil))
+	if gotURI != "/api/%2Fusers" {
+		t.Errorf("expected encoded slash to be preserved upstream, got %q", gotURI)
+	}
+	if _, ok := p.cache.Get("GET /api/%2Fusers?"); !ok {
+		t.Error("expected encoded slash to get its own cache key")
+	}
+}
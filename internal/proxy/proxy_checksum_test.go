@@ -0,0 +1,79 @@
+package proxy
+
+import (
+	"Aegis/internal/cache"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// serializedStore keeps entries encoded, like a persistent backend would
+type serializedStore struct {
+	data map[string][]byte
+}
+
+func (s *serializedStore) Fetch(key string) (cache.Response, bool, error) {
+	raw, ok := s.data[key]
+	if !ok {
+		return cache.Response{}, false, nil
+	}
+	var resp cache.Response
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return cache.Response{}, false, err
+	}
+	return resp, true, nil
+}
+
+func (s *serializedStore) Put(key string, value cache.Response) error {
+	raw, err := json.Marshal(value)
+	s.data[key] = raw
+	return err
+}
+
+func (s *serializedStore) Delete(key string) error {
+	delete(s.data, key)
+	return nil
+}
+
+func TestChecksumRejectsCorruptedEntry(t *testing.T) {
+	var failing atomic.Bool
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("original body"))
+	}))
+	defer upstream.Close()
+
+	store := &serializedStore{data: map[string][]byte{}}
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, nil, WithStore(store), WithChecksums(true))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/doc", nil))
+	failing.Store(true)
+
+	// Intact entry is served as backup
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/doc", nil))
+	if rec.Header().Get("X-Cache") != CacheHitBackup || rec.Body.String() != "original body" {
+		t.Fatalf("expected intact backup, got %q %q", rec.Header().Get("X-Cache"), rec.Body.String())
+	}
+
+	// Flip the persisted body, keeping the stored checksum
+	entry, _, _ := store.Fetch("GET /doc?")
+	entry.Body = []byte("0riginal body")
+	raw, _ := json.Marshal(entry)
+	store.data["GET /doc?"] = raw
+
+	rec = httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/doc", nil))
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("expected corrupted entry to be treated as a miss (502), got %d %q", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,233 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// heldUpstream counts requests and holds each one until release is closed
+func heldUpstream(t *testing.T, body string) (*httptest.Server, *atomic.Int32, chan struct{}) {
+	t.Helper()
+	var calls atomic.Int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-release
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls, release
+}
+
+func TestCoalescingColdKey(t *testing.T) {
+	upstream, calls, release := heldUpstream(t, "popular")
+	p, err := New(upstream.URL, 5*time.Second, time.Minute, nil, nil, WithCoalescing(true))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	const n = 50
+	recs := make([]*httptest.ResponseRecorder, n)
+	var wg sync.WaitGroup
+	for i := range recs {
+		recs[i] = httptest.NewRecorder()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.ServeHTTP(recs[i], httptest.NewRequest("GET", "/popular", nil))
+		}()
+	}
+	// Let every request join the flight before upstream answers
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := calls.Load(); got != 1 {
+		t.Errorf("expected 1 upstream request, got %d", got)
+	}
+	statuses := make(map[string]int)
+	for _, rec := range recs {
+		if rec.Code != http.StatusOK || rec.Body.String() != "popular" {
+			t.Fatalf("expected 200 popular, got %d %q", rec.Code, rec.Body.String())
+		}
+		statuses[rec.Header().Get("X-Cache")]++
+	}
+	if statuses[CacheMiss] != 1 || statuses[CacheCoalesced] != n-1 {
+		t.Errorf("expected 1 MISS and %d COALESCED, got %v", n-1, statuses)
+	}
+}
+
+func TestCoalescingBypassedForPost(t *testing.T) {
+	upstream, calls, release := heldUpstream(t, "created")
+	p, err := New(upstream.URL, 5*time.Second, time.Minute, nil, nil, WithCoalescing(true))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/orders", strings.NewReader("{}")))
+		}()
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for calls.Load() < 5 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+	if got := calls.Load(); got != 5 {
+		t.Errorf("expected every POST to reach upstream, got %d", got)
+	}
+}
+
+func TestCoalescingSkipsIncompleteResponse(t *testing.T) {
+	p, _ := New("http://example.com", 5*time.Second, time.Minute, nil, nil, WithCoalescing(true))
+	fl, leader := p.flights.join("GET /big?")
+	if !leader {
+		t.Fatal("expected first join to lead")
+	}
+	if _, leader := p.flights.join("GET /big?"); leader {
+		t.Fatal("expected second join to wait")
+	}
+	rec := p.leadFlight(httptest.NewRecorder(), httptest.NewRequest("GET", "/big", nil), fl)
+	rec.Header().Set("Content-Length", "10")
+	rec.WriteHeader(http.StatusOK)
+	rec.Write([]byte("short"))
+	rec.completed = true
+	p.flights.finish("GET /big?", rec)
+
+	if fl.shared {
+		t.Error("expected truncated body not to be shared")
+	}
+	if _, leader := p.flights.join("GET /big?"); !leader {
+		t.Error("expected a finished flight to be forgotten")
+	}
+}
+
+func TestCoalescingKeepsPerUserResponsesApart(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+	}{
+		{"private with cookie", http.Header{"Cache-Control": {"private"}, "Vary": {"Cookie"}}},
+		{"sets cookie", http.Header{}},
+		{"varies on cookie", http.Header{"Vary": {"Cookie"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			release := make(chan struct{})
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				<-release
+				for k, v := range tt.header {
+					w.Header()[k] = v
+				}
+				if tt.name != "varies on cookie" {
+					w.Header().Set("Set-Cookie", "sid="+r.Header.Get("Cookie"))
+				}
+				w.Write([]byte("hello " + r.Header.Get("Cookie")))
+			}))
+			defer upstream.Close()
+			p, err := New(upstream.URL, 5*time.Second, time.Minute, nil, nil, WithCoalescing(true))
+			if err != nil {
+				t.Fatalf("failed to create proxy: %v", err)
+			}
+
+			users := []string{"alice", "bob"}
+			recs := make([]*httptest.ResponseRecorder, len(users))
+			var wg sync.WaitGroup
+			for i, user := range users {
+				recs[i] = httptest.NewRecorder()
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					req := httptest.NewRequest("GET", "/account", nil)
+					req.Header.Set("Cookie", user)
+					p.ServeHTTP(recs[i], req)
+				}()
+			}
+			time.Sleep(100 * time.Millisecond)
+			close(release)
+			wg.Wait()
+
+			for i, user := range users {
+				if got := recs[i].Body.String(); got != "hello "+user {
+					t.Errorf("expected %s to get their own response, got %q (X-Cache %q)", user, got, recs[i].Header().Get("X-Cache"))
+				}
+				if c := recs[i].Header().Get("Set-Cookie"); c != "" && c != "sid="+user {
+					t.Errorf("expected %s not to get another user's cookie, got %q", user, c)
+				}
+			}
+			if got := calls.Load(); got != 2 {
+				t.Errorf("expected 2 upstream requests, got %d", got)
+			}
+		})
+	}
+}
+
+func TestCoalescingSkipsAbortedLeader(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) > 1 {
+			w.Write([]byte("partial-rest"))
+			return
+		}
+		<-release
+		// Chunked, cut off after the first chunk
+		w.Write([]byte("partial-"))
+		w.(http.Flusher).Flush()
+		conn, _, err := http.NewResponseController(w).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+	}))
+	defer upstream.Close()
+	p, err := New(upstream.URL, 5*time.Second, time.Minute, nil, nil, WithCoalescing(true), WithStreaming(1))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer func() {
+			if v := recover(); v != http.ErrAbortHandler {
+				t.Errorf("expected the leader to abort, got %v", v)
+			}
+		}()
+		p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/stream", nil))
+	}()
+	// Let the leader reach upstream before the followers join
+	for calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	recs := make([]*httptest.ResponseRecorder, 3)
+	for i := range recs {
+		recs[i] = httptest.NewRecorder()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.ServeHTTP(recs[i], httptest.NewRequest("GET", "/stream", nil))
+		}()
+	}
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	for _, rec := range recs {
+		if rec.Header().Get("X-Cache") == CacheCoalesced || rec.Body.String() != "partial-rest" {
+			t.Errorf("expected followers to fetch on their own, got %q %q", rec.Header().Get("X-Cache"), rec.Body.String())
+		}
+	}
+}
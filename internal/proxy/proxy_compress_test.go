@@ -0,0 +1,54 @@
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCompressedBodyRoundTrip(t *testing.T) {
+	var body bytes.Buffer
+	body.WriteString("[")
+	for i := 0; body.Len() < 100<<10; i++ {
+		fmt.Fprintf(&body, `{"id":%d,"name":"item %d","tags":["a","b"]},`, i, i)
+	}
+	body.WriteString(`{}]`)
+	original := body.Bytes()
+
+	var failing atomic.Bool
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(original)
+	}))
+	defer upstream.Close()
+
+	p, err := New(upstream.URL, 5*time.Second, time.Minute, nil, nil, WithCompression(1024))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/items", nil))
+	if p.cache.MemoryUsage() >= int64(len(original)) {
+		t.Errorf("expected the stored body to be compressed, memory is %d bytes", p.cache.MemoryUsage())
+	}
+
+	failing.Store(true)
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/items", nil))
+	if rec.Header().Get("X-Cache") != CacheHitBackup {
+		t.Fatalf("expected %s, got %q", CacheHitBackup, rec.Header().Get("X-Cache"))
+	}
+	if !bytes.Equal(rec.Body.Bytes(), original) {
+		t.Errorf("expected the served body to match the original %d bytes, got %d", len(original), rec.Body.Len())
+	}
+	if rec.Header().Get("Content-Encoding") != "" {
+		t.Errorf("expected no Content-Encoding, got %q", rec.Header().Get("Content-Encoding"))
+	}
+}
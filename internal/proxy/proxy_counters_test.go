@@ -0,0 +1,55 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStatsCounters(t *testing.T) {
+	var failing atomic.Bool
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer upstream.Close()
+
+	p, err := New(upstream.URL, 5*time.Second, time.Minute, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/data", nil))
+	p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/data", nil))
+	failing.Store(true)
+	p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/data", nil))
+	p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/uncached", nil))
+
+	rec := httptest.NewRecorder()
+	p.StatsHandler(rec, httptest.NewRequest("GET", "/stats", nil))
+	var stats statsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("failed to parse stats: %v", err)
+	}
+	want := counterStats{Requests: 4, Miss: 1, Bypass: 1, HitBackup: 1, UpstreamErrors: 2}
+	if stats.Counters != want {
+		t.Errorf("expected counters %+v, got %+v", want, stats.Counters)
+	}
+	if stats.HitRatio != 0.5 {
+		t.Errorf("expected hit ratio 0.5, got %g", stats.HitRatio)
+	}
+
+	rec = httptest.NewRecorder()
+	p.StatsHandler(rec, httptest.NewRequest("GET", "/stats?format=text", nil))
+	for _, line := range []string{"requests 4\n", "hit_backup 1\n", "upstream_errors 2\n"} {
+		if !strings.Contains(rec.Body.String(), line) {
+			t.Errorf("expected %q in text stats, got %q", line, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package proxy
+
+import (
+	"Aegis/internal/cache"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDashboardRendersStats(t *testing.T) {
+	p, err := New("http://upstream.internal", 5*time.Second, 0, nil, nil,
+		WithCircuitBreaker(3, time.Minute), WithInstanceID("X-Aegis-Instance", "edge-7"))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	p.cache.Set("a", cache.Response{Body: []byte("one")})
+	p.cache.Set("b", cache.Response{Body: []byte("two")})
+	p.counters.record(CacheMiss)
+	p.counters.record(CacheHitBackup)
+
+	rec := httptest.NewRecorder()
+	p.DashboardHandler(rec, httptest.NewRequest("GET", "/dashboard", nil))
+
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("expected HTML content type, got %q", ct)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		"<td>2</td>",                     // cached entries
+		"<td>50.0%</td>",                 // hit ratio
+		`<td class="closed">closed</td>`, // breaker
+		"edge-7",
+		"http://upstream.internal",
+		`http-equiv="refresh"`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected dashboard to contain %q", want)
+		}
+	}
+	if strings.Contains(body, "<script") || strings.Contains(body, "<link") {
+		t.Error("expected a self-contained page without external resources")
+	}
+}
@@ -0,0 +1,92 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestErrorBodyTriggersFailover(t *testing.T) {
+	var broken atomic.Bool
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if broken.Load() {
+			w.Write([]byte("<html><title>Service Unavailable</title></html>"))
+			return
+		}
+		w.Write([]byte("<html>catalog</html>"))
+	}))
+	defer upstream.Close()
+
+	p, err := New(upstream.URL, 5*time.Second, time.Minute, nil, nil,
+		WithErrorBodyDetection(ErrorBody{
+			Contains:     []string{"<title>Service Unavailable</title>"},
+			ContentTypes: []string{"text/html"},
+		}),
+		WithCircuitBreaker(10, time.Minute))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		return rec
+	}
+
+	// A normal 200 is cached
+	if rec := get("/catalog"); rec.Header().Get("X-Cache") != CacheMiss {
+		t.Fatalf("expected normal page to be cached, got %s", rec.Header().Get("X-Cache"))
+	}
+
+	broken.Store(true)
+	rec := get("/catalog")
+	if got := rec.Header().Get("X-Cache"); got != CacheHitBackup {
+		t.Errorf("expected error page to trigger failover, got %s", got)
+	}
+	if rec.Body.String() != "<html>catalog</html>" {
+		t.Errorf("expected backup body, got %q", rec.Body.String())
+	}
+	if got := p.breaker.failures; got != 1 {
+		t.Errorf("expected error page to count as an upstream failure, got %d", got)
+	}
+
+	// Without a backup the page is passed through but not cached
+	rec = get("/other")
+	if got := rec.Header().Get("X-Cache"); got != CachePass || rec.Code != http.StatusOK {
+		t.Errorf("expected PASS 200, got %s %d", got, rec.Code)
+	}
+	if _, ok := p.cache.Get("GET /other?"); ok {
+		t.Error("expected error page not to be cached")
+	}
+}
+
+func TestErrorBodyOnlyConfiguredTypesAndPaths(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", r.URL.Query().Get("type"))
+		w.Write([]byte(`{"message":"Internal error"}`))
+	}))
+	defer upstream.Close()
+
+	p, err := New(upstream.URL, 5*time.Second, time.Minute, nil, nil,
+		WithErrorBodyDetection(ErrorBody{
+			Contains:     []string{"Internal error"},
+			ContentTypes: []string{"text/html"},
+			Paths:        []string{"/shop/"},
+		}))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	for path, want := range map[string]string{
+		"/shop/cart?type=text/html":       CachePass,
+		"/shop/api?type=application/json": CacheMiss,
+		"/blog/post?type=text/html":       CacheMiss,
+	} {
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if got := rec.Header().Get("X-Cache"); got != want {
+			t.Errorf("%s: expected %s, got %s", path, want, got)
+		}
+	}
+}
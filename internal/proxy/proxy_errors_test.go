@@ -0,0 +1,75 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestErrorCaptureEndpoint(t *testing.T) {
+	n := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		w.Header().Set("X-Attempt", fmt.Sprint(n))
+		w.Header().Set("Set-Cookie", "sid=secret")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(fmt.Sprintf("failure %d", n)))
+	}))
+	defer upstream.Close()
+
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, nil, WithErrorCapture(2))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest("GET", "/flaky", nil))
+		// Captured errors are never served to clients
+		if strings.Contains(rec.Body.String(), "failure") {
+			t.Errorf("expected upstream error body not to be served, got %q", rec.Body.String())
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	p.ErrorsHandler(rec, httptest.NewRequest("GET", "/errors", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	var captured []CapturedError
+	if err := json.Unmarshal(rec.Body.Bytes(), &captured); err != nil {
+		t.Fatalf("failed to parse errors JSON: %v", err)
+	}
+
+	// Ring holds the two most recent errors, oldest first
+	if len(captured) != 2 {
+		t.Fatalf("expected 2 captured errors, got %d", len(captured))
+	}
+	if captured[0].Body != "failure 2" || captured[1].Body != "failure 3" {
+		t.Errorf("unexpected captured bodies: %q, %q", captured[0].Body, captured[1].Body)
+	}
+	if captured[1].Status != http.StatusInternalServerError || captured[1].Path != "/flaky" {
+		t.Errorf("unexpected captured entry: %+v", captured[1])
+	}
+	if captured[1].Header.Get("X-Attempt") != "3" {
+		t.Errorf("expected captured headers, got %v", captured[1].Header)
+	}
+	if c := captured[1].Header.Get("Set-Cookie"); c != "" {
+		t.Errorf("expected Set-Cookie to be dropped from captured headers, got %q", c)
+	}
+}
+
+func TestErrorCaptureDisabled(t *testing.T) {
+	p, _ := New("http://example.com", 5*time.Second, 0, nil, nil)
+
+	rec := httptest.NewRecorder()
+	p.ErrorsHandler(rec, httptest.NewRequest("GET", "/errors", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 when disabled, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,109 @@
+package proxy
+
+import (
+	"Aegis/internal/utils"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestETagRevalidation(t *testing.T) {
+	var etag atomic.Value
+	etag.Store(`"v1"`)
+	var full, conditional atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := etag.Load().(string)
+		if inm := r.Header.Get("If-None-Match"); inm != "" {
+			conditional.Add(1)
+			if inm == current {
+				w.Header().Set("ETag", current)
+				w.Header().Set("X-Served", "304")
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+		full.Add(1)
+		w.Header().Set("ETag", current)
+		w.Write([]byte("body " + current))
+	}))
+	defer upstream.Close()
+
+	clock := utils.NewFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	p, err := New(upstream.URL, 5*time.Second, time.Minute, nil, nil,
+		WithClock(clock), WithETagRevalidation(true))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	get := func(h http.Header) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/asset", nil)
+		for k, v := range h {
+			req.Header[k] = v
+		}
+		p.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := get(nil); rec.Header().Get("X-Cache") != CacheMiss {
+		t.Fatalf("expected first request MISS, got %s", rec.Header().Get("X-Cache"))
+	}
+
+	// Expired, but upstream confirms it has not changed
+	clock.Advance(2 * time.Minute)
+	rec := get(nil)
+	if got := rec.Header().Get("X-Cache"); got != CacheRevalidated {
+		t.Fatalf("expected REVALIDATED, got %s", got)
+	}
+	if rec.Code != http.StatusOK || rec.Body.String() != `body "v1"` {
+		t.Errorf("expected cached body with 200, got %d %q", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("X-Served") != "304" {
+		t.Error("expected headers of the 304 to update the cached ones")
+	}
+	if full.Load() != 1 {
+		t.Errorf("expected a single full response, got %d", full.Load())
+	}
+	if ttl := storedTTL(t, p, "GET /asset?"); ttl != time.Minute {
+		t.Errorf("expected renewed TTL of 1m, got %s", ttl)
+	}
+
+	// Clients sending their own validators are passed through unchanged
+	if rec := get(http.Header{"If-None-Match": {`"v1"`}}); rec.Code != http.StatusNotModified {
+		t.Errorf("expected client's conditional request to get 304, got %d", rec.Code)
+	}
+
+	// A changed resource replaces the entry
+	etag.Store(`"v2"`)
+	rec = get(nil)
+	if got := rec.Header().Get("X-Cache"); got != CacheMiss || rec.Body.String() != `body "v2"` {
+		t.Errorf("expected MISS with new body, got %s %q", got, rec.Body.String())
+	}
+	if rec := get(nil); rec.Header().Get("X-Cache") != CacheRevalidated || rec.Body.String() != `body "v2"` {
+		t.Errorf("expected new entry to revalidate, got %s %q", rec.Header().Get("X-Cache"), rec.Body.String())
+	}
+}
+
+func TestETagRevalidationDisabled(t *testing.T) {
+	var conditional atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") != "" {
+			conditional.Add(1)
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("body"))
+	}))
+	defer upstream.Close()
+
+	p, err := New(upstream.URL, 5*time.Second, time.Minute, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	for range 2 {
+		p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/asset", nil))
+	}
+	if conditional.Load() != 0 {
+		t.Errorf("expected no conditional requests, got %d", conditional.Load())
+	}
+}
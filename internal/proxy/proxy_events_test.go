@@ -0,0 +1,104 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEventsDeliveredToWebhook(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	defer upstream.Close()
+
+	received := make(chan CacheEvent, 10)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev CacheEvent
+		if err := json.NewDecoder(r.Body).Decode(&ev); err != nil {
+			t.Errorf("invalid event body: %v", err)
+		}
+		received <- ev
+	}))
+	defer webhook.Close()
+
+	logFile := filepath.Join(t.TempDir(), "events.log")
+	p, err := New(upstream.URL, 5*time.Second, time.Minute, nil, nil,
+		WithEvents(Events{WebhookURL: webhook.URL, LogFile: logFile}))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/page", nil))
+
+	select {
+	case ev := <-received:
+		if ev.Type != EventStore || ev.Key != "GET /page?" || ev.Status != http.StatusOK || ev.Bytes != 5 {
+			t.Errorf("unexpected event %+v", ev)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected a store event at the webhook")
+	}
+
+	p.Close()
+	data, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("read event log: %v", err)
+	}
+	if !strings.Contains(string(data), `"type":"store","key":"GET /page?"`) {
+		t.Errorf("expected store event in event log, got %q", data)
+	}
+}
+
+func TestEventsOnCapacityEviction(t *testing.T) {
+	upstream := okUpstream(t)
+
+	logFile := filepath.Join(t.TempDir(), "events.log")
+	p, err := New(upstream.URL, 5*time.Second, time.Minute, nil, nil,
+		WithMaxEntries(1), WithEvents(Events{LogFile: logFile}))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/a", nil))
+	p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/b", nil))
+
+	p.Close()
+	data, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("read event log: %v", err)
+	}
+	if !strings.Contains(string(data), `"type":"evict","key":"GET /a?"`) {
+		t.Errorf("expected evict event for the displaced entry, got %q", data)
+	}
+}
+
+func TestEventsFullQueueDrops(t *testing.T) {
+	// No worker runs, so the queue is never drained
+	s, err := newEventSink(Events{WebhookURL: "http://127.0.0.1:1", QueueSize: 2})
+	if err != nil {
+		t.Fatalf("failed to create event sink: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 5; i++ {
+			s.emit(CacheEvent{Type: EventStore, Key: "k"})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("emit blocked on a full queue")
+	}
+
+	if n := s.dropped.Load(); n != 3 {
+		t.Errorf("expected 3 dropped events, got %d", n)
+	}
+}
@@ -0,0 +1,86 @@
+package proxy
+
+import (
+	"Aegis/internal/utils"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestForwardedHeaders(t *testing.T) {
+	var got http.Header
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	trusted, _ := utils.ParseCIDRs([]string{"10.0.0.0/8"})
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, nil, WithTrustedProxies(trusted))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		header     http.Header
+		wantFor    string
+		wantHost   string
+		wantProto  string
+	}{
+		{"direct client", "203.0.113.9:5555", http.Header{}, "203.0.113.9", "shop.example", "http"},
+		{"appends to chain", "203.0.113.9:5555",
+			http.Header{"X-Forwarded-For": {"198.51.100.1, 198.51.100.2"}},
+			"198.51.100.1, 198.51.100.2, 203.0.113.9", "shop.example", "http"},
+		{"trusted proxy", "10.1.2.3:5555",
+			http.Header{"X-Forwarded-For": {"198.51.100.1"}, "X-Forwarded-Host": {"www.example"}, "X-Forwarded-Proto": {"https"}},
+			"198.51.100.1, 10.1.2.3", "www.example", "https"},
+		{"untrusted host and proto", "203.0.113.9:5555",
+			http.Header{"X-Forwarded-Host": {"evil.example"}, "X-Forwarded-Proto": {"https"}},
+			"203.0.113.9", "shop.example", "http"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://shop.example/page", nil)
+			req.RemoteAddr = tt.remoteAddr
+			req.Header = tt.header
+			p.ServeHTTP(httptest.NewRecorder(), req)
+
+			if v := got.Values("X-Forwarded-For"); len(v) != 1 || v[0] != tt.wantFor {
+				t.Errorf("expected X-Forwarded-For %q, got %q", tt.wantFor, v)
+			}
+			if v := got.Get("X-Forwarded-Host"); v != tt.wantHost {
+				t.Errorf("expected X-Forwarded-Host %q, got %q", tt.wantHost, v)
+			}
+			if v := got.Get("X-Forwarded-Proto"); v != tt.wantProto {
+				t.Errorf("expected X-Forwarded-Proto %q, got %q", tt.wantProto, v)
+			}
+		})
+	}
+}
+
+func TestForwardedHeadersDisabled(t *testing.T) {
+	var got http.Header
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, nil, WithForwardedHeaders(false))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	req := httptest.NewRequest("GET", "/page", nil)
+	req.Header.Set("X-Forwarded-For", "198.51.100.1")
+	p.ServeHTTP(httptest.NewRecorder(), req)
+
+	if v := got.Get("X-Forwarded-For"); v != "198.51.100.1" {
+		t.Errorf("expected X-Forwarded-For passed through unchanged, got %q", v)
+	}
+	if v := got.Get("X-Forwarded-Host"); v != "" {
+		t.Errorf("expected no X-Forwarded-Host, got %q", v)
+	}
+}
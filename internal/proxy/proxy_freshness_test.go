@@ -0,0 +1,80 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHeuristicTTLFromLastModified(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	p, _ := New("http://example.com", 5*time.Second, 0, nil, nil, WithHeuristicFreshness(0.1, 0))
+
+	h := http.Header{}
+	h.Set("Last-Modified", now.Add(-10*time.Hour).Format(http.TimeFormat))
+
+	if ttl := p.heuristicTTL(h, now); ttl != time.Hour {
+		t.Errorf("expected heuristic TTL 1h, got %s", ttl)
+	}
+
+	// Capped by max_ttl
+	p, _ = New("http://example.com", 5*time.Second, 0, nil, nil, WithHeuristicFreshness(0.1, 30*time.Minute))
+	if ttl := p.heuristicTTL(h, now); ttl != 30*time.Minute {
+		t.Errorf("expected capped TTL 30m, got %s", ttl)
+	}
+
+	// Missing or future Last-Modified yields no TTL
+	if ttl := p.heuristicTTL(http.Header{}, now); ttl != 0 {
+		t.Errorf("expected no TTL without Last-Modified, got %s", ttl)
+	}
+	h.Set("Last-Modified", now.Add(time.Hour).Format(http.TimeFormat))
+	if ttl := p.heuristicTTL(h, now); ttl != 0 {
+		t.Errorf("expected no TTL for future Last-Modified, got %s", ttl)
+	}
+}
+
+func TestHeuristicTTLAppliedOnStore(t *testing.T) {
+	cacheControl := ""
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Last-Modified", time.Now().Add(-10*time.Hour).UTC().Format(http.TimeFormat))
+		if cacheControl != "" {
+			w.Header().Set("Cache-Control", cacheControl)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	p, _ := New(upstream.URL, 5*time.Second, 0, nil, nil, WithHeuristicFreshness(0.1, 0))
+
+	req := httptest.NewRequest("GET", "/doc", nil)
+	p.ServeHTTP(httptest.NewRecorder(), req)
+
+	entry, ok := p.cache.Get(p.cacheKey(req))
+	if !ok {
+		t.Fatal("expected entry to be cached")
+	}
+	expected := time.Now().Add(time.Hour)
+	if entry.ExpireAt.Before(expected.Add(-5*time.Second)) || entry.ExpireAt.After(expected.Add(5*time.Second)) {
+		t.Errorf("expected expiry around now+1h, got %s", entry.ExpireAt)
+	}
+
+	// Explicit freshness disables the heuristic
+	cacheControl = "public, max-age=60"
+	req = httptest.NewRequest("GET", "/explicit", nil)
+	p.ServeHTTP(httptest.NewRecorder(), req)
+	entry, _ = p.cache.Get(p.cacheKey(req))
+	if !entry.ExpireAt.IsZero() {
+		t.Errorf("expected no heuristic expiry with explicit max-age, got %s", entry.ExpireAt)
+	}
+}
+
+func TestHeuristicTTLConfiguredTTLWins(t *testing.T) {
+	p, _ := New("http://example.com", 5*time.Second, time.Minute, nil, nil, WithHeuristicFreshness(0.1, 0))
+
+	h := http.Header{}
+	h.Set("Last-Modified", time.Now().Add(-10*time.Hour).UTC().Format(http.TimeFormat))
+	if ttl := p.entryTTL(h, p.ttl); ttl != time.Minute {
+		t.Errorf("expected configured TTL 1m, got %s", ttl)
+	}
+}
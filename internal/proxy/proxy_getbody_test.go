@@ -0,0 +1,103 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func getBodyUpstream(t *testing.T) (*httptest.Server, *[]string) {
+	t.Helper()
+	var received []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		received = append(received, string(b))
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("echo:" + string(b)))
+	}))
+	t.Cleanup(upstream.Close)
+	return upstream, &received
+}
+
+func TestGetBodyIgnore(t *testing.T) {
+	upstream, received := getBodyUpstream(t)
+	p, _ := New(upstream.URL, 5*time.Second, 0, nil, nil, WithGetBodyPolicy(GetBodyIgnore))
+
+	for _, body := range []string{"first", "second"} {
+		p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/search", strings.NewReader(body)))
+	}
+
+	if (*received)[0] != "first" {
+		t.Errorf("expected body to be forwarded, got %q", (*received)[0])
+	}
+	// Both requests share a single entry
+	if p.cache.Size() != 1 {
+		t.Errorf("expected 1 cache entry, got %d", p.cache.Size())
+	}
+}
+
+func TestGetBodyReject(t *testing.T) {
+	upstream, received := getBodyUpstream(t)
+	p, _ := New(upstream.URL, 5*time.Second, 0, nil, nil, WithGetBodyPolicy(GetBodyReject))
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/search", strings.NewReader("query")))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", rec.Code)
+	}
+	if len(*received) != 0 {
+		t.Errorf("expected no upstream request, got %d", len(*received))
+	}
+
+	// GET without a body is unaffected
+	rec = httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/search", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status 200 without body, got %d", rec.Code)
+	}
+}
+
+func TestGetBodyKey(t *testing.T) {
+	upstream, received := getBodyUpstream(t)
+	p, _ := New(upstream.URL, 5*time.Second, 0, nil, nil, WithGetBodyPolicy(GetBodyKey))
+
+	for _, body := range []string{"first", "second", "first"} {
+		p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/search", strings.NewReader(body)))
+	}
+
+	if p.cache.Size() != 2 {
+		t.Errorf("expected 2 cache entries for distinct bodies, got %d", p.cache.Size())
+	}
+	if len(*received) != 3 || (*received)[1] != "second" {
+		t.Errorf("expected bodies to be forwarded intact, got %q", *received)
+	}
+}
+
+func TestGetBodyKeyFields(t *testing.T) {
+	upstream, received := getBodyUpstream(t)
+	p, _ := New(upstream.URL, 5*time.Second, 0, nil, nil,
+		WithGetBodyPolicy(GetBodyKey), WithGetBodyKeyFields([]string{"query", "filters.category"}))
+
+	for _, body := range []string{
+		`{"query": "shoes", "filters": {"category": "men"}, "client_ts": 1700000000}`,
+		`{"client_ts": 1700000099, "filters": {"category": "men", "cursor": "abc"}, "query": "shoes"}`,
+		`{"query": "shoes", "filters": {"category": "women"}, "client_ts": 1700000000}`,
+		`{"query": "shoes"}`,
+		`not json`,
+	} {
+		p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/search", strings.NewReader(body)))
+	}
+
+	// Bodies differing only in ignored fields share an entry
+	if p.cache.Size() != 4 {
+		t.Errorf("expected 4 cache entries, got %d", p.cache.Size())
+	}
+	// The whole body is still forwarded
+	if !strings.Contains((*received)[1], "client_ts") {
+		t.Errorf("expected the full body upstream, got %q", (*received)[1])
+	}
+}
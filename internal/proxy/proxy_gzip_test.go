@@ -0,0 +1,151 @@
+package proxy
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResponseCompression(t *testing.T) {
+	large := strings.Repeat(`{"item":"value"},`, 200)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/large":
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, large)
+		case "/small":
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"ok":true}`)
+		case "/image":
+			w.Header().Set("Content-Type", "image/png")
+			io.WriteString(w, large)
+		case "/no-transform":
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Cache-Control", "no-transform")
+			io.WriteString(w, large)
+		case "/encoded":
+			w.Header().Set("Content-Type", "text/plain")
+			w.Header().Set("Content-Encoding", "br")
+			io.WriteString(w, large)
+		}
+	}))
+	defer upstream.Close()
+
+	p, err := New(upstream.URL, 5*time.Second, time.Hour, nil, nil, WithResponseCompression(1024))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		path           string
+		acceptEncoding string
+		wantEncoding   string
+	}{
+		{"large json", "/large", "gzip, deflate", "gzip"},
+		{"below threshold", "/small", "gzip", ""},
+		{"not compressible", "/image", "gzip", ""},
+		{"already encoded", "/encoded", "gzip", "br"},
+		{"no-transform", "/no-transform", "gzip", ""},
+		{"client without gzip", "/large", "", ""},
+		{"gzip refused", "/large", "gzip;q=0, identity", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			rec := httptest.NewRecorder()
+			p.ServeHTTP(rec, req)
+
+			if got := rec.Header().Get("Content-Encoding"); got != tt.wantEncoding {
+				t.Fatalf("expected Content-Encoding %q, got %q", tt.wantEncoding, got)
+			}
+			if tt.wantEncoding != "gzip" {
+				return
+			}
+			if cl := rec.Header().Get("Content-Length"); cl != "" {
+				t.Errorf("expected no Content-Length on a compressed response, got %s", cl)
+			}
+			zr, err := gzip.NewReader(rec.Body)
+			if err != nil {
+				t.Fatalf("expected a valid gzip body: %v", err)
+			}
+			body, err := io.ReadAll(zr)
+			if err != nil {
+				t.Fatalf("expected a valid gzip body: %v", err)
+			}
+			if string(body) != large {
+				t.Errorf("decompressed body differs from upstream's")
+			}
+		})
+	}
+
+	// The cache keeps the uncompressed body for clients without gzip
+	entry, ok := p.cache.Get("GET /large?")
+	if !ok || string(entry.Body) != large {
+		t.Errorf("expected the uncompressed body to be cached")
+	}
+}
+
+func TestResponseCompressionDisabled(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, strings.Repeat("text ", 1000))
+	}))
+	defer upstream.Close()
+
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("Content-Encoding"); got != "" {
+		t.Errorf("expected no compression by default, got Content-Encoding %q", got)
+	}
+}
+
+func TestResponseCompressionNotCompletedOnAbort(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, "partial ")
+		w.(http.Flusher).Flush()
+		time.Sleep(300 * time.Millisecond)
+		io.WriteString(w, "rest")
+	}))
+	defer upstream.Close()
+
+	p, err := New(upstream.URL, 50*time.Millisecond, 0, nil, nil, WithResponseCompression(1024))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	req := httptest.NewRequest("POST", "/stream", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	func() {
+		defer func() {
+			if v := recover(); v != http.ErrAbortHandler {
+				t.Errorf("expected the pass-through to abort, got %v", v)
+			}
+		}()
+		p.ServeHTTP(rec, req)
+	}()
+
+	// A cut-off stream must not end in a gzip trailer that makes it look whole
+	zr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatalf("expected the gzip stream to have started: %v", err)
+	}
+	if _, err := io.ReadAll(zr); err == nil {
+		t.Error("expected the aborted gzip stream to be incomplete")
+	}
+}
@@ -0,0 +1,51 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHandoffExportsHottestOnClose(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("body of " + r.URL.Path))
+	}))
+	defer upstream.Close()
+	path := filepath.Join(t.TempDir(), "handoff")
+
+	old, err := New(upstream.URL, 5*time.Second, time.Hour, nil, nil, WithHandoff(path, 2))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	for p, requests := range map[string]int{"/hot": 5, "/warm": 3, "/cold": 1} {
+		for i := 0; i < requests; i++ {
+			old.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", p, nil))
+		}
+	}
+	old.Close()
+
+	// The new instance starts with only the two most requested entries
+	upstream.Close()
+	next, err := New(upstream.URL, 5*time.Second, time.Hour, nil, nil, WithHandoff(path, 2))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	defer next.Close()
+	if next.cache.Size() != 2 {
+		t.Fatalf("expected 2 imported entries, got %d", next.cache.Size())
+	}
+	for _, p := range []string{"/hot", "/warm"} {
+		rec := httptest.NewRecorder()
+		next.ServeHTTP(rec, httptest.NewRequest("GET", p, nil))
+		if got := rec.Header().Get("X-Cache"); got != CacheHitBackup || rec.Body.String() != "body of "+p {
+			t.Errorf("%s: expected imported backup, got %s %q", p, got, rec.Body.String())
+		}
+	}
+	rec := httptest.NewRecorder()
+	next.ServeHTTP(rec, httptest.NewRequest("GET", "/cold", nil))
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("expected /cold to be left out of the handoff, got %d", rec.Code)
+	}
+}
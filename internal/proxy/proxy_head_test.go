@@ -0,0 +1,73 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func headUpstream(t *testing.T) (*httptest.Server, *bool) {
+	t.Helper()
+	shouldFail := false
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if shouldFail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusOK)
+		if r.Method != http.MethodHead {
+			w.Write([]byte("full body"))
+		}
+	}))
+	t.Cleanup(upstream.Close)
+	return upstream, &shouldFail
+}
+
+func TestHeadDoesNotPoisonGetEntry(t *testing.T) {
+	upstream, shouldFail := headUpstream(t)
+	p, _ := New(upstream.URL, 5*time.Second, 0, nil, nil, WithHeadPolicy(HeadCacheSeparate))
+
+	p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/doc", nil))
+	p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("HEAD", "/doc", nil))
+
+	if p.cache.Size() != 2 {
+		t.Errorf("expected separate GET and HEAD entries, got %d", p.cache.Size())
+	}
+
+	// GET failover still returns the full body
+	*shouldFail = true
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/doc", nil))
+	if rec.Header().Get("X-Cache") != "HIT-BACKUP" || rec.Body.String() != "full body" {
+		t.Errorf("expected GET backup with full body, got %q %q", rec.Header().Get("X-Cache"), rec.Body.String())
+	}
+
+	// HEAD failover uses its own metadata entry
+	rec = httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("HEAD", "/doc", nil))
+	if rec.Header().Get("X-Cache") != "HIT-BACKUP" {
+		t.Errorf("expected HEAD backup, got %q", rec.Header().Get("X-Cache"))
+	}
+}
+
+func TestHeadCacheNone(t *testing.T) {
+	upstream, _ := headUpstream(t)
+	p, _ := New(upstream.URL, 5*time.Second, 0, nil, nil, WithHeadPolicy(HeadCacheNone))
+
+	p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/doc", nil))
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("HEAD", "/doc", nil))
+
+	if rec.Header().Get("X-Cache") != "PASS" {
+		t.Errorf("expected X-Cache: PASS for HEAD, got %q", rec.Header().Get("X-Cache"))
+	}
+	if p.cache.Size() != 1 {
+		t.Errorf("expected only the GET entry, got %d", p.cache.Size())
+	}
+	entry, _ := p.cache.Get(p.cacheKey(httptest.NewRequest("GET", "/doc", nil)))
+	if string(entry.Body) != "full body" {
+		t.Errorf("expected GET entry body intact, got %q", entry.Body)
+	}
+}
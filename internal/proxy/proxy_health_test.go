@@ -0,0 +1,173 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHealthCheckExpectations(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "OPTIONS" || r.URL.Path != "/health" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		w.Write([]byte("status: ok"))
+	}))
+	defer upstream.Close()
+
+	tests := []struct {
+		name    string
+		check   HealthCheck
+		healthy bool
+	}{
+		{"expected status", HealthCheck{ExpectedStatus: []int{200, 204}}, true},
+		{"wrong status", HealthCheck{ExpectedStatus: []int{200}}, false},
+		{"any status below 500", HealthCheck{}, true},
+		{"wrong method", HealthCheck{Method: "GET", ExpectedStatus: []int{204}}, false},
+	}
+
+	for _, tt := range tests {
+		tt.check.Interval = time.Hour
+		tt.check.Path = "/health"
+		if tt.check.Method == "" {
+			tt.check.Method = "OPTIONS"
+		}
+		p, err := New(upstream.URL, 5*time.Second, 0, nil, nil, WithHealthCheck(tt.check))
+		if err != nil {
+			t.Fatalf("failed to create proxy: %v", err)
+		}
+		if got := p.probeUpstream(context.Background(), p.upstream); got != tt.healthy {
+			t.Errorf("%s: expected healthy=%v, got %v", tt.name, tt.healthy, got)
+		}
+		p.Close()
+	}
+}
+
+func TestHealthCheckBodyContains(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"degraded"}`))
+	}))
+	defer upstream.Close()
+
+	check := HealthCheck{Interval: time.Hour, ExpectedBodyContains: `"status":"ok"`}
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, nil, WithHealthCheck(check))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	defer p.Close()
+
+	if p.probeUpstream(context.Background(), p.upstream) {
+		t.Error("expected unhealthy when the body lacks the expected text")
+	}
+
+	p.health.ExpectedBodyContains = `"status":"degraded"`
+	if !p.probeUpstream(context.Background(), p.upstream) {
+		t.Error("expected healthy when the body contains the expected text")
+	}
+}
+
+func TestHealthCheckReportedInStats(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer upstream.Close()
+
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, nil,
+		WithHealthCheck(HealthCheck{Interval: 10 * time.Millisecond}))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	defer p.Close()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for p.upstreamHealthy.Load() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	rec := httptest.NewRecorder()
+	p.StatsHandler(rec, httptest.NewRequest("GET", "/stats", nil))
+	var stats statsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&stats); err != nil {
+		t.Fatalf("failed to decode stats: %v", err)
+	}
+	if stats.UpstreamHealthy == nil || *stats.UpstreamHealthy {
+		t.Errorf("expected upstream_healthy false, got %v", stats.UpstreamHealthy)
+	}
+}
+
+func TestHealthCheckTakesUpstreamOutOfRotation(t *testing.T) {
+	var down atomic.Bool
+	var hits [2]atomic.Int64
+	urls := make([]string, 2)
+	for i := range urls {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/health" {
+				if i == 1 && down.Load() {
+					w.WriteHeader(http.StatusServiceUnavailable)
+				}
+				return
+			}
+			hits[i].Add(1)
+			w.Write([]byte("ok"))
+		}))
+		defer srv.Close()
+		urls[i] = srv.URL
+	}
+	down.Store(true)
+
+	p, err := New(urls[0], 5*time.Second, 0, nil, nil, WithExtraUpstreams(urls[1:]),
+		WithHealthCheck(HealthCheck{Interval: 10 * time.Millisecond, Path: "/health", FailureThreshold: 2}))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	defer p.Close()
+
+	waitFor := func(want bool) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for p.upstreamDown[1].Load() != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected upstream 1 down=%v", want)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	serve := func(n int) {
+		for range n {
+			p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/data", nil))
+		}
+	}
+
+	waitFor(true)
+	serve(10)
+	if hits[0].Load() != 10 || hits[1].Load() != 0 {
+		t.Errorf("expected all requests on the healthy upstream, got %d and %d", hits[0].Load(), hits[1].Load())
+	}
+
+	rec := httptest.NewRecorder()
+	p.StatsHandler(rec, httptest.NewRequest("GET", "/stats", nil))
+	var stats statsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&stats); err != nil {
+		t.Fatalf("failed to decode stats: %v", err)
+	}
+	if len(stats.Upstreams) != 2 || !stats.Upstreams[0].Healthy || stats.Upstreams[1].Healthy {
+		t.Errorf("expected upstream 1 reported unhealthy, got %+v", stats.Upstreams)
+	}
+	if stats.UpstreamHealthy == nil || !*stats.UpstreamHealthy {
+		t.Error("expected upstream_healthy while one upstream is up")
+	}
+
+	// Back in rotation after passing again
+	down.Store(false)
+	waitFor(false)
+	serve(10)
+	if hits[1].Load() != 5 {
+		t.Errorf("expected the recovered upstream to get half of the requests, got %d", hits[1].Load())
+	}
+}
@@ -0,0 +1,52 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// hostlessRequest builds an HTTP/1.0 request without a Host header
+func hostlessRequest() *http.Request {
+	req := httptest.NewRequest("GET", "/page", nil)
+	req.Proto, req.ProtoMajor, req.ProtoMinor = "HTTP/1.0", 1, 0
+	req.Host = ""
+	return req
+}
+
+func TestMissingHostRejectedByDefault(t *testing.T) {
+	upstream := okUpstream(t)
+
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, hostlessRequest())
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", rec.Code)
+	}
+}
+
+func TestMissingHostUsesDefaultHost(t *testing.T) {
+	upstream := okUpstream(t)
+
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, nil, WithDefaultHost("www.example.com"))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	req := hostlessRequest()
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", rec.Code)
+	}
+	if req.Host != "www.example.com" {
+		t.Errorf("expected Host to be substituted, got %q", req.Host)
+	}
+}
@@ -0,0 +1,137 @@
+package proxy
+
+import (
+	"Aegis/internal/cache"
+	"Aegis/internal/utils"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMaxEntriesPerHost(t *testing.T) {
+	upstream := okUpstream(t)
+
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, nil, WithHostInKey(true), WithMaxEntriesPerHost(2))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	get := func(host, path string) {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Host = host
+		p.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	// A busy host fills its own share only
+	for _, path := range []string{"/1", "/2", "/3", "/4"} {
+		get("busy.example.com", path)
+	}
+	get("quiet.example.com", "/1")
+	get("quiet.example.com", "/2")
+
+	if n := p.hostEntries.count("busy.example.com"); n != 2 {
+		t.Errorf("expected busy host capped at 2 entries, got %d", n)
+	}
+	if n := p.hostEntries.count("quiet.example.com"); n != 2 {
+		t.Errorf("expected quiet host to keep 2 entries, got %d", n)
+	}
+	if p.cache.Size() != 4 {
+		t.Errorf("expected 4 cached entries in total, got %d", p.cache.Size())
+	}
+
+	// Oldest entries of the busy host were evicted
+	for path, cached := range map[string]bool{"/1": false, "/2": false, "/3": true, "/4": true} {
+		if _, ok := p.cache.Get("GET " + path + "?|host:busy.example.com"); ok != cached {
+			t.Errorf("busy %s: expected cached=%v", path, cached)
+		}
+	}
+}
+
+func TestHostEntriesLRU(t *testing.T) {
+	h := newHostEntries(2, cache.EvictLRU)
+	h.touch("a", "k1")
+	h.touch("a", "k2")
+	h.touch("a", "k1") // k1 used again, k2 is now the oldest
+
+	evicted := h.touch("a", "k3")
+	if len(evicted) != 1 || evicted[0] != "k2" {
+		t.Errorf("expected k2 to be evicted, got %v", evicted)
+	}
+	if evicted := h.touch("b", "k4"); len(evicted) != 0 {
+		t.Errorf("expected other host unaffected, got %v", evicted)
+	}
+}
+
+func TestMaxEntriesPerHostSLRU(t *testing.T) {
+	upstream := okUpstream(t)
+
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, nil,
+		WithHostInKey(true), WithMaxEntriesPerHost(5), WithEvictionPolicy(cache.EvictSLRU))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	get := func(path string) {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Host = "example.com"
+		p.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	// A repeatedly requested page survives a crawl of one-off pages
+	get("/hot")
+	get("/hot")
+	for i := 0; i < 50; i++ {
+		get(fmt.Sprintf("/crawl/%d", i))
+	}
+
+	if _, ok := p.cache.Get("GET /hot?|host:example.com"); !ok {
+		t.Error("expected the hot entry to survive the scan")
+	}
+	if n := p.hostEntries.count("example.com"); n != 5 {
+		t.Errorf("expected host capped at 5 entries, got %d", n)
+	}
+}
+
+func TestHostEntriesForgetRemovedKeys(t *testing.T) {
+	upstream := okUpstream(t)
+	clock := utils.NewFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	p, err := New(upstream.URL, 5*time.Second, time.Minute, nil, nil, WithClock(clock),
+		WithHostInKey(true), WithMaxEntriesPerHost(2), WithMaxEntries(3))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	get := func(host string) {
+		req := httptest.NewRequest("GET", "/page", nil)
+		req.Host = host
+		p.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	// Hosts whose entries the global bound evicted are dropped
+	for i := range 10 {
+		get(fmt.Sprintf("host%d.example.com", i))
+	}
+	if n := p.hostEntries.hostCount(); n != 3 {
+		t.Errorf("expected 3 tracked hosts after global evictions, got %d", n)
+	}
+
+	// Purged entries are no longer counted
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/purge?url=/page", nil)
+	req.Host = "host9.example.com"
+	p.PurgeHandler(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected purge to succeed, got %d", rec.Code)
+	}
+	if n := p.hostEntries.count("host9.example.com"); n != 0 {
+		t.Errorf("expected purged entry not to be counted, got %d", n)
+	}
+
+	// Swept entries are no longer counted
+	clock.Advance(2 * time.Minute)
+	p.cache.Sweep()
+	if n := p.hostEntries.hostCount(); n != 0 {
+		t.Errorf("expected no tracked hosts after expiry, got %d", n)
+	}
+}
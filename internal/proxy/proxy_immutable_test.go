@@ -0,0 +1,161 @@
+package proxy
+
+import (
+	"Aegis/internal/cache"
+	"Aegis/internal/utils"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestImmutableServedWithoutRevalidation(t *testing.T) {
+	var hits atomic.Int64
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Cache-Control", "public, max-age=1, immutable")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("asset-v1"))
+	}))
+	defer upstream.Close()
+
+	p, err := New(upstream.URL, 5*time.Second, time.Hour, nil, nil, WithImmutable(true))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/app.3f2a.js", nil))
+	if got := rec.Header().Get("X-Cache"); got != CacheMiss {
+		t.Fatalf("expected first request to be MISS, got %q", got)
+	}
+
+	// Age the entry well past max-age=1 while it is still within the TTL
+	entry, _ := p.cache.Get("GET /app.3f2a.js?")
+	entry.SavedAt = entry.SavedAt.Add(-time.Minute)
+	p.cache.Set("GET /app.3f2a.js?", entry)
+
+	rec = httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/app.3f2a.js", nil))
+	if got := rec.Header().Get("X-Cache"); got != CacheHit {
+		t.Errorf("expected HIT, got %q", got)
+	}
+	if rec.Body.String() != "asset-v1" {
+		t.Errorf("expected cached body, got %q", rec.Body.String())
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("expected upstream to be contacted once, got %d", n)
+	}
+}
+
+func TestImmutableOutlivesOriginMaxAge(t *testing.T) {
+	var hits atomic.Int64
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Cache-Control", "public, max-age=10, immutable")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("asset-v1"))
+	}))
+	defer upstream.Close()
+
+	clock := utils.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	p, err := New(upstream.URL, 5*time.Second, time.Hour, nil, nil,
+		WithImmutable(true), WithRespectOriginTTL(true), WithClock(clock))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/app.3f2a.js", nil))
+	clock.Advance(time.Minute)
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/app.3f2a.js", nil))
+	if got := rec.Header().Get("X-Cache"); got != CacheHit {
+		t.Errorf("expected HIT past max-age, got %q", got)
+	}
+	if rec.Body.String() != "asset-v1" {
+		t.Errorf("expected cached body, got %q", rec.Body.String())
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("expected upstream to be contacted once, got %d", n)
+	}
+}
+
+func TestImmutableIgnoredWhenDisabledOrMutable(t *testing.T) {
+	var hits atomic.Int64
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	immutable := http.Header{"Cache-Control": {"max-age=31536000, immutable"}}
+	tests := []struct {
+		name    string
+		enabled bool
+		header  http.Header
+	}{
+		{"disabled", false, immutable},
+		{"mutable entry", true, http.Header{"Cache-Control": {"max-age=60"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hits.Store(0)
+			p, err := New(upstream.URL, 5*time.Second, 0, nil, nil, WithImmutable(tt.enabled))
+			if err != nil {
+				t.Fatalf("failed to create proxy: %v", err)
+			}
+			p.cache.Set("GET /asset?", cache.Response{Status: http.StatusOK, Header: tt.header, Body: []byte("cached")})
+
+			rec := httptest.NewRecorder()
+			p.ServeHTTP(rec, httptest.NewRequest("GET", "/asset", nil))
+			if got := rec.Header().Get("X-Cache"); got != CacheMiss {
+				t.Errorf("expected MISS, got %q", got)
+			}
+			if hits.Load() != 1 {
+				t.Errorf("expected request to reach upstream")
+			}
+		})
+	}
+}
+
+func TestPragmaNoCache(t *testing.T) {
+	var hits atomic.Int64
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Cache-Control", "max-age=31536000, immutable")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("fresh"))
+	}))
+	defer upstream.Close()
+
+	for _, honor := range []bool{true, false} {
+		hits.Store(0)
+		p, err := New(upstream.URL, 5*time.Second, 0, nil, nil, WithImmutable(true), WithHonorPragma(honor))
+		if err != nil {
+			t.Fatalf("failed to create proxy: %v", err)
+		}
+		p.cache.Set("GET /asset?", cache.Response{
+			Status: http.StatusOK,
+			Header: http.Header{"Cache-Control": {"max-age=31536000, immutable"}},
+			Body:   []byte("stored"),
+		})
+
+		req := httptest.NewRequest("GET", "/asset", nil)
+		req.Header.Set("Pragma", "no-cache")
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, req)
+
+		if honor {
+			if rec.Header().Get("X-Cache") != CacheMiss || hits.Load() != 1 {
+				t.Errorf("honor_pragma: expected fresh fetch, got X-Cache %q and %d upstream hits", rec.Header().Get("X-Cache"), hits.Load())
+			}
+			if entry, _ := p.cache.Get("GET /asset?"); string(entry.Body) != "fresh" {
+				t.Errorf("honor_pragma: expected stored entry to be updated, got %q", entry.Body)
+			}
+		} else if rec.Header().Get("X-Cache") != CacheHit || hits.Load() != 0 {
+			t.Errorf("pragma ignored: expected cache HIT, got X-Cache %q and %d upstream hits", rec.Header().Get("X-Cache"), hits.Load())
+		}
+	}
+}
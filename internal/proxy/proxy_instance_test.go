@@ -0,0 +1,98 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestInstanceIDHeader(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, nil, WithInstanceID("X-Aegis-Instance", ""))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	var first string
+	for _, path := range []string{"/a", "/b", "/fail"} {
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		id := rec.Header().Get("X-Aegis-Instance")
+		if id == "" {
+			t.Fatalf("%s: expected instance header", path)
+		}
+		if first == "" {
+			first = id
+		} else if id != first {
+			t.Errorf("%s: expected stable instance ID %q, got %q", path, first, id)
+		}
+	}
+
+	other, _ := New(upstream.URL, 5*time.Second, 0, nil, nil, WithInstanceID("X-Aegis-Instance", ""))
+	rec := httptest.NewRecorder()
+	other.ServeHTTP(rec, httptest.NewRequest("GET", "/a", nil))
+	if rec.Header().Get("X-Aegis-Instance") == first {
+		t.Error("expected another instance to generate a different ID")
+	}
+}
+
+func TestInstanceIDConfigured(t *testing.T) {
+	upstream := okUpstream(t)
+	p, err := New(upstream.URL, time.Second, 0, nil, nil, WithInstanceID("X-Node", "edge-1"))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("POST", "/x", nil))
+	if got := rec.Header().Get("X-Node"); got != "edge-1" {
+		t.Errorf("expected configured instance ID, got %q", got)
+	}
+}
+
+func TestServedByHeader(t *testing.T) {
+	fail := false
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer upstream.Close()
+
+	tests := []struct {
+		name     string
+		opts     []Option
+		expected string // empty = header absent
+	}{
+		{"default", nil, "Aegis"},
+		{"custom", []Option{WithServedBy("edge-1")}, "edge-1"},
+		{"disabled", []Option{WithServedBy("")}, ""},
+	}
+
+	for _, tt := range tests {
+		p, err := New(upstream.URL, 5*time.Second, 0, nil, nil, tt.opts...)
+		if err != nil {
+			t.Fatalf("failed to create proxy: %v", err)
+		}
+
+		// Success, then backup served from cache
+		for _, failing := range []bool{false, true} {
+			fail = failing
+			rec := httptest.NewRecorder()
+			p.ServeHTTP(rec, httptest.NewRequest("GET", "/page", nil))
+			if got := rec.Header().Get("X-Served-By"); got != tt.expected {
+				t.Errorf("%s (upstream failing=%v): expected X-Served-By %q, got %q", tt.name, failing, tt.expected, got)
+			}
+		}
+	}
+}
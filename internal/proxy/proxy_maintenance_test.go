@@ -0,0 +1,86 @@
+package proxy
+
+import (
+	"Aegis/internal/cache"
+	"Aegis/internal/utils"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMaintenanceWindowServesFromCache(t *testing.T) {
+	var calls atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Write([]byte("fresh"))
+	}))
+	defer upstream.Close()
+
+	// Sunday 02:00-03:00 UTC
+	clock := utils.NewFakeClock(time.Date(2024, 1, 7, 1, 30, 0, 0, time.UTC))
+	windows := []MaintenanceWindow{{Start: 2 * time.Hour, End: 3 * time.Hour, Days: []time.Weekday{time.Sunday}}}
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, nil, WithClock(clock), WithMaintenanceWindows(windows, nil))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	p.cache.Set("GET /page?", cache.Response{Status: http.StatusOK, Body: []byte("cached")})
+
+	// Outside the window upstream is used
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/page", nil))
+	if rec.Header().Get("X-Cache") != "MISS" || calls.Load() != 1 {
+		t.Fatalf("expected MISS from upstream outside the window, got %s (%d calls)", rec.Header().Get("X-Cache"), calls.Load())
+	}
+
+	// Inside the window upstream is never contacted
+	clock.Advance(time.Hour)
+	rec = httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/page", nil))
+	if rec.Header().Get("X-Cache") != "HIT-BACKUP" || rec.Body.String() != "fresh" {
+		t.Errorf("expected HIT-BACKUP inside the window, got %s %q", rec.Header().Get("X-Cache"), rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("POST", "/submit", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("expected 503 with Retry-After for POST, got %d %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected no upstream calls inside the window, got %d", calls.Load()-1)
+	}
+
+	// Back to normal after the window
+	clock.Advance(time.Hour)
+	rec = httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/page", nil))
+	if rec.Header().Get("X-Cache") != "MISS" || calls.Load() != 2 {
+		t.Errorf("expected MISS after the window, got %s (%d calls)", rec.Header().Get("X-Cache"), calls.Load())
+	}
+}
+
+func TestMaintenanceWindowPastMidnight(t *testing.T) {
+	// Fridays 23:00 until Saturday 01:00
+	m := &maintenance{
+		windows: []MaintenanceWindow{{Start: 23 * time.Hour, End: time.Hour, Days: []time.Weekday{time.Friday}}},
+		loc:     time.UTC,
+	}
+	tests := []struct {
+		at        time.Time
+		active    bool
+		remaining time.Duration
+	}{
+		{time.Date(2024, 1, 5, 22, 59, 0, 0, time.UTC), false, 0},               // Friday
+		{time.Date(2024, 1, 5, 23, 30, 0, 0, time.UTC), true, 90 * time.Minute}, // Friday
+		{time.Date(2024, 1, 6, 0, 30, 0, 0, time.UTC), true, 30 * time.Minute},  // Saturday
+		{time.Date(2024, 1, 6, 23, 30, 0, 0, time.UTC), false, 0},               // Saturday evening
+		{time.Date(2024, 1, 7, 0, 30, 0, 0, time.UTC), false, 0},                // Sunday morning
+	}
+	for _, tt := range tests {
+		active, remaining := m.active(tt.at)
+		if active != tt.active || remaining != tt.remaining {
+			t.Errorf("%s: expected (%v, %v), got (%v, %v)", tt.at, tt.active, tt.remaining, active, remaining)
+		}
+	}
+}
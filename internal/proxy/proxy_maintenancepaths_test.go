@@ -0,0 +1,73 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMaintenancePaths(t *testing.T) {
+	var upstreamHits atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamHits.Add(1)
+		w.Write([]byte("from upstream"))
+	}))
+	defer upstream.Close()
+
+	p, err := New(upstream.URL, 5*time.Second, time.Minute, nil, nil, WithMaintenancePaths([]MaintenancePath{
+		{Prefix: "/api/checkout", Body: `{"error":"down"}`, ContentType: "application/json", Enabled: true},
+		{Prefix: "/api/checkout/status", Status: http.StatusOK, Body: "ok", Enabled: false},
+		{Prefix: "/api/search", Status: http.StatusGone, Enabled: false},
+	}))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		return rec
+	}
+
+	rec := get("/api/checkout/pay")
+	if rec.Code != http.StatusServiceUnavailable || rec.Body.String() != `{"error":"down"}` {
+		t.Errorf("expected static 503, got %d %q", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected configured content type, got %q", ct)
+	}
+	if upstreamHits.Load() != 0 || p.cache.Size() != 0 {
+		t.Errorf("expected neither upstream nor cache to be used, got %d hits and %d entries", upstreamHits.Load(), p.cache.Size())
+	}
+
+	// A longer disabled prefix stays online, as do other paths
+	for _, path := range []string{"/api/checkout/status", "/api/products", "/api/search"} {
+		if rec := get(path); rec.Code != http.StatusOK || rec.Body.String() != "from upstream" {
+			t.Errorf("%s: expected proxied response, got %d %q", path, rec.Code, rec.Body.String())
+		}
+	}
+
+	// Toggle at runtime
+	toggle := httptest.NewRecorder()
+	p.MaintenanceHandler(toggle, httptest.NewRequest("POST", "/maintenance?prefix=/api/search&enabled=true", nil))
+	var states []maintenancePathState
+	if err := json.Unmarshal(toggle.Body.Bytes(), &states); err != nil || toggle.Code != http.StatusOK {
+		t.Fatalf("unexpected toggle response %d %q", toggle.Code, toggle.Body.String())
+	}
+	if rec := get("/api/search?q=x"); rec.Code != http.StatusGone {
+		t.Errorf("expected /api/search to be offline after toggle, got %d", rec.Code)
+	}
+	toggle = httptest.NewRecorder()
+	p.MaintenanceHandler(toggle, httptest.NewRequest("POST", "/maintenance?prefix=/api/checkout&enabled=false", nil))
+	if rec := get("/api/checkout/pay"); rec.Code != http.StatusOK {
+		t.Errorf("expected /api/checkout back online, got %d", rec.Code)
+	}
+
+	toggle = httptest.NewRecorder()
+	p.MaintenanceHandler(toggle, httptest.NewRequest("POST", "/maintenance?prefix=/nope&enabled=true", nil))
+	if toggle.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown prefix, got %d", toggle.Code)
+	}
+}
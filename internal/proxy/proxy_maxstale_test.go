@@ -0,0 +1,47 @@
+package proxy
+
+import (
+	"Aegis/internal/utils"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMaxStaleRefusesOldBackup(t *testing.T) {
+	var down atomic.Bool
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("fresh"))
+	}))
+	defer upstream.Close()
+
+	clock := utils.NewFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, nil, WithClock(clock), WithMaxStale(time.Hour))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/report", nil))
+	down.Store(true)
+
+	clock.Advance(30 * time.Minute)
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/report", nil))
+	if got := rec.Header().Get("X-Cache"); got != CacheHitBackup {
+		t.Fatalf("expected a recent backup to be served, got X-Cache %q (status %d)", got, rec.Code)
+	}
+	if got := rec.Header().Get("X-Cache-Age"); got != "1800" {
+		t.Errorf("expected X-Cache-Age 1800, got %q", got)
+	}
+
+	clock.Advance(time.Hour)
+	rec = httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/report", nil))
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("expected 502 for a backup older than max_stale, got %d (X-Cache %q)", rec.Code, rec.Header().Get("X-Cache"))
+	}
+}
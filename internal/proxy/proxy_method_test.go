@@ -0,0 +1,78 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLowercaseGetIsCacheable(t *testing.T) {
+	var gotMethod atomic.Value
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod.Store(r.Method)
+		w.Write([]byte("ok"))
+	}))
+	defer upstream.Close()
+
+	p, err := New(upstream.URL, 5*time.Second, time.Minute, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("get", "/page", nil))
+
+	if got := rec.Header().Get("X-Cache"); got != CacheMiss {
+		t.Errorf("expected X-Cache MISS, got %s", got)
+	}
+	if _, ok := p.cache.Get("GET /page?"); !ok {
+		t.Error("expected entry cached under the GET key")
+	}
+	if got := gotMethod.Load(); got != http.MethodGet {
+		t.Errorf("expected upstream to receive GET, got %v", got)
+	}
+}
+
+func TestNonStandardMethods(t *testing.T) {
+	var gotMethod atomic.Value
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod.Store(r.Method)
+		w.WriteHeader(http.StatusMultiStatus)
+	}))
+	defer upstream.Close()
+
+	tests := []struct {
+		policy   string
+		method   string
+		status   int
+		upstream string
+	}{
+		{"", "PROPFIND", http.StatusMultiStatus, "PROPFIND"},
+		{MethodsForward, "propfind", http.StatusMultiStatus, "propfind"},
+		{MethodsNormalize, "propfind", http.StatusMultiStatus, "PROPFIND"},
+		{MethodsReject, "PROPFIND", http.StatusNotImplemented, ""},
+		{MethodsReject, "delete", http.StatusMultiStatus, "DELETE"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy+" "+tt.method, func(t *testing.T) {
+			gotMethod.Store("")
+			p, err := New(upstream.URL, 5*time.Second, time.Minute, nil, nil, WithNonStandardMethods(tt.policy))
+			if err != nil {
+				t.Fatalf("failed to create proxy: %v", err)
+			}
+			rec := httptest.NewRecorder()
+			p.ServeHTTP(rec, httptest.NewRequest(tt.method, "/dav/folder", nil))
+
+			if rec.Code != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, rec.Code)
+			}
+			if got := gotMethod.Load(); got != tt.upstream {
+				t.Errorf("expected upstream method %q, got %q", tt.upstream, got)
+			}
+			if p.cache.Size() != 0 {
+				t.Errorf("expected nothing cached, got %d entries", p.cache.Size())
+			}
+		})
+	}
+}
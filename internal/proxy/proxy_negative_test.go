@@ -0,0 +1,135 @@
+package proxy
+
+import (
+	"Aegis/internal/utils"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNegativeCaching(t *testing.T) {
+	var calls atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		switch r.URL.Path {
+		case "/missing":
+			http.NotFound(w, r)
+		case "/gone":
+			w.WriteHeader(http.StatusGone)
+		case "/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.Write([]byte("ok"))
+		}
+	}))
+	defer upstream.Close()
+
+	clock := utils.NewFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	p, err := New(upstream.URL, 5*time.Second, time.Hour, nil, nil,
+		WithClock(clock), WithNegativeCaching(30*time.Second, nil))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		return rec
+	}
+
+	if rec := get("/missing"); rec.Code != http.StatusNotFound || rec.Header().Get("X-Cache") != CacheMiss {
+		t.Fatalf("expected 404 MISS, got %d %s", rec.Code, rec.Header().Get("X-Cache"))
+	}
+	if ttl := storedTTL(t, p, "GET /missing?"); ttl != 30*time.Second {
+		t.Errorf("expected negative TTL 30s, got %s", ttl)
+	}
+	calls.Store(0)
+	rec := get("/missing")
+	if rec.Code != http.StatusNotFound || rec.Header().Get("X-Cache") != CacheHitNegative {
+		t.Errorf("expected 404 HIT-NEGATIVE, got %d %s", rec.Code, rec.Header().Get("X-Cache"))
+	}
+	if calls.Load() != 0 {
+		t.Errorf("expected negative hit not to reach upstream, got %d calls", calls.Load())
+	}
+
+	// After the negative TTL upstream is asked again
+	clock.Advance(31 * time.Second)
+	if rec := get("/missing"); rec.Header().Get("X-Cache") != CacheMiss || calls.Load() != 1 {
+		t.Errorf("expected expired negative entry to go upstream, got %s with %d calls", rec.Header().Get("X-Cache"), calls.Load())
+	}
+
+	// 410 is not in the default list, 5xx is never cached
+	if rec := get("/gone"); rec.Header().Get("X-Cache") != CachePass {
+		t.Errorf("expected 410 not to be cached by default, got %s", rec.Header().Get("X-Cache"))
+	}
+	get("/broken")
+	if _, ok := p.cache.Get("GET /broken?"); ok {
+		t.Error("expected 5xx not to be cached")
+	}
+}
+
+func TestNegativeEntryNotServedAsBackup(t *testing.T) {
+	var status atomic.Int32
+	status.Store(http.StatusOK)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(status.Load()))
+		w.Write([]byte("body"))
+	}))
+	defer upstream.Close()
+
+	p, err := New(upstream.URL, 5*time.Second, time.Hour, nil, nil,
+		WithNegativeCaching(30*time.Second, nil))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	get := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/page", nil)
+		// Skip the negative hit so the request reaches upstream
+		req.Header.Set("Cache-Control", "no-cache")
+		p.ServeHTTP(rec, req)
+		return rec
+	}
+
+	get()
+	status.Store(http.StatusNotFound)
+	get()
+	status.Store(http.StatusBadGateway)
+
+	rec := get()
+	if rec.Code != http.StatusBadGateway || rec.Header().Get("X-Cache") == CacheHitBackup {
+		t.Errorf("expected the 502 to pass through instead of a 404 backup, got %d %s", rec.Code, rec.Header().Get("X-Cache"))
+	}
+}
+
+func TestNegativeCachingConfiguredStatuses(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer upstream.Close()
+
+	p, err := New(upstream.URL, 5*time.Second, time.Hour, nil, nil,
+		WithNegativeCaching(time.Minute, []int{http.StatusNotFound, http.StatusGone}))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	for _, want := range []string{CacheMiss, CacheHitNegative} {
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest("GET", "/old", nil))
+		if got := rec.Header().Get("X-Cache"); got != want || rec.Code != http.StatusGone {
+			t.Errorf("expected 410 %s, got %d %s", want, rec.Code, got)
+		}
+	}
+}
+
+func TestNegativeCachingDisabled(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(http.NotFound))
+	defer upstream.Close()
+
+	p, _ := New(upstream.URL, 5*time.Second, time.Hour, nil, nil)
+	p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/missing", nil))
+	if p.cache.Size() != 0 {
+		t.Error("expected 404 not to be cached without negative_ttl")
+	}
+}
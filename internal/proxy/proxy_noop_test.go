@@ -0,0 +1,46 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNoopPaths(t *testing.T) {
+	var hits atomic.Int64
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, nil, WithNoopPaths([]string{"/ping"}))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/ping?t=1", nil))
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("expected 204 for noop path, got %d", rec.Code)
+	}
+	if hits.Load() != 0 || p.cache.Size() != 0 {
+		t.Errorf("expected noop path to skip upstream and cache, got %d hits, %d entries", hits.Load(), p.cache.Size())
+	}
+
+	for _, tc := range []struct{ method, path string }{
+		{"GET", "/ping/deep"},
+		{"POST", "/ping"},
+	} {
+		rec = httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.path, nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s %s: expected request to be forwarded, got %d", tc.method, tc.path, rec.Code)
+		}
+	}
+	if hits.Load() != 2 {
+		t.Errorf("expected 2 forwarded requests, got %d", hits.Load())
+	}
+}
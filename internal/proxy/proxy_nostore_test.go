@@ -0,0 +1,50 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOriginCacheControlPreventsStore(t *testing.T) {
+	for _, tt := range []struct {
+		cacheControl string
+		status       string
+	}{
+		{"no-store", CachePass},
+		{"private", CachePass},
+		{`private="Set-Cookie", max-age=60`, CachePass},
+		{"No-Cache", CachePass},
+		{"public, max-age=60", CacheMiss},
+		{"", CacheMiss},
+	} {
+		t.Run(tt.cacheControl, func(t *testing.T) {
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.cacheControl != "" {
+					w.Header().Set("Cache-Control", tt.cacheControl)
+				}
+				w.Write([]byte("account page"))
+			}))
+			defer upstream.Close()
+
+			p, err := New(upstream.URL, 5*time.Second, time.Minute, nil, nil)
+			if err != nil {
+				t.Fatalf("failed to create proxy: %v", err)
+			}
+			rec := httptest.NewRecorder()
+			p.ServeHTTP(rec, httptest.NewRequest("GET", "/account", nil))
+
+			if got := rec.Header().Get("X-Cache"); got != tt.status {
+				t.Errorf("expected X-Cache %s, got %s", tt.status, got)
+			}
+			wantSize := 0
+			if tt.status == CacheMiss {
+				wantSize = 1
+			}
+			if p.cache.Size() != wantSize {
+				t.Errorf("expected %d cached entries, got %d", wantSize, p.cache.Size())
+			}
+		})
+	}
+}
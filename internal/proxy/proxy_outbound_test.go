@@ -0,0 +1,74 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestOutboundProxyRoutesThroughProxy(t *testing.T) {
+	var proxied atomic.Int32
+	outbound := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A forward proxy receives the absolute upstream URL
+		if r.URL.Host != "upstream.invalid" {
+			t.Errorf("expected request for upstream.invalid, got %q", r.URL.String())
+		}
+		proxied.Add(1)
+		w.Write([]byte("via proxy"))
+	}))
+	defer outbound.Close()
+
+	p, err := New("http://upstream.invalid", 5*time.Second, 0, nil, nil, WithOutboundProxy(outbound.URL))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/data", nil))
+
+	if rec.Code != http.StatusOK || rec.Body.String() != "via proxy" {
+		t.Errorf("expected response through the outbound proxy, got %d %q", rec.Code, rec.Body.String())
+	}
+	if proxied.Load() != 1 {
+		t.Errorf("expected 1 proxied request, got %d", proxied.Load())
+	}
+}
+
+func TestOutboundProxyDirect(t *testing.T) {
+	var proxied atomic.Int32
+	outbound := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied.Add(1)
+	}))
+	defer outbound.Close()
+	t.Setenv("HTTP_PROXY", outbound.URL)
+
+	upstream := okUpstream(t)
+
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, nil, WithOutboundProxy(OutboundDirect))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	if p.transport.Proxy != nil {
+		t.Error("expected no transport proxy function with direct")
+	}
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/data", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", rec.Code)
+	}
+	if proxied.Load() != 0 {
+		t.Errorf("expected no proxied requests, got %d", proxied.Load())
+	}
+}
+
+func TestOutboundProxyInvalid(t *testing.T) {
+	for _, raw := range []string{"ftp://proxy:21", "socks5://", "::bad"} {
+		if _, err := New("http://example.com", 0, 0, nil, nil, WithOutboundProxy(raw)); err == nil {
+			t.Errorf("%q: expected error", raw)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package proxy
+
+import (
+	"Aegis/internal/utils"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPathRateLimits(t *testing.T) {
+	upstream := okUpstream(t)
+	clock := utils.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, nil, WithClock(clock), WithRateLimit(RateLimit{
+		Rate:  100,
+		Burst: 100,
+		Paths: []PathRateLimit{
+			{Prefix: "/api/", Rate: 50, Burst: 50},
+			{Prefix: "/api/search", Rate: 1, Burst: 2},
+			{Prefix: "/static/"}, // unlimited
+		},
+	}))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	get := func(path string) int {
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		return rec.Code
+	}
+
+	// The search endpoint allows its own burst of 2
+	for i := 0; i < 2; i++ {
+		if code := get("/api/search?q=x"); code != http.StatusOK {
+			t.Fatalf("search request %d: expected 200, got %d", i, code)
+		}
+	}
+	if code := get("/api/search?q=y"); code != http.StatusTooManyRequests {
+		t.Errorf("expected 429 once the search limit is used up, got %d", code)
+	}
+
+	// Other paths are limited independently
+	for _, path := range []string{"/api/items", "/home", "/static/app.js"} {
+		if code := get(path); code != http.StatusOK {
+			t.Errorf("%s: expected 200 while search is limited, got %d", path, code)
+		}
+	}
+	for i := 0; i < 200; i++ {
+		if code := get("/static/app.js"); code != http.StatusOK {
+			t.Fatalf("expected unlimited static path, got %d after %d requests", code, i)
+		}
+	}
+
+	// One token is refilled per second
+	clock.Advance(time.Second)
+	if code := get("/api/search?q=z"); code != http.StatusOK {
+		t.Errorf("expected 200 after the bucket refilled, got %d", code)
+	}
+	if code := get("/api/search?q=z"); code != http.StatusTooManyRequests {
+		t.Errorf("expected 429 again, got %d", code)
+	}
+}
+
+func TestGlobalRateLimit(t *testing.T) {
+	upstream := okUpstream(t)
+	clock := utils.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, nil, WithClock(clock), WithRateLimit(RateLimit{Rate: 1, Burst: 1}))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	for i, want := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest("GET", "/page", nil))
+		if rec.Code != want {
+			t.Errorf("request %d: expected %d, got %d", i, want, rec.Code)
+		}
+		if want == http.StatusTooManyRequests && rec.Header().Get("Retry-After") == "" {
+			t.Error("expected Retry-After on 429")
+		}
+	}
+}
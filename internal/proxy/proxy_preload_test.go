@@ -0,0 +1,59 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPreload(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("body of " + r.URL.Path))
+	}))
+	defer upstream.Close()
+
+	p, err := New(upstream.URL, 5*time.Second, 10*time.Minute, nil, nil, WithPreload([]PreloadEntry{
+		{URL: "/home", TTL: time.Hour},
+		{URL: "/about?lang=en"},
+		{URL: "/missing", TTL: time.Hour},
+	}))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	// Not ready before the preload has run
+	rec := httptest.NewRecorder()
+	p.ReadyHandler(rec, httptest.NewRequest("GET", "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 before preload, got %d", rec.Code)
+	}
+
+	if err := p.Preload(context.Background()); err == nil {
+		t.Error("expected an error for the uncacheable entry")
+	}
+
+	if ttl := storedTTL(t, p, "GET /home?"); ttl != time.Hour {
+		t.Errorf("expected manifest TTL 1h, got %s", ttl)
+	}
+	if ttl := storedTTL(t, p, "GET /about?lang=en"); ttl != 10*time.Minute {
+		t.Errorf("expected default TTL 10m, got %s", ttl)
+	}
+	if entry, _ := p.cache.Get("GET /home?"); string(entry.Body) != "body of /home" {
+		t.Errorf("unexpected preloaded body %q", entry.Body)
+	}
+	if _, ok := p.cache.Get("GET /missing?"); ok {
+		t.Error("expected failed entry not to be cached")
+	}
+
+	rec = httptest.NewRecorder()
+	p.ReadyHandler(rec, httptest.NewRequest("GET", "/readyz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected 200 after preload, got %d", rec.Code)
+	}
+}
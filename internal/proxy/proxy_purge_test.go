@@ -0,0 +1,125 @@
+package proxy
+
+import (
+	"Aegis/internal/utils"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestPurgeHandler(t *testing.T) {
+	upstream := okUpstream(t)
+	p, err := New(upstream.URL, 5*time.Second, time.Minute, []string{"Accept-Language"}, nil,
+		WithKeyHash(KeyHashSHA256))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	fetch := func(target, lang string) {
+		req := httptest.NewRequest("GET", target, nil)
+		req.Header.Set("Accept-Language", lang)
+		p.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	fetch("/api/users?page=1", "en")
+	fetch("/api/users?page=1", "de")
+	fetch("/api/users?page=2", "en")
+	if p.cache.Size() != 3 {
+		t.Fatalf("expected 3 cached entries, got %d", p.cache.Size())
+	}
+
+	purge := func(form url.Values, lang string) (int, purgeResponse) {
+		req := httptest.NewRequest("POST", "/purge?"+form.Encode(), nil)
+		if lang != "" {
+			req.Header.Set("Accept-Language", lang)
+		}
+		rec := httptest.NewRecorder()
+		p.PurgeHandler(rec, req)
+		var resp purgeResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("invalid JSON response %q: %v", rec.Body.String(), err)
+		}
+		return rec.Code, resp
+	}
+
+	// By request fields: only the entry with the same key headers goes
+	code, resp := purge(url.Values{"method": {"GET"}, "url": {"/api/users?page=1"}}, "en")
+	if code != http.StatusOK || !resp.Purged {
+		t.Errorf("expected purge by url to succeed, got %d %+v", code, resp)
+	}
+	if p.cache.Size() != 2 {
+		t.Errorf("expected 2 cached entries after purge, got %d", p.cache.Size())
+	}
+
+	// Purging again finds nothing
+	code, resp = purge(url.Values{"url": {"/api/users?page=1"}}, "en")
+	if code != http.StatusNotFound || resp.Purged {
+		t.Errorf("expected 404 for a missing entry, got %d %+v", code, resp)
+	}
+
+	// By raw key, hashed like any other key
+	code, resp = purge(url.Values{"key": {"GET /api/users?page=2|Accept-Language:en"}}, "")
+	if code != http.StatusOK || !resp.Purged {
+		t.Errorf("expected purge by key to succeed, got %d %+v", code, resp)
+	}
+	if p.cache.Size() != 1 {
+		t.Errorf("expected 1 cached entry after purge, got %d", p.cache.Size())
+	}
+
+	if code, _ = purge(url.Values{"url": {"api/users"}}, ""); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a relative url, got %d", code)
+	}
+	if code, _ = purge(url.Values{"method": {"POST"}, "url": {"/api/users"}}, ""); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an uncacheable method, got %d", code)
+	}
+
+	rec := httptest.NewRecorder()
+	p.PurgeHandler(rec, httptest.NewRequest("GET", "/purge?url=/api/users", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for GET, got %d", rec.Code)
+	}
+}
+
+func TestPurgeStaleEntry(t *testing.T) {
+	upstream := okUpstream(t)
+	clock := utils.NewFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	p, err := New(upstream.URL, 5*time.Second, time.Minute, nil, nil,
+		WithClock(clock), WithStaleWhileRevalidate(time.Minute))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/page", nil))
+
+	// Expired, but still inside the stale window
+	clock.Advance(90 * time.Second)
+	rec := httptest.NewRecorder()
+	p.PurgeHandler(rec, httptest.NewRequest("POST", "/purge?url=/page", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected a stale entry to be purged, got %d %s", rec.Code, rec.Body.String())
+	}
+	if _, ok := p.fetchStaleEntry(httptest.NewRequest("GET", "/page", nil), "GET /page?"); ok {
+		t.Error("expected the stale copy to be gone")
+	}
+}
+
+func TestPurgeCanonicalPath(t *testing.T) {
+	upstream := okUpstream(t)
+	p, err := New(upstream.URL, 5*time.Second, time.Minute, nil, nil, WithCanonicalPath(true, false))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	for _, target := range []string{"/a//b", "/a/%7Ec"} {
+		p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", target, nil))
+		rec := httptest.NewRecorder()
+		p.PurgeHandler(rec, httptest.NewRequest("POST", "/purge?"+url.Values{"method": {"get"}, "url": {target}}.Encode(), nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("expected %s to be purged under its canonical key, got %d %s", target, rec.Code, rec.Body.String())
+		}
+	}
+	if p.cache.Size() != 0 {
+		t.Errorf("expected an empty cache after purging, got %d entries", p.cache.Size())
+	}
+}
@@ -0,0 +1,67 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestIgnoredQueryParamsShareEntry(t *testing.T) {
+	var mu sync.Mutex
+	var forwarded []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		forwarded = append(forwarded, r.URL.RawQuery)
+		mu.Unlock()
+		w.Write([]byte("page"))
+	}))
+	defer upstream.Close()
+
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, nil, WithIgnoredQueryParams(AnalyticsQueryParams))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	req1 := httptest.NewRequest("GET", "/page?id=1&utm_source=newsletter", nil)
+	req2 := httptest.NewRequest("GET", "/page?id=1&utm_source=twitter&gclid=abc", nil)
+	if p.cacheKey(req1) != p.cacheKey(req2) {
+		t.Errorf("expected shared key, got %s and %s", p.cacheKey(req1), p.cacheKey(req2))
+	}
+	if key := p.cacheKey(req1); key != "GET /page?id=1" {
+		t.Errorf("expected key without analytics params, got %s", key)
+	}
+
+	for _, req := range []*http.Request{req1, req2} {
+		p.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if p.cache.Size() != 1 {
+		t.Errorf("expected 1 shared cache entry, got %d", p.cache.Size())
+	}
+	expected := []string{"id=1&utm_source=newsletter", "id=1&utm_source=twitter&gclid=abc"}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(forwarded) != len(expected) {
+		t.Fatalf("expected %d upstream requests, got %d", len(expected), len(forwarded))
+	}
+	for i := range expected {
+		if forwarded[i] != expected[i] {
+			t.Errorf("expected query %q forwarded upstream, got %q", expected[i], forwarded[i])
+		}
+	}
+}
+
+func TestIgnoredQueryParamsOtherParamsKept(t *testing.T) {
+	p, _ := New("http://example.com", 0, 0, nil, nil, WithIgnoredQueryParams([]string{"ref"}))
+
+	req1 := httptest.NewRequest("GET", "/page?id=1&ref=home", nil)
+	req2 := httptest.NewRequest("GET", "/page?id=2&ref=home", nil)
+	if p.cacheKey(req1) == p.cacheKey(req2) {
+		t.Error("expected different keys for different non-ignored params")
+	}
+	if key := p.cacheKey(httptest.NewRequest("GET", "/page?utm_source=x", nil)); key != "GET /page?utm_source=x" {
+		t.Errorf("expected only configured params ignored, got %s", key)
+	}
+}
@@ -0,0 +1,63 @@
+package proxy
+
+import (
+	"Aegis/internal/cache"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func rateLimitedUpstream(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte("slow down"))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestFailoverOn429ServesBackup(t *testing.T) {
+	upstream := rateLimitedUpstream(t)
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, nil, WithFailoverOn429(true))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	p.cache.Set("GET /quota?", cache.Response{Status: http.StatusOK, Header: http.Header{}, Body: []byte("cached")})
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/quota", nil))
+
+	if rec.Code != http.StatusOK || rec.Body.String() != "cached" {
+		t.Errorf("expected cached 200, got %d %q", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("X-Cache"); got != CacheHitBackup {
+		t.Errorf("expected HIT-BACKUP, got %q", got)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "" {
+		t.Errorf("expected no Retry-After on backup, got %q", got)
+	}
+}
+
+func TestFailoverOn429PropagatesWhenCold(t *testing.T) {
+	upstream := rateLimitedUpstream(t)
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, nil, WithFailoverOn429(true))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/quota", nil))
+
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("expected 429 to be propagated, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "30" {
+		t.Errorf("expected Retry-After 30, got %q", got)
+	}
+	if got := rec.Header().Get("X-Cache"); got != CachePass {
+		t.Errorf("expected PASS, got %q", got)
+	}
+}
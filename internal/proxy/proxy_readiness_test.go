@@ -0,0 +1,160 @@
+package proxy
+
+import (
+	"Aegis/internal/cache"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestReadyzClosedBreaker(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, nil, WithCircuitBreaker(2, time.Minute))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/ok", nil))
+
+	rec := httptest.NewRecorder()
+	p.ReadyHandler(rec, httptest.NewRequest("GET", "/readyz", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", rec.Code)
+	}
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to parse readyz JSON: %v", err)
+	}
+	if body["circuit_breaker"] != "closed" {
+		t.Errorf("expected circuit_breaker closed, got %v", body["circuit_breaker"])
+	}
+}
+
+func TestReadyzOpenBreaker(t *testing.T) {
+	requestCount := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestCount++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer upstream.Close()
+
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, nil, WithCircuitBreaker(2, time.Minute))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/down", nil))
+	}
+
+	// Third request must be short-circuited by the open breaker
+	if requestCount != 2 {
+		t.Errorf("expected 2 upstream requests, got %d", requestCount)
+	}
+
+	rec := httptest.NewRecorder()
+	p.ReadyHandler(rec, httptest.NewRequest("GET", "/readyz", nil))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503, got %d", rec.Code)
+	}
+}
+
+func TestReadyzOpenBreakerReadyWithCache(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer upstream.Close()
+
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, nil,
+		WithCircuitBreaker(1, time.Minute), WithReadyWhenCached(true))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/down", nil))
+
+	// Open breaker with an empty cache is not ready
+	rec := httptest.NewRecorder()
+	p.ReadyHandler(rec, httptest.NewRequest("GET", "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503 with empty cache, got %d", rec.Code)
+	}
+
+	p.cache.Set("GET /cached?", cache.Response{Status: 200, Body: []byte("cached")})
+
+	rec = httptest.NewRecorder()
+	p.ReadyHandler(rec, httptest.NewRequest("GET", "/readyz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status 200 with cached entries, got %d", rec.Code)
+	}
+}
+
+func TestReadyzUnhealthyUpstreams(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer upstream.Close()
+
+	for _, readyWhenCached := range []bool{false, true} {
+		p, err := New(upstream.URL, 5*time.Second, 0, nil, nil, WithReadyWhenCached(readyWhenCached),
+			WithHealthCheck(HealthCheck{Interval: 10 * time.Millisecond, Path: "/health"}))
+		if err != nil {
+			t.Fatalf("failed to create proxy: %v", err)
+		}
+		defer p.Close()
+		deadline := time.Now().Add(2 * time.Second)
+		for p.upstreamHealthy.Load() {
+			if time.Now().After(deadline) {
+				t.Fatal("expected upstream to fail its health check")
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+
+		rec := httptest.NewRecorder()
+		p.ReadyHandler(rec, httptest.NewRequest("GET", "/readyz", nil))
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("ready_with_cache=%v: expected status 503 with unhealthy upstreams, got %d", readyWhenCached, rec.Code)
+		}
+
+		p.cache.Set("GET /cached?", cache.Response{Status: 200, Body: []byte("cached")})
+		want := http.StatusServiceUnavailable
+		if readyWhenCached {
+			want = http.StatusOK
+		}
+		rec = httptest.NewRecorder()
+		p.ReadyHandler(rec, httptest.NewRequest("GET", "/readyz", nil))
+		if rec.Code != want {
+			t.Errorf("ready_with_cache=%v: expected status %d with cached entries, got %d", readyWhenCached, want, rec.Code)
+		}
+	}
+}
+
+func TestBreakerHalfOpenAfterCooldown(t *testing.T) {
+	now := time.Now()
+	b := newBreaker(1, time.Second)
+	b.now = func() time.Time { return now }
+
+	b.Failure()
+	if b.State() != "open" {
+		t.Fatalf("expected open, got %s", b.State())
+	}
+
+	now = now.Add(2 * time.Second)
+	if !b.Allow() {
+		t.Error("expected breaker to allow a request after cooldown")
+	}
+
+	b.Success()
+	if b.State() != "closed" {
+		t.Errorf("expected closed after success, got %s", b.State())
+	}
+}
@@ -0,0 +1,65 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRequestTimeoutHeaderClamping(t *testing.T) {
+	p, err := New("http://example.com", 2*time.Second, 0, nil, nil, WithRequestTimeoutHeader(true))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	tests := []struct {
+		header string
+		want   time.Duration
+	}{
+		{"", 2 * time.Second},
+		{"0.5", 500 * time.Millisecond},
+		{"99999", 2 * time.Second},
+		{"-1", 2 * time.Second},
+		{"soon", 2 * time.Second},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set(RequestTimeoutHeader, tt.header)
+		if got := p.requestTimeout(req); got != tt.want {
+			t.Errorf("Request-Timeout %q: expected %v, got %v", tt.header, tt.want, got)
+		}
+	}
+
+	disabled, _ := New("http://example.com", 2*time.Second, 0, nil, nil)
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(RequestTimeoutHeader, "0.5")
+	if got := disabled.requestTimeout(req); got != 2*time.Second {
+		t.Errorf("expected header to be ignored when disabled, got %v", got)
+	}
+}
+
+func TestRequestTimeoutHeaderShortensDeadline(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, nil, WithRequestTimeoutHeader(true))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/slow", nil)
+	req.Header.Set(RequestTimeoutHeader, "0.05")
+	rec := httptest.NewRecorder()
+	start := time.Now()
+	p.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("expected 502 after client deadline, got %d", rec.Code)
+	}
+	if elapsed := time.Since(start); elapsed > 250*time.Millisecond {
+		t.Errorf("expected request to give up near 50ms, took %v", elapsed)
+	}
+}
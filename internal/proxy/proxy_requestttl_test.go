@@ -0,0 +1,82 @@
+package proxy
+
+import (
+	"Aegis/internal/utils"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTTLRequestHeader(t *testing.T) {
+	var forwarded string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded = r.Header.Get(TTLRequestHeader)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	trusted, _ := utils.ParseCIDRs([]string{"10.0.0.0/8"})
+	p, err := New(upstream.URL, 5*time.Second, time.Hour, nil, nil,
+		WithTrustedProxies(trusted), WithTTLRequestHeader(true))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		remoteAddr string
+		header     string
+		wantTTL    time.Duration
+	}{
+		{"trusted source", "/trusted", "10.1.2.3:5555", "60", time.Minute},
+		{"untrusted source", "/untrusted", "203.0.113.9:5555", "60", time.Hour},
+		{"invalid value", "/invalid", "10.1.2.3:5555", "soon", time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			req.RemoteAddr = tt.remoteAddr
+			req.Header.Set(TTLRequestHeader, tt.header)
+			p.ServeHTTP(httptest.NewRecorder(), req)
+
+			if forwarded != "" {
+				t.Errorf("expected %s to be stripped, upstream got %q", TTLRequestHeader, forwarded)
+			}
+			entry, ok := p.cache.Get(p.cacheKey(req))
+			if !ok {
+				t.Fatal("expected response to be cached")
+			}
+			if got := entry.ExpireAt.Sub(entry.SavedAt).Round(time.Second); got != tt.wantTTL {
+				t.Errorf("expected TTL %v, got %v", tt.wantTTL, got)
+			}
+		})
+	}
+}
+
+func TestTTLRequestHeaderDisabled(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	trusted, _ := utils.ParseCIDRs([]string{"10.0.0.0/8"})
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, nil, WithTrustedProxies(trusted))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/page", nil)
+	req.RemoteAddr = "10.1.2.3:5555"
+	req.Header.Set(TTLRequestHeader, "60")
+	p.ServeHTTP(httptest.NewRecorder(), req)
+
+	entry, ok := p.cache.Get(p.cacheKey(req))
+	if !ok {
+		t.Fatal("expected response to be cached")
+	}
+	if !entry.ExpireAt.IsZero() {
+		t.Errorf("expected header to be ignored when disabled, got expiry %v", entry.ExpireAt)
+	}
+}
@@ -0,0 +1,111 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyUpstream fails the first failures requests with 503, then answers 200
+func flakyUpstream(t *testing.T, failures int64) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+	var calls atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func TestRetryRecoversFromFailures(t *testing.T) {
+	upstream, calls := flakyUpstream(t, 2)
+	p, err := New(upstream.URL, 5*time.Second, time.Minute, nil, nil, WithRetries(2, time.Millisecond))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/data", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "ok" {
+		t.Errorf("expected 200 ok after retries, got %d %q", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("X-Cache") != CacheMiss {
+		t.Errorf("expected X-Cache MISS, got %q", rec.Header().Get("X-Cache"))
+	}
+	if calls.Load() != 3 {
+		t.Errorf("expected 3 upstream attempts, got %d", calls.Load())
+	}
+}
+
+func TestRetriesExhausted(t *testing.T) {
+	upstream, calls := flakyUpstream(t, 10)
+	p, err := New(upstream.URL, 5*time.Second, time.Minute, nil, nil, WithRetries(2, time.Millisecond))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/data", nil))
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("expected 502 without a backup, got %d", rec.Code)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("expected 3 upstream attempts, got %d", calls.Load())
+	}
+}
+
+func TestNoRetryForPost(t *testing.T) {
+	upstream, calls := flakyUpstream(t, 1)
+	p, err := New(upstream.URL, 5*time.Second, time.Minute, nil, nil, WithRetries(2, time.Millisecond))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("POST", "/data", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected the 503 passed through, got %d", rec.Code)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected a single upstream attempt, got %d", calls.Load())
+	}
+}
+
+func TestRetryGoesToNextUpstream(t *testing.T) {
+	down, downCalls := flakyUpstream(t, 10)
+	up, upCalls := flakyUpstream(t, 0)
+	p, err := New(down.URL, 5*time.Second, time.Minute, nil, nil,
+		WithExtraUpstreams([]string{up.URL}), WithRetries(1, time.Millisecond))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	// Each request starts on the next upstream in turn; its retry takes the one after
+	for range 4 {
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest("GET", "/data", nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("expected 200, got %d", rec.Code)
+		}
+	}
+	if downCalls.Load() != 4 || upCalls.Load() != 4 {
+		t.Errorf("expected every failed attempt retried on the other upstream, got %d failed and %d successful", downCalls.Load(), upCalls.Load())
+	}
+}
+
+func TestRetryDelayBackoff(t *testing.T) {
+	p, _ := New("http://example.com", 0, 0, nil, nil, WithRetries(3, 100*time.Millisecond))
+	for n, base := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 3: 400 * time.Millisecond} {
+		for range 20 {
+			if d := p.retryDelay(n); d < base/2 || d > base {
+				t.Errorf("attempt %d: expected a delay in [%s, %s], got %s", n, base/2, base, d)
+			}
+		}
+	}
+}
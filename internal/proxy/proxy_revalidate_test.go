@@ -0,0 +1,85 @@
+package proxy
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRevalidateOncePerKey(t *testing.T) {
+	upstream := okUpstream(t)
+	p, err := New(upstream.URL, 5*time.Second, time.Minute, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	defer p.Close()
+
+	var runs atomic.Int32
+	release := make(chan struct{})
+	done := make(chan struct{}, 100)
+	refresh := func() {
+		runs.Add(1)
+		<-release
+		done <- struct{}{}
+	}
+
+	var queued atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if p.revalidate("GET /popular", refresh) {
+				queued.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Another key is not held back by the running refresh
+	if !p.revalidate("GET /other", refresh) {
+		t.Error("expected refresh of another key to be queued")
+	}
+	close(release)
+	<-done
+	<-done
+
+	if got := queued.Load(); got != 1 {
+		t.Errorf("expected 1 queued refresh for the key, got %d", got)
+	}
+	if got := runs.Load(); got != 2 {
+		t.Errorf("expected 2 refreshes to run, got %d", got)
+	}
+
+	// The slot is free again once the refresh finished
+	deadline := time.Now().Add(time.Second)
+	for !p.revalidate("GET /popular", refresh) {
+		if time.Now().After(deadline) {
+			t.Fatal("expected a new refresh after the first one completed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	<-done
+}
+
+func TestRevalidationsPerKeyLimit(t *testing.T) {
+	upstream := okUpstream(t)
+	p, err := New(upstream.URL, 5*time.Second, time.Minute, nil, nil, WithMaxRevalidationsPerKey(2))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	defer p.Close()
+
+	release := make(chan struct{})
+	defer close(release)
+	var queued int
+	for i := 0; i < 5; i++ {
+		if p.revalidate("GET /popular", func() { <-release }) {
+			queued++
+		}
+	}
+	if queued != 2 {
+		t.Errorf("expected 2 queued refreshes, got %d", queued)
+	}
+}
@@ -0,0 +1,122 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRewriteCaptureGroup(t *testing.T) {
+	var gotURI string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotURI = r.URL.RequestURI()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, nil, WithRewriteRules([]RewriteRule{
+		{Match: `^/v1/users/(\d+)$`, Replace: "/internal/user?id=$1"},
+		{Match: `^/v1/`, Replace: "/never/"},
+	}))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/users/123", nil))
+	if gotURI != "/internal/user?id=123" {
+		t.Errorf("expected rewritten URI /internal/user?id=123, got %s", gotURI)
+	}
+}
+
+func TestRewriteNoMatchPassthrough(t *testing.T) {
+	var gotURI string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotURI = r.URL.RequestURI()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, nil, WithRewriteRules([]RewriteRule{
+		{Match: `^/v1/users/(\d+)$`, Replace: "/internal/user?id=$1"},
+	}))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v2/orders?page=2", nil))
+	if gotURI != "/v2/orders?page=2" {
+		t.Errorf("expected unchanged URI /v2/orders?page=2, got %s", gotURI)
+	}
+}
+
+func TestRewriteInvalidRegex(t *testing.T) {
+	_, err := New("http://example.com", 5*time.Second, 0, nil, nil, WithRewriteRules([]RewriteRule{
+		{Match: `(unclosed`, Replace: "/x"},
+	}))
+	if err == nil {
+		t.Error("expected error for invalid rewrite regex")
+	}
+}
+
+func TestRewriteAllowedHost(t *testing.T) {
+	var hit bool
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hit = r.URL.Path == "/v2/items"
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer other.Close()
+
+	otherURL, _ := url.Parse(other.URL)
+	p, err := New("http://primary.invalid", 5*time.Second, 0, nil, nil,
+		WithRewriteRules([]RewriteRule{{Match: `^/legacy/(.*)$`, Replace: other.URL + "/v2/$1"}}),
+		WithAllowedUpstreamHosts([]string{otherURL.Host}))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/legacy/items", nil))
+	if rec.Code != http.StatusOK || !hit {
+		t.Errorf("expected request to reach allowed host, got %d", rec.Code)
+	}
+}
+
+func TestRewriteDisallowedHostBlocked(t *testing.T) {
+	var hit bool
+	evil := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hit = true
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer evil.Close()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, nil,
+		WithRewriteRules([]RewriteRule{{Match: `^/fetch/(.*)$`, Replace: "http://$1"}}))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	evilHost := strings.TrimPrefix(evil.URL, "http://")
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/fetch/"+evilHost+"/secret", nil))
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("expected status 502 for disallowed host, got %d", rec.Code)
+	}
+	if hit {
+		t.Error("expected disallowed host not to be contacted")
+	}
+
+	// The configured upstream stays reachable
+	rec = httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/ok", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status 200 for configured upstream, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,82 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSavingsStats(t *testing.T) {
+	var failing atomic.Bool
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		if r.URL.Path == "/logo.png" {
+			w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		}
+		w.Write([]byte(strings.Repeat("x", len(r.URL.Path)*100)))
+	}))
+	defer upstream.Close()
+
+	p, err := New(upstream.URL, 5*time.Second, time.Hour, nil, nil,
+		WithImmutable(true), WithSavingsStats(true))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	serve := func(path string) string {
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		return rec.Header().Get("X-Cache")
+	}
+
+	// 900-byte immutable asset and 500-byte page, both MISS first
+	serve("/logo.png")
+	serve("/page")
+
+	for i := 0; i < 3; i++ {
+		if got := serve("/logo.png"); got != CacheHit {
+			t.Fatalf("expected HIT, got %s", got)
+		}
+	}
+	failing.Store(true)
+	if got := serve("/page"); got != CacheHitBackup {
+		t.Fatalf("expected HIT-BACKUP, got %s", got)
+	}
+
+	rec := httptest.NewRecorder()
+	p.StatsHandler(rec, httptest.NewRequest("GET", "/stats", nil))
+	var stats statsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("failed to parse stats JSON: %v", err)
+	}
+	if stats.Savings == nil {
+		t.Fatal("expected savings in stats")
+	}
+	if stats.Savings.Requests != 4 {
+		t.Errorf("expected 4 saved requests, got %d", stats.Savings.Requests)
+	}
+	if want := int64(3*900 + 500); stats.Savings.Bytes != want {
+		t.Errorf("expected %d saved bytes, got %d", want, stats.Savings.Bytes)
+	}
+
+	rec = httptest.NewRecorder()
+	p.StatsHandler(rec, httptest.NewRequest("GET", "/stats?format=text", nil))
+	if !strings.Contains(rec.Body.String(), "saved_requests 4\nsaved_bytes 3200\n") {
+		t.Errorf("expected savings in text stats, got:\n%s", rec.Body.String())
+	}
+}
+
+func TestSavingsStatsDisabled(t *testing.T) {
+	p, _ := New("http://example.com", 5*time.Second, time.Hour, nil, nil)
+	rec := httptest.NewRecorder()
+	p.StatsHandler(rec, httptest.NewRequest("GET", "/stats", nil))
+	if strings.Contains(rec.Body.String(), "savings") {
+		t.Errorf("expected no savings without the option, got %s", rec.Body.String())
+	}
+}
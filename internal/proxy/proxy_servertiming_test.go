@@ -0,0 +1,119 @@
+package proxy
+
+import (
+	"Aegis/internal/cache"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// parseUpstreamDur extracts the upstream dur value (ms) from a Server-Timing header
+func parseUpstreamDur(t *testing.T, header string) float64 {
+	t.Helper()
+	for _, metric := range strings.Split(header, ",") {
+		metric = strings.TrimSpace(metric)
+		if rest, ok := strings.CutPrefix(metric, "upstream;dur="); ok {
+			dur, err := strconv.ParseFloat(rest, 64)
+			if err != nil {
+				t.Fatalf("invalid upstream dur in %q: %v", header, err)
+			}
+			return dur
+		}
+	}
+	t.Fatalf("no upstream metric in Server-Timing %q", header)
+	return 0
+}
+
+func TestServerTimingUpstreamDuration(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, nil, WithServerTiming(true))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/slow", nil))
+
+	header := rec.Header().Get("Server-Timing")
+	if dur := parseUpstreamDur(t, header); dur < 50 || dur > 1000 {
+		t.Errorf("expected upstream dur near 50ms, got %v", dur)
+	}
+	if !strings.HasSuffix(header, ", cache;desc=MISS") {
+		t.Errorf("expected cache desc MISS, got %q", header)
+	}
+}
+
+func TestServerTimingOnBackupHit(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer upstream.Close()
+
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, nil, WithServerTiming(true))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	p.cache.Set("GET /page?", cache.Response{Status: http.StatusOK, Header: http.Header{}, Body: []byte("cached")})
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/page", nil))
+
+	header := rec.Header().Get("Server-Timing")
+	parseUpstreamDur(t, header)
+	if !strings.HasSuffix(header, ", cache;desc=HIT-BACKUP") {
+		t.Errorf("expected cache desc HIT-BACKUP, got %q", header)
+	}
+}
+
+func TestServerTimingDisabledByDefault(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/page", nil))
+	if got := rec.Header().Get("Server-Timing"); got != "" {
+		t.Errorf("expected no Server-Timing header, got %q", got)
+	}
+}
+
+func TestDebugCacheHeaders(t *testing.T) {
+	upstream := okUpstream(t)
+
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, nil, WithDebugHeaders(true))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	for i, path := range []string{"/a", "/b"} {
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if got, want := rec.Header().Get("X-Cache-Entries"), strconv.Itoa(i+1); got != want {
+			t.Errorf("%s: expected X-Cache-Entries %s, got %q", path, want, got)
+		}
+		if got, want := rec.Header().Get("X-Cache-Memory-Bytes"), strconv.FormatInt(p.cache.MemoryUsage(), 10); got != want {
+			t.Errorf("%s: expected X-Cache-Memory-Bytes %s, got %q", path, want, got)
+		}
+	}
+
+	off, _ := New(upstream.URL, 5*time.Second, 0, nil, nil)
+	rec := httptest.NewRecorder()
+	off.ServeHTTP(rec, httptest.NewRequest("GET", "/a", nil))
+	if rec.Header().Get("X-Cache-Entries") != "" || rec.Header().Get("X-Cache-Memory-Bytes") != "" {
+		t.Error("expected no cache state headers outside debug mode")
+	}
+}
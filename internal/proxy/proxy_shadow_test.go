@@ -0,0 +1,107 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// shadowUpstream counts mirrored requests by path
+func shadowUpstream(t *testing.T) (*httptest.Server, *sync.Map, *atomic.Int64) {
+	t.Helper()
+	var paths sync.Map
+	var total atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths.Store(r.URL.RequestURI(), true)
+		total.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &paths, &total
+}
+
+func okUpstream(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestShadowSampleRate(t *testing.T) {
+	upstream := okUpstream(t)
+	shadow, _, total := shadowUpstream(t)
+
+	for _, sticky := range []bool{false, true} {
+		t.Run(fmt.Sprintf("sticky=%v", sticky), func(t *testing.T) {
+			total.Store(0)
+			p, err := New(upstream.URL, 5*time.Second, 0, nil, nil,
+				WithShadow(Shadow{Upstream: shadow.URL, SampleRate: 0.25, Sticky: sticky}))
+			if err != nil {
+				t.Fatalf("failed to create proxy: %v", err)
+			}
+
+			const n = 1000
+			for i := 0; i < n; i++ {
+				p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", fmt.Sprintf("/item/%d", i), nil))
+			}
+			p.Close()
+
+			if got := total.Load(); got < n*15/100 || got > n*35/100 {
+				t.Errorf("expected roughly 25%% of %d requests mirrored, got %d", n, got)
+			}
+		})
+	}
+}
+
+func TestShadowStickySampling(t *testing.T) {
+	upstream := okUpstream(t)
+	shadow, _, total := shadowUpstream(t)
+
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, nil,
+		WithShadow(Shadow{Upstream: shadow.URL, SampleRate: 0.5, Sticky: true}))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	for i := 0; i < 20; i++ {
+		p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/same?q=1", nil))
+	}
+	p.Close()
+
+	if got := total.Load(); got != 0 && got != 20 {
+		t.Errorf("expected identical requests to be sampled alike, got %d of 20 mirrored", got)
+	}
+}
+
+func TestShadowPathFilterAndMethods(t *testing.T) {
+	upstream := okUpstream(t)
+	shadow, paths, total := shadowUpstream(t)
+
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, nil,
+		WithShadow(Shadow{Upstream: shadow.URL, SampleRate: 1, PathPrefixes: []string{"/api/"}}))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/items?page=2", nil))
+	p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/static/app.js", nil))
+	p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/api/items", nil))
+	p.Close()
+
+	if total.Load() != 1 {
+		t.Errorf("expected exactly one mirrored request, got %d", total.Load())
+	}
+	if _, ok := paths.Load("/api/items?page=2"); !ok {
+		t.Error("expected matching GET to be mirrored with its query")
+	}
+}
+
+func TestShadowInvalidUpstream(t *testing.T) {
+	if _, err := New("http://example.com", time.Second, 0, nil, nil, WithShadow(Shadow{Upstream: "not a url"})); err == nil {
+		t.Error("expected error for invalid shadow upstream")
+	}
+}
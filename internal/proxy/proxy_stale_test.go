@@ -0,0 +1,96 @@
+package proxy
+
+import (
+	"Aegis/internal/utils"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStaleWhileRevalidate(t *testing.T) {
+	var version atomic.Int32
+	var calls atomic.Int32
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Refreshes wait until the test has sent all stale requests
+		if calls.Add(1) > 1 {
+			<-release
+		}
+		fmt.Fprintf(w, "v%d", version.Load())
+	}))
+	defer upstream.Close()
+
+	clock := utils.NewFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	p, err := New(upstream.URL, 5*time.Second, time.Minute, nil, nil,
+		WithClock(clock), WithStaleWhileRevalidate(30*time.Second))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	defer p.Close()
+	get := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest("GET", "/fast", nil))
+		return rec
+	}
+
+	get()
+	version.Store(1)
+	clock.Advance(70 * time.Second)
+
+	// Every request in the window gets the stale body, one refresh runs
+	for i := 0; i < 5; i++ {
+		rec := get()
+		if got := rec.Header().Get("X-Cache"); got != CacheStale || rec.Body.String() != "v0" {
+			t.Fatalf("expected STALE v0, got %s %q", got, rec.Body.String())
+		}
+	}
+	close(release)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if entry, ok := p.cache.Get("GET /fast?"); ok && string(entry.Body) == "v1" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected background refresh to store v1")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("expected a single background refresh, got %d upstream calls", got-1)
+	}
+
+	rec := get()
+	if rec.Header().Get("X-Cache") == CacheStale || rec.Body.String() != "v1" {
+		t.Errorf("expected fresh v1 after refresh, got %s %q", rec.Header().Get("X-Cache"), rec.Body.String())
+	}
+}
+
+func TestStaleWindowElapsed(t *testing.T) {
+	upstream := okUpstream(t)
+	clock := utils.NewFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	p, err := New(upstream.URL, 5*time.Second, time.Minute, nil, nil,
+		WithClock(clock), WithStaleWhileRevalidate(30*time.Second))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	defer p.Close()
+
+	p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/fast", nil))
+	clock.Advance(70 * time.Second)
+	if n := p.cache.Sweep(); n != 0 {
+		t.Errorf("expected entry within the stale window to be kept, got %d swept", n)
+	}
+	clock.Advance(time.Minute)
+	if n := p.cache.Sweep(); n != 1 {
+		t.Errorf("expected entry past the stale window to be swept, got %d", n)
+	}
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/fast", nil))
+	if got := rec.Header().Get("X-Cache"); got != CacheMiss {
+		t.Errorf("expected MISS past the stale window, got %s", got)
+	}
+}
@@ -0,0 +1,48 @@
+package proxy
+
+import (
+	"Aegis/internal/logger"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatsSnapshotLogged(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	}))
+	defer upstream.Close()
+
+	logs := captureLog(t)
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, logger.New(true, false, "info"),
+		WithStatsLogInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/data", nil))
+
+	time.Sleep(50 * time.Millisecond)
+	p.Close()
+
+	var line string
+	for _, l := range strings.Split(logs.String(), "\n") {
+		if i := strings.Index(l, "[INFO] stats: "); i >= 0 {
+			line = l[i+len("[INFO] stats: "):]
+		}
+	}
+	if line == "" {
+		t.Fatalf("expected at least one stats snapshot, got %q", logs.String())
+	}
+
+	var snap statsSnapshot
+	if err := json.Unmarshal([]byte(line), &snap); err != nil {
+		t.Fatalf("failed to parse snapshot %q: %v", line, err)
+	}
+	if snap.CacheSize != 1 || snap.Requests != 1 || snap.Miss != 1 {
+		t.Errorf("unexpected snapshot: %+v", snap)
+	}
+}
@@ -0,0 +1,79 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCustomCacheableAndFailoverStatus(t *testing.T) {
+	var status atomic.Int64
+	status.Store(http.StatusMovedPermanently)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		code := int(status.Load())
+		if code == http.StatusMovedPermanently {
+			w.Header().Set("Location", "/new")
+		}
+		w.WriteHeader(code)
+		w.Write([]byte(strconv.Itoa(code)))
+	}))
+	defer upstream.Close()
+
+	p, err := New(upstream.URL, 5*time.Second, time.Minute, nil, nil,
+		WithCacheableStatus([]StatusRange{{200, 299}, {301, 301}}),
+		WithFailoverStatus([]StatusRange{{503, 503}}))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	serve := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest("GET", "/old", nil))
+		return rec
+	}
+
+	// The redirect is passed through, not followed, and cached
+	rec := serve()
+	if rec.Code != http.StatusMovedPermanently || rec.Header().Get("X-Cache") != CacheMiss {
+		t.Fatalf("expected a cached 301, got %d %q", rec.Code, rec.Header().Get("X-Cache"))
+	}
+	if rec.Header().Get("Location") != "/new" {
+		t.Errorf("expected Location /new, got %q", rec.Header().Get("Location"))
+	}
+
+	// 503 fails over to the cached redirect
+	status.Store(http.StatusServiceUnavailable)
+	rec = serve()
+	if rec.Code != http.StatusMovedPermanently || rec.Header().Get("X-Cache") != CacheHitBackup {
+		t.Errorf("expected the backup 301 on 503, got %d %q", rec.Code, rec.Header().Get("X-Cache"))
+	}
+
+	// Other 5xx are passed through
+	status.Store(http.StatusBadGateway)
+	rec = serve()
+	if rec.Code != http.StatusBadGateway || rec.Header().Get("X-Cache") != CachePass {
+		t.Errorf("expected 502 passed through, got %d %q", rec.Code, rec.Header().Get("X-Cache"))
+	}
+}
+
+func TestDefaultStatusSets(t *testing.T) {
+	p, _ := New("http://example.com", 0, time.Minute, nil, nil)
+	for status, want := range map[int][2]bool{
+		200: {true, false},
+		204: {true, false},
+		301: {false, false},
+		404: {false, false},
+		500: {false, true},
+		503: {false, true},
+	} {
+		if got := statusIn(p.cacheableStatus, status); got != want[0] {
+			t.Errorf("%d: expected cacheable=%v", status, want[0])
+		}
+		if got := statusIn(p.failoverStatus, status); got != want[1] {
+			t.Errorf("%d: expected failover=%v", status, want[1])
+		}
+	}
+}
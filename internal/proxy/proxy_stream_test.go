@@ -0,0 +1,161 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStreamingTeeCachesLargeResponse(t *testing.T) {
+	first := strings.Repeat("a", 64*1024)
+	rest := strings.Repeat("b", 64*1024)
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(first))
+		w.(http.Flusher).Flush()
+		<-release
+		w.Write([]byte(rest))
+	}))
+	defer upstream.Close()
+
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, nil, WithStreaming(1024))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	srv := httptest.NewServer(p)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/large")
+	if err != nil {
+		close(release)
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// The first part arrives while upstream still holds the rest
+	head := make([]byte, len(first))
+	if _, err := io.ReadFull(resp.Body, head); err != nil {
+		close(release)
+		t.Fatalf("failed to read streamed part: %v", err)
+	}
+	close(release)
+	tail, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read rest of body: %v", err)
+	}
+
+	if got := string(head) + string(tail); got != first+rest {
+		t.Errorf("expected full body, got %d bytes", len(got))
+	}
+	if resp.Header.Get("X-Cache") != "MISS" {
+		t.Errorf("expected X-Cache MISS, got %s", resp.Header.Get("X-Cache"))
+	}
+
+	entry, ok := p.cache.Get("GET /large?")
+	if !ok {
+		t.Fatal("expected streamed response to be cached")
+	}
+	if string(entry.Body) != first+rest {
+		t.Errorf("expected cached body of %d bytes, got %d", len(first+rest), len(entry.Body))
+	}
+}
+
+func TestStreamingInterruptedLeavesNoEntry(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "100000")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(strings.Repeat("x", 4096)))
+		w.(http.Flusher).Flush()
+		// Drop the connection before the promised length
+		panic(http.ErrAbortHandler)
+	}))
+	defer upstream.Close()
+
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, nil, WithStreaming(1024))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	srv := httptest.NewServer(p)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/broken")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if _, err := io.ReadAll(resp.Body); err == nil {
+		t.Error("expected the client to see the interrupted stream")
+	}
+	if p.cache.Size() != 0 {
+		t.Errorf("expected no partial entry, got %d entries", p.cache.Size())
+	}
+}
+
+func TestStreamingSmallResponseBuffered(t *testing.T) {
+	p, err := New("http://example.com", 5*time.Second, 0, nil, nil, WithStreaming(1024))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	resp := &http.Response{StatusCode: http.StatusOK, ContentLength: 10}
+	if p.shouldStream(httptest.NewRequest("GET", "/", nil), resp) {
+		t.Error("expected a small response to be buffered")
+	}
+	resp.ContentLength = -1
+	if !p.shouldStream(httptest.NewRequest("GET", "/", nil), resp) {
+		t.Error("expected a response of unknown length to be streamed")
+	}
+}
+
+func TestPassThroughStreamsUncachedResponse(t *testing.T) {
+	first := strings.Repeat("a", 64*1024)
+	rest := strings.Repeat("b", 64*1024)
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(first))
+		w.(http.Flusher).Flush()
+		<-release
+		w.Write([]byte(rest))
+	}))
+	defer upstream.Close()
+
+	// No streaming threshold: only non-cacheable responses pass through
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	srv := httptest.NewServer(p)
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/download", "text/plain", nil)
+	if err != nil {
+		close(release)
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// The first part arrives while upstream still holds the rest
+	head := make([]byte, len(first))
+	if _, err := io.ReadFull(resp.Body, head); err != nil {
+		close(release)
+		t.Fatalf("failed to read streamed part: %v", err)
+	}
+	close(release)
+	tail, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read rest of body: %v", err)
+	}
+
+	if got := string(head) + string(tail); got != first+rest {
+		t.Errorf("expected full body, got %d bytes", len(got))
+	}
+	if resp.Header.Get("X-Cache") != CacheBypass {
+		t.Errorf("expected X-Cache BYPASS, got %q", resp.Header.Get("X-Cache"))
+	}
+}
@@ -2,10 +2,12 @@ package proxy
 
 import (
 	"Aegis/internal/cache"
+	"Aegis/internal/utils"
 	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -147,6 +149,89 @@ func TestProxyStatsHandler(t *testing.T) {
 	}
 }
 
+func TestProxyMaxEntriesEvictions(t *testing.T) {
+	upstream := okUpstream(t)
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, nil, WithMaxEntries(2))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	for _, path := range []string{"/1", "/2", "/3", "/4"} {
+		p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	rec := httptest.NewRecorder()
+	p.StatsHandler(rec, httptest.NewRequest("GET", "/stats", nil))
+	var stats map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("failed to parse stats JSON: %v", err)
+	}
+	if stats["cache_size"].(float64) != 2 || stats["evictions"].(float64) != 2 {
+		t.Errorf("expected cache_size 2 and evictions 2, got %v and %v", stats["cache_size"], stats["evictions"])
+	}
+	if _, ok := p.cache.Get("GET /4?"); !ok {
+		t.Error("expected the newest entry to be cached")
+	}
+}
+
+func TestProxyStatsText(t *testing.T) {
+	upstream := okUpstream(t)
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, nil, WithHealthCheck(HealthCheck{Interval: time.Hour}))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	defer p.Close()
+	p.cache.Set("key1", cache.Response{Body: []byte("test")})
+
+	rec := httptest.NewRecorder()
+	p.StatsHandler(rec, httptest.NewRequest("GET", "/stats?format=text", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("expected text/plain, got %q", ct)
+	}
+
+	values := make(map[string]float64)
+	for _, line := range strings.Split(strings.TrimSpace(rec.Body.String()), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			t.Fatalf("expected a \"key value\" line, got %q", line)
+		}
+		v, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			t.Fatalf("unparseable value in %q: %v", line, err)
+		}
+		values[fields[0]] = v
+	}
+	for key, want := range map[string]float64{"cache_size": 1, "hit_ratio": 0, "hit_ratio_5m": 0, "upstream_healthy": 1} {
+		if got, ok := values[key]; !ok || got != want {
+			t.Errorf("expected %s %v, got %v (present=%v)", key, want, got, ok)
+		}
+	}
+}
+
+func TestProxyStatsMemoryRecompute(t *testing.T) {
+	p, err := New("http://example.com", 5*time.Second, 0, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	p.cache.Set("key1", cache.Response{Header: http.Header{"Content-Type": {"text/plain"}}, Body: []byte("test")})
+	p.cache.Set("key2", cache.Response{Body: []byte("test2")})
+	p.cache.Set("key1", cache.Response{Body: []byte("replaced")})
+
+	memoryBytes := func(target string) float64 {
+		rec := httptest.NewRecorder()
+		p.StatsHandler(rec, httptest.NewRequest("GET", target, nil))
+		var stats map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil {
+			t.Fatalf("failed to parse stats JSON: %v", err)
+		}
+		return stats["memory_bytes"].(float64)
+	}
+
+	fast, full := memoryBytes("/stats"), memoryBytes("/stats?recompute=true")
+	if fast != full {
+		t.Errorf("expected fast memory_bytes %v to match recomputed %v", fast, full)
+	}
+}
+
 func TestProxyTimeout(t *testing.T) {
 	// Upstream that delays
 	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -179,7 +264,8 @@ func TestProxyCacheWithTTL(t *testing.T) {
 	defer upstream.Close()
 
 	// Proxy with 100ms TTL
-	p, err := New(upstream.URL, 5*time.Second, 100*time.Millisecond, nil, nil)
+	clock := utils.NewFakeClock(time.Now())
+	p, err := New(upstream.URL, 5*time.Second, 100*time.Millisecond, nil, nil, WithClock(clock))
 	if err != nil {
 		t.Fatalf("failed to create proxy: %v", err)
 	}
@@ -193,11 +279,16 @@ func TestProxyCacheWithTTL(t *testing.T) {
 		t.Errorf("expected status 200, got %d", rec1.Code)
 	}
 
-	// Wait for TTL to expire
-	time.Sleep(150 * time.Millisecond)
+	cacheKey := p.cacheKey(req1)
+	clock.Advance(99 * time.Millisecond)
+	if _, ok := p.cache.Get(cacheKey); !ok {
+		t.Error("expected cache entry to be fresh before TTL")
+	}
+
+	// Let the TTL expire
+	clock.Advance(2 * time.Millisecond)
 
 	// Cache entry should be expired
-	cacheKey := p.cacheKey(req1)
 	if _, ok := p.cache.Get(cacheKey); ok {
 		t.Error("expected cache entry to be expired")
 	}
@@ -336,3 +427,27 @@ func TestFullProxyFlow(t *testing.T) {
 		t.Error("expected at least 1 item in cache")
 	}
 }
+
+func TestProxyTransportConfig(t *testing.T) {
+	p, err := New("http://example.com", 5*time.Second, 0, nil, nil,
+		WithIdleConnTimeout(15*time.Second), WithDisableKeepAlives(true))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	transport := p.client.Transport.(*http.Transport)
+	if transport.IdleConnTimeout != 15*time.Second {
+		t.Errorf("expected IdleConnTimeout 15s, got %s", transport.IdleConnTimeout)
+	}
+	if !transport.DisableKeepAlives {
+		t.Error("expected DisableKeepAlives to be true")
+	}
+
+	// Defaults are kept without options
+	p, _ = New("http://example.com", 5*time.Second, 0, nil, nil)
+	transport = p.client.Transport.(*http.Transport)
+	if transport.IdleConnTimeout != 90*time.Second || transport.DisableKeepAlives {
+		t.Errorf("unexpected default transport settings: idle=%s keepalives-disabled=%v",
+			transport.IdleConnTimeout, transport.DisableKeepAlives)
+	}
+}
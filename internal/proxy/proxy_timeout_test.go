@@ -0,0 +1,97 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// slowUpstream answers after delay, or sends headers at once and the body
+// after delay when slowBody is set
+func slowUpstream(t *testing.T, delay time.Duration, slowBody bool) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if slowBody {
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+		}
+		time.Sleep(delay)
+		w.Write([]byte("slow"))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestTimeoutSingleDeadline(t *testing.T) {
+	upstream := slowUpstream(t, 100*time.Millisecond, false)
+
+	tests := []struct {
+		name     string
+		timeout  time.Duration
+		header   string
+		expected int
+	}{
+		{"global timeout above latency", time.Second, "", http.StatusOK},
+		{"request timeout above latency", time.Second, "0.5", http.StatusOK},
+		{"request timeout capped by global", 300 * time.Millisecond, "10", http.StatusOK},
+		{"no global timeout", 0, "", http.StatusOK},
+		{"request timeout without global", 0, "0.02", http.StatusBadGateway},
+		{"global timeout below latency", 20 * time.Millisecond, "", http.StatusBadGateway},
+	}
+
+	for _, tt := range tests {
+		p, err := New(upstream.URL, tt.timeout, 0, nil, nil, WithRequestTimeoutHeader(true))
+		if err != nil {
+			t.Fatalf("failed to create proxy: %v", err)
+		}
+		if p.client.Timeout != 0 {
+			t.Fatalf("expected no client timeout, got %v", p.client.Timeout)
+		}
+
+		req := httptest.NewRequest("POST", "/slow", nil)
+		if tt.header != "" {
+			req.Header.Set(RequestTimeoutHeader, tt.header)
+		}
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, req)
+
+		if rec.Code != tt.expected {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.expected, rec.Code)
+		}
+	}
+}
+
+func TestTimeoutCoversBodyRead(t *testing.T) {
+	upstream := slowUpstream(t, 300*time.Millisecond, true)
+
+	p, err := New(upstream.URL, 50*time.Millisecond, 0, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	start := time.Now()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/slow-body", nil))
+
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("expected 502 when the body outlives the deadline, got %d", rec.Code)
+	}
+	if elapsed := time.Since(start); elapsed > 250*time.Millisecond {
+		t.Errorf("expected request to give up near 50ms, took %v", elapsed)
+	}
+
+	// A passed-through body has sent its headers: the connection is aborted
+	start = time.Now()
+	func() {
+		defer func() {
+			if v := recover(); v != http.ErrAbortHandler {
+				t.Errorf("expected the pass-through to abort, got %v", v)
+			}
+		}()
+		p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/slow-body", nil))
+	}()
+	if elapsed := time.Since(start); elapsed > 250*time.Millisecond {
+		t.Errorf("expected pass-through to give up near 50ms, took %v", elapsed)
+	}
+}
@@ -0,0 +1,116 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func transformUpstream(t *testing.T) *httptest.Server {
+	t.Helper()
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/json" {
+			w.Header().Set("Content-Type", "application/json")
+		} else {
+			w.Header().Set("Content-Type", "text/plain")
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("hello world"))
+	}))
+	t.Cleanup(upstream.Close)
+	return upstream
+}
+
+func TestTransformCommand(t *testing.T) {
+	upstream := transformUpstream(t)
+	p, _ := New(upstream.URL, 5*time.Second, 0, nil, nil, WithTransform(Transform{
+		Command:      []string{"tr", "a-z", "A-Z"},
+		ContentTypes: []string{"text/"},
+	}))
+
+	req := httptest.NewRequest("GET", "/text", nil)
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, req)
+
+	if rec.Body.String() != "HELLO WORLD" {
+		t.Errorf("expected transformed body, got %q", rec.Body.String())
+	}
+	entry, _ := p.cache.Get(p.cacheKey(req))
+	if string(entry.Body) != "HELLO WORLD" {
+		t.Errorf("expected transformed body to be cached, got %q", entry.Body)
+	}
+
+	// Non-matching content type is left alone
+	rec = httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/json", nil))
+	if rec.Body.String() != "hello world" {
+		t.Errorf("expected untransformed JSON body, got %q", rec.Body.String())
+	}
+}
+
+func TestTransformFallbackToOriginal(t *testing.T) {
+	upstream := transformUpstream(t)
+
+	tests := []struct {
+		name      string
+		transform Transform
+	}{
+		{"failing", Transform{Command: []string{"false"}}},
+		{"timeout", Transform{Command: []string{"sleep", "5"}, Timeout: 50 * time.Millisecond}},
+		{"missing", Transform{Command: []string{"/nonexistent/transform"}}},
+		{"too-large", Transform{Command: []string{"yes"}, MaxBytes: 64, Timeout: 200 * time.Millisecond}},
+	}
+
+	for _, tt := range tests {
+		p, _ := New(upstream.URL, 5*time.Second, 0, nil, nil, WithTransform(tt.transform))
+
+		start := time.Now()
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest("GET", "/text", nil))
+
+		if rec.Body.String() != "hello world" {
+			t.Errorf("%s: expected original body, got %q", tt.name, rec.Body.String())
+		}
+		if time.Since(start) > 2*time.Second {
+			t.Errorf("%s: transform was not bounded by its timeout", tt.name)
+		}
+	}
+}
+
+func TestTransformSkippedForNoTransform(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		if r.URL.Path == "/no-transform" {
+			w.Header().Set("Cache-Control", "public, no-transform, max-age=60")
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("hello world"))
+	}))
+	defer upstream.Close()
+
+	p, _ := New(upstream.URL, 5*time.Second, 0, nil, nil, WithTransform(Transform{
+		Command: []string{"tr", "a-z", "A-Z"},
+	}))
+
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"/no-transform", "hello world"},
+		{"/plain", "HELLO WORLD"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, req)
+		if rec.Body.String() != tt.expected {
+			t.Errorf("%s: expected body %q, got %q", tt.path, tt.expected, rec.Body.String())
+		}
+		entry, _ := p.cache.Get(p.cacheKey(req))
+		if string(entry.Body) != tt.expected {
+			t.Errorf("%s: expected cached body %q, got %q", tt.path, tt.expected, entry.Body)
+		}
+	}
+}
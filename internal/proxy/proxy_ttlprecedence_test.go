@@ -0,0 +1,144 @@
+package proxy
+
+import (
+	"Aegis/internal/utils"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTTLPrecedence(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// Every signal disagrees with the others
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age=120, s-maxage=300")
+		w.Header().Set("Date", now.Format(http.TimeFormat))
+		w.Header().Set("Expires", now.Add(10*time.Minute).Format(http.TimeFormat))
+		if r.URL.Query().Get("custom") != "" {
+			w.Header().Set(CacheTTLHeader, r.URL.Query().Get("custom"))
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer upstream.Close()
+
+	tests := []struct {
+		name       string
+		precedence []string
+		query      string
+		want       time.Duration
+	}{
+		{"unset uses default", nil, "", time.Hour},
+		{"custom first", []string{"x-cache-ttl", "cache-control", "expires", "default"}, "custom=30", 30 * time.Second},
+		{"custom missing falls through", []string{"x-cache-ttl", "cache-control", "expires", "default"}, "", 5 * time.Minute},
+		{"custom malformed falls through", []string{"x-cache-ttl", "expires", "default"}, "custom=soon", 10 * time.Minute},
+		{"expires before cache-control", []string{"expires", "cache-control"}, "", 10 * time.Minute},
+		{"default before headers", []string{"default", "cache-control"}, "custom=30", time.Hour},
+		{"default closes the list", []string{"x-cache-ttl"}, "", time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := New(upstream.URL, 5*time.Second, time.Hour, nil, nil,
+				WithClock(utils.NewFakeClock(now)), WithTTLPrecedence(tt.precedence))
+			if err != nil {
+				t.Fatalf("failed to create proxy: %v", err)
+			}
+			p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/page?"+tt.query, nil))
+			if ttl := storedTTL(t, p, "GET /page?"+tt.query); ttl != tt.want {
+				t.Errorf("expected TTL %s, got %s", tt.want, ttl)
+			}
+		})
+	}
+}
+
+func TestCacheControlSignalUsesMaxAge(t *testing.T) {
+	p, _ := New("http://example.com", 0, time.Hour, nil, nil)
+	for header, want := range map[string]time.Duration{
+		"max-age=60":             time.Minute,
+		`private, max-age="90"`:  90 * time.Second,
+		"s-maxage=0, max-age=30": 30 * time.Second,
+	} {
+		h := http.Header{"Cache-Control": {header}}
+		if ttl, ok := p.signalTTL(TTLSignalCacheControl, h); !ok || ttl != want {
+			t.Errorf("%q: expected %s, got %s (ok=%v)", header, want, ttl, ok)
+		}
+	}
+	if _, ok := p.signalTTL(TTLSignalCacheControl, http.Header{"Cache-Control": {"max-age=0"}}); ok {
+		t.Error("expected max-age=0 to be skipped")
+	}
+}
+
+func TestRespectOriginTTL(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cc := r.URL.Query().Get("cc"); cc != "" {
+			w.Header().Set("Cache-Control", cc)
+		}
+		if r.URL.Query().Get("expires") != "" {
+			w.Header().Set("Date", now.Format(http.TimeFormat))
+			w.Header().Set("Expires", now.Add(20*time.Minute).Format(http.TimeFormat))
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer upstream.Close()
+
+	tests := []struct {
+		name    string
+		ttl     time.Duration
+		respect bool
+		query   string
+		want    time.Duration
+	}{
+		{"max-age below ttl", time.Hour, true, "cc=max-age%3D300", 5 * time.Minute},
+		{"max-age capped by ttl", time.Hour, true, "cc=max-age%3D86400", time.Hour},
+		{"expires without max-age", time.Hour, true, "expires=1", 20 * time.Minute},
+		{"max-age before expires", time.Hour, true, "cc=max-age%3D60&expires=1", time.Minute},
+		{"silent origin uses ttl", time.Hour, true, "", time.Hour},
+		{"no ttl, no cap", 0, true, "cc=max-age%3D86400", 24 * time.Hour},
+		{"disabled ignores max-age", time.Hour, false, "cc=max-age%3D300", time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := New(upstream.URL, 5*time.Second, tt.ttl, nil, nil,
+				WithClock(utils.NewFakeClock(now)), WithRespectOriginTTL(tt.respect))
+			if err != nil {
+				t.Fatalf("failed to create proxy: %v", err)
+			}
+			p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api?"+tt.query, nil))
+			if ttl := storedTTL(t, p, "GET /api?"+tt.query); ttl != tt.want {
+				t.Errorf("expected TTL %s, got %s", tt.want, ttl)
+			}
+		})
+	}
+}
+
+func TestTTLRules(t *testing.T) {
+	upstream := okUpstream(t)
+	p, err := New(upstream.URL, 5*time.Second, 5*time.Minute, nil, nil,
+		WithClock(utils.NewFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))),
+		WithTTLRules([]TTLRule{
+			{Path: "/static/", TTL: time.Hour},
+			{Path: "/api/*", TTL: 10 * time.Second},
+			{Path: "/static/", TTL: time.Minute}, // shadowed by the first rule
+		}))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+
+	tests := []struct {
+		path string
+		want time.Duration
+	}{
+		{"/static/app.js", time.Hour},
+		{"/api/users", 10 * time.Second},
+		{"/api/users/1", 5 * time.Minute}, // * does not cross a slash
+		{"/other", 5 * time.Minute},
+	}
+	for _, tt := range tests {
+		p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", tt.path, nil))
+		if ttl := storedTTL(t, p, "GET "+tt.path+"?"); ttl != tt.want {
+			t.Errorf("%s: expected TTL %s, got %s", tt.path, tt.want, ttl)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRoundRobinUpstreams(t *testing.T) {
+	var hits [3]atomic.Int64
+	urls := make([]string, len(hits))
+	for i := range hits {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits[i].Add(1)
+			w.Write([]byte("ok"))
+		}))
+		defer srv.Close()
+		urls[i] = srv.URL
+	}
+
+	p, err := New(urls[0], 5*time.Second, time.Minute, nil, nil, WithExtraUpstreams(urls[1:]))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	for range 30 {
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest("GET", "/data", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", rec.Code)
+		}
+	}
+	for i := range hits {
+		if n := hits[i].Load(); n != 10 {
+			t.Errorf("expected upstream %d to receive 10 requests, got %d", i, n)
+		}
+	}
+}
+
+func TestExtraUpstreamMustBeAbsolute(t *testing.T) {
+	if _, err := New("http://a.example", 0, 0, nil, nil, WithExtraUpstreams([]string{"b.example"})); err == nil {
+		t.Error("expected an error for an upstream without scheme")
+	}
+}
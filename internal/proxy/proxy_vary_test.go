@@ -0,0 +1,131 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestVaryReflectedOnMissAndHit(t *testing.T) {
+	var failing atomic.Bool
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Vary", "Accept-Encoding")
+		w.Header().Set("Connection", "close")
+		w.Write([]byte("ok"))
+	}))
+	defer upstream.Close()
+
+	tests := []struct {
+		name string
+		opts []Option
+		want []string
+	}{
+		{"upstream only", nil, []string{"Accept-Encoding"}},
+		{"with key headers", []Option{WithVaryKeyHeaders(true), WithCountryKey("X-Country", "ZZ")},
+			[]string{"Accept-Encoding", "X-Tenant", "X-Country"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := New(upstream.URL, 5*time.Second, time.Minute, []string{"x-tenant"}, nil, tt.opts...)
+			if err != nil {
+				t.Fatalf("failed to create proxy: %v", err)
+			}
+			failing.Store(false)
+			for _, want := range []string{CacheMiss, CacheHitBackup} {
+				rec := httptest.NewRecorder()
+				p.ServeHTTP(rec, httptest.NewRequest("GET", "/page", nil))
+				if got := rec.Header().Get("X-Cache"); got != want {
+					t.Fatalf("expected %s, got %s", want, got)
+				}
+				if vary := rec.Header().Values("Vary"); !slices.Equal(vary, tt.want) {
+					t.Errorf("%s: expected Vary %v, got %v", want, tt.want, vary)
+				}
+				failing.Store(true)
+			}
+		})
+	}
+}
+
+func TestReflectVaryKeepsWildcard(t *testing.T) {
+	p, _ := New("http://example.com", 0, 0, []string{"X-Tenant"}, nil, WithVaryKeyHeaders(true))
+	h := http.Header{"Vary": {"*"}}
+	p.reflectVary(h)
+	if vary := h.Values("Vary"); !slices.Equal(vary, []string{"*"}) {
+		t.Errorf("expected Vary * unchanged, got %v", vary)
+	}
+}
+
+func TestVaryAcceptEncodingSeparatesEntries(t *testing.T) {
+	var failing atomic.Bool
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Vary", "Accept-Encoding")
+		if r.Header.Get("Accept-Encoding") == "gzip" {
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write([]byte("gzipped"))
+			return
+		}
+		w.Write([]byte("identity"))
+	}))
+	defer upstream.Close()
+
+	p, err := New(upstream.URL, 5*time.Second, time.Minute, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	get := func(encoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/asset", nil)
+		if encoding != "" {
+			req.Header.Set("Accept-Encoding", encoding)
+		}
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, req)
+		return rec
+	}
+	get("gzip")
+	get("identity")
+
+	// Marker plus one entry per encoding
+	if p.cache.Size() != 3 {
+		t.Errorf("expected 3 cache entries, got %d", p.cache.Size())
+	}
+
+	failing.Store(true)
+	for encoding, want := range map[string]string{"gzip": "gzipped", "identity": "identity"} {
+		rec := get(encoding)
+		if rec.Header().Get("X-Cache") != CacheHitBackup || rec.Body.String() != want {
+			t.Errorf("%s: expected backup %q, got %s %q", encoding, want, rec.Header().Get("X-Cache"), rec.Body.String())
+		}
+	}
+	if rec := get(""); rec.Code != http.StatusBadGateway {
+		t.Errorf("expected no backup for a request without Accept-Encoding, got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestVaryStarNotCached(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Vary", "Accept-Encoding, *")
+		w.Write([]byte("ok"))
+	}))
+	defer upstream.Close()
+
+	p, err := New(upstream.URL, 5*time.Second, time.Minute, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/asset", nil))
+	if got := rec.Header().Get("X-Cache"); got != CachePass || p.cache.Size() != 0 {
+		t.Errorf("expected PASS and nothing cached, got %s with %d entries", got, p.cache.Size())
+	}
+}
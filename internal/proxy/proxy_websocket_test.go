@@ -0,0 +1,166 @@
+package proxy
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// echoWebSocketUpstream completes an upgrade handshake to the requested
+// protocol and echoes raw bytes
+func echoWebSocketUpstream(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isUpgrade(r) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		conn, brw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: " + r.Header.Get("Upgrade") + "\r\nConnection: Upgrade\r\n\r\n")
+		brw.Flush()
+		_, _ = io.Copy(conn, brw)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// dialUpgrade performs the WebSocket upgrade handshake against the proxy
+func dialUpgrade(t *testing.T, addr string) (net.Conn, *bufio.Reader) {
+	t.Helper()
+	return dialUpgradeTo(t, addr, "websocket")
+}
+
+// dialUpgradeTo performs an upgrade handshake to protocol against the proxy
+func dialUpgradeTo(t *testing.T, addr, protocol string) (net.Conn, *bufio.Reader) {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("dial proxy: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.Write([]byte("GET /socket HTTP/1.1\r\nHost: aegis\r\nConnection: Upgrade\r\nUpgrade: " + protocol + "\r\n\r\n"))
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatalf("read handshake: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expected 101, got %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get("X-Cache"); got != CacheBypass {
+		t.Errorf("expected X-Cache BYPASS on upgrade, got %q", got)
+	}
+	return conn, br
+}
+
+func TestWebSocketOutlivesRequestTimeout(t *testing.T) {
+	upstream := echoWebSocketUpstream(t)
+	p, err := New(upstream.URL, 50*time.Millisecond, 0, nil, nil, WithWebSocketIdleTimeout(time.Second))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	front := httptest.NewServer(p)
+	defer front.Close()
+
+	conn, br := dialUpgrade(t, front.Listener.Addr().String())
+
+	// Stay connected well past the 50ms REST timeout, with traffic
+	for i := 0; i < 3; i++ {
+		time.Sleep(100 * time.Millisecond)
+		conn.Write([]byte("ping\n"))
+		line, err := br.ReadString('\n')
+		if err != nil || line != "ping\n" {
+			t.Fatalf("round %d: expected echo, got %q (%v)", i, line, err)
+		}
+	}
+	if p.cache.Size() != 0 {
+		t.Errorf("expected upgrade not to be cached, got %d entries", p.cache.Size())
+	}
+}
+
+func TestUpgradeToOtherProtocol(t *testing.T) {
+	upstream := echoWebSocketUpstream(t)
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	front := httptest.NewServer(p)
+	defer front.Close()
+
+	conn, br := dialUpgradeTo(t, front.Listener.Addr().String(), "custom-tcp")
+	conn.Write([]byte("hello\n"))
+	if line, err := br.ReadString('\n'); err != nil || line != "hello\n" {
+		t.Errorf("expected echo, got %q (%v)", line, err)
+	}
+}
+
+func TestUpgradeBalancedAcrossUpstreams(t *testing.T) {
+	var hits [2]atomic.Int64
+	urls := make([]string, 2)
+	for i := range urls {
+		echo := echoWebSocketUpstream(t)
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits[i].Add(1)
+			echo.Config.Handler.ServeHTTP(w, r)
+		}))
+		defer srv.Close()
+		urls[i] = srv.URL
+	}
+	p, err := New(urls[0], 5*time.Second, 0, nil, nil, WithExtraUpstreams(urls[1:]))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	front := httptest.NewServer(p)
+	defer front.Close()
+
+	for range 2 {
+		dialUpgrade(t, front.Listener.Addr().String())
+	}
+	if hits[0].Load() != 1 || hits[1].Load() != 1 {
+		t.Errorf("expected one upgrade per upstream, got %d and %d", hits[0].Load(), hits[1].Load())
+	}
+}
+
+func TestWebSocketIdleTimeout(t *testing.T) {
+	upstream := echoWebSocketUpstream(t)
+	p, err := New(upstream.URL, 5*time.Second, 0, nil, nil, WithWebSocketIdleTimeout(100*time.Millisecond))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	front := httptest.NewServer(p)
+	defer front.Close()
+
+	conn, br := dialUpgrade(t, front.Listener.Addr().String())
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := br.ReadByte(); err == nil || strings.Contains(err.Error(), "timeout") {
+		t.Errorf("expected proxy to close the idle connection, got %v", err)
+	}
+}
+
+func TestRESTRequestStillTimesOut(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	p, err := New(upstream.URL, 50*time.Millisecond, 0, nil, nil, WithWebSocketIdleTimeout(time.Second))
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/slow", nil))
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("expected 502 after REST timeout, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,109 @@
+package proxy
+
+import (
+	"Aegis/internal/cache"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// purgeResponse is the JSON document returned by /purge
+type purgeResponse struct {
+	Purged bool   `json:"purged"`
+	Key    string `json:"key,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// PurgeHandler removes one cached entry. The entry is named either by the
+// request it was cached for (method, default GET, and url, a path with
+// optional query; host overrides the Host and key headers are taken from
+// the purge request itself) or by a raw cache key as composed before
+// hashing. It answers 404 when no such entry is cached.
+func (p *Proxy) PurgeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		w.Header().Set("Allow", "POST, DELETE")
+		writePurgeResponse(w, http.StatusMethodNotAllowed, purgeResponse{Error: "method not allowed"})
+		return
+	}
+
+	key, problem := p.purgeKey(r)
+	if problem != "" {
+		writePurgeResponse(w, http.StatusBadRequest, purgeResponse{Error: problem})
+		return
+	}
+	key = p.hashKey(key)
+
+	// Expired entries still served as STALE count as present
+	var ok bool
+	var ferr error
+	if sf, stale := p.store.(cache.StaleFetcher); stale {
+		_, ok, ferr = sf.FetchStale(key)
+	} else {
+		_, ok, ferr = p.store.Fetch(key)
+	}
+	if derr := p.store.Delete(key); derr != nil {
+		ferr = derr
+	} else {
+		p.forgetHostEntry(key)
+	}
+	if ferr != nil {
+		if p.logger != nil {
+			p.logger.Error("cache backend purge failed: key=%s err=%v", key, ferr)
+		}
+		writePurgeResponse(w, http.StatusServiceUnavailable, purgeResponse{Key: key, Error: "cache backend error"})
+		return
+	}
+	if !ok {
+		writePurgeResponse(w, http.StatusNotFound, purgeResponse{Key: key})
+		return
+	}
+
+	p.emitEvent(EventPurge, key, 0, 0)
+	if p.logger != nil {
+		p.logger.Info("purged cache entry: key=%s", key)
+	}
+	writePurgeResponse(w, http.StatusOK, purgeResponse{Purged: true, Key: key})
+}
+
+// purgeKey composes the cache key named by a purge request, or returns
+// what is wrong with the request
+func (p *Proxy) purgeKey(r *http.Request) (string, string) {
+	if key := r.FormValue("key"); key != "" {
+		return key, ""
+	}
+	target := r.FormValue("url")
+	if !strings.HasPrefix(target, "/") {
+		return "", "expected key or url starting with /"
+	}
+	method := r.FormValue("method")
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	cached, err := http.NewRequest(method, target, nil)
+	if err != nil {
+		return "", "invalid url: " + err.Error()
+	}
+	// Normalized as ServeHTTP does before composing the key
+	p.normalizeMethod(cached)
+	if cached.Method != http.MethodGet && cached.Method != http.MethodHead {
+		return "", "only GET and HEAD entries are cached"
+	}
+	if p.canonicalPath {
+		p.canonicalizePath(cached)
+	}
+	cached.Header = r.Header
+	cached.RemoteAddr = r.RemoteAddr
+	cached.TLS = r.TLS
+	cached.Host = r.Host
+	if host := r.FormValue("host"); host != "" {
+		cached.Host = host
+	}
+	return p.cacheKey(cached), ""
+}
+
+func writePurgeResponse(w http.ResponseWriter, status int, resp purgeResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(resp)
+}
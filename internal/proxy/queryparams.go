@@ -0,0 +1,44 @@
+package proxy
+
+import (
+	"net/url"
+	"strings"
+)
+
+// AnalyticsQueryParams are marketing/tracking parameters that never change
+// the response. A trailing "*" matches any parameter with that prefix.
+var AnalyticsQueryParams = []string{"utm_*", "gclid", "fbclid", "msclkid", "dclid", "mc_cid", "mc_eid", "_ga"}
+
+// keyQuery returns the raw query used in the cache key: the request query
+// without ignored parameters. The upstream request keeps them.
+func (p *Proxy) keyQuery(rawQuery string) string {
+	if len(p.ignoreParams) == 0 || rawQuery == "" {
+		return rawQuery
+	}
+	parts := strings.Split(rawQuery, "&")
+	kept := parts[:0]
+	for _, part := range parts {
+		name, _, _ := strings.Cut(part, "=")
+		if decoded, err := url.QueryUnescape(name); err == nil {
+			name = decoded
+		}
+		if !p.ignoredParam(name) {
+			kept = append(kept, part)
+		}
+	}
+	return strings.Join(kept, "&")
+}
+
+// ignoredParam reports whether a query parameter is left out of the key
+func (p *Proxy) ignoredParam(name string) bool {
+	for _, pattern := range p.ignoreParams {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == pattern {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package proxy
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimit configures request rate limiting. Paths override the global
+// limit for matching path prefixes, the longest prefix wins.
+type RateLimit struct {
+	Rate  float64 // Requests per second (0 = unlimited)
+	Burst int     // Requests allowed at once (min 1)
+	Paths []PathRateLimit
+}
+
+// PathRateLimit is a rate limit for requests under a path prefix
+type PathRateLimit struct {
+	Prefix string
+	Rate   float64 // Requests per second (0 = unlimited)
+	Burst  int
+}
+
+// tokenBucket allows rate events per second with bursts of up to burst
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst)}
+}
+
+// allow takes a token if one is available at now
+func (b *tokenBucket) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.last.IsZero() {
+		b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// pathBucket is the bucket of one path prefix; a nil bucket means unlimited
+type pathBucket struct {
+	prefix string
+	bucket *tokenBucket
+}
+
+// rateLimiter picks the bucket for a request path
+type rateLimiter struct {
+	global *tokenBucket
+	paths  []pathBucket // Longest prefix first
+}
+
+func newRateLimiter(cfg RateLimit) *rateLimiter {
+	l := &rateLimiter{}
+	if cfg.Rate > 0 {
+		l.global = newTokenBucket(cfg.Rate, cfg.Burst)
+	}
+	for _, pl := range cfg.Paths {
+		pb := pathBucket{prefix: pl.Prefix}
+		if pl.Rate > 0 {
+			pb.bucket = newTokenBucket(pl.Rate, pl.Burst)
+		}
+		l.paths = append(l.paths, pb)
+	}
+	sort.SliceStable(l.paths, func(i, j int) bool {
+		return len(l.paths[i].prefix) > len(l.paths[j].prefix)
+	})
+	return l
+}
+
+// allow reports whether a request to path is within its limit
+func (l *rateLimiter) allow(path string, now time.Time) bool {
+	bucket := l.global
+	for _, pb := range l.paths {
+		if strings.HasPrefix(path, pb.prefix) {
+			bucket = pb.bucket
+			break
+		}
+	}
+	return bucket == nil || bucket.allow(now)
+}
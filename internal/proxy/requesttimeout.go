@@ -0,0 +1,34 @@
+package proxy
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RequestTimeoutHeader carries the client's own deadline in seconds
+const RequestTimeoutHeader = "Request-Timeout"
+
+// requestTimeout returns the upstream timeout for r: the configured timeout,
+// shortened to the client's Request-Timeout when honored. Client values can
+// only shorten the deadline, never extend it. It is the single deadline of
+// the upstream exchange, including reading the response body (0 = none).
+func (p *Proxy) requestTimeout(r *http.Request) time.Duration {
+	timeout := p.timeout
+	if !p.honorRequestTimeout {
+		return timeout
+	}
+	v := r.Header.Get(RequestTimeoutHeader)
+	if v == "" {
+		return timeout
+	}
+	secs, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	if err != nil || secs <= 0 {
+		return timeout
+	}
+	if d := time.Duration(secs * float64(time.Second)); timeout <= 0 || d < timeout {
+		return d
+	}
+	return timeout
+}
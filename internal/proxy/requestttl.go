@@ -0,0 +1,30 @@
+package proxy
+
+import (
+	"Aegis/internal/utils"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TTLRequestHeader lets trusted callers set the cache TTL (in seconds) of
+// the response to their request
+const TTLRequestHeader = "X-Aegis-Cache-TTL"
+
+// requestTTL returns the TTL requested via TTLRequestHeader. The header is
+// honored only when enabled and sent from a trusted proxy address.
+func (p *Proxy) requestTTL(r *http.Request) (time.Duration, bool) {
+	v := r.Header.Get(TTLRequestHeader)
+	if v == "" || !p.allowTTLHeader || !utils.RemoteAddrInNets(r.RemoteAddr, p.trustedProxies) {
+		return 0, false
+	}
+	secs, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil || secs < 0 {
+		if p.logger != nil {
+			p.logger.Debug("ignoring invalid %s: %q", TTLRequestHeader, v)
+		}
+		return 0, false
+	}
+	return time.Duration(secs) * time.Second, true
+}
@@ -0,0 +1,83 @@
+package proxy
+
+import (
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// DefaultRetryBackoff is the delay before the first upstream retry
+const DefaultRetryBackoff = 100 * time.Millisecond
+
+// maxRetryDrain caps how much of a failed response is read so its
+// connection can be reused for the retry
+const maxRetryDrain = 64 * 1024
+
+// mayRetry reports whether r may be sent upstream more than once: GET and
+// HEAD requests without a body to replay
+func (p *Proxy) mayRetry(r *http.Request) bool {
+	if p.retries <= 0 || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+		return false
+	}
+	return r.Body == nil || r.Body == http.NoBody
+}
+
+// retryable reports whether an upstream attempt failed in a way a retry
+// may fix: a transport error or a failover status
+func (p *Proxy) retryable(resp *http.Response, err error) bool {
+	return err != nil || statusIn(p.failoverStatus, resp.StatusCode)
+}
+
+// retryDelay returns the backoff before retry attempt n (from 1): the
+// base backoff doubled per attempt, with the upper half randomized
+func (p *Proxy) retryDelay(n int) time.Duration {
+	d := p.retryBackoff << (n - 1)
+	return d/2 + rand.N(d/2+1)
+}
+
+// sendUpstream sends req upstream and, for requests allowed by mayRetry,
+// retries failed attempts up to the configured number of times, each
+// against the next upstream in the pool. Retries stop when the request
+// deadline would pass during the backoff or the circuit breaker opens;
+// the last outcome is returned either way.
+func (p *Proxy) sendUpstream(r *http.Request, req *http.Request) (*http.Response, error) {
+	resp, err := p.client.Do(req)
+	if !p.mayRetry(r) {
+		return resp, err
+	}
+	ctx := req.Context()
+	for n := 1; n <= p.retries && p.retryable(resp, err); n++ {
+		delay := p.retryDelay(n)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			break
+		}
+		if p.breaker != nil && p.breaker.Open() {
+			break
+		}
+
+		p.recordUpstreamResult(false)
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxRetryDrain))
+			resp.Body.Close()
+		}
+		if p.logger != nil {
+			p.logger.Debug("retrying upstream request in %s: %s %s attempt=%d", delay, r.Method, r.URL.Path, n+1)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+
+		retry := req.Clone(ctx)
+		if u, uerr := p.resolveUpstream(r.URL.EscapedPath(), r.URL.RawQuery); uerr == nil && p.hostAllowed(u) {
+			retry.URL = u
+			retry.Host = u.Host
+		}
+		resp, err = p.client.Do(retry)
+	}
+	return resp, err
+}
@@ -0,0 +1,67 @@
+package proxy
+
+import "sync"
+
+// defaultRevalidationsPerKey is used when no per-key limit is configured
+const defaultRevalidationsPerKey = 1
+
+// revalidations counts running background refreshes per cache key, so a
+// popular stale key triggers one refresh instead of one per request
+type revalidations struct {
+	mu      sync.Mutex
+	max     int
+	running map[string]int
+}
+
+func newRevalidations(max int) *revalidations {
+	if max <= 0 {
+		max = defaultRevalidationsPerKey
+	}
+	return &revalidations{max: max, running: make(map[string]int)}
+}
+
+// acquire reserves a refresh slot for key, reporting false when max
+// refreshes of key are already running
+func (rv *revalidations) acquire(key string) bool {
+	rv.mu.Lock()
+	defer rv.mu.Unlock()
+	if rv.running[key] >= rv.max {
+		return false
+	}
+	rv.running[key]++
+	return true
+}
+
+// release frees a slot taken by acquire
+func (rv *revalidations) release(key string) {
+	rv.mu.Lock()
+	defer rv.mu.Unlock()
+	if rv.running[key] <= 1 {
+		delete(rv.running, key)
+		return
+	}
+	rv.running[key]--
+}
+
+// revalidate runs fn on the background pool to refresh key. Triggers
+// beyond the per-key limit are dropped while earlier refreshes of key are
+// still running; it reports whether fn was queued.
+func (p *Proxy) revalidate(key string, fn func()) bool {
+	if !p.revalidations.acquire(key) {
+		if p.logger != nil {
+			p.logger.Debug("revalidation already running, dropping trigger: key=%s", key)
+		}
+		return false
+	}
+	queued := p.background.submit(func() {
+		defer p.revalidations.release(key)
+		fn()
+	})
+	if !queued {
+		p.revalidations.release(key)
+		if p.logger != nil {
+			p.logger.Error("background queue full, dropping revalidation: key=%s", key)
+		}
+	}
+	return queued
+}
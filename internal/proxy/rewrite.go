@@ -0,0 +1,93 @@
+package proxy
+
+import (
+	"Aegis/internal/utils"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// RewriteRule rewrites the upstream request URI (path plus "?query")
+// using a regular expression with capture-group substitution.
+// A replacement yielding an absolute URL sends the request to that host,
+// subject to the upstream host allowlist.
+type RewriteRule struct {
+	Match   string // Regular expression matched against the request URI
+	Replace string // Replacement, may reference capture groups ($1, ${name})
+}
+
+type compiledRewrite struct {
+	re      *regexp.Regexp
+	replace string
+}
+
+func compileRewrites(rules []RewriteRule) ([]compiledRewrite, error) {
+	compiled := make([]compiledRewrite, 0, len(rules))
+	for i, rule := range rules {
+		re, err := regexp.Compile(rule.Match)
+		if err != nil {
+			return nil, fmt.Errorf("rewrite rule %d: %w", i, err)
+		}
+		compiled = append(compiled, compiledRewrite{re: re, replace: rule.Replace})
+	}
+	return compiled, nil
+}
+
+// rewriteURI applies the first matching rule to the request URI.
+// Requests not matching any rule pass through unchanged.
+func (p *Proxy) rewriteURI(uri string) string {
+	for _, rw := range p.rewrites {
+		if !rw.re.MatchString(uri) {
+			continue
+		}
+		out := rw.re.ReplaceAllString(uri, rw.replace)
+		if p.logger != nil {
+			p.logger.Debug("rewrite: %s -> %s", uri, out)
+		}
+		return out
+	}
+	return uri
+}
+
+// resolveUpstream builds the final upstream URL for a request:
+// base upstream (the next in the pool) + (rewritten) path + query, or an
+// absolute rewrite target.
+// path is the escaped request path, so encodings such as %2F are preserved.
+func (p *Proxy) resolveUpstream(path, rawQuery string) (*url.URL, error) {
+	var err error
+	uri := path
+	if rawQuery != "" {
+		uri += "?" + rawQuery
+	}
+	uri = p.rewriteURI(uri)
+
+	if strings.HasPrefix(uri, "http://") || strings.HasPrefix(uri, "https://") {
+		u, err := url.Parse(uri)
+		if err != nil {
+			return nil, fmt.Errorf("parse rewritten url: %w", err)
+		}
+		return u, nil
+	}
+
+	newPath, newQuery, _ := strings.Cut(uri, "?")
+	upstream := p.pickUpstream()
+	u := *upstream
+	u.RawPath = utils.SingleSlashJoin(upstream.EscapedPath(), newPath)
+	if u.Path, err = url.PathUnescape(u.RawPath); err != nil {
+		return nil, fmt.Errorf("invalid upstream path: %w", err)
+	}
+	u.RawQuery = newQuery
+	return &u, nil
+}
+
+// hostAllowed checks the resolved upstream host against the allowlist.
+// Entries match either the bare hostname or host:port.
+func (p *Proxy) hostAllowed(u *url.URL) bool {
+	for _, h := range p.allowedHosts {
+		if strings.EqualFold(h, u.Host) || strings.EqualFold(h, u.Hostname()) {
+			return true
+		}
+	}
+	return false
+}
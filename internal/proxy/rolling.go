@@ -0,0 +1,76 @@
+package proxy
+
+import (
+	"sync"
+	"time"
+)
+
+// Sliding windows reported in /stats
+var hitRatioWindows = []struct {
+	name string
+	d    time.Duration
+}{
+	{"1m", time.Minute},
+	{"5m", 5 * time.Minute},
+	{"15m", 15 * time.Minute},
+}
+
+const (
+	rollingBucketSize  = 10 * time.Second
+	rollingBucketCount = 90 // 15 minutes
+)
+
+type rollingBucket struct {
+	index int64 // bucket number since the epoch
+	hits  int64
+	total int64
+}
+
+// rollingCounter tracks hits over a ring of time buckets
+type rollingCounter struct {
+	mu      sync.Mutex
+	buckets [rollingBucketCount]rollingBucket
+	now     func() time.Time
+}
+
+func newRollingCounter() *rollingCounter {
+	return &rollingCounter{now: time.Now}
+}
+
+func (c *rollingCounter) currentIndex() int64 {
+	return c.now().UnixNano() / int64(rollingBucketSize)
+}
+
+// Record counts a cacheable request, hit or not
+func (c *rollingCounter) Record(hit bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	idx := c.currentIndex()
+	b := &c.buckets[idx%rollingBucketCount]
+	if b.index != idx {
+		*b = rollingBucket{index: idx}
+	}
+	b.total++
+	if hit {
+		b.hits++
+	}
+}
+
+// Ratio returns the hit ratio over the trailing window
+func (c *rollingCounter) Ratio(window time.Duration) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	idx := c.currentIndex()
+	oldest := idx - int64(window/rollingBucketSize) + 1
+	var hits, total int64
+	for _, b := range c.buckets {
+		if b.index >= oldest && b.index <= idx {
+			hits += b.hits
+			total += b.total
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(hits) / float64(total)
+}
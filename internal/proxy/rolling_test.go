@@ -0,0 +1,86 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRollingCounterWindows(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := newRollingCounter()
+	c.now = func() time.Time { return now }
+
+	// 10 minutes ago: 4 misses
+	now = now.Add(-10 * time.Minute)
+	for i := 0; i < 4; i++ {
+		c.Record(false)
+	}
+
+	// 3 minutes ago: 1 hit, 1 miss
+	now = now.Add(7 * time.Minute)
+	c.Record(true)
+	c.Record(false)
+
+	// Now: 3 hits, 1 miss
+	now = now.Add(3 * time.Minute)
+	for i := 0; i < 3; i++ {
+		c.Record(true)
+	}
+	c.Record(false)
+
+	tests := []struct {
+		window   time.Duration
+		expected float64
+	}{
+		{time.Minute, 3.0 / 4},
+		{5 * time.Minute, 4.0 / 6},
+		{15 * time.Minute, 4.0 / 10},
+	}
+	for _, tt := range tests {
+		if got := c.Ratio(tt.window); got != tt.expected {
+			t.Errorf("Ratio(%s) = %v, expected %v", tt.window, got, tt.expected)
+		}
+	}
+
+	// Buckets older than the ring are dropped
+	now = now.Add(20 * time.Minute)
+	if got := c.Ratio(15 * time.Minute); got != 0 {
+		t.Errorf("expected empty window after 20m, got %v", got)
+	}
+}
+
+func TestStatsHitRatioWindow(t *testing.T) {
+	shouldFail := false
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if shouldFail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	p, _ := New(upstream.URL, 5*time.Second, 0, nil, nil)
+	p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/data", nil))
+	shouldFail = true
+	p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/data", nil))
+
+	rec := httptest.NewRecorder()
+	p.StatsHandler(rec, httptest.NewRequest("GET", "/stats", nil))
+
+	var stats statsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("failed to parse stats JSON: %v", err)
+	}
+	if stats.HitRatio != 0.5 {
+		t.Errorf("expected cumulative hit_ratio 0.5, got %v", stats.HitRatio)
+	}
+	for _, win := range []string{"1m", "5m", "15m"} {
+		if stats.HitRatioWindow[win] != 0.5 {
+			t.Errorf("expected %s hit ratio 0.5, got %v", win, stats.HitRatioWindow[win])
+		}
+	}
+}
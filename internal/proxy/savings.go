@@ -0,0 +1,32 @@
+package proxy
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// savings estimates the upstream load taken off by the cache
+type savings struct {
+	requests atomic.Int64 // Requests answered without waiting for upstream
+	bytes    atomic.Int64 // Cached body bytes upstream did not send
+}
+
+type savingsStats struct {
+	Requests int64 `json:"requests"`
+	Bytes    int64 `json:"bytes"`
+}
+
+// recordSavings counts a response served from cache with the given
+// status. HIT, HIT-BACKUP, HIT-NEGATIVE, STALE and COALESCED save a request and its body;
+// REVALIDATED still asks upstream but saves the body.
+func (p *Proxy) recordSavings(r *http.Request, status string, body []byte) {
+	if p.savings == nil {
+		return
+	}
+	if status != CacheRevalidated {
+		p.savings.requests.Add(1)
+	}
+	if r.Method != http.MethodHead {
+		p.savings.bytes.Add(int64(len(body)))
+	}
+}
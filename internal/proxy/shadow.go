@@ -0,0 +1,98 @@
+package proxy
+
+import (
+	"Aegis/internal/utils"
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Shadow configures mirroring of GET and HEAD requests to a second upstream.
+// Mirrored requests run in the background; their responses are discarded and
+// never affect the client response or the cache.
+type Shadow struct {
+	Upstream     string        // Base URL of the shadow upstream (empty = disabled)
+	SampleRate   float64       // Fraction of eligible requests mirrored (0..1)
+	PathPrefixes []string      // Only mirror these path prefixes (empty = all)
+	Sticky       bool          // Sample by request hash, so identical requests decide alike
+	Timeout      time.Duration // Timeout of a mirrored request
+
+	target *url.URL
+}
+
+// matches reports whether a request is eligible for mirroring
+func (s *Shadow) matches(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	return len(s.PathPrefixes) == 0 || hasAnyPrefix(r.URL.Path, s.PathPrefixes)
+}
+
+// sampled decides whether an eligible request is mirrored
+func (s *Shadow) sampled(r *http.Request) bool {
+	if s.SampleRate >= 1 {
+		return true
+	}
+	if s.SampleRate <= 0 {
+		return false
+	}
+	if s.Sticky {
+		h := utils.XXHash64([]byte(r.Method + " " + r.URL.RequestURI()))
+		return float64(h%10000)/10000 < s.SampleRate
+	}
+	return rand.Float64() < s.SampleRate
+}
+
+// mirror sends a copy of r to the shadow upstream in the background
+func (p *Proxy) mirror(r *http.Request) {
+	if !p.shadow.matches(r) || !p.shadow.sampled(r) {
+		return
+	}
+	target := *p.shadow.target
+	target.Path = utils.SingleSlashJoin(target.Path, r.URL.Path)
+	target.RawQuery = r.URL.RawQuery
+	header := make(http.Header, len(r.Header))
+	utils.CopyHeadersForUpstream(header, r.Header)
+	header.Del(TTLRequestHeader)
+	p.setForwardedHeaders(header, r)
+	method := r.Method
+
+	p.runBackground("shadow request", func() {
+		// Detached from the client request, which may finish first
+		ctx, cancel := context.WithTimeout(context.Background(), p.shadow.Timeout)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, method, target.String(), nil)
+		if err != nil {
+			return
+		}
+		req.Header = header
+		resp, err := p.client.Do(req)
+		if err != nil {
+			if p.logger != nil {
+				p.logger.Debug("shadow request failed: %s %s: %v", method, target.String(), err)
+			}
+			return
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if p.logger != nil {
+			p.logger.Debug("shadow response: %s %s -> %d", method, target.String(), resp.StatusCode)
+		}
+	})
+}
+
+// parseShadowTarget validates the shadow upstream URL
+func parseShadowTarget(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("parse shadow upstream: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("parse shadow upstream: %q is not an absolute http(s) URL", raw)
+	}
+	return u, nil
+}
@@ -0,0 +1,85 @@
+package proxy
+
+import (
+	"Aegis/internal/utils"
+	"context"
+	"net/http"
+)
+
+// serveStale answers r with an entry that expired less than staleWindow
+// ago and refreshes it in the background, at most once per key at a time.
+// It returns false when the request must go upstream.
+func (p *Proxy) serveStale(w http.ResponseWriter, r *http.Request, key string) bool {
+	if p.staleWindow <= 0 || !p.mayShareEntry(r) || p.requestNoCache(r) {
+		return false
+	}
+	cached, ok := p.fetchStaleEntry(r, key)
+	if !ok || cached.ExpireAt.IsZero() {
+		return false
+	}
+	now := p.clock.Now()
+	if !now.After(cached.ExpireAt) || now.After(cached.ExpireAt.Add(p.staleWindow)) {
+		return false
+	}
+
+	// The refresh outlives r, so it gets its own copy without the body
+	bg := r.Clone(context.Background())
+	bg.Body = http.NoBody
+	p.revalidate(key, func() { p.refreshEntry(bg, key) })
+
+	if p.logger != nil {
+		p.logger.Debug("serving stale entry while revalidating: key=%s age=%s", key, now.Sub(cached.SavedAt))
+	}
+	utils.CopyHeadersForClient(w.Header(), cached.Header)
+	p.setServedBy(w)
+	p.setCacheStatus(w, CacheStale)
+	p.recordSavings(r, CacheStale, cached.Body)
+	w.WriteHeader(cached.Status)
+	_, _ = w.Write(cached.Body)
+	return true
+}
+
+// refreshEntry fetches r from upstream and stores the response under key
+// when it is cacheable. Failures keep the stale entry.
+func (p *Proxy) refreshEntry(r *http.Request, key string) {
+	if p.breaker != nil && !p.breaker.Allow() {
+		return
+	}
+	upURL, err := p.resolveUpstream(r.URL.EscapedPath(), r.URL.RawQuery)
+	if err != nil || !p.hostAllowed(upURL) {
+		return
+	}
+	ctx, cancel := utils.RequestContextWithTimeout(r.Context(), p.requestTimeout(r))
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, r.Method, upURL.String(), nil)
+	if err != nil {
+		return
+	}
+	utils.CopyHeadersForUpstream(req.Header, r.Header)
+	req.Header.Del(TTLRequestHeader)
+	p.setForwardedHeaders(req.Header, r)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		p.recordUpstreamResult(false)
+		if p.logger != nil {
+			p.logger.Error("background revalidation failed: key=%s err=%v", key, err)
+		}
+		return
+	}
+	defer resp.Body.Close()
+	body, oversize, err := p.readBody(resp.Body)
+	if err != nil || oversize {
+		p.recordUpstreamResult(err == nil)
+		return
+	}
+	errorPage := p.isErrorPage(r, resp, body)
+	p.recordUpstreamResult(resp.StatusCode < 500 && !errorPage)
+	if errorPage || !p.shouldStore(r, resp, body) {
+		if p.logger != nil {
+			p.logger.Debug("background revalidation not stored: key=%s status=%d", key, resp.StatusCode)
+		}
+		return
+	}
+	_ = p.storeEntry(r, key, resp, body, p.storeTTL(r, key, resp, body))
+}
@@ -0,0 +1,60 @@
+package proxy
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// statsSnapshot is the periodic summary written to the log
+type statsSnapshot struct {
+	CacheSize   int     `json:"cache_size"`
+	MemoryBytes int64   `json:"memory_bytes"`
+	Requests    int64   `json:"requests"`
+	Miss        int64   `json:"miss"`
+	Pass        int64   `json:"pass"`
+	Bypass      int64   `json:"bypass"`
+	HitBackup   int64   `json:"hit_backup"`
+	Hit         int64   `json:"hit"`
+	Revalidated int64   `json:"revalidated"`
+	Stale       int64   `json:"stale"`
+	HitNegative int64   `json:"hit_negative"`
+	Coalesced   int64   `json:"coalesced"`
+	HitRatio    float64 `json:"hit_ratio"`
+
+	UpstreamErrors int64 `json:"upstream_errors"`
+}
+
+func (p *Proxy) snapshot() statsSnapshot {
+	return statsSnapshot{
+		CacheSize:   p.cacheSize(),
+		MemoryBytes: p.cache.MemoryUsage(),
+		Requests:    p.counters.requests.Load(),
+		Miss:        p.counters.miss.Load(),
+		Pass:        p.counters.pass.Load(),
+		Bypass:      p.counters.bypass.Load(),
+		HitBackup:   p.counters.hitBackup.Load(),
+		Hit:         p.counters.hit.Load(),
+		Revalidated: p.counters.revalidated.Load(),
+		Stale:       p.counters.stale.Load(),
+		HitNegative: p.counters.hitNegative.Load(),
+		Coalesced:   p.counters.coalesced.Load(),
+		HitRatio:    roundRatio(p.counters.hitRatio()),
+
+		UpstreamErrors: p.counters.upstreamErrors.Load(),
+	}
+}
+
+// logStats writes a stats snapshot every interval until stop is closed
+func (p *Proxy) logStats(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			b, _ := json.Marshal(p.snapshot())
+			p.logger.Info("stats: %s", b)
+		case <-stop:
+			return
+		}
+	}
+}
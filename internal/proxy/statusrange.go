@@ -0,0 +1,23 @@
+package proxy
+
+import "slices"
+
+// StatusRange is an inclusive range of HTTP status codes; a single
+// status has Min == Max
+type StatusRange struct {
+	Min, Max int
+}
+
+var (
+	// DefaultCacheableStatus are the upstream statuses stored in the cache
+	DefaultCacheableStatus = []StatusRange{{200, 299}}
+	// DefaultFailoverStatus are the upstream statuses answered with a backup
+	DefaultFailoverStatus = []StatusRange{{500, 599}}
+)
+
+// statusIn reports whether status falls in one of ranges
+func statusIn(ranges []StatusRange, status int) bool {
+	return slices.ContainsFunc(ranges, func(sr StatusRange) bool {
+		return status >= sr.Min && status <= sr.Max
+	})
+}
@@ -0,0 +1,46 @@
+package proxy
+
+import (
+	"Aegis/internal/utils"
+	"time"
+)
+
+// Optional capabilities of a cache.Store beyond Fetch, Put and Delete
+type (
+	clockedStore interface {
+		SetClock(clock utils.Clock)
+	}
+	storeSizer interface {
+		Size() int
+	}
+	sweepingStore interface {
+		Sweep() int
+		SetSweepGrace(grace time.Duration)
+	}
+)
+
+// cacheSize returns the number of entries held by the store, or by the
+// memory cache when the store cannot count them
+func (p *Proxy) cacheSize() int {
+	if p.sizer != nil {
+		return p.sizer.Size()
+	}
+	return p.cache.Size()
+}
+
+// sweepStore removes expired entries from a store other than the memory
+// cache every interval until stop is closed
+func (p *Proxy) sweepStore(s sweepingStore, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if n := s.Sweep(); n > 0 && p.logger != nil {
+				p.logger.Debug("swept %d expired entries from the cache store", n)
+			}
+		}
+	}
+}
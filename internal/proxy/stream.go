@@ -0,0 +1,144 @@
+package proxy
+
+import (
+	"Aegis/internal/utils"
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// streamChunkSize is the read size used when streaming upstream bodies
+const streamChunkSize = 32 * 1024
+
+// shouldStream reports whether a cacheable response is streamed to the
+// client while being teed into the cache, instead of buffered first.
+// Bodies of unknown length count as large. Transforms need the whole body,
+// so they disable streaming unless upstream forbids them, and so does
+// error page detection for the paths and types it checks.
+func (p *Proxy) shouldStream(r *http.Request, resp *http.Response) bool {
+	if p.streamThreshold <= 0 || r.Method != http.MethodGet {
+		return false
+	}
+	if p.transform != nil && !noTransform(resp.Header) {
+		return false
+	}
+	if !statusIn(p.cacheableStatus, resp.StatusCode) {
+		return false
+	}
+	// Error page detection needs the whole body as well
+	if p.errorBody != nil && p.errorBody.applies(r, resp) {
+		return false
+	}
+	return resp.ContentLength < 0 || resp.ContentLength > int64(p.streamThreshold)
+}
+
+// serveStream copies a cacheable upstream body to the client as it arrives and
+// keeps a copy that becomes the cache entry once the body is complete.
+// Headers are already sent when the body fails, so no backup can be
+// served: the client connection is aborted and the partial copy dropped.
+func (p *Proxy) serveStream(w http.ResponseWriter, r *http.Request, key string, resp *http.Response) {
+	store := (resp.ContentLength < 0 || p.storableSize(int(resp.ContentLength))) &&
+		!p.bypassMarked(resp.Header) && !originForbidsStore(resp.Header)
+
+	utils.CopyHeadersForClient(w.Header(), resp.Header)
+	p.setServedBy(w)
+	if store {
+		p.setCacheStatus(w, CacheMiss)
+	} else {
+		p.setCacheStatus(w, CachePass)
+	}
+	w.WriteHeader(resp.StatusCode)
+
+	var buf bytes.Buffer
+	if store && resp.ContentLength > 0 {
+		buf.Grow(int(resp.ContentLength))
+	}
+	rc := http.NewResponseController(w)
+	chunk := make([]byte, streamChunkSize)
+	for {
+		n, err := resp.Body.Read(chunk)
+		if n > 0 {
+			if store {
+				buf.Write(chunk[:n])
+				if p.maxBodyBytes > 0 && buf.Len() > p.maxBodyBytes {
+					store = false
+					buf = bytes.Buffer{}
+				}
+			}
+			if _, werr := w.Write(chunk[:n]); werr != nil {
+				if p.logger != nil {
+					p.logger.Debug("client went away while streaming, entry not cached: key=%s err=%v", key, werr)
+				}
+				return
+			}
+			_ = rc.Flush()
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			p.recordUpstreamResult(false)
+			if p.logger != nil {
+				p.logger.Error("upstream stream interrupted, partial entry discarded: key=%s err=%v", key, err)
+			}
+			panic(http.ErrAbortHandler)
+		}
+	}
+
+	p.recordUpstreamResult(true)
+	if store && p.shouldStore(r, resp, buf.Bytes()) {
+		_ = p.storeEntry(r, key, resp, buf.Bytes(), p.storeTTL(r, key, resp, buf.Bytes()))
+	}
+}
+
+// shouldPassThrough reports whether a response to a non-cacheable request
+// is copied to the client as it arrives instead of buffered first. 5xx
+// bodies are buffered for error capture, and transforms and error page
+// detection need the whole body as in shouldStream.
+func (p *Proxy) shouldPassThrough(r *http.Request, resp *http.Response) bool {
+	if resp.StatusCode >= 500 {
+		return false
+	}
+	if p.transform != nil && !noTransform(resp.Header) {
+		return false
+	}
+	return p.errorBody == nil || !p.errorBody.applies(r, resp)
+}
+
+// servePassThrough copies a non-cacheable upstream body to the client in
+// chunks, flushing each one, so large downloads neither sit in memory nor
+// delay the first byte. As in serveStream, a failing body aborts the
+// client connection.
+func (p *Proxy) servePassThrough(w http.ResponseWriter, r *http.Request, resp *http.Response) {
+	utils.CopyHeadersForClient(w.Header(), resp.Header)
+	p.setServedBy(w)
+	p.setCacheStatus(w, CacheBypass)
+	w.WriteHeader(resp.StatusCode)
+
+	rc := http.NewResponseController(w)
+	chunk := make([]byte, streamChunkSize)
+	for {
+		n, err := resp.Body.Read(chunk)
+		if n > 0 {
+			if _, werr := w.Write(chunk[:n]); werr != nil {
+				if p.logger != nil {
+					p.logger.Debug("client went away during pass-through: %s %s err=%v", r.Method, r.URL.Path, werr)
+				}
+				return
+			}
+			_ = rc.Flush()
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			p.recordUpstreamResult(false)
+			if p.logger != nil {
+				p.logger.Error("upstream pass-through interrupted: %s %s err=%v", r.Method, r.URL.Path, err)
+			}
+			panic(http.ErrAbortHandler)
+		}
+	}
+	p.recordUpstreamResult(true)
+}
@@ -0,0 +1,70 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"time"
+)
+
+// Transform configures an external command that rewrites response bodies.
+// The body is piped to the command's stdin and replaced with its stdout.
+type Transform struct {
+	Command      []string      // Program and arguments (empty = disabled)
+	ContentTypes []string      // Content-Type prefixes to transform (empty = all)
+	Timeout      time.Duration // Maximum run time per body
+	MaxBytes     int           // Largest input/output body handled
+}
+
+var errTransformOutputTooLarge = errors.New("transform output too large")
+
+// limitedBuffer fails writes beyond its limit
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > b.limit {
+		return 0, errTransformOutputTooLarge
+	}
+	return b.Buffer.Write(p)
+}
+
+// matches reports whether a response body qualifies for transformation
+func (t *Transform) matches(contentType string, size int) bool {
+	if size > t.MaxBytes {
+		return false
+	}
+	return len(t.ContentTypes) == 0 || hasAnyPrefix(contentType, t.ContentTypes)
+}
+
+// run pipes body through the command and returns its output
+func (t *Transform) run(ctx context.Context, body []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, t.Command[0], t.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(body)
+	out := &limitedBuffer{limit: t.MaxBytes}
+	cmd.Stdout = out
+	cmd.WaitDelay = t.Timeout
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("transform timed out after %s", t.Timeout)
+		}
+		return nil, fmt.Errorf("transform command: %w", err)
+	}
+	return out.Bytes(), nil
+}
+
+// noTransform reports whether upstream forbids modifying the body
+// (Cache-Control: no-transform, RFC 9111 5.2.2.6). Every body transform,
+// such as the external command or compression, must check it.
+func noTransform(h http.Header) bool {
+	return hasCacheControl(h, "no-transform")
+}
@@ -0,0 +1,106 @@
+package proxy
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TTL signals, in the order given to WithTTLPrecedence
+const (
+	TTLSignalCustom       = "x-cache-ttl"   // X-Cache-TTL response header, in seconds
+	TTLSignalCacheControl = "cache-control" // s-maxage, else max-age
+	TTLSignalExpires      = "expires"       // Expires relative to Date
+	TTLSignalDefault      = "default"       // Configured ttl or heuristic freshness
+)
+
+// CacheTTLHeader is the custom response header read by TTLSignalCustom
+const CacheTTLHeader = "X-Cache-TTL"
+
+// DefaultTTLPrecedence ignores upstream signals and uses the configured TTL
+var DefaultTTLPrecedence = []string{TTLSignalDefault}
+
+// OriginTTLPrecedence is used by WithRespectOriginTTL unless another
+// precedence is configured: the origin's freshness first, the ttl when
+// it is silent
+var OriginTTLPrecedence = []string{TTLSignalCacheControl, TTLSignalExpires, TTLSignalDefault}
+
+// entryTTL returns the TTL for a response about to be stored: the first
+// signal in the precedence list that the response carries wins. Signals
+// with a missing, malformed or non-positive value are skipped. The default
+// signal always applies, so it also ends a list that doesn't name it.
+// When respecting origin TTLs, a configured ttl caps upstream signals.
+// base is the configured ttl for the request, see pathTTL.
+func (p *Proxy) entryTTL(h http.Header, base time.Duration) time.Duration {
+	for _, signal := range p.ttlPrecedence {
+		if signal == TTLSignalDefault {
+			break
+		}
+		if ttl, ok := p.signalTTL(signal, h); ok {
+			if p.respectOriginTTL && base > 0 && ttl > base {
+				ttl = base
+			}
+			if p.logger != nil {
+				p.logger.Debug("ttl %s from %s", ttl, signal)
+			}
+			return ttl
+		}
+	}
+	ttl := p.defaultTTL(h, base)
+	if p.logger != nil {
+		p.logger.Debug("ttl %s from %s", ttl, TTLSignalDefault)
+	}
+	return ttl
+}
+
+// signalTTL reads one upstream TTL signal from response headers
+func (p *Proxy) signalTTL(signal string, h http.Header) (time.Duration, bool) {
+	switch signal {
+	case TTLSignalCustom:
+		return positiveSeconds(h.Get(CacheTTLHeader))
+	case TTLSignalCacheControl:
+		if ttl, ok := positiveSeconds(cacheControlValue(h, "s-maxage")); ok {
+			return ttl, true
+		}
+		return positiveSeconds(cacheControlValue(h, "max-age"))
+	case TTLSignalExpires:
+		return expiresTTL(h, p.clock.Now())
+	}
+	return 0, false
+}
+
+// cacheControlValue returns the value of a Cache-Control directive, or ""
+func cacheControlValue(h http.Header, directive string) string {
+	for _, v := range h.Values("Cache-Control") {
+		for _, d := range strings.Split(v, ",") {
+			name, value, _ := strings.Cut(strings.TrimSpace(d), "=")
+			if strings.EqualFold(name, directive) {
+				return strings.Trim(value, `"`)
+			}
+		}
+	}
+	return ""
+}
+
+// positiveSeconds parses a positive number of seconds
+func positiveSeconds(v string) (time.Duration, bool) {
+	secs, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil || secs <= 0 {
+		return 0, false
+	}
+	return time.Duration(secs) * time.Second, true
+}
+
+// expiresTTL returns Expires minus Date (or now without a valid Date)
+func expiresTTL(h http.Header, now time.Time) (time.Duration, bool) {
+	expires, err := http.ParseTime(h.Get("Expires"))
+	if err != nil {
+		return 0, false
+	}
+	if date, err := http.ParseTime(h.Get("Date")); err == nil {
+		now = date
+	}
+	ttl := expires.Sub(now)
+	return ttl, ttl > 0
+}
@@ -0,0 +1,46 @@
+package proxy
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// parseUpstreams parses the extra upstreams given to WithExtraUpstreams
+func parseUpstreams(raw []string) ([]*url.URL, error) {
+	upstreams := make([]*url.URL, 0, len(raw))
+	for _, s := range raw {
+		u, err := url.Parse(s)
+		if err != nil {
+			return nil, fmt.Errorf("parse upstream %q: %w", s, err)
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("parse upstream %q: expected an absolute URL", s)
+		}
+		upstreams = append(upstreams, u)
+	}
+	return upstreams, nil
+}
+
+// pickUpstream returns the upstream for the next request, rotating
+// round-robin through the pool and skipping upstreams that failed their
+// health check. When all are down the rotation goes on regardless, so
+// requests can still reach an upstream that recovered between probes.
+func (p *Proxy) pickUpstream() *url.URL {
+	if len(p.upstreams) < 2 {
+		return p.upstream
+	}
+	n := p.upstreamNext.Add(1) - 1
+	size := uint64(len(p.upstreams))
+	for i := range size {
+		if idx := (n + i) % size; !p.upstreamDown[idx].Load() {
+			return p.upstreams[idx]
+		}
+	}
+	return p.upstreams[n%size]
+}
+
+// upstreamStats reports the health of one upstream in /stats
+type upstreamStats struct {
+	URL     string `json:"url"`
+	Healthy bool   `json:"healthy"`
+}
@@ -0,0 +1,71 @@
+package proxy
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// responseVary returns the canonical, sorted request header names of the
+// response Vary header, or just "*" when it varies on everything
+func responseVary(h http.Header) []string {
+	var names []string
+	for _, v := range h.Values("Vary") {
+		for _, name := range strings.Split(v, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if name == "*" {
+				return []string{"*"}
+			}
+			if name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+// varyKey qualifies a base key with the request's values of the headers
+// a response varies on. The base key itself holds a marker listing them,
+// so a lookup finds the qualified key in two steps.
+func (p *Proxy) varyKey(r *http.Request, base string, names []string) string {
+	var b strings.Builder
+	b.WriteString(base)
+	b.WriteString("|vary:")
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte('&')
+		}
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(p.limitKeyValue(keyHeaderValue(r.Header, name)))
+	}
+	return p.hashKey(b.String())
+}
+
+// reflectVary adds the request headers that are part of the cache key to
+// the response Vary header, so downstream caches key their copies the same
+// way. The upstream Vary is kept as is; names already listed or covered by
+// "Vary: *" are not added again.
+func (p *Proxy) reflectVary(h http.Header) {
+	names := p.keyHeaders
+	if p.countryHeader != "" {
+		names = append(names[:len(names):len(names)], p.countryHeader)
+	}
+	listed := make(map[string]bool)
+	for _, v := range h.Values("Vary") {
+		for _, name := range strings.Split(v, ",") {
+			listed[http.CanonicalHeaderKey(strings.TrimSpace(name))] = true
+		}
+	}
+	if listed["*"] {
+		return
+	}
+	for _, name := range names {
+		name = http.CanonicalHeaderKey(name)
+		if !listed[name] {
+			h.Add("Vary", name)
+			listed[name] = true
+		}
+	}
+}
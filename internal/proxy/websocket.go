@@ -0,0 +1,135 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// defaultWebSocketIdleTimeout closes upgraded connections without traffic
+const defaultWebSocketIdleTimeout = 5 * time.Minute
+
+// isUpgrade reports whether r asks to switch protocols, such as to
+// WebSocket. Such requests are streamed: never cached and not bound by the
+// request timeout.
+func isUpgrade(r *http.Request) bool {
+	if r.Header.Get("Upgrade") == "" {
+		return false
+	}
+	for _, v := range r.Header.Values("Connection") {
+		for _, token := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// upgradeTargetKey carries the upstream URL chosen by serveUpgrade to the
+// passthrough, so the pool is consulted once per request
+type upgradeTargetKey struct{}
+
+// newWebSocketProxy builds the passthrough used for upgrade requests. Both
+// connections of an upgraded session close after idleTimeout without traffic.
+func (p *Proxy) newWebSocketProxy() *httputil.ReverseProxy {
+	dialer := &net.Dialer{Timeout: 5 * time.Second, KeepAlive: 30 * time.Second}
+	transport := &http.Transport{
+		Proxy: p.transport.Proxy,
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := dialer.DialContext(ctx, network, addr)
+			if err != nil {
+				return nil, err
+			}
+			return p.withIdleTimeout(conn), nil
+		},
+		TLSHandshakeTimeout: 5 * time.Second,
+	}
+	return &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.Out.URL = pr.In.Context().Value(upgradeTargetKey{}).(*url.URL)
+			pr.Out.Host = ""
+			pr.Out.Header.Del(TTLRequestHeader)
+			p.setForwardedHeaders(pr.Out.Header, pr.In)
+		},
+		Transport: transport,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			if p.logger != nil {
+				p.logger.Error("upgrade upstream error: %s: %v", r.URL.Path, err)
+			}
+			http.Error(w, "Bad Gateway: "+err.Error(), http.StatusBadGateway)
+		},
+	}
+}
+
+// serveUpgrade passes an upgrade request through to upstream. The
+// Upgrade and Connection headers reach upstream for the handshake, and
+// after a 101 bytes are copied both ways until either side closes.
+func (p *Proxy) serveUpgrade(w http.ResponseWriter, r *http.Request) {
+	upURL, err := p.resolveUpstream(r.URL.EscapedPath(), r.URL.RawQuery)
+	if err != nil {
+		http.Error(w, "Bad Gateway: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	if !p.hostAllowed(upURL) {
+		if p.logger != nil {
+			p.logger.Error("blocked request to disallowed upstream host: %s %s -> %s", r.Method, r.URL.Path, upURL.Host)
+		}
+		http.Error(w, "Bad Gateway: upstream host not allowed", http.StatusBadGateway)
+		return
+	}
+	if p.logger != nil {
+		p.logger.Debug("upgrade to %s: %s -> %s", r.Header.Get("Upgrade"), r.URL.Path, upURL.String())
+	}
+	p.setServedBy(w)
+	p.setCacheStatus(w, CacheBypass)
+	r = r.WithContext(context.WithValue(r.Context(), upgradeTargetKey{}, upURL))
+	p.wsProxy.ServeHTTP(&idleTimeoutWriter{ResponseWriter: w, p: p}, r)
+}
+
+// withIdleTimeout wraps conn so it fails after wsIdleTimeout without traffic
+func (p *Proxy) withIdleTimeout(conn net.Conn) net.Conn {
+	if p.wsIdleTimeout <= 0 {
+		return conn
+	}
+	return &idleTimeoutConn{Conn: conn, timeout: p.wsIdleTimeout}
+}
+
+// idleTimeoutConn pushes its deadline forward on every read and write
+type idleTimeoutConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c *idleTimeoutConn) Read(b []byte) (int, error) {
+	_ = c.Conn.SetDeadline(time.Now().Add(c.timeout))
+	return c.Conn.Read(b)
+}
+
+func (c *idleTimeoutConn) Write(b []byte) (int, error) {
+	_ = c.Conn.SetDeadline(time.Now().Add(c.timeout))
+	return c.Conn.Write(b)
+}
+
+// idleTimeoutWriter applies the idle timeout to the hijacked client connection
+type idleTimeoutWriter struct {
+	http.ResponseWriter
+	p *Proxy
+}
+
+func (w *idleTimeoutWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	return w.p.withIdleTimeout(conn), brw, nil
+}
+
+func (w *idleTimeoutWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the current time. Time-based code takes a Clock so tests can
+// control time instead of sleeping.
+type Clock interface {
+	Now() time.Time
+}
+
+// RealClock is the wall clock
+type RealClock struct{}
+
+// Now returns time.Now()
+func (RealClock) Now() time.Time {
+	return time.Now()
+}
+
+// FakeClock is a manually advanced Clock for tests
+type FakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFakeClock returns a FakeClock stopped at now
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the current fake time
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the fake time forward by d
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
@@ -2,6 +2,9 @@ package utils
 
 import (
 	"context"
+	"crypto/subtle"
+	"fmt"
+	"net"
 	"net/http"
 	"strings"
 	"time"
@@ -76,8 +79,11 @@ func EnsureLeadingSlash(s string) string {
 }
 
 // RequestContextWithTimeout creates a context with timeout,
-// respecting parent's deadline if shorter
+// respecting parent's deadline if shorter. d <= 0 adds no deadline.
 func RequestContextWithTimeout(parent context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return parent, func() {}
+	}
 	if deadline, ok := parent.Deadline(); ok && time.Until(deadline) < d {
 		// Parent already has shorter timeout - return no-op cancel
 		return parent, func() {}
@@ -86,9 +92,74 @@ func RequestContextWithTimeout(parent context.Context, d time.Duration) (context
 }
 
 // ZeroOrExpiry returns zero time or expiry time based on TTL
-func ZeroOrExpiry(ttl time.Duration) time.Time {
+func ZeroOrExpiry(clock Clock, ttl time.Duration) time.Time {
 	if ttl <= 0 {
 		return time.Time{}
 	}
-	return time.Now().Add(ttl)
+	return clock.Now().Add(ttl)
+}
+
+// ParseCIDRs parses a list of CIDRs or bare IP addresses into networks
+func ParseCIDRs(values []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(values))
+	for _, v := range values {
+		if !strings.Contains(v, "/") {
+			ip := net.ParseIP(v)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address %q", v)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q: %w", v, err)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// RemoteAddrInNets checks if the host part of a remote address
+// belongs to any of the given networks
+func RemoteAddrInNets(remoteAddr string, nets []*net.IPNet) bool {
+	if len(nets) == 0 {
+		return false
+	}
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireBasicAuth protects next with HTTP Basic authentication.
+// Credentials are compared in constant time. An empty password refuses
+// every request rather than accepting an empty one.
+func RequireBasicAuth(user, password string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, p, ok := r.BasicAuth()
+		userOK := subtle.ConstantTimeCompare([]byte(u), []byte(user)) == 1
+		passOK := subtle.ConstantTimeCompare([]byte(p), []byte(password)) == 1
+		if !ok || !userOK || !passOK || password == "" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="aegis admin"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
 }
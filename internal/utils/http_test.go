@@ -3,6 +3,7 @@ package utils
 import (
 	"context"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 )
@@ -154,29 +155,138 @@ func TestRequestContextWithTimeout(t *testing.T) {
 	if time.Until(deadline2) > 150*time.Millisecond {
 		t.Error("expected to keep parent's shorter deadline")
 	}
+
+	// No timeout adds no deadline
+	ctx3, cancel3 := RequestContextWithTimeout(parent, 0)
+	defer cancel3()
+	if _, ok := ctx3.Deadline(); ok {
+		t.Error("expected no deadline for zero timeout")
+	}
 }
 
 func TestZeroOrExpiry(t *testing.T) {
+	clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+
 	// Zero TTL
-	result := ZeroOrExpiry(0)
+	result := ZeroOrExpiry(clock, 0)
 	if !result.IsZero() {
 		t.Error("expected zero time for TTL=0")
 	}
 
 	// Negative TTL
-	result = ZeroOrExpiry(-1 * time.Second)
+	result = ZeroOrExpiry(clock, -1*time.Second)
 	if !result.IsZero() {
 		t.Error("expected zero time for negative TTL")
 	}
 
 	// Positive TTL
 	ttl := 5 * time.Second
-	result = ZeroOrExpiry(ttl)
-	if result.IsZero() {
-		t.Error("expected non-zero time for positive TTL")
+	result = ZeroOrExpiry(clock, ttl)
+	if !result.Equal(clock.Now().Add(ttl)) {
+		t.Errorf("expected expiry at now + TTL, got %v", result)
+	}
+}
+
+func TestParseCIDRs(t *testing.T) {
+	nets, err := ParseCIDRs([]string{"10.0.0.0/8", "192.168.1.1", "::1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
 	}
-	expected := time.Now().Add(ttl)
-	if result.Before(expected.Add(-100*time.Millisecond)) || result.After(expected.Add(100*time.Millisecond)) {
-		t.Error("expected expiry time around now + TTL")
+	if len(nets) != 3 {
+		t.Fatalf("expected 3 networks, got %d", len(nets))
+	}
+
+	if _, err := ParseCIDRs([]string{"not-an-ip"}); err == nil {
+		t.Error("expected error for invalid IP")
+	}
+	if _, err := ParseCIDRs([]string{"10.0.0.0/99"}); err == nil {
+		t.Error("expected error for invalid CIDR")
+	}
+}
+
+func TestRemoteAddrInNets(t *testing.T) {
+	nets, _ := ParseCIDRs([]string{"10.0.0.0/8", "192.168.1.1", "::1"})
+
+	tests := []struct {
+		addr     string
+		expected bool
+	}{
+		{"10.1.2.3:5555", true},
+		{"192.168.1.1:80", true},
+		{"192.168.1.2:80", false},
+		{"[::1]:8080", true},
+		{"10.1.2.3", true},
+		{"garbage", false},
+	}
+
+	for _, tt := range tests {
+		if got := RemoteAddrInNets(tt.addr, nets); got != tt.expected {
+			t.Errorf("RemoteAddrInNets(%q) = %v, expected %v", tt.addr, got, tt.expected)
+		}
+	}
+
+	if RemoteAddrInNets("10.1.2.3:5555", nil) {
+		t.Error("expected no match with empty network list")
+	}
+}
+
+func TestXXHash64(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected uint64
+	}{
+		{"", 0xef46db3751d8e999},
+		{"a", 0xd24ec4f1a98c6e5b},
+		{"abc", 0x44bc2cf5ad770999},
+		{"Nobody inspects the spammish repetition", 0xfbcea83c8a378bf1},
+	}
+
+	for _, tt := range tests {
+		if got := XXHash64([]byte(tt.input)); got != tt.expected {
+			t.Errorf("XXHash64(%q) = %#x, expected %#x", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestRequireBasicAuth(t *testing.T) {
+	h := RequireBasicAuth("admin", "s3cret", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		user, password string
+		set            bool
+		expected       int
+	}{
+		{"admin", "s3cret", true, http.StatusOK},
+		{"admin", "wrong", true, http.StatusUnauthorized},
+		{"other", "s3cret", true, http.StatusUnauthorized},
+		{"", "", false, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/dashboard", nil)
+		if tt.set {
+			req.SetBasicAuth(tt.user, tt.password)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tt.expected {
+			t.Errorf("user=%q password=%q: expected %d, got %d", tt.user, tt.password, tt.expected, rec.Code)
+		}
+	}
+}
+
+func TestRequireBasicAuthEmptyPassword(t *testing.T) {
+	h := RequireBasicAuth("admin", "", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest("GET", "/errors", nil)
+	req.SetBasicAuth("admin", "")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected an empty configured password to refuse admin:, got %d", rec.Code)
 	}
 }
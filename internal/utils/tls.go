@@ -0,0 +1,170 @@
+package utils
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ServerTLS describes TLS termination on the listener
+type ServerTLS struct {
+	CertFile     string
+	KeyFile      string
+	MinVersion   uint16   // tls.VersionTLS12 etc. (0 = crypto/tls default)
+	ALPN         []string // Offered application protocols, in preference order
+	CipherSuites []uint16 // TLS 1.2 cipher suites (nil = crypto/tls default)
+	// SessionTicketKeyFile holds hex-encoded 32-byte keys, one per line.
+	// The first key encrypts new tickets, the others only decrypt, so a
+	// key can be rotated in while tickets issued under the old one resume.
+	SessionTicketKeyFile string
+}
+
+// ServerTLSConfig is the listener's TLS configuration. Session ticket keys
+// can be reloaded from their file while the server runs.
+type ServerTLSConfig struct {
+	settings ServerTLS
+	base     *tls.Config
+	current  atomic.Pointer[tls.Config]
+}
+
+// NewServerTLSConfig loads the certificate and session ticket keys
+func NewServerTLSConfig(s ServerTLS) (*ServerTLSConfig, error) {
+	cert, err := tls.LoadX509KeyPair(s.CertFile, s.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load certificate: %w", err)
+	}
+	c := &ServerTLSConfig{
+		settings: s,
+		base: &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   s.MinVersion,
+			NextProtos:   slices.Clone(s.ALPN),
+			CipherSuites: slices.Clone(s.CipherSuites),
+		},
+	}
+	c.current.Store(c.base)
+	if s.SessionTicketKeyFile != "" {
+		if err := c.ReloadTicketKeys(); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// TLSConfig returns the configuration for http.Server.TLSConfig. Handshakes
+// use the most recently loaded session ticket keys.
+func (c *ServerTLSConfig) TLSConfig() *tls.Config {
+	cfg := c.base.Clone()
+	if c.settings.SessionTicketKeyFile != "" {
+		// http.Server clones its TLSConfig, so keys set later only reach
+		// handshakes through GetConfigForClient
+		cfg.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return c.current.Load(), nil
+		}
+	}
+	return cfg
+}
+
+// ReloadTicketKeys rereads the session ticket key file
+func (c *ServerTLSConfig) ReloadTicketKeys() error {
+	keys, err := LoadSessionTicketKeys(c.settings.SessionTicketKeyFile)
+	if err != nil {
+		return err
+	}
+	cfg := c.base.Clone()
+	cfg.SetSessionTicketKeys(keys)
+	c.current.Store(cfg)
+	return nil
+}
+
+// RotateTicketKeys reloads the session ticket key file every interval
+// until ctx is done. A failed reload keeps the previous keys.
+func (c *ServerTLSConfig) RotateTicketKeys(ctx context.Context, interval time.Duration, logf func(format string, v ...interface{})) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.ReloadTicketKeys(); err != nil {
+				logf("session ticket key reload failed, keeping previous keys: %v", err)
+			}
+		}
+	}
+}
+
+// LoadSessionTicketKeys reads hex-encoded 32-byte keys, one per line.
+// Blank lines and lines starting with # are skipped.
+func LoadSessionTicketKeys(path string) ([][32]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("session ticket keys: %w", err)
+	}
+	defer f.Close()
+
+	var keys [][32]byte
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		raw, err := hex.DecodeString(text)
+		if err != nil || len(raw) != 32 {
+			return nil, fmt.Errorf("session ticket keys: %s:%d: expected 64 hex characters", path, line)
+		}
+		var key [32]byte
+		copy(key[:], raw)
+		keys = append(keys, key)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("session ticket keys: %w", err)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("session ticket keys: %s: no keys", path)
+	}
+	return keys, nil
+}
+
+// ParseTLSVersion parses "1.0" to "1.3" ("" = crypto/tls default)
+func ParseTLSVersion(s string) (uint16, error) {
+	switch s {
+	case "":
+		return 0, nil
+	case "1.0":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("unknown TLS version %q (expected 1.0, 1.1, 1.2 or 1.3)", s)
+}
+
+// ParseCipherSuites maps crypto/tls cipher suite names such as
+// TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 to their IDs. Insecure suites
+// are rejected.
+func ParseCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		i := slices.IndexFunc(tls.CipherSuites(), func(s *tls.CipherSuite) bool { return s.Name == name })
+		if i < 0 {
+			return nil, fmt.Errorf("unknown or insecure cipher suite %q", name)
+		}
+		ids = append(ids, tls.CipherSuites()[i].ID)
+	}
+	return ids, nil
+}
@@ -0,0 +1,191 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTestCert writes a self-signed certificate for 127.0.0.1
+func writeTestCert(t *testing.T, dir string) (certFile, keyFile string) {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "aegis test"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &priv.PublicKey, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestServerTLSConfig(t *testing.T) {
+	certFile, keyFile := writeTestCert(t, t.TempDir())
+	suites, err := ParseCipherSuites([]string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewServerTLSConfig(ServerTLS{
+		CertFile:     certFile,
+		KeyFile:      keyFile,
+		MinVersion:   tls.VersionTLS12,
+		ALPN:         []string{"h2", "http/1.1"},
+		CipherSuites: suites,
+	})
+	if err != nil {
+		t.Fatalf("failed to build TLS config: %v", err)
+	}
+	cfg := c.TLSConfig()
+	if cfg.MinVersion != tls.VersionTLS12 {
+		t.Errorf("expected min version TLS 1.2, got %x", cfg.MinVersion)
+	}
+	if !slices.Equal(cfg.NextProtos, []string{"h2", "http/1.1"}) {
+		t.Errorf("expected ALPN [h2 http/1.1], got %v", cfg.NextProtos)
+	}
+	if !slices.Equal(cfg.CipherSuites, []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256}) {
+		t.Errorf("unexpected cipher suites %v", cfg.CipherSuites)
+	}
+
+	if _, err := ParseCipherSuites([]string{"TLS_RSA_WITH_RC4_128_SHA"}); err == nil {
+		t.Error("expected an insecure cipher suite to be rejected")
+	}
+	if _, err := ParseTLSVersion("1.4"); err == nil {
+		t.Error("expected an unknown TLS version to be rejected")
+	}
+}
+
+// serveTLSOnce accepts one connection, completes the handshake and writes
+// a byte so the client receives its session ticket
+func serveTLSOnce(t *testing.T, cfg *tls.Config) string {
+	t.Helper()
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		defer ln.Close()
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = conn.Write([]byte("x"))
+		_, _ = conn.Read(make([]byte, 1))
+	}()
+	return ln.Addr().String()
+}
+
+// dialResumed connects and reports whether the session was resumed
+func dialResumed(t *testing.T, addr string, cache tls.ClientSessionCache) bool {
+	t.Helper()
+	conn, err := tls.Dial("tcp", addr, &tls.Config{InsecureSkipVerify: true, ClientSessionCache: cache})
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Read(make([]byte, 1)); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	return conn.ConnectionState().DidResume
+}
+
+func TestSessionTicketKeyResumption(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCert(t, dir)
+	oldKey := strings.Repeat("ab", 32)
+	newKey := strings.Repeat("cd", 32)
+	keyPath := filepath.Join(dir, "tickets")
+	if err := os.WriteFile(keyPath, []byte("# current\n"+oldKey+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	settings := ServerTLS{CertFile: certFile, KeyFile: keyFile, SessionTicketKeyFile: keyPath}
+
+	newServer := func() *ServerTLSConfig {
+		c, err := NewServerTLSConfig(settings)
+		if err != nil {
+			t.Fatalf("failed to build TLS config: %v", err)
+		}
+		return c
+	}
+	clientCache := tls.NewLRUClientSessionCache(8)
+
+	if dialResumed(t, serveTLSOnce(t, newServer().TLSConfig()), clientCache) {
+		t.Fatal("expected a full handshake on first connection")
+	}
+	// A restarted server with the same key file resumes the session
+	if !dialResumed(t, serveTLSOnce(t, newServer().TLSConfig()), clientCache) {
+		t.Error("expected resumption after restart with a persisted key")
+	}
+
+	// Rotating a new key in keeps tickets under the old one valid
+	c := newServer()
+	cfg := c.TLSConfig()
+	if err := os.WriteFile(keyPath, []byte(newKey+"\n"+oldKey+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ReloadTicketKeys(); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if !dialResumed(t, serveTLSOnce(t, cfg), clientCache) {
+		t.Error("expected resumption with the previous key after rotation")
+	}
+
+	// Tickets issued under the new key resume once the old key is dropped
+	if err := os.WriteFile(keyPath, []byte(newKey+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ReloadTicketKeys(); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	clientCache = tls.NewLRUClientSessionCache(8)
+	if dialResumed(t, serveTLSOnce(t, newServer().TLSConfig()), clientCache) {
+		t.Fatal("expected a full handshake with a fresh client cache")
+	}
+	if !dialResumed(t, serveTLSOnce(t, cfg), clientCache) {
+		t.Error("expected resumption with the rotated key")
+	}
+}
+
+func TestLoadSessionTicketKeysInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tickets")
+	for _, content := range []string{"", "# only a comment\n", "abcd\n"} {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadSessionTicketKeys(path); err == nil {
+			t.Errorf("expected error for key file %q", content)
+		}
+	}
+}
@@ -1,11 +1,21 @@
 package main
 
 import (
+	"Aegis/internal/cache"
 	"Aegis/internal/config"
 	"Aegis/internal/logger"
 	"Aegis/internal/proxy"
+	"Aegis/internal/utils"
+	"context"
+	"crypto/tls"
+	"errors"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"time"
 )
 
 func main() {
@@ -14,9 +24,194 @@ func main() {
 
 	// Create logger
 	appLogger := logger.New(cfg.Logging.Enabled, cfg.Logging.AccessLog, cfg.Logging.Level)
+	appLogger.ExcludeAccessPaths(cfg.Logging.AccessExcludePaths, cfg.Logging.AccessLogErrors)
 
 	// Create proxy
-	p, err := proxy.New(cfg.Upstream, cfg.Timeout, cfg.TTL, cfg.Cache.KeyHeaders, appLogger)
+	rewrites := make([]proxy.RewriteRule, 0, len(cfg.Routing.Rewrites))
+	for _, rw := range cfg.Routing.Rewrites {
+		rewrites = append(rewrites, proxy.RewriteRule{Match: rw.Match, Replace: rw.Replace})
+	}
+	ignoreParams := cfg.Cache.IgnoreQueryParams
+	if cfg.Cache.IgnoreAnalyticsParams {
+		ignoreParams = append(ignoreParams, proxy.AnalyticsQueryParams...)
+	}
+	maintenance := make([]proxy.MaintenanceWindow, 0, len(cfg.Maintenance.Windows))
+	for _, mw := range cfg.Maintenance.Windows {
+		maintenance = append(maintenance, proxy.MaintenanceWindow{Start: mw.Start, End: mw.End, Days: mw.Days})
+	}
+	maintenancePaths := make([]proxy.MaintenancePath, 0, len(cfg.Maintenance.Paths))
+	for _, mp := range cfg.Maintenance.Paths {
+		maintenancePaths = append(maintenancePaths, proxy.MaintenancePath{
+			Prefix: mp.Prefix, Status: mp.Status, Body: mp.Body, ContentType: mp.ContentType, Enabled: mp.Enabled,
+		})
+	}
+	preload := make([]proxy.PreloadEntry, 0, len(cfg.Cache.Preload))
+	for _, e := range cfg.Cache.Preload {
+		preload = append(preload, proxy.PreloadEntry{URL: e.URL, TTL: e.TTL})
+	}
+	cacheableStatus := make([]proxy.StatusRange, 0, len(cfg.Cache.CacheableStatus))
+	for _, sr := range cfg.Cache.CacheableStatus {
+		cacheableStatus = append(cacheableStatus, proxy.StatusRange{Min: sr.Min, Max: sr.Max})
+	}
+	failoverStatus := make([]proxy.StatusRange, 0, len(cfg.Cache.FailoverStatus))
+	for _, sr := range cfg.Cache.FailoverStatus {
+		failoverStatus = append(failoverStatus, proxy.StatusRange{Min: sr.Min, Max: sr.Max})
+	}
+	ttlRules := make([]proxy.TTLRule, 0, len(cfg.Cache.Rules))
+	for _, rule := range cfg.Cache.Rules {
+		ttlRules = append(ttlRules, proxy.TTLRule{Path: rule.Path, TTL: rule.TTL})
+	}
+	rateLimitPaths := make([]proxy.PathRateLimit, 0, len(cfg.RateLimit.Paths))
+	for _, pl := range cfg.RateLimit.Paths {
+		rateLimitPaths = append(rateLimitPaths, proxy.PathRateLimit{Prefix: pl.Prefix, Rate: pl.Rate, Burst: pl.Burst})
+	}
+	var events proxy.Events
+	if cfg.Events.Enabled {
+		events = proxy.Events{
+			WebhookURL: cfg.Events.WebhookURL,
+			LogFile:    cfg.Events.LogFile,
+			QueueSize:  cfg.Events.QueueSize,
+			Timeout:    cfg.Events.Timeout,
+		}
+	}
+	var store cache.Store
+	switch cfg.Cache.Backend {
+	case "disk":
+		fs, err := cache.NewFileStore(cfg.Cache.Dir)
+		if err != nil {
+			log.Fatalf("init cache store: %v", err)
+		}
+		store = fs
+	case "redis":
+		store = cache.NewRedisStore(cache.RedisOptions{
+			Addr:     cfg.Cache.Redis.Addr,
+			Password: cfg.Cache.Redis.Password,
+			DB:       cfg.Cache.Redis.DB,
+		})
+	}
+	p, err := proxy.New(cfg.Upstream, cfg.Timeout, cfg.TTL, cfg.Cache.KeyHeaders, appLogger,
+		proxy.WithStore(store),
+		proxy.WithExtraUpstreams(cfg.ExtraUpstreams),
+		proxy.WithCircuitBreaker(cfg.CircuitBreaker.FailureThreshold, cfg.CircuitBreaker.Cooldown),
+		proxy.WithMaintenanceWindows(maintenance, cfg.Maintenance.Location),
+		proxy.WithMaintenancePaths(maintenancePaths),
+		proxy.WithReadyWhenCached(cfg.Readiness.ReadyWithCache),
+		proxy.WithBodyDump(proxy.BodyDump{
+			MaxBytes:     cfg.Logging.DumpRequestBody.MaxBytes,
+			ContentTypes: cfg.Logging.DumpRequestBody.ContentTypes,
+			PathPrefixes: cfg.Logging.DumpRequestBody.Paths,
+			RedactFields: cfg.Logging.DumpRequestBody.RedactFields,
+		}),
+		proxy.WithTrustedProxies(cfg.TrustedProxies),
+		proxy.WithForwardedHeaders(cfg.ForwardedHeaders),
+		proxy.WithResponseCompression(cfg.CompressMinBytes),
+		proxy.WithRequestTimeoutHeader(cfg.HonorRequestTimeoutHeader),
+		proxy.WithRetries(cfg.Retries, cfg.RetryBackoff),
+		proxy.WithInstanceID(cfg.InstanceHeader, cfg.InstanceID),
+		proxy.WithDefaultHost(cfg.DefaultHost),
+		proxy.WithNonStandardMethods(cfg.NonStandardMethods),
+		proxy.WithServedBy(cfg.ServedBy),
+		proxy.WithSchemeInKey(cfg.Cache.KeyIncludeScheme),
+		proxy.WithVaryKeyHeaders(cfg.Cache.VaryKeyHeaders),
+		proxy.WithHostInKey(cfg.Cache.KeyIncludeHost),
+		proxy.WithMaxEntries(cfg.Cache.MaxEntries),
+		proxy.WithMaxMemory(cfg.Cache.MaxMemory),
+		proxy.WithCompression(cfg.Cache.CompressMinBytes),
+		proxy.WithSweepInterval(cfg.Cache.SweepInterval),
+		proxy.WithStaleWhileRevalidate(cfg.Cache.StaleWhileRevalidate),
+		proxy.WithMaxStale(cfg.Cache.MaxStale),
+		proxy.WithNegativeCaching(cfg.Cache.NegativeTTL, cfg.Cache.NegativeStatuses),
+		proxy.WithCacheableStatus(cacheableStatus),
+		proxy.WithFailoverStatus(failoverStatus),
+		proxy.WithHandoff(cfg.Cache.HandoffPath, cfg.Cache.HandoffTopN),
+		proxy.WithMaxEntriesPerHost(cfg.Cache.MaxEntriesPerHost),
+		proxy.WithEvictionPolicy(cfg.Cache.EvictionPolicy),
+		proxy.WithTimeBucket(cfg.Cache.TimeBucket),
+		proxy.WithIgnoredQueryParams(ignoreParams),
+		proxy.WithCountryKey(cfg.Cache.CountryHeader, cfg.Cache.CountryDefault),
+		proxy.WithStatusHeader(cfg.Cache.StatusHeader),
+		proxy.WithGetBodyPolicy(cfg.Cache.GetBody),
+		proxy.WithGetBodyKeyFields(cfg.Cache.GetBodyKeyFields),
+		proxy.WithBodySizeRange(cfg.Cache.MinBodyBytes, cfg.Cache.MaxBodyBytes),
+		proxy.WithOversizePolicy(cfg.Cache.OversizeBody),
+		proxy.WithBypassHeader(cfg.Cache.BypassHeader, cfg.Cache.BypassValue),
+		proxy.WithSkipEmptyBody(cfg.Cache.SkipEmptyBody),
+		proxy.WithCoalescing(cfg.Cache.Coalesce),
+		proxy.WithStreaming(cfg.Cache.StreamThresholdBytes),
+		proxy.WithKeyHash(cfg.Cache.HashKeys),
+		proxy.WithHashLongKeys(cfg.Cache.HashKeysLongerThan),
+		proxy.WithTTLRules(ttlRules),
+		proxy.WithMaxKeyValueBytes(cfg.Cache.MaxKeyHeaderValueBytes),
+		proxy.WithBackendFailurePolicy(cfg.Cache.BackendFailure),
+		proxy.WithBackendRetries(cfg.Cache.BackendRetries),
+		proxy.WithSharedAuthBackup(cfg.Cache.AllowSharedAuthBackup),
+		proxy.WithStatsLogInterval(cfg.Stats.LogInterval),
+		proxy.WithSavingsStats(cfg.Stats.Savings),
+		proxy.WithTTLPrecedence(cfg.Cache.HeaderPrecedence),
+		proxy.WithRespectOriginTTL(cfg.Cache.RespectOriginTTL),
+		proxy.WithETagRevalidation(cfg.Cache.ETagRevalidation),
+		proxy.WithHeuristicFreshness(cfg.Cache.HeuristicFraction, cfg.Cache.MaxTTL),
+		proxy.WithHeadPolicy(cfg.Cache.Head),
+		proxy.WithImmutable(cfg.Cache.Immutable),
+		proxy.WithTTLRequestHeader(cfg.Cache.AllowTTLRequestHeader),
+		proxy.WithHonorPragma(cfg.Cache.HonorPragma),
+		proxy.WithFailoverOn429(cfg.Cache.FailoverOn429),
+		proxy.WithChecksums(cfg.Cache.VerifyChecksums),
+		proxy.WithAdaptiveTTL(cfg.Cache.AdaptiveTTL, cfg.Cache.AdaptiveTTLMinFactor, cfg.Cache.AdaptiveTTLMaxFactor),
+		proxy.WithAllowedUpstreamHosts(cfg.Security.AllowedUpstreamHosts),
+		proxy.WithTransform(proxy.Transform{
+			Command:      cfg.Transform.Command,
+			ContentTypes: cfg.Transform.ContentTypes,
+			Timeout:      cfg.Transform.Timeout,
+			MaxBytes:     cfg.Transform.MaxBytes,
+		}),
+		proxy.WithRewriteRules(rewrites),
+		proxy.WithNoopPaths(cfg.Routing.NoopPaths),
+		proxy.WithCanonicalPath(cfg.Routing.CanonicalizePath, cfg.Routing.DecodeEncodedSlash),
+		proxy.WithErrorCapture(cfg.Diagnostics.CaptureErrorsN),
+		proxy.WithIdleConnTimeout(cfg.Transport.IdleConnTimeout),
+		proxy.WithDisableKeepAlives(cfg.Transport.DisableKeepAlives),
+		proxy.WithOutboundProxy(cfg.Transport.OutboundProxy),
+		proxy.WithAdmission(proxy.Admission{
+			MaxInFlight:  cfg.Admission.MaxInFlight,
+			Policy:       cfg.Admission.Policy,
+			QueueDepth:   cfg.Admission.QueueDepth,
+			QueueTimeout: cfg.Admission.QueueTimeout,
+		}),
+		proxy.WithRateLimit(proxy.RateLimit{
+			Rate:  cfg.RateLimit.Rate,
+			Burst: cfg.RateLimit.Burst,
+			Paths: rateLimitPaths,
+		}),
+		proxy.WithServerTiming(cfg.Debug.ServerTiming),
+		proxy.WithDebugHeaders(cfg.Debug.Enabled),
+		proxy.WithWebSocketIdleTimeout(cfg.WebSocket.IdleTimeout),
+		proxy.WithBackgroundWorkers(cfg.Background.MaxWorkers),
+		proxy.WithMaxRevalidationsPerKey(cfg.Background.MaxRevalidationsPerKey),
+		proxy.WithPreload(preload),
+		proxy.WithEvents(events),
+		proxy.WithHealthCheck(proxy.HealthCheck{
+			Path:                 cfg.Health.Path,
+			Interval:             cfg.Health.Interval,
+			Timeout:              cfg.Health.Timeout,
+			Method:               cfg.Health.Method,
+			ExpectedStatus:       cfg.Health.ExpectedStatus,
+			ExpectedBodyContains: cfg.Health.ExpectedBodyContains,
+			FailureThreshold:     cfg.Health.FailureThreshold,
+		}),
+		proxy.WithShadow(proxy.Shadow{
+			Upstream:     cfg.Shadow.Upstream,
+			SampleRate:   cfg.Shadow.SampleRate,
+			PathPrefixes: cfg.Shadow.Paths,
+			Sticky:       cfg.Shadow.Sticky,
+			Timeout:      cfg.Shadow.Timeout,
+		}),
+		proxy.WithErrorBodyDetection(proxy.ErrorBody{
+			Contains:     cfg.ErrorBody.Contains,
+			ContentTypes: cfg.ErrorBody.ContentTypes,
+			Paths:        cfg.ErrorBody.Paths,
+		}),
+	)
 	if err != nil {
 		log.Fatalf("init proxy: %v", err)
 	}
@@ -24,6 +219,23 @@ func main() {
 	// Setup routes
 	mux := http.NewServeMux()
 	mux.HandleFunc("/stats", p.StatsHandler)
+	mux.HandleFunc("/readyz", p.ReadyHandler)
+	if cfg.Admin.Dashboard {
+		mux.Handle("GET /dashboard", utils.RequireBasicAuth(cfg.Admin.User, cfg.Admin.Password,
+			http.HandlerFunc(p.DashboardHandler)))
+	}
+	if cfg.Admin.Maintenance {
+		mux.Handle("/maintenance", utils.RequireBasicAuth(cfg.Admin.User, cfg.Admin.Password,
+			http.HandlerFunc(p.MaintenanceHandler)))
+	}
+	if cfg.Admin.Purge {
+		mux.Handle("/purge", utils.RequireBasicAuth(cfg.Admin.User, cfg.Admin.Password,
+			http.HandlerFunc(p.PurgeHandler)))
+	}
+	if cfg.Diagnostics.CaptureErrorsN > 0 {
+		mux.Handle("GET /errors", utils.RequireBasicAuth(cfg.Admin.User, cfg.Admin.Password,
+			http.HandlerFunc(p.ErrorsHandler)))
+	}
 	mux.Handle("/", p)
 
 	// Wrap with access log middleware
@@ -35,13 +247,78 @@ func main() {
 	// Start server
 	log.Printf("listening on %s, upstream %s, ttl=%s, timeout=%s",
 		cfg.Listen, cfg.Upstream, cfg.TTL.String(), cfg.Timeout.String())
+	if len(cfg.ExtraUpstreams) > 0 {
+		log.Printf("balancing round-robin with upstreams: %v", cfg.ExtraUpstreams)
+	}
 	if len(cfg.Cache.KeyHeaders) > 0 {
 		log.Printf("cache key includes headers: %v", cfg.Cache.KeyHeaders)
 	}
+	if cfg.CircuitBreaker.FailureThreshold > 0 {
+		log.Printf("circuit breaker enabled: threshold=%d cooldown=%s",
+			cfg.CircuitBreaker.FailureThreshold, cfg.CircuitBreaker.Cooldown)
+	}
+	if cfg.Admission.MaxInFlight > 0 {
+		log.Printf("admission control enabled: max_in_flight=%d policy=%s",
+			cfg.Admission.MaxInFlight, cfg.Admission.Policy)
+	}
 	if cfg.Logging.Enabled {
 		log.Printf("logging enabled: level=%s access_log=%v", cfg.Logging.Level, cfg.Logging.AccessLog)
 	}
-	if err := http.ListenAndServe(cfg.Listen, handler); err != nil {
-		log.Fatal(err)
+
+	srv := &http.Server{Addr: cfg.Listen, Handler: handler}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if cfg.TLS.CertFile != "" {
+		tlsCfg, err := utils.NewServerTLSConfig(utils.ServerTLS{
+			CertFile:             cfg.TLS.CertFile,
+			KeyFile:              cfg.TLS.KeyFile,
+			MinVersion:           cfg.TLS.MinVersion,
+			ALPN:                 cfg.TLS.ALPN,
+			CipherSuites:         cfg.TLS.CipherSuites,
+			SessionTicketKeyFile: cfg.TLS.SessionTicketKeyFile,
+		})
+		if err != nil {
+			log.Fatalf("init tls: %v", err)
+		}
+		srv.TLSConfig = tlsCfg.TLSConfig()
+		// http.Server adds h2 on its own unless HTTP/2 is switched off
+		if !slices.Contains(cfg.TLS.ALPN, "h2") {
+			srv.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+		}
+		if cfg.TLS.SessionTicketReload > 0 {
+			go tlsCfg.RotateTicketKeys(ctx, cfg.TLS.SessionTicketReload, log.Printf)
+		}
+		log.Printf("tls enabled: min_version=%s alpn=%v", tls.VersionName(cfg.TLS.MinVersion), cfg.TLS.ALPN)
+	}
+
+	go func() {
+		serve := srv.ListenAndServe
+		if srv.TLSConfig != nil {
+			serve = func() error { return srv.ListenAndServeTLS("", "") }
+		}
+		if err := serve(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	// Warm the cache; /readyz stays not ready until this finishes
+	if len(preload) > 0 {
+		log.Printf("preloading %d cache entries", len(preload))
+		go func() {
+			if err := p.Preload(ctx); err != nil && cfg.Cache.PreloadStrict {
+				log.Fatalf("preload: %v", err)
+			}
+		}()
+	}
+
+	// Graceful shutdown
+	<-ctx.Done()
+	log.Printf("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("shutdown: %v", err)
 	}
+	p.Close()
 }